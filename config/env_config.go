@@ -7,6 +7,21 @@ import (
 	"strings"
 )
 
+// Rate limit groups, each configured with RATE_LIMIT_<GROUP>_REQUESTS and RATE_LIMIT_<GROUP>_WINDOW
+const (
+	RateLimitGroupAPI    = "api"
+	RateLimitGroupUpload = "upload"
+	RateLimitGroupAdmin  = "admin"
+	// RateLimitGroupIP is keyed by client IP and checked before authentication
+	RateLimitGroupIP = "ip"
+)
+
+// RateLimitRule allows Requests per Window seconds
+type RateLimitRule struct {
+	Requests int
+	Window   int // seconds
+}
+
 type EnvConfig struct {
 	Postgres struct {
 		HOST     string
@@ -37,9 +52,12 @@ type EnvConfig struct {
 		Password string
 	}
 	Minio struct {
-		Endpoint     string
-		RootUser     string
-		RootPassword string
+		Endpoint       string
+		RootUser       string
+		RootPassword   string
+		PublicEndpoint string // Host clients reach MinIO on, direct-upload URLs are signed for it
+		PublicSecure   bool
+		Region         string
 	}
 	LargeFile struct {
 		Threshold  int64  // Default 50MB (52428800 bytes)
@@ -54,6 +72,67 @@ type EnvConfig struct {
 		OTLPEndpoint string
 		ServiceName  string
 	}
+	Authz struct {
+		DefaultRole string // role applied when a token has no permission claim
+	}
+	AuthCache struct {
+		TTL              int // seconds, upper bound for caching a token validation result
+		BreakerThreshold int // consecutive auth-service failures before the breaker opens
+		BreakerCooldown  int // seconds the breaker stays open before a trial call
+	}
+	HMAC struct {
+		AllowV1 bool // accept legacy v1 signatures (no query/header signing, optional nonce)
+	}
+	RateLimit struct {
+		Enabled bool
+		Groups  map[string]RateLimitRule
+	}
+	Quota struct {
+		// Limits applied when an account or bucket quota is first created; 0 = unlimited
+		AccountMaxBytes   int64
+		AccountMaxObjects int64
+		BucketMaxBytes    int64
+		BucketMaxObjects  int64
+		MaxObjectSize     int64
+	}
+	Usage struct {
+		FlushInterval  int // seconds between writes of buffered usage counters
+		SampleInterval int // seconds between stored-bytes samples per bucket, 0 = sampling disabled
+	}
+	Upload struct {
+		Backend string // "http" sends files to the upload-service, "minio" writes them to MinIO from this service
+	}
+	Callback struct {
+		// Delivery of upload completion callbacks
+		MaxAttempts  int  // attempts before a callback is given up
+		BaseDelay    int  // seconds before the first retry, doubled after each failed attempt
+		MaxDelay     int  // seconds, upper bound of the delay between attempts
+		Timeout      int  // seconds per attempt
+		Workers      int  // callbacks delivered concurrently
+		AllowPrivate bool // allow callback URLs reaching loopback, private or link-local addresses
+	}
+	Archive struct {
+		// Limits on extracting uploaded archives, guarding against zip bombs; 0 = unlimited
+		MaxEntries int   // files and folders per archive
+		MaxBytes   int64 // total uncompressed bytes per archive
+		MaxRatio   int   // uncompressed bytes per archive byte
+	}
+	HTTPServer struct {
+		Addr              string
+		ReadHeaderTimeout int // seconds
+		ReadTimeout       int // seconds, 0 = no limit (chunk uploads can be slow)
+		WriteTimeout      int // seconds, 0 = no limit (streaming downloads can be long)
+		IdleTimeout       int // seconds
+		ShutdownTimeout   int // seconds to drain in-flight requests on shutdown
+		DrainDelay        int // seconds readiness fails before the listener closes
+	}
+	Health struct {
+		CheckTimeoutMs int    // Per-dependency timeout for readiness checks
+		ConsumerAddr   string // Listen address of the consumer health server
+	}
+	Consumer struct {
+		ShutdownTimeout int // seconds to let in-flight handlers finish on shutdown
+	}
 	PrivateKey string
 
 	Environment struct {
@@ -115,6 +194,15 @@ func LoadEnvConfig() *EnvConfig {
 	config.Minio.Endpoint = os.Getenv("MINIO_ENDPOINT")
 	config.Minio.RootUser = os.Getenv("MINIO_ROOT_USER")
 	config.Minio.RootPassword = os.Getenv("MINIO_ROOT_PASSWORD")
+	config.Minio.PublicEndpoint = os.Getenv("MINIO_PUBLIC_ENDPOINT")
+	if config.Minio.PublicEndpoint == "" {
+		config.Minio.PublicEndpoint = config.Minio.Endpoint
+	}
+	config.Minio.PublicSecure = os.Getenv("MINIO_PUBLIC_SECURE") == "true"
+	config.Minio.Region = os.Getenv("MINIO_REGION")
+	if config.Minio.Region == "" {
+		config.Minio.Region = "us-east-1"
+	}
 
 	// Large file configuration
 	if thresholdStr := os.Getenv("LARGE_FILE_THRESHOLD"); thresholdStr != "" {
@@ -131,6 +219,98 @@ func LoadEnvConfig() *EnvConfig {
 		config.LargeFile.TempBucket = "temp-uploads"
 	}
 
+	// Authorization
+	config.Authz.DefaultRole = os.Getenv("AUTHZ_DEFAULT_ROLE")
+	if config.Authz.DefaultRole == "" {
+		config.Authz.DefaultRole = "member"
+	}
+
+	// Token validation cache and auth-service circuit breaker
+	config.AuthCache.TTL = getEnvInt("AUTH_CACHE_TTL", 300)
+	config.AuthCache.BreakerThreshold = getEnvInt("AUTH_BREAKER_THRESHOLD", 5)
+	config.AuthCache.BreakerCooldown = getEnvInt("AUTH_BREAKER_COOLDOWN", 30)
+
+	// HMAC signatures
+	// v1 makes X-Nonce optional and so lets requests be replayed, legacy clients are opted in explicitly
+	config.HMAC.AllowV1 = os.Getenv("HMAC_ALLOW_V1") == "true"
+
+	// Rate limiting
+	config.RateLimit.Enabled = os.Getenv("RATE_LIMIT_ENABLED") != "false"
+	config.RateLimit.Groups = map[string]RateLimitRule{
+		RateLimitGroupAPI:    getRateLimitRule(RateLimitGroupAPI, 300, 60),
+		RateLimitGroupUpload: getRateLimitRule(RateLimitGroupUpload, 60, 60),
+		RateLimitGroupAdmin:  getRateLimitRule(RateLimitGroupAdmin, 60, 60),
+		RateLimitGroupIP:     getRateLimitRule(RateLimitGroupIP, 600, 60),
+	}
+
+	// Storage quota defaults
+	config.Quota.AccountMaxBytes = getEnvInt64("QUOTA_ACCOUNT_MAX_BYTES", 0)
+	config.Quota.AccountMaxObjects = getEnvInt64("QUOTA_ACCOUNT_MAX_OBJECTS", 0)
+	config.Quota.BucketMaxBytes = getEnvInt64("QUOTA_BUCKET_MAX_BYTES", 0)
+	config.Quota.BucketMaxObjects = getEnvInt64("QUOTA_BUCKET_MAX_OBJECTS", 0)
+	config.Quota.MaxObjectSize = getEnvInt64("QUOTA_MAX_OBJECT_SIZE", 0)
+
+	// Usage metering
+	config.Usage.FlushInterval = getEnvInt("USAGE_FLUSH_INTERVAL", 60)
+	if config.Usage.FlushInterval == 0 {
+		config.Usage.FlushInterval = 60
+	}
+	config.Usage.SampleInterval = getEnvInt("USAGE_SAMPLE_INTERVAL", 3600)
+
+	// Upload backend
+	config.Upload.Backend = strings.ToLower(os.Getenv("UPLOAD_BACKEND"))
+	if config.Upload.Backend == "" {
+		config.Upload.Backend = "http"
+	}
+
+	// Upload completion callbacks
+	config.Callback.MaxAttempts = getEnvInt("CALLBACK_MAX_ATTEMPTS", 8)
+	if config.Callback.MaxAttempts == 0 {
+		config.Callback.MaxAttempts = 1
+	}
+	config.Callback.BaseDelay = getEnvInt("CALLBACK_BASE_DELAY", 5)
+	config.Callback.MaxDelay = getEnvInt("CALLBACK_MAX_DELAY", 600)
+	config.Callback.Timeout = getEnvInt("CALLBACK_TIMEOUT", 10)
+	if config.Callback.Timeout == 0 {
+		config.Callback.Timeout = 10
+	}
+	config.Callback.Workers = getEnvInt("CALLBACK_WORKERS", 8)
+	if config.Callback.Workers == 0 {
+		config.Callback.Workers = 1
+	}
+	config.Callback.AllowPrivate = os.Getenv("CALLBACK_ALLOW_PRIVATE") == "true"
+
+	// Archive extraction
+	config.Archive.MaxEntries = getEnvInt("ARCHIVE_MAX_ENTRIES", 10000)
+	config.Archive.MaxBytes = getEnvInt64("ARCHIVE_MAX_BYTES", 10<<30)
+	config.Archive.MaxRatio = getEnvInt("ARCHIVE_MAX_RATIO", 100)
+
+	// HTTP server
+	config.HTTPServer.Addr = os.Getenv("HTTP_ADDR")
+	if config.HTTPServer.Addr == "" {
+		config.HTTPServer.Addr = ":8080"
+	}
+	config.HTTPServer.ReadHeaderTimeout = getEnvInt("HTTP_READ_HEADER_TIMEOUT", 10)
+	config.HTTPServer.ReadTimeout = getEnvInt("HTTP_READ_TIMEOUT", 0)
+	config.HTTPServer.WriteTimeout = getEnvInt("HTTP_WRITE_TIMEOUT", 0)
+	config.HTTPServer.IdleTimeout = getEnvInt("HTTP_IDLE_TIMEOUT", 120)
+	config.HTTPServer.ShutdownTimeout = getEnvInt("HTTP_SHUTDOWN_TIMEOUT", 30)
+	config.HTTPServer.DrainDelay = getEnvInt("HTTP_SHUTDOWN_DRAIN_DELAY", 10)
+
+	// Health checks
+	if val := os.Getenv("HEALTH_CHECK_TIMEOUT_MS"); val != "" {
+		config.Health.CheckTimeoutMs, _ = strconv.Atoi(val)
+	}
+	if config.Health.CheckTimeoutMs <= 0 {
+		config.Health.CheckTimeoutMs = 2000
+	}
+	config.Health.ConsumerAddr = os.Getenv("CONSUMER_HEALTH_ADDR")
+	if config.Health.ConsumerAddr == "" {
+		config.Health.ConsumerAddr = ":8080"
+	}
+
+	config.Consumer.ShutdownTimeout = getEnvInt("CONSUMER_SHUTDOWN_TIMEOUT", 30)
+
 	config.PrivateKey = os.Getenv("PRIVATE_KEY")
 
 	config.ExternalService.AuthorizationServiceURL = os.Getenv("AUTHORIZATION_SERVICE_URL")
@@ -181,3 +361,38 @@ func LoadEnvConfig() *EnvConfig {
 
 	return &config
 }
+
+// getEnvInt reads an integer environment variable, falling back to def when unset or invalid
+func getEnvInt(key string, def int) int {
+	val := os.Getenv(key)
+	if val == "" {
+		return def
+	}
+	n, err := strconv.Atoi(val)
+	if err != nil || n < 0 {
+		return def
+	}
+	return n
+}
+
+// getEnvInt64 reads a 64-bit integer environment variable, falling back to def when unset or invalid
+func getEnvInt64(key string, def int64) int64 {
+	val := os.Getenv(key)
+	if val == "" {
+		return def
+	}
+	n, err := strconv.ParseInt(val, 10, 64)
+	if err != nil || n < 0 {
+		return def
+	}
+	return n
+}
+
+// getRateLimitRule reads RATE_LIMIT_<GROUP>_REQUESTS and RATE_LIMIT_<GROUP>_WINDOW
+func getRateLimitRule(group string, defRequests, defWindow int) RateLimitRule {
+	prefix := "RATE_LIMIT_" + strings.ToUpper(group)
+	return RateLimitRule{
+		Requests: getEnvInt(prefix+"_REQUESTS", defRequests),
+		Window:   getEnvInt(prefix+"_WINDOW", defWindow),
+	}
+}
@@ -0,0 +1,252 @@
+// Package conflict decides what an upload does when its name is already taken in the target folder.
+// The bucket sets a default mode and every upload may choose its own; the unique index on
+// (bucket_id, parent_path, origin_name) backs the decision when uploads race each other.
+package conflict
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tnqbao/gau-cloud-orchestrator/entity"
+	"github.com/tnqbao/gau-cloud-orchestrator/infra"
+	"github.com/tnqbao/gau-cloud-orchestrator/repository"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrNameTaken is returned in reject mode when the folder already has an object with the name
+	ErrNameTaken = errors.New("an object with this name already exists")
+	// ErrChanged is returned by Commit when another upload took the name first; resolve again and retry
+	ErrChanged = errors.New("the name was taken by a concurrent upload")
+)
+
+// storeAttempts is how often Store resolves a name again after losing it to a concurrent upload
+const storeAttempts = 3
+
+// Modes lists the valid conflict modes
+var Modes = []string{entity.ConflictOverwrite, entity.ConflictRename, entity.ConflictReject, entity.ConflictVersion}
+
+// ValidMode reports whether mode is a known conflict mode; "" is not
+func ValidMode(mode string) bool {
+	for _, m := range Modes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// Mode returns the requested mode, or the bucket default when none was requested
+func Mode(bucket *entity.Bucket, requested string) string {
+	if requested != "" {
+		return requested
+	}
+	if ValidMode(bucket.ConflictMode) {
+		return bucket.ConflictMode
+	}
+	return entity.ConflictRename
+}
+
+// Plan is the outcome of resolving an upload name
+type Plan struct {
+	Mode     string
+	Name     string         // name the upload is stored under
+	Existing *entity.Object // object whose content is replaced, nil when a new object is created
+
+	version *entity.ObjectVersion
+}
+
+// Renamed reports whether the upload is stored under another name than requested
+func (p *Plan) Renamed(requested string) bool {
+	return p.Name != requested
+}
+
+type Resolver struct {
+	infra      *infra.Infra
+	repository *repository.Repository
+}
+
+func New(inf *infra.Infra, repo *repository.Repository) *Resolver {
+	return &Resolver{infra: inf, repository: repo}
+}
+
+// Resolve decides where an upload of name into parentPath goes under mode
+func (r *Resolver) Resolve(bucket *entity.Bucket, parentPath, name, mode string) (*Plan, error) {
+	plan := &Plan{Mode: mode, Name: name}
+
+	existing, err := r.repository.ObjectRepo.FindByBucketPathAndName(bucket.ID, parentPath, name)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return plan, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch mode {
+	case entity.ConflictReject:
+		return nil, ErrNameTaken
+	case entity.ConflictOverwrite, entity.ConflictVersion:
+		plan.Existing = existing
+	default:
+		if plan.Name, err = r.repository.ObjectRepo.NextFreeName(bucket.ID, parentPath, name); err != nil {
+			return nil, err
+		}
+	}
+	return plan, nil
+}
+
+// StorageName returns the file name an upload stored by its own name is written under: the name
+// with a random prefix. The key is never shared with another upload, so content written before
+// Commit cannot replace the blob of an object, whichever upload wins the name.
+func StorageName(name string) string {
+	return uuid.NewString() + "-" + name
+}
+
+// PrepareVersion copies the content about to be replaced to its version key. In version mode it
+// must run before the new content is written, since both may share a storage key.
+func (r *Resolver) PrepareVersion(ctx context.Context, bucket *entity.Bucket, plan *Plan) error {
+	if plan.Mode != entity.ConflictVersion || plan.Existing == nil {
+		return nil
+	}
+
+	existing := plan.Existing
+	version := &entity.ObjectVersion{
+		ID:          uuid.New(),
+		ObjectID:    existing.ID,
+		BucketID:    existing.BucketID,
+		Version:     existing.Version,
+		ContentType: existing.ContentType,
+		Size:        existing.Size,
+		StorageKey:  entity.VersionStorageKey(existing.ID, existing.Version, existing.URL),
+		FileHash:    existing.FileHash,
+		CreatedAt:   existing.LastModified,
+	}
+	if err := r.infra.Minio.CopyObject(ctx, bucket.Name, existing.StorageKey(), bucket.Name, version.StorageKey, existing.Size); err != nil {
+		return err
+	}
+	plan.version = version
+	return nil
+}
+
+// Abort removes a version copy made by PrepareVersion for an upload that is not committed
+func (r *Resolver) Abort(ctx context.Context, bucket *entity.Bucket, plan *Plan) {
+	if plan.version == nil {
+		return
+	}
+	if err := r.infra.Minio.DeleteObject(ctx, bucket.Name, plan.version.StorageKey); err != nil {
+		r.infra.Logger.ErrorWithContextf(ctx, err, "[Conflict] Failed to remove version copy '%s/%s': %v", bucket.Name, plan.version.StorageKey, err)
+	}
+	plan.version = nil
+}
+
+// Commit stores object under the plan: it is created as a new object, or its content replaces the
+// existing one, which keeps its ID and gets a new version. The caller has already counted object
+// against the quotas; a replacement gives back the extra object slot and, in overwrite mode, the
+// replaced bytes, then removes the replaced blob once its last reference is gone. Content the bucket
+// already stored is referenced there and the copy written for object is removed. The stored object is returned.
+func (r *Resolver) Commit(ctx context.Context, bucket *entity.Bucket, plan *Plan, object *entity.Object) (*entity.Object, error) {
+	object.OriginName = plan.Name
+	// An object already pointing at a blob references it without having written a copy
+	written := object.BlobKey == ""
+	if plan.Existing == nil {
+		err := r.repository.ObjectRepo.Create(object)
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, ErrChanged
+		}
+		if err != nil {
+			return nil, err
+		}
+		if written {
+			RemoveUnusedCopy(ctx, r.infra, r.repository, bucket, object)
+		}
+		return object, nil
+	}
+
+	existing := plan.Existing
+	previous := *existing
+	if plan.version != nil {
+		plan.version.ReplacedAt = time.Now()
+	}
+	unreferenced, err := r.repository.ObjectRepo.Replace(existing, object, plan.version)
+	if errors.Is(err, repository.ErrObjectChanged) {
+		r.Abort(ctx, bucket, plan)
+		return nil, ErrChanged
+	}
+	if err != nil {
+		r.Abort(ctx, bucket, plan)
+		return nil, err
+	}
+
+	released := previous.Size
+	if plan.version != nil {
+		released = 0 // the previous content stays stored as a version
+	}
+	if err := r.repository.StorageQuotaRepo.ReleaseUsage(bucket.OwnerID, bucket.ID, released, 1); err != nil {
+		r.infra.Logger.ErrorWithContextf(ctx, err, "[Conflict] Failed to release replaced usage of object %s: %v", existing.ID, err)
+	}
+
+	if unreferenced {
+		if err := r.infra.Minio.DeleteObject(ctx, bucket.Name, previous.StorageKey()); err != nil {
+			r.infra.Logger.ErrorWithContextf(ctx, err, "[Conflict] Failed to remove replaced object '%s/%s': %v", bucket.Name, previous.StorageKey(), err)
+		}
+	}
+	if written {
+		RemoveUnusedCopy(ctx, r.infra, r.repository, bucket, object)
+	}
+	return existing, nil
+}
+
+// RemoveUnusedCopy deletes the key a stored object's content was written to when the object
+// references a blob elsewhere because the bucket already held the content. A key that is itself
+// the blob of other content is left alone.
+func RemoveUnusedCopy(ctx context.Context, inf *infra.Infra, repo *repository.Repository, bucket *entity.Bucket, object *entity.Object) {
+	written := object.WrittenKey()
+	if object.StorageKey() == written {
+		return
+	}
+	_, err := repo.BlobRepo.FindByKey(bucket.ID, written)
+	if err == nil {
+		return
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		inf.Logger.ErrorWithContextf(ctx, err, "[Conflict] Failed to check references of '%s/%s': %v", bucket.Name, written, err)
+		return
+	}
+	if err := inf.Minio.DeleteObject(ctx, bucket.Name, written); err != nil {
+		// The object is already served from the shared blob, the copy is only an orphan
+		inf.Logger.ErrorWithContextf(ctx, err, "[Conflict] Failed to remove duplicate copy '%s/%s': %v", bucket.Name, written, err)
+		return
+	}
+	inf.Logger.InfoWithContextf(ctx, "[Conflict] Content of '%s/%s' is shared with '%s'", bucket.Name, written, object.StorageKey())
+}
+
+// Store resolves name under mode and commits object, for uploads whose content is already written
+// to a key of its own. A name taken by a concurrent upload is resolved again, up to storeAttempts
+// times. It returns the stored object and the previous state of the object it replaced, if any.
+func (r *Resolver) Store(ctx context.Context, bucket *entity.Bucket, parentPath, name, mode string, object *entity.Object) (*entity.Object, *entity.Object, error) {
+	for attempt := 1; attempt <= storeAttempts; attempt++ {
+		plan, err := r.Resolve(bucket, parentPath, name, mode)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := r.PrepareVersion(ctx, bucket, plan); err != nil {
+			return nil, nil, err
+		}
+		var replaced *entity.Object
+		if plan.Existing != nil {
+			previous := *plan.Existing
+			replaced = &previous
+		}
+		stored, err := r.Commit(ctx, bucket, plan, object)
+		if err == nil {
+			return stored, replaced, nil
+		}
+		if !errors.Is(err, ErrChanged) {
+			return nil, nil, err
+		}
+		r.infra.Logger.WarningWithContextf(ctx, "[Conflict] Name '%s' was taken concurrently, attempt %d/%d", name, attempt, storeAttempts)
+	}
+	return nil, nil, ErrChanged
+}
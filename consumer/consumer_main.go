@@ -2,10 +2,13 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/tnqbao/gau-cloud-orchestrator/config"
@@ -56,13 +59,87 @@ func main() {
 		log.Fatalf("Failed to start Object consumer: %v", err)
 	}
 
+	// Start Archive Consumer (for extracting uploaded archives)
+	archiveConsumer := worker.NewArchiveConsumer(infra.RabbitMQ.Channel, infra, repo, worker.ArchiveLimits{
+		MaxEntries: cfg.EnvConfig.Archive.MaxEntries,
+		MaxBytes:   cfg.EnvConfig.Archive.MaxBytes,
+		MaxRatio:   cfg.EnvConfig.Archive.MaxRatio,
+	})
+	if err := archiveConsumer.Start(ctx); err != nil {
+		infra.Logger.ErrorWithContextf(ctx, err, "Failed to start Archive consumer: %v", err)
+		log.Fatalf("Failed to start Archive consumer: %v", err)
+	}
+
+	// Start Callback Consumer (for POSTing upload results to their callback URLs)
+	callbackConsumer := worker.NewCallbackConsumer(infra.RabbitMQ.Channel, infra, repo, worker.CallbackPolicy{
+		MaxAttempts:  cfg.EnvConfig.Callback.MaxAttempts,
+		BaseDelay:    time.Duration(cfg.EnvConfig.Callback.BaseDelay) * time.Second,
+		MaxDelay:     time.Duration(cfg.EnvConfig.Callback.MaxDelay) * time.Second,
+		Timeout:      time.Duration(cfg.EnvConfig.Callback.Timeout) * time.Second,
+		Workers:      cfg.EnvConfig.Callback.Workers,
+		AllowPrivate: cfg.EnvConfig.Callback.AllowPrivate,
+	})
+	if err := callbackConsumer.Start(ctx); err != nil {
+		infra.Logger.ErrorWithContextf(ctx, err, "Failed to start Callback consumer: %v", err)
+		log.Fatalf("Failed to start Callback consumer: %v", err)
+	}
+
+	// Start Auth Consumer (for token revocations from the authorization service)
+	authConsumer := worker.NewAuthConsumer(infra.RabbitMQ.Channel, infra, repo)
+	if err := authConsumer.Start(ctx); err != nil {
+		infra.Logger.ErrorWithContextf(ctx, err, "Failed to start Auth consumer: %v", err)
+		log.Fatalf("Failed to start Auth consumer: %v", err)
+	}
+
+	// Delete the files of duplicate blobs folded by the blob migration
+	worker.SweepOrphanedBlobs(ctx, infra, repo)
+
+	// Start stored bytes sampler (for per-bucket usage reports)
+	if interval := cfg.EnvConfig.Usage.SampleInterval; interval > 0 {
+		worker.NewStoredBytesSampler(infra, repo, time.Duration(interval)*time.Second).Start(ctx)
+	}
+
+	// Start health server so Kubernetes can probe the consumer
+	healthServer := infra.Health.NewServer(cfg.EnvConfig.Health.ConsumerAddr,
+		infraPkg.HealthDepPostgres,
+		infraPkg.HealthDepRedis,
+		infraPkg.HealthDepRabbitMQ,
+		infraPkg.HealthDepMinio,
+	)
+	go func() {
+		log.Printf("Consumer health server started on %s", healthServer.Addr)
+		if err := healthServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			infra.Logger.ErrorWithContextf(ctx, err, "Consumer health server failed: %v", err)
+		}
+	}()
+
 	// Wait for interrupt signal to gracefully shutdown
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
 	infra.Logger.InfoWithContextf(ctx, "Shutting down consumer...")
+	infra.Health.SetShuttingDown()
 	cancel() // Cancel context to stop consumers
 
-	infra.Logger.InfoWithContextf(ctx, "Consumer exited properly")
+	// Let the messages in hand be handled, acked or requeued before the connections close
+	shutdownTimeout := time.Duration(cfg.EnvConfig.Consumer.ShutdownTimeout) * time.Second
+	if worker.Drain(shutdownTimeout) {
+		log.Println("Consumer handlers drained")
+	} else {
+		log.Printf("Consumer handlers did not finish within %s, their messages are redelivered", shutdownTimeout)
+	}
+
+	if err := healthServer.Close(); err != nil {
+		infra.Logger.ErrorWithContextf(context.Background(), err, "Failed to close consumer health server: %v", err)
+	}
+
+	// Close infra clients and flush OTel exporters with a fresh deadline
+	infraCtx, infraCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer infraCancel()
+
+	infra.Logger.InfoWithContextf(infraCtx, "Consumer exited properly")
+	if err := infra.Shutdown(infraCtx); err != nil {
+		log.Printf("Error while shutting down infra: %v", err)
+	}
 }
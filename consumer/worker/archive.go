@@ -0,0 +1,433 @@
+package worker
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/tnqbao/gau-cloud-orchestrator/conflict"
+	"github.com/tnqbao/gau-cloud-orchestrator/entity"
+	"github.com/tnqbao/gau-cloud-orchestrator/infra"
+	"github.com/tnqbao/gau-cloud-orchestrator/infra/produce"
+	"github.com/tnqbao/gau-cloud-orchestrator/quotawarn"
+	"github.com/tnqbao/gau-cloud-orchestrator/repository"
+	"github.com/tnqbao/gau-cloud-orchestrator/utils"
+	"gorm.io/gorm"
+)
+
+// extractProgressInterval is how often the extraction progress is written to the upload session
+const extractProgressInterval = 2 * time.Second
+
+// ArchiveLimits bounds what a single archive may expand to; 0 disables a limit
+type ArchiveLimits struct {
+	MaxEntries int   // files and folders
+	MaxBytes   int64 // total uncompressed bytes
+	MaxRatio   int   // uncompressed bytes per archive byte
+}
+
+// ArchiveConsumer extracts uploaded archives into objects
+type ArchiveConsumer struct {
+	channel    *amqp.Channel
+	infra      *infra.Infra
+	repository *repository.Repository
+	limits     ArchiveLimits
+}
+
+func NewArchiveConsumer(channel *amqp.Channel, infra *infra.Infra, repo *repository.Repository, limits ArchiveLimits) *ArchiveConsumer {
+	return &ArchiveConsumer{
+		channel:    channel,
+		infra:      infra,
+		repository: repo,
+		limits:     limits,
+	}
+}
+
+func (c *ArchiveConsumer) Start(ctx context.Context) error {
+	msgs, err := c.channel.Consume(
+		produce.ArchiveExtractQueue,
+		"",
+		false,
+		false,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to register archive extract consumer: %w", err)
+	}
+
+	c.infra.Logger.InfoWithContextf(ctx, "[Archive Consumer] Started listening for extraction jobs on queue: %s", produce.ArchiveExtractQueue)
+
+	spawn(func() {
+		for {
+			select {
+			case <-ctx.Done():
+				c.infra.Logger.InfoWithContextf(ctx, "[Archive Consumer] Shutting down...")
+				return
+			case msg, ok := <-msgs:
+				if !ok {
+					c.infra.Logger.WarningWithContextf(ctx, "[Archive Consumer] Channel closed")
+					return
+				}
+				c.handleExtract(ctx, msg)
+			}
+		}
+	})
+
+	return nil
+}
+
+// handleExtract extracts one archive. Extraction is not retried: entries already created
+// stay in place, and the session reports how far it got and why it stopped.
+func (c *ArchiveConsumer) handleExtract(ctx context.Context, msg amqp.Delivery) {
+	c.infra.Logger.InfoWithContextf(ctx, "[Archive Consumer] Received message: %s", string(msg.Body))
+
+	var payload produce.ArchiveExtractMessage
+	if err := json.Unmarshal(msg.Body, &payload); err != nil {
+		c.infra.Logger.ErrorWithContextf(ctx, err, "[Archive Consumer] Failed to unmarshal message: %v", err)
+		_ = msg.Nack(false, false)
+		return
+	}
+
+	uploadID, err := uuid.Parse(payload.UploadID)
+	if err != nil {
+		c.infra.Logger.ErrorWithContextf(ctx, err, "[Archive Consumer] Invalid upload ID")
+		_ = msg.Nack(false, false)
+		return
+	}
+
+	session, err := c.repository.UploadSessionRepo.FindByID(uploadID)
+	if err != nil || session.Status != entity.UploadStatusExtracting {
+		// Aborted, or already handled by an earlier delivery
+		c.infra.Logger.WarningWithContextf(ctx, "[Archive Consumer] Upload %s is not waiting for extraction, dropping job", uploadID)
+		_ = msg.Ack(false)
+		return
+	}
+
+	job := &extractJob{
+		consumer: c,
+		session:  session,
+		format:   payload.Format,
+		target:   payload.TargetPath,
+	}
+	err = job.load(payload)
+	if err == nil {
+		err = job.run(ctx)
+	}
+
+	status := entity.UploadStatusCompleted
+	if err != nil {
+		c.infra.Logger.ErrorWithContextf(ctx, err, "[Archive Consumer] Extraction of upload %s stopped after %d entries: %v", uploadID, job.progress.Entries, err)
+		status = entity.UploadStatusFailed
+		job.progress.Error = err.Error()
+	}
+	if updateErr := c.repository.UploadSessionRepo.UpdateExtraction(uploadID, job.progress, status); updateErr != nil {
+		c.infra.Logger.WarningWithContextf(ctx, "[Archive Consumer] Failed to update session %s: %v", uploadID, updateErr)
+	}
+
+	after, _ := json.Marshal(job.progress)
+	recordAudit(ctx, c.infra, c.repository, payload.UserID, &entity.AuditLog{
+		Action:     entity.AuditActionUploadExtract,
+		TargetType: entity.AuditTargetUploadSession,
+		TargetID:   uploadID.String(),
+		TargetName: path.Join(session.CustomPath, session.FileName),
+		After:      after,
+	}, err)
+
+	publishUploadEvent(ctx, c.infra, c.repository, uploadID, infra.UploadEventForStatus(status), payload.ObjectID, job.progress.Error)
+
+	c.infra.Logger.InfoWithContextf(ctx, "[Archive Consumer] Upload %s extracted into '%s': %d entries, %d bytes, %d skipped",
+		uploadID, payload.TargetPath, job.progress.Entries, job.progress.Bytes, job.progress.Skipped)
+	_ = msg.Ack(false)
+}
+
+// extractJob holds the state of one archive extraction
+type extractJob struct {
+	consumer *ArchiveConsumer
+	session  *entity.UploadSession
+	bucket   *entity.Bucket
+	archive  *entity.Object
+	format   string
+	target   string
+
+	entries   int   // entries read so far, for MaxEntries
+	expanded  int64 // uncompressed bytes read so far, for MaxBytes and MaxRatio
+	progress  entity.Extraction
+	lastSaved time.Time
+}
+
+func (j *extractJob) load(payload produce.ArchiveExtractMessage) error {
+	bucketID, err := uuid.Parse(payload.BucketID)
+	if err != nil {
+		return fmt.Errorf("invalid bucket ID: %w", err)
+	}
+	if j.bucket, err = j.consumer.repository.BucketRepo.FindByID(bucketID); err != nil {
+		return fmt.Errorf("bucket %s not found: %w", bucketID, err)
+	}
+
+	objectID, err := uuid.Parse(payload.ObjectID)
+	if err != nil {
+		return fmt.Errorf("invalid object ID: %w", err)
+	}
+	if j.archive, err = j.consumer.repository.ObjectRepo.FindByID(objectID); err != nil {
+		return fmt.Errorf("archive object %s not found: %w", objectID, err)
+	}
+	return nil
+}
+
+func (j *extractJob) run(ctx context.Context) error {
+	reader, info, err := j.consumer.infra.Minio.GetObject(ctx, j.bucket.Name, j.archive.StorageKey())
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	if err := j.consumer.repository.FolderRepo.EnsurePath(j.bucket.ID, j.target); err != nil {
+		return err
+	}
+
+	switch j.format {
+	case utils.ArchiveFormatZip:
+		return j.extractZip(ctx, reader, info.Size)
+	case utils.ArchiveFormatTar:
+		return j.extractTar(ctx, reader, info.Size)
+	case utils.ArchiveFormatTarGz:
+		gz, err := gzip.NewReader(bufio.NewReader(reader))
+		if err != nil {
+			return fmt.Errorf("not a gzip archive: %w", err)
+		}
+		defer gz.Close()
+		return j.extractTar(ctx, gz, info.Size)
+	default:
+		return fmt.Errorf("unsupported archive format %q", j.format)
+	}
+}
+
+// extractZip checks the sizes declared in the central directory against the limits before
+// extracting anything. Reading an entry past its declared size fails, so they cannot lie.
+func (j *extractJob) extractZip(ctx context.Context, r io.ReaderAt, size int64) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return fmt.Errorf("not a zip archive: %w", err)
+	}
+
+	var declared uint64
+	for _, f := range zr.File {
+		if f.UncompressedSize64 > math.MaxInt64-declared {
+			return errors.New("archive expands beyond the size limit")
+		}
+		declared += f.UncompressedSize64
+		if !f.FileInfo().IsDir() {
+			j.progress.TotalEntries++
+		}
+	}
+	if err := j.checkLimits(len(zr.File), int64(declared), size); err != nil {
+		return err
+	}
+
+	for _, f := range zr.File {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		mode := f.Mode()
+		switch {
+		case mode.IsDir():
+			if err := j.addFolder(f.Name); err != nil {
+				return err
+			}
+		case !mode.IsRegular():
+			j.skip(f.Name, "not a regular file")
+		default:
+			rc, err := f.Open()
+			if err != nil {
+				return fmt.Errorf("entry %s: %w", f.Name, err)
+			}
+			err = j.addFile(ctx, f.Name, int64(f.UncompressedSize64), f.Modified, rc)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// extractTar streams a tar archive. Sizes are only known entry by entry, so the limits are
+// checked before each entry is read.
+func (j *extractJob) extractTar(ctx context.Context, r io.Reader, size int64) error {
+	tr := tar.NewReader(r)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid tar archive: %w", err)
+		}
+
+		j.entries++
+		if header.Typeflag == tar.TypeReg {
+			j.expanded += header.Size
+		}
+		if err := j.checkLimits(j.entries, j.expanded, size); err != nil {
+			return err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := j.addFolder(header.Name); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := j.addFile(ctx, header.Name, header.Size, header.ModTime, tr); err != nil {
+				return err
+			}
+		default:
+			j.skip(header.Name, "not a regular file")
+		}
+	}
+}
+
+// checkLimits rejects archives with too many entries, too many bytes or a suspicious compression ratio
+func (j *extractJob) checkLimits(entries int, expanded, archiveSize int64) error {
+	limits := j.consumer.limits
+	if limits.MaxEntries > 0 && entries > limits.MaxEntries {
+		return fmt.Errorf("archive has more than %d entries", limits.MaxEntries)
+	}
+	if limits.MaxBytes > 0 && expanded > limits.MaxBytes {
+		return fmt.Errorf("archive expands to more than %s", utils.FormatBytes(limits.MaxBytes))
+	}
+	if limits.MaxRatio > 0 && archiveSize > 0 && expanded/int64(limits.MaxRatio) > archiveSize {
+		return fmt.Errorf("archive compression ratio exceeds %d:1", limits.MaxRatio)
+	}
+	return nil
+}
+
+func (j *extractJob) addFolder(name string) error {
+	rel, err := utils.CleanArchiveEntryPath(name)
+	if err != nil {
+		j.skip(name, err.Error())
+		return nil
+	}
+	return j.consumer.repository.FolderRepo.EnsurePath(j.bucket.ID, path.Join(j.target, rel))
+}
+
+// addFile uploads one entry as an object. Entries whose name is taken are skipped rather
+// than overwriting existing objects; quota and storage errors stop the extraction.
+func (j *extractJob) addFile(ctx context.Context, name string, size int64, modified time.Time, r io.Reader) error {
+	c := j.consumer
+
+	rel, err := utils.CleanArchiveEntryPath(name)
+	if err != nil {
+		j.skip(name, err.Error())
+		return nil
+	}
+	full := path.Join(j.target, rel)
+	parentPath, fileName := path.Split(full)
+	parentPath = strings.TrimSuffix(parentPath, "/")
+	if len(fileName) > 512 || len(parentPath) > 1024 {
+		j.skip(name, "the name is too long")
+		return nil
+	}
+
+	// Cheap early skip; Store below decides for good once the content is written
+	if _, err := c.repository.ObjectRepo.FindByBucketPathAndName(j.bucket.ID, parentPath, fileName); err == nil {
+		j.skip(name, conflict.ErrNameTaken.Error())
+		return nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	quotas, err := c.repository.StorageQuotaRepo.ReserveUsage(j.bucket.OwnerID, j.bucket.ID, size)
+	if err != nil {
+		return err
+	}
+	release := func() {
+		if err := c.repository.StorageQuotaRepo.ReleaseUsage(j.bucket.OwnerID, j.bucket.ID, size, 1); err != nil {
+			c.infra.Logger.ErrorWithContextf(ctx, err, "[Archive Consumer] Failed to release %d bytes of bucket %s: %v", size, j.bucket.ID, err)
+		}
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(fileName))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	// Written to a key of its own, so an entry losing the name never touches another object's content
+	resp, err := c.infra.Uploader.UploadStream(r, conflict.StorageName(fileName), contentType, j.bucket.Name, parentPath)
+	if err != nil {
+		release()
+		return fmt.Errorf("entry %s: %w", name, err)
+	}
+
+	if modified.IsZero() {
+		modified = time.Now()
+	}
+	object := &entity.Object{
+		ID:           uuid.New(),
+		BucketID:     j.bucket.ID,
+		ContentType:  contentType,
+		OriginName:   fileName,
+		ParentPath:   parentPath,
+		CreatedAt:    time.Now(),
+		LastModified: modified,
+		Size:         size,
+		URL:          path.Base(resp.FilePath),
+		FileHash:     resp.FileHash,
+	}
+	// Entries never replace objects, whatever the bucket's conflict mode
+	_, _, err = conflict.New(c.infra, c.repository).Store(ctx, j.bucket, parentPath, fileName, entity.ConflictReject, object)
+	if err != nil {
+		// Nothing references the key written above
+		if err := c.infra.Minio.DeleteObject(ctx, j.bucket.Name, resp.FilePath); err != nil {
+			c.infra.Logger.ErrorWithContextf(ctx, err, "[Archive Consumer] Failed to remove uncommitted entry '%s/%s': %v", j.bucket.Name, resp.FilePath, err)
+		}
+		release()
+		if errors.Is(err, conflict.ErrNameTaken) {
+			j.skip(name, err.Error())
+			return nil
+		}
+		return fmt.Errorf("entry %s: %w", name, err)
+	}
+	quotawarn.Notify(ctx, c.infra, c.repository, quotas)
+
+	j.progress.Entries++
+	j.progress.Bytes += size
+	j.saveProgress()
+	return nil
+}
+
+func (j *extractJob) skip(name, reason string) {
+	j.consumer.infra.Logger.WarningWithContextf(context.Background(), "[Archive Consumer] Skipping entry '%s' of upload %s: %s", name, j.session.ID, reason)
+	j.progress.Skipped++
+	j.saveProgress()
+}
+
+// saveProgress writes the progress to the session at most every extractProgressInterval
+func (j *extractJob) saveProgress() {
+	if time.Since(j.lastSaved) < extractProgressInterval {
+		return
+	}
+	j.lastSaved = time.Now()
+	if err := j.consumer.repository.UploadSessionRepo.UpdateExtraction(j.session.ID, j.progress, ""); err != nil {
+		j.consumer.infra.Logger.WarningWithContextf(context.Background(), "[Archive Consumer] Failed to save progress of upload %s: %v", j.session.ID, err)
+	}
+}
@@ -0,0 +1,33 @@
+package worker
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/tnqbao/gau-cloud-orchestrator/entity"
+	"github.com/tnqbao/gau-cloud-orchestrator/infra"
+	"github.com/tnqbao/gau-cloud-orchestrator/repository"
+)
+
+// recordAudit appends an audit entry for work a consumer performed on behalf of ownerID.
+// The entry is attributed to the system actor; err marks it as a failure.
+// A failed write is logged but never affects how the message is acknowledged.
+func recordAudit(ctx context.Context, inf *infra.Infra, repo *repository.Repository, ownerID string, entry *entity.AuditLog, err error) {
+	owner, parseErr := uuid.Parse(ownerID)
+	if parseErr != nil {
+		inf.Logger.WarningWithContextf(ctx, "[Audit] Skipping %s on %s %s: invalid owner ID '%s'", entry.Action, entry.TargetType, entry.TargetID, ownerID)
+		return
+	}
+
+	entry.OwnerID = owner
+	entry.ActorType = entity.AuditActorSystem
+	entry.Result = entity.AuditResultSuccess
+	if err != nil {
+		entry.Result = entity.AuditResultFailure
+		entry.Error = err.Error()
+	}
+
+	if createErr := repo.AuditLogRepo.Create(entry); createErr != nil {
+		inf.Logger.ErrorWithContextf(ctx, createErr, "[Audit] Failed to record %s on %s %s: %v", entry.Action, entry.TargetType, entry.TargetID, createErr)
+	}
+}
@@ -0,0 +1,122 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/tnqbao/gau-cloud-orchestrator/infra"
+	"github.com/tnqbao/gau-cloud-orchestrator/infra/produce"
+	"github.com/tnqbao/gau-cloud-orchestrator/repository"
+)
+
+type AuthConsumer struct {
+	channel    *amqp.Channel
+	infra      *infra.Infra
+	repository *repository.Repository
+}
+
+func NewAuthConsumer(channel *amqp.Channel, infra *infra.Infra, repo *repository.Repository) *AuthConsumer {
+	return &AuthConsumer{
+		channel:    channel,
+		infra:      infra,
+		repository: repo,
+	}
+}
+
+func (c *AuthConsumer) Start(ctx context.Context) error {
+	if err := c.startTokenRevokedConsumer(ctx); err != nil {
+		return fmt.Errorf("failed to start auth consumer: %w", err)
+	}
+	return nil
+}
+
+// startTokenRevokedConsumer listens for token revocations from the authorization service
+// so cached validation results are invalidated before their TTL runs out
+func (c *AuthConsumer) startTokenRevokedConsumer(ctx context.Context) error {
+	msgs, err := c.channel.Consume(
+		produce.TokenRevokedQueue,
+		"",
+		false, // manual ack
+		false,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to register token revoked consumer: %w", err)
+	}
+
+	c.infra.Logger.InfoWithContextf(ctx, "[Auth Consumer] Started listening for token revocations on queue: %s", produce.TokenRevokedQueue)
+
+	spawn(func() {
+		for {
+			select {
+			case <-ctx.Done():
+				c.infra.Logger.InfoWithContextf(ctx, "[Auth Consumer] Shutting down...")
+				return
+			case msg, ok := <-msgs:
+				if !ok {
+					c.infra.Logger.WarningWithContextf(ctx, "[Auth Consumer] Channel closed")
+					return
+				}
+				c.handleTokenRevoked(ctx, msg)
+			}
+		}
+	})
+
+	return nil
+}
+
+// handleTokenRevoked marks the token as revoked in the token cache until it expires
+func (c *AuthConsumer) handleTokenRevoked(ctx context.Context, msg amqp.Delivery) {
+	var payload produce.TokenRevokedMessage
+	if err := json.Unmarshal(msg.Body, &payload); err != nil {
+		c.infra.Logger.ErrorWithContextf(ctx, err, "[Auth Consumer] Failed to unmarshal message: %v", err)
+		_ = msg.Nack(false, false)
+		return
+	}
+
+	tokenHash := payload.TokenHash
+	if tokenHash == "" && payload.AccessToken != "" {
+		tokenHash = infra.HashToken(payload.AccessToken)
+	}
+	if tokenHash == "" {
+		c.infra.Logger.WarningWithContextf(ctx, "[Auth Consumer] Revocation message without token, user_id: %s", payload.UserID)
+		_ = msg.Nack(false, false)
+		return
+	}
+
+	// Keep the revocation for the rest of the token lifetime (0 = use the JWT expiry)
+	var ttl time.Duration
+	if payload.ExpiresAt > 0 {
+		ttl = time.Until(time.Unix(payload.ExpiresAt, 0))
+		if ttl <= 0 {
+			// Token already expired, nothing to revoke
+			_ = msg.Ack(false)
+			return
+		}
+	}
+
+	var err error
+	maxRetries := 3
+	for attempt := 1; attempt <= maxRetries; attempt++ {
+		err = c.infra.TokenCache.Revoke(ctx, tokenHash, ttl)
+		if err == nil {
+			c.infra.Logger.InfoWithContextf(ctx, "[Auth Consumer] Revoked token for user_id: %s", payload.UserID)
+			_ = msg.Ack(false)
+			return
+		}
+
+		c.infra.Logger.ErrorWithContextf(ctx, err, "[Auth Consumer] Attempt %d/%d failed: %v", attempt, maxRetries, err)
+
+		if attempt < maxRetries {
+			time.Sleep(time.Duration(attempt) * 2 * time.Second)
+		}
+	}
+
+	c.infra.Logger.ErrorWithContextf(ctx, err, "[Auth Consumer] Failed after %d attempts, requeueing message", maxRetries)
+	_ = msg.Nack(false, true)
+}
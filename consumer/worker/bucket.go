@@ -64,7 +64,7 @@ func (c *BucketConsumer) startUpdatePolicyConsumer(ctx context.Context) error {
 
 	c.infra.Logger.InfoWithContextf(ctx, "[Bucket Consumer] Started listening for update policy jobs on queue: %s", produce.BucketUpdatePolicyQueue)
 
-	go func() {
+	spawn(func() {
 		for {
 			select {
 			case <-ctx.Done():
@@ -78,7 +78,7 @@ func (c *BucketConsumer) startUpdatePolicyConsumer(ctx context.Context) error {
 				c.handleUpdatePolicy(ctx, msg)
 			}
 		}
-	}()
+	})
 
 	return nil
 }
@@ -254,7 +254,7 @@ func (c *BucketConsumer) startDeleteBucketConsumer(ctx context.Context) error {
 
 	c.infra.Logger.InfoWithContextf(ctx, "[Bucket Consumer] Started listening for delete bucket jobs on queue: %s", produce.BucketDeleteQueue)
 
-	go func() {
+	spawn(func() {
 		for {
 			select {
 			case <-ctx.Done():
@@ -268,7 +268,7 @@ func (c *BucketConsumer) startDeleteBucketConsumer(ctx context.Context) error {
 				c.handleDeleteBucket(ctx, msg)
 			}
 		}
-	}()
+	})
 
 	return nil
 }
@@ -295,6 +295,15 @@ func (c *BucketConsumer) handleDeleteBucket(ctx context.Context, msg amqp.Delive
 		err = c.executeDeleteBucket(ctx, userID, payload.BucketName)
 		if err == nil {
 			c.infra.Logger.InfoWithContextf(ctx, "[Bucket Consumer - Delete] Successfully deleted bucket from MinIO and updated policies for user ID: %s, bucket: %s", userID.String(), payload.BucketName)
+			// The bucket quota went with the bucket row; the account usage is rebuilt from the remaining objects
+			if err := c.repository.StorageQuotaRepo.RecalculateAccount(userID); err != nil {
+				c.infra.Logger.ErrorWithContextf(ctx, err, "[Bucket Consumer - Delete] Failed to recalculate quota usage: %v", err)
+			}
+			recordAudit(ctx, c.infra, c.repository, payload.UserID, &entity.AuditLog{
+				Action:     entity.AuditActionBucketPurge,
+				TargetType: entity.AuditTargetBucket,
+				TargetName: payload.BucketName,
+			}, nil)
 			_ = msg.Ack(false)
 			return
 		}
@@ -308,6 +317,11 @@ func (c *BucketConsumer) handleDeleteBucket(ctx context.Context, msg amqp.Delive
 
 	// After max retries, reject and requeue
 	c.infra.Logger.ErrorWithContextf(ctx, err, "[Bucket Consumer - Delete] Failed after %d attempts, requeueing message", maxRetries)
+	recordAudit(ctx, c.infra, c.repository, payload.UserID, &entity.AuditLog{
+		Action:     entity.AuditActionBucketPurge,
+		TargetType: entity.AuditTargetBucket,
+		TargetName: payload.BucketName,
+	}, err)
 	_ = msg.Nack(false, true)
 }
 
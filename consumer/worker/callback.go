@@ -0,0 +1,402 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/tnqbao/gau-cloud-orchestrator/entity"
+	"github.com/tnqbao/gau-cloud-orchestrator/infra"
+	"github.com/tnqbao/gau-cloud-orchestrator/infra/produce"
+	"github.com/tnqbao/gau-cloud-orchestrator/repository"
+	"github.com/tnqbao/gau-cloud-orchestrator/utils"
+	"gorm.io/gorm"
+)
+
+// Headers of a completion callback. The signature is the hex HMAC-SHA256, keyed with the session's
+// callback secret, of "<timestamp>.<body>".
+const (
+	CallbackHeaderEvent     = "X-Callback-Event"
+	CallbackHeaderDelivery  = "X-Callback-Delivery"
+	CallbackHeaderAttempt   = "X-Callback-Attempt"
+	CallbackHeaderTimestamp = "X-Callback-Timestamp"
+	CallbackHeaderSignature = "X-Callback-Signature"
+)
+
+// callbackResponseLimit is how much of a failed response body is kept in the delivery log
+const callbackResponseLimit = 512
+
+// CallbackPolicy controls how completion callbacks are delivered
+type CallbackPolicy struct {
+	MaxAttempts  int
+	BaseDelay    time.Duration // before the first retry, doubled after each failed attempt
+	MaxDelay     time.Duration
+	Timeout      time.Duration // per attempt
+	Workers      int
+	AllowPrivate bool // allow URLs reaching loopback, private or link-local addresses
+}
+
+// UploadCallback is the body POSTed to the callback URL of an upload
+type UploadCallback struct {
+	Event     string                `json:"event"` // "completed" or "failed"
+	UploadID  uuid.UUID             `json:"upload_id"`
+	BucketID  uuid.UUID             `json:"bucket_id"`
+	Status    string                `json:"status"`
+	FileName  string                `json:"file_name"`
+	FileSize  int64                 `json:"file_size"`
+	Path      string                `json:"path"`
+	Object    *UploadCallbackObject `json:"object,omitempty"`
+	Error     string                `json:"error,omitempty"`
+	Timestamp time.Time             `json:"timestamp"`
+}
+
+// UploadCallbackObject describes the object created by a completed upload
+type UploadCallbackObject struct {
+	ID          uuid.UUID `json:"id"`
+	Name        string    `json:"name"`
+	Path        string    `json:"path"`
+	Size        int64     `json:"size"`
+	ContentType string    `json:"content_type"`
+	FileHash    string    `json:"file_hash"`
+	URL         string    `json:"url"`
+	Version     int       `json:"version"`
+}
+
+// CallbackConsumer POSTs the result of uploads to their callback URLs. Failed attempts are retried
+// with exponential backoff while the message is held; every attempt is written to the delivery log,
+// so a message redelivered after a restart carries on from the last attempt.
+type CallbackConsumer struct {
+	channel    *amqp.Channel
+	infra      *infra.Infra
+	repository *repository.Repository
+	policy     CallbackPolicy
+	client     *http.Client
+}
+
+func NewCallbackConsumer(channel *amqp.Channel, infra *infra.Infra, repo *repository.Repository, policy CallbackPolicy) *CallbackConsumer {
+	dialer := &net.Dialer{Timeout: policy.Timeout}
+	transport := &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: policy.Timeout}
+	if policy.AllowPrivate {
+		transport.Proxy = http.ProxyFromEnvironment
+	} else {
+		// Checked on the resolved address, so a public name pointing inside is refused as well.
+		// No proxy is used, it would connect on our behalf past the check.
+		dialer.Control = publicAddressOnly
+	}
+
+	return &CallbackConsumer{
+		channel:    channel,
+		infra:      infra,
+		repository: repo,
+		policy:     policy,
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   policy.Timeout,
+			// A redirect could lead anywhere; it is reported as a failed delivery instead
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+func (c *CallbackConsumer) Start(ctx context.Context) error {
+	msgs, err := c.channel.Consume(
+		produce.UploadCallbackQueue,
+		"",
+		false,
+		false,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to register upload callback consumer: %w", err)
+	}
+
+	c.infra.Logger.InfoWithContextf(ctx, "[Callback Consumer] Started listening for callbacks on queue: %s", produce.UploadCallbackQueue)
+
+	// Retries wait in their own goroutine, so a slow endpoint only holds one of the workers
+	workers := make(chan struct{}, max(c.policy.Workers, 1))
+	spawn(func() {
+		for {
+			select {
+			case <-ctx.Done():
+				c.infra.Logger.InfoWithContextf(ctx, "[Callback Consumer] Shutting down...")
+				return
+			case msg, ok := <-msgs:
+				if !ok {
+					c.infra.Logger.WarningWithContextf(ctx, "[Callback Consumer] Channel closed")
+					return
+				}
+				select {
+				case workers <- struct{}{}:
+				case <-ctx.Done():
+					_ = msg.Nack(false, true)
+					return
+				}
+				spawn(func() {
+					defer func() { <-workers }()
+					c.handleCallback(ctx, msg)
+				})
+			}
+		}
+	})
+
+	return nil
+}
+
+// handleCallback delivers one callback, retrying until it is accepted, refused for good or out of attempts
+func (c *CallbackConsumer) handleCallback(ctx context.Context, msg amqp.Delivery) {
+	var payload produce.UploadCallbackMessage
+	if err := json.Unmarshal(msg.Body, &payload); err != nil {
+		c.infra.Logger.ErrorWithContextf(ctx, err, "[Callback Consumer] Failed to unmarshal message: %v", err)
+		_ = msg.Nack(false, false)
+		return
+	}
+
+	uploadID, err := uuid.Parse(payload.UploadID)
+	if err != nil {
+		c.infra.Logger.ErrorWithContextf(ctx, err, "[Callback Consumer] Invalid upload ID")
+		_ = msg.Nack(false, false)
+		return
+	}
+
+	session, err := c.repository.UploadSessionRepo.FindByID(uploadID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.infra.Logger.WarningWithContextf(ctx, "[Callback Consumer] Upload %s no longer exists, dropping callback", uploadID)
+		_ = msg.Ack(false)
+		return
+	}
+	if err != nil {
+		c.infra.Logger.ErrorWithContextf(ctx, err, "[Callback Consumer] Failed to load session %s: %v", uploadID, err)
+		_ = msg.Nack(false, true)
+		return
+	}
+	if session.CallbackURL == "" {
+		_ = msg.Ack(false)
+		return
+	}
+
+	attempts, delivered, err := c.repository.UploadCallbackRepo.Progress(uploadID, payload.Event)
+	if err != nil {
+		c.infra.Logger.ErrorWithContextf(ctx, err, "[Callback Consumer] Failed to load deliveries of upload %s: %v", uploadID, err)
+		_ = msg.Nack(false, true)
+		return
+	}
+	if delivered {
+		_ = msg.Ack(false)
+		return
+	}
+
+	body, err := json.Marshal(c.buildCallback(session, payload))
+	if err != nil {
+		c.infra.Logger.ErrorWithContextf(ctx, err, "[Callback Consumer] Failed to encode callback of upload %s: %v", uploadID, err)
+		_ = msg.Nack(false, false)
+		return
+	}
+
+	for attempt := attempts + 1; attempt <= c.policy.MaxAttempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-time.After(c.retryDelay(attempt - 1)):
+			case <-ctx.Done():
+				// Picked up again, with the attempts made so far, by the next consumer
+				_ = msg.Nack(false, true)
+				return
+			}
+		}
+
+		delivery := c.deliver(ctx, session, payload.Event, body, attempt)
+		if !delivery.Success && ctx.Err() != nil {
+			// The attempt was cut short by shutdown and is made again once the message is redelivered
+			_ = msg.Nack(false, true)
+			return
+		}
+		if delivery.Success {
+			c.infra.Logger.InfoWithContextf(ctx, "[Callback Consumer] Delivered %s callback of upload %s on attempt %d", payload.Event, uploadID, attempt)
+			_ = msg.Ack(false)
+			return
+		}
+		if !retryableCallback(delivery.StatusCode) {
+			c.infra.Logger.WarningWithContextf(ctx, "[Callback Consumer] Callback of upload %s refused with status %d, not retrying", uploadID, delivery.StatusCode)
+			_ = msg.Ack(false)
+			return
+		}
+	}
+
+	c.infra.Logger.ErrorWithContextf(ctx, nil, "[Callback Consumer] Gave up on %s callback of upload %s after %d attempts", payload.Event, uploadID, c.policy.MaxAttempts)
+	_ = msg.Ack(false)
+}
+
+// buildCallback describes the result of the upload, with the object it created when still present
+func (c *CallbackConsumer) buildCallback(session *entity.UploadSession, payload produce.UploadCallbackMessage) UploadCallback {
+	callback := UploadCallback{
+		Event:     payload.Event,
+		UploadID:  session.ID,
+		BucketID:  session.BucketID,
+		Status:    string(session.Status),
+		FileName:  session.FileName,
+		FileSize:  session.FileSize,
+		Path:      session.CustomPath,
+		Error:     payload.Error,
+		Timestamp: time.Unix(payload.Timestamp, 0).UTC(),
+	}
+	if objectID, err := uuid.Parse(payload.ObjectID); err == nil {
+		if object, err := c.repository.ObjectRepo.FindByID(objectID); err == nil {
+			callback.Object = &UploadCallbackObject{
+				ID:          object.ID,
+				Name:        object.OriginName,
+				Path:        object.ParentPath,
+				Size:        object.Size,
+				ContentType: object.ContentType,
+				FileHash:    object.FileHash,
+				URL:         object.URL,
+				Version:     object.Version,
+			}
+		}
+	}
+	return callback
+}
+
+// deliver makes one signed attempt and records it in the delivery log
+func (c *CallbackConsumer) deliver(ctx context.Context, session *entity.UploadSession, event string, body []byte, attempt int) *entity.UploadCallbackDelivery {
+	delivery := &entity.UploadCallbackDelivery{
+		ID:       uuid.New(),
+		UploadID: session.ID,
+		Event:    event,
+		Attempt:  attempt,
+		URL:      session.CallbackURL,
+	}
+
+	start := time.Now()
+	statusCode, err := c.post(ctx, session, delivery, body)
+	delivery.DurationMs = time.Since(start).Milliseconds()
+	delivery.StatusCode = statusCode
+	delivery.Success = err == nil
+	if !delivery.Success && ctx.Err() != nil {
+		// Cut short by shutdown, the attempt is made again once the message is redelivered
+		return delivery
+	}
+	if err != nil {
+		delivery.Error = err.Error()
+		c.infra.Logger.WarningWithContextf(ctx, "[Callback Consumer] Attempt %d of %s callback of upload %s failed: %v", attempt, event, session.ID, err)
+	}
+
+	if err := c.repository.UploadCallbackRepo.Create(delivery); err != nil {
+		c.infra.Logger.ErrorWithContextf(ctx, err, "[Callback Consumer] Failed to record delivery of upload %s: %v", session.ID, err)
+	}
+	return delivery
+}
+
+// post sends the callback and returns the response status, with an error unless it is a 2xx
+func (c *CallbackConsumer) post(ctx context.Context, session *entity.UploadSession, delivery *entity.UploadCallbackDelivery, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, session.CallbackURL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(CallbackHeaderEvent, delivery.Event)
+	req.Header.Set(CallbackHeaderDelivery, delivery.ID.String())
+	req.Header.Set(CallbackHeaderAttempt, strconv.Itoa(delivery.Attempt))
+	req.Header.Set(CallbackHeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(CallbackHeaderSignature, SignCallback(session.CallbackSecret, timestamp, body))
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, callbackResponseLimit))
+		return resp.StatusCode, nil
+	}
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, callbackResponseLimit))
+	return resp.StatusCode, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(snippet))
+}
+
+// retryDelay is how long to wait after the given number of failed attempts
+func (c *CallbackConsumer) retryDelay(failed int) time.Duration {
+	delay := c.policy.BaseDelay
+	for i := 1; i < failed && delay < c.policy.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, c.policy.MaxDelay)
+}
+
+// retryableCallback reports whether an attempt that got statusCode may succeed later. Requests that
+// got no response, timed out, were throttled or hit a server error are retried; other refusals are final.
+func retryableCallback(statusCode int) bool {
+	return statusCode == 0 ||
+		statusCode == http.StatusRequestTimeout ||
+		statusCode == http.StatusTooManyRequests ||
+		statusCode >= 500
+}
+
+// SignCallback signs a callback body sent at timestamp
+func SignCallback(secret string, timestamp int64, body []byte) string {
+	return utils.ComputeHMACSHA256(secret, strconv.FormatInt(timestamp, 10)+"."+string(body))
+}
+
+// specialPurposeRanges are the address blocks a callback may not reach: this network, private,
+// shared (carrier-grade NAT), loopback, link-local, protocol assignments, documentation,
+// benchmarking, multicast, reserved and broadcast, and their IPv6 counterparts
+var specialPurposeRanges = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("10.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("127.0.0.0/8"),
+	netip.MustParsePrefix("169.254.0.0/16"),
+	netip.MustParsePrefix("172.16.0.0/12"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("192.88.99.0/24"),
+	netip.MustParsePrefix("192.168.0.0/16"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("224.0.0.0/4"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("255.255.255.255/32"),
+	netip.MustParsePrefix("::/128"),
+	netip.MustParsePrefix("::1/128"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("100::/64"),
+	netip.MustParsePrefix("2001::/23"),
+	netip.MustParsePrefix("2001:db8::/32"),
+	netip.MustParsePrefix("2002::/16"),
+	netip.MustParsePrefix("fc00::/7"),
+	netip.MustParsePrefix("fe80::/10"),
+	netip.MustParsePrefix("ff00::/8"),
+}
+
+// publicAddressOnly refuses connections to any address of specialPurposeRanges. IPv4-mapped IPv6
+// addresses are checked as the IPv4 address they carry.
+func publicAddressOnly(_, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	ip := addrPort.Addr().Unmap()
+	for _, prefix := range specialPurposeRanges {
+		if prefix.Contains(ip) {
+			return fmt.Errorf("callback address %s is not public", ip)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package worker
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/tnqbao/gau-cloud-orchestrator/infra/produce"
+)
+
+// maxComposeSources is the most objects MinIO concatenates in one ComposeObject call
+const maxComposeSources = 10000
+
+// startChunkCompleteConsumer listens for chunk_complete messages when the orchestrator runs with
+// the MinIO upload backend. The chunks are composed here instead of by the upload-service.
+func (c *UploadConsumer) startChunkCompleteConsumer(ctx context.Context) error {
+	msgs, err := c.channel.Consume(
+		produce.ChunkCompleteQueue,
+		"",
+		false, // manual ack
+		false,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to register chunk_complete consumer: %w", err)
+	}
+
+	c.infra.Logger.InfoWithContextf(ctx, "[Upload Consumer] Started listening for chunk_complete on queue: %s", produce.ChunkCompleteQueue)
+
+	spawn(func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-msgs:
+				if !ok {
+					c.infra.Logger.WarningWithContextf(ctx, "[Upload Consumer] Chunk complete channel closed")
+					return
+				}
+				c.handleChunkComplete(ctx, msg)
+			}
+		}
+	})
+
+	return nil
+}
+
+// handleChunkComplete composes the chunks of an upload into its final object and records it.
+// The chunks are kept until the upload is recorded, so a redelivered message can compose again.
+func (c *UploadConsumer) handleChunkComplete(ctx context.Context, msg amqp.Delivery) {
+	var payload produce.ChunkCompleteMessage
+	if err := json.Unmarshal(msg.Body, &payload); err != nil {
+		c.infra.Logger.ErrorWithContextf(ctx, err, "[Upload Consumer] Failed to unmarshal chunk_complete message")
+		settle(msg, composeInvalid)
+		return
+	}
+
+	c.infra.Logger.InfoWithContextf(ctx, "[Upload Consumer] Composing %d chunks of upload %s", payload.TotalChunks, payload.UploadID)
+
+	result := produce.ComposeCompletedMessage{
+		UploadID:    payload.UploadID,
+		BucketID:    payload.BucketID,
+		UserID:      payload.UserID,
+		FileSize:    payload.FileSize,
+		ContentType: payload.ContentType,
+		FileName:    payload.FileName,
+		CustomPath:  payload.CustomPath,
+		Timestamp:   time.Now().Unix(),
+	}
+	fileHash, key, err := c.composeChunks(ctx, payload)
+	if err != nil {
+		c.infra.Logger.ErrorWithContextf(ctx, err, "[Upload Consumer] Failed to compose upload %s: %v", payload.UploadID, err)
+		result.Error = err.Error()
+	} else {
+		result.Success = true
+		result.FileHash = fileHash
+		result.FilePath = key
+	}
+
+	outcome := c.completeUpload(ctx, result)
+	if outcome == composeDone && result.Success {
+		if err := c.infra.Minio.DeleteObjectsWithPrefix(ctx, payload.TempBucket, payload.TempPrefix); err != nil {
+			c.infra.Logger.WarningWithContextf(ctx, "[Upload Consumer] Failed to remove chunks of upload %s: %v", payload.UploadID, err)
+		}
+	}
+	settle(msg, outcome)
+}
+
+// composeChunks hashes the chunks of an upload in order and concatenates them server-side into
+// <custom_path>/<hash><ext> of the target bucket, the key the upload-service would have used.
+// It returns the file hash and the key.
+func (c *UploadConsumer) composeChunks(ctx context.Context, p produce.ChunkCompleteMessage) (string, string, error) {
+	chunks, err := c.infra.Minio.ListObjects(ctx, p.TempBucket, p.TempPrefix)
+	if err != nil {
+		return "", "", err
+	}
+	if len(chunks) != p.TotalChunks {
+		return "", "", fmt.Errorf("found %d chunks, expected %d", len(chunks), p.TotalChunks)
+	}
+	if len(chunks) > maxComposeSources {
+		return "", "", fmt.Errorf("%d chunks exceed the compose limit of %d", len(chunks), maxComposeSources)
+	}
+
+	// Chunk names are zero-padded, so key order is upload order
+	hasher := sha256.New()
+	keys := make([]string, 0, len(chunks))
+	var size int64
+	for _, chunk := range chunks {
+		reader, _, err := c.infra.Minio.GetObject(ctx, p.TempBucket, chunk.Key)
+		if err != nil {
+			return "", "", err
+		}
+		n, err := io.Copy(hasher, reader)
+		reader.Close()
+		if err != nil {
+			return "", "", fmt.Errorf("failed to read chunk %s: %w", chunk.Key, err)
+		}
+		size += n
+		keys = append(keys, chunk.Key)
+	}
+	if size != p.FileSize {
+		return "", "", fmt.Errorf("chunks hold %d bytes, expected %d", size, p.FileSize)
+	}
+	fileHash := hex.EncodeToString(hasher.Sum(nil))
+
+	ext := filepath.Ext(p.FileName)
+	if ext == "" {
+		ext = ".bin"
+	}
+	key := path.Join(p.CustomPath, fileHash+ext)
+
+	// Identical content already stored under the key is shared rather than written again
+	exists, err := c.infra.Minio.ObjectExists(ctx, p.TargetBucket, key)
+	if err != nil {
+		return "", "", err
+	}
+	if !exists {
+		if err := c.infra.Minio.ComposeObjects(ctx, p.TempBucket, keys, p.TargetBucket, key, p.ContentType); err != nil {
+			return "", "", err
+		}
+	}
+
+	return fileHash, key, nil
+}
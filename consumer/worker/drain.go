@@ -0,0 +1,35 @@
+package worker
+
+import (
+	"sync"
+	"time"
+)
+
+// running tracks the consume loops and the handlers they start, so shutdown can wait for them
+var running sync.WaitGroup
+
+// spawn runs fn in a goroutine that Drain waits for
+func spawn(fn func()) {
+	running.Add(1)
+	go func() {
+		defer running.Done()
+		fn()
+	}()
+}
+
+// Drain waits for the consume loops to stop, which they do once the context they were started with
+// is cancelled and the message in hand is handled. It returns false when timeout passes first.
+func Drain(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
@@ -3,16 +3,23 @@ package worker
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/google/uuid"
 	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/tnqbao/gau-cloud-orchestrator/entity"
 	"github.com/tnqbao/gau-cloud-orchestrator/infra"
 	"github.com/tnqbao/gau-cloud-orchestrator/infra/produce"
 	"github.com/tnqbao/gau-cloud-orchestrator/repository"
+	"github.com/tnqbao/gau-cloud-orchestrator/transfer"
 )
 
-// ObjectConsumer handles object deletion messages from the queue
+// deletePathBatchSize is the number of storage keys checked for references at a time while deleting a path
+const deletePathBatchSize = 500
+
+// ObjectConsumer handles object deletion and folder transfer messages from the queue
 type ObjectConsumer struct {
 	channel    *amqp.Channel
 	infra      *infra.Infra
@@ -38,6 +45,10 @@ func (c *ObjectConsumer) Start(ctx context.Context) error {
 		return fmt.Errorf("failed to start path delete consumer: %w", err)
 	}
 
+	if err := c.startTransferConsumer(ctx); err != nil {
+		return fmt.Errorf("failed to start object transfer consumer: %w", err)
+	}
+
 	return nil
 }
 
@@ -57,7 +68,7 @@ func (c *ObjectConsumer) startDeleteObjectConsumer(ctx context.Context) error {
 
 	c.infra.Logger.InfoWithContextf(ctx, "[Object Consumer] Started listening for delete object jobs on queue: %s", produce.ObjectDeleteQueue)
 
-	go func() {
+	spawn(func() {
 		for {
 			select {
 			case <-ctx.Done():
@@ -71,7 +82,7 @@ func (c *ObjectConsumer) startDeleteObjectConsumer(ctx context.Context) error {
 				c.handleDeleteObject(ctx, msg)
 			}
 		}
-	}()
+	})
 
 	return nil
 }
@@ -89,9 +100,19 @@ func (c *ObjectConsumer) handleDeleteObject(ctx context.Context, msg amqp.Delive
 	maxRetries := 3
 	var lastErr error
 	for attempt := 1; attempt <= maxRetries; attempt++ {
-		lastErr = c.infra.Minio.DeleteObject(ctx, payload.BucketName, payload.ObjectPath)
+		lastErr = c.deleteUnreferenced(ctx, &payload)
 		if lastErr == nil {
 			c.infra.Logger.InfoWithContextf(ctx, "[Object Consumer - Delete Object] Successfully deleted object '%s' from bucket '%s'", payload.ObjectPath, payload.BucketName)
+			objects := int64(1)
+			if payload.Version {
+				objects = 0
+			}
+			releaseQuotaUsage(ctx, c.infra, c.repository, payload.UserID, payload.BucketID, payload.Size, objects)
+			recordAudit(ctx, c.infra, c.repository, payload.UserID, &entity.AuditLog{
+				Action:     entity.AuditActionObjectPurge,
+				TargetType: entity.AuditTargetObject,
+				TargetName: payload.BucketName + "/" + payload.ObjectPath,
+			}, nil)
 			_ = msg.Ack(false)
 			return
 		}
@@ -105,9 +126,30 @@ func (c *ObjectConsumer) handleDeleteObject(ctx context.Context, msg amqp.Delive
 
 	// After max retries, reject and requeue
 	c.infra.Logger.ErrorWithContextf(ctx, lastErr, "[Object Consumer - Delete Object] Failed after %d attempts, requeueing message", maxRetries)
+	recordAudit(ctx, c.infra, c.repository, payload.UserID, &entity.AuditLog{
+		Action:     entity.AuditActionObjectPurge,
+		TargetType: entity.AuditTargetObject,
+		TargetName: payload.BucketName + "/" + payload.ObjectPath,
+	}, lastErr)
 	_ = msg.Nack(false, true)
 }
 
+// deleteUnreferenced removes the file of a deleted object unless a blob still holds it, as when
+// an upload of the same content took a reference to the key after the delete was queued
+func (c *ObjectConsumer) deleteUnreferenced(ctx context.Context, payload *produce.DeleteObjectMessage) error {
+	if bucketID, err := uuid.Parse(payload.BucketID); err == nil {
+		referenced, err := c.repository.BlobRepo.ReferencedKeys(bucketID, []string{payload.ObjectPath})
+		if err != nil {
+			return err
+		}
+		if referenced[payload.ObjectPath] {
+			c.infra.Logger.InfoWithContextf(ctx, "[Object Consumer - Delete Object] Keeping '%s' in bucket '%s', it is referenced again", payload.ObjectPath, payload.BucketName)
+			return nil
+		}
+	}
+	return c.infra.Minio.DeleteObject(ctx, payload.BucketName, payload.ObjectPath)
+}
+
 func (c *ObjectConsumer) startDeletePathConsumer(ctx context.Context) error {
 	msgs, err := c.channel.Consume(
 		produce.PathDeleteQueue,
@@ -124,7 +166,7 @@ func (c *ObjectConsumer) startDeletePathConsumer(ctx context.Context) error {
 
 	c.infra.Logger.InfoWithContextf(ctx, "[Object Consumer] Started listening for delete path jobs on queue: %s", produce.PathDeleteQueue)
 
-	go func() {
+	spawn(func() {
 		for {
 			select {
 			case <-ctx.Done():
@@ -138,7 +180,7 @@ func (c *ObjectConsumer) startDeletePathConsumer(ctx context.Context) error {
 				c.handleDeletePath(ctx, msg)
 			}
 		}
-	}()
+	})
 
 	return nil
 }
@@ -152,14 +194,25 @@ func (c *ObjectConsumer) handleDeletePath(ctx context.Context, msg amqp.Delivery
 		_ = msg.Nack(false, false)
 		return
 	}
+	bucketID, err := uuid.Parse(payload.BucketID)
+	if err != nil {
+		c.infra.Logger.ErrorWithContextf(ctx, err, "[Object Consumer - Delete Path] Invalid bucket_id: %v", err)
+		_ = msg.Nack(false, false)
+		return
+	}
 
 	maxRetries := 3
 	var lastErr error
 	for attempt := 1; attempt <= maxRetries; attempt++ {
-		// Delete all objects with the given path prefix from MinIO
-		lastErr = c.infra.Minio.DeleteObjectsWithPrefix(ctx, payload.BucketName, payload.Path+"/")
+		lastErr = c.deletePath(ctx, bucketID, &payload)
 		if lastErr == nil {
 			c.infra.Logger.InfoWithContextf(ctx, "[Object Consumer - Delete Path] Successfully deleted path '%s/' from bucket '%s'", payload.Path, payload.BucketName)
+			releaseQuotaUsage(ctx, c.infra, c.repository, payload.UserID, payload.BucketID, payload.Size, payload.ObjectCount)
+			recordAudit(ctx, c.infra, c.repository, payload.UserID, &entity.AuditLog{
+				Action:     entity.AuditActionObjectPurge,
+				TargetType: entity.AuditTargetPath,
+				TargetName: payload.BucketName + "/" + payload.Path + "/",
+			}, nil)
 			_ = msg.Ack(false)
 			return
 		}
@@ -173,5 +226,181 @@ func (c *ObjectConsumer) handleDeletePath(ctx context.Context, msg amqp.Delivery
 
 	// After max retries, reject and requeue
 	c.infra.Logger.ErrorWithContextf(ctx, lastErr, "[Object Consumer - Delete Path] Failed after %d attempts, requeueing message", maxRetries)
+	recordAudit(ctx, c.infra, c.repository, payload.UserID, &entity.AuditLog{
+		Action:     entity.AuditActionObjectPurge,
+		TargetType: entity.AuditTargetPath,
+		TargetName: payload.BucketName + "/" + payload.Path + "/",
+	}, lastErr)
 	_ = msg.Nack(false, true)
 }
+
+// deletePath removes everything stored under the deleted path and the unreferenced blobs named in
+// payload, except the blobs objects outside the path still reference
+func (c *ObjectConsumer) deletePath(ctx context.Context, bucketID uuid.UUID, payload *produce.DeletePathMessage) error {
+	stored, err := c.infra.Minio.ListObjects(ctx, payload.BucketName, payload.Path+"/")
+	if err != nil {
+		return err
+	}
+	keys := append([]string(nil), payload.Keys...)
+	for i := range stored {
+		keys = append(keys, stored[i].Key)
+	}
+
+	for start := 0; start < len(keys); start += deletePathBatchSize {
+		batch := keys[start:min(start+deletePathBatchSize, len(keys))]
+		referenced, err := c.repository.BlobRepo.ReferencedKeys(bucketID, batch)
+		if err != nil {
+			return err
+		}
+		unused := make([]string, 0, len(batch))
+		for _, key := range batch {
+			if !referenced[key] {
+				unused = append(unused, key)
+			}
+		}
+		if err := c.infra.Minio.DeleteObjects(ctx, payload.BucketName, unused); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *ObjectConsumer) startTransferConsumer(ctx context.Context) error {
+	msgs, err := c.channel.Consume(
+		produce.ObjectTransferQueue,
+		"",
+		false,
+		false,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to register object transfer consumer: %w", err)
+	}
+
+	c.infra.Logger.InfoWithContextf(ctx, "[Object Consumer] Started listening for folder transfer jobs on queue: %s", produce.ObjectTransferQueue)
+
+	spawn(func() {
+		for {
+			select {
+			case <-ctx.Done():
+				c.infra.Logger.InfoWithContextf(ctx, "[Object Consumer - Transfer] Shutting down...")
+				return
+			case msg, ok := <-msgs:
+				if !ok {
+					c.infra.Logger.WarningWithContextf(ctx, "[Object Consumer - Transfer] Channel closed")
+					return
+				}
+				c.handleTransfer(ctx, msg)
+			}
+		}
+	})
+
+	return nil
+}
+
+func (c *ObjectConsumer) handleTransfer(ctx context.Context, msg amqp.Delivery) {
+	c.infra.Logger.InfoWithContextf(ctx, "[Object Consumer - Transfer] Received message: %s", string(msg.Body))
+
+	var payload produce.ObjectTransferMessage
+	if err := json.Unmarshal(msg.Body, &payload); err != nil {
+		c.infra.Logger.ErrorWithContextf(ctx, err, "[Object Consumer - Transfer] Failed to unmarshal message: %v", err)
+		_ = msg.Nack(false, false)
+		return
+	}
+
+	mode := transfer.Mode(payload.Mode)
+	action := entity.AuditActionFolderCopy
+	if mode == transfer.ModeMove {
+		action = entity.AuditActionFolderMove
+	}
+	audit := func(result transfer.FolderResult, err error) {
+		after, _ := json.Marshal(map[string]interface{}{
+			"bucket_id": payload.DestBucketID,
+			"path":      payload.DestPath,
+			"result":    result,
+		})
+		recordAudit(ctx, c.infra, c.repository, payload.UserID, &entity.AuditLog{
+			Action:     action,
+			TargetType: entity.AuditTargetFolder,
+			TargetID:   payload.SourceBucketID,
+			TargetName: payload.SourcePath,
+			After:      after,
+		}, err)
+	}
+
+	src, dst, err := c.transferBuckets(payload)
+	if err != nil {
+		// The buckets are gone or the message is malformed, retrying cannot help
+		c.infra.Logger.ErrorWithContextf(ctx, err, "[Object Consumer - Transfer] Dropping transfer of '%s': %v", payload.SourcePath, err)
+		audit(transfer.FolderResult{}, err)
+		_ = msg.Nack(false, false)
+		return
+	}
+
+	maxRetries := 3
+	var lastErr error
+	var result transfer.FolderResult
+	for attempt := 1; attempt <= maxRetries; attempt++ {
+		// Each run resumes where the last one stopped: moved objects have left the source
+		// and objects copied already are skipped
+		result, lastErr = transfer.New(c.infra, c.repository).Folder(ctx, mode, src, payload.SourcePath, dst, payload.DestPath)
+		if lastErr == nil {
+			c.infra.Logger.InfoWithContextf(ctx, "[Object Consumer - Transfer] %s of '%s' to '%s' completed: %d transferred, %d skipped",
+				mode, payload.SourcePath, payload.DestPath, result.Transferred, result.Skipped)
+			audit(result, nil)
+			_ = msg.Ack(false)
+			return
+		}
+
+		if errors.Is(lastErr, repository.ErrQuotaExceeded) || errors.Is(lastErr, transfer.ErrInvalidDestination) {
+			c.infra.Logger.WarningWithContextf(ctx, "[Object Consumer - Transfer] %s of '%s' stopped after %d objects: %v", mode, payload.SourcePath, result.Transferred, lastErr)
+			audit(result, lastErr)
+			_ = msg.Ack(false)
+			return
+		}
+
+		c.infra.Logger.ErrorWithContextf(ctx, lastErr, "[Object Consumer - Transfer] Attempt %d/%d failed: %v", attempt, maxRetries, lastErr)
+
+		if attempt < maxRetries {
+			time.Sleep(time.Duration(attempt) * 2 * time.Second)
+		}
+	}
+
+	// After max retries, reject and requeue
+	c.infra.Logger.ErrorWithContextf(ctx, lastErr, "[Object Consumer - Transfer] Failed after %d attempts, requeueing message", maxRetries)
+	audit(result, lastErr)
+	_ = msg.Nack(false, true)
+}
+
+// transferBuckets loads the source and destination buckets of a transfer and checks
+// that both still belong to the user who requested it
+func (c *ObjectConsumer) transferBuckets(payload produce.ObjectTransferMessage) (*entity.Bucket, *entity.Bucket, error) {
+	if payload.Mode != string(transfer.ModeCopy) && payload.Mode != string(transfer.ModeMove) {
+		return nil, nil, fmt.Errorf("unknown transfer mode %q", payload.Mode)
+	}
+
+	userID, err := uuid.Parse(payload.UserID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid user ID: %w", err)
+	}
+
+	buckets := make([]*entity.Bucket, 2)
+	for i, raw := range []string{payload.SourceBucketID, payload.DestBucketID} {
+		bucketID, err := uuid.Parse(raw)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid bucket ID '%s': %w", raw, err)
+		}
+		bucket, err := c.repository.BucketRepo.FindByID(bucketID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("bucket %s not found: %w", bucketID, err)
+		}
+		if bucket.OwnerID != userID {
+			return nil, nil, fmt.Errorf("bucket %s is not owned by user %s", bucketID, userID)
+		}
+		buckets[i] = bucket
+	}
+
+	return buckets[0], buckets[1], nil
+}
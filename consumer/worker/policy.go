@@ -8,6 +8,7 @@ import (
 
 	"github.com/google/uuid"
 	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/tnqbao/gau-cloud-orchestrator/entity"
 	"github.com/tnqbao/gau-cloud-orchestrator/infra"
 	"github.com/tnqbao/gau-cloud-orchestrator/infra/produce"
 	"github.com/tnqbao/gau-cloud-orchestrator/repository"
@@ -51,7 +52,7 @@ func (c *IAMConsumer) startUpdatePolicyConsumer(ctx context.Context) error {
 
 	c.infra.Logger.InfoWithContextf(ctx, "[IAM Consumer] Started listening for update policy jobs on queue: %s", produce.IAMUpdatePolicyQueue)
 
-	go func() {
+	spawn(func() {
 		for {
 			select {
 			case <-ctx.Done():
@@ -65,7 +66,7 @@ func (c *IAMConsumer) startUpdatePolicyConsumer(ctx context.Context) error {
 				c.handleUpdatePolicy(ctx, msg)
 			}
 		}
-	}()
+	})
 
 	return nil
 }
@@ -92,6 +93,7 @@ func (c *IAMConsumer) handleUpdatePolicy(ctx context.Context, msg amqp.Delivery)
 		err = c.executeUpdatePolicy(ctx, iamID, payload.OldPolicyName, payload.NewPolicyName, payload.PolicyJSON)
 		if err == nil {
 			c.infra.Logger.InfoWithContextf(ctx, "[IAM Consumer - Update Policy] Successfully updated policy for IAM ID: %s", iamID.String())
+			c.auditPolicyUpdate(ctx, iamID, payload, nil)
 			_ = msg.Ack(false)
 			return
 		}
@@ -105,9 +107,30 @@ func (c *IAMConsumer) handleUpdatePolicy(ctx context.Context, msg amqp.Delivery)
 
 	// After max retries, reject and requeue
 	c.infra.Logger.ErrorWithContextf(ctx, err, "[IAM Consumer - Update Policy] Failed after %d attempts, requeueing message", maxRetries)
+	c.auditPolicyUpdate(ctx, iamID, payload, err)
 	_ = msg.Nack(false, true)
 }
 
+// auditPolicyUpdate records the policy rename against the account that owns the IAM user
+func (c *IAMConsumer) auditPolicyUpdate(ctx context.Context, iamID uuid.UUID, payload produce.UpdateIAMPolicyMessage, err error) {
+	iamUser, lookupErr := c.repository.IAMUserRepo.GetByID(iamID)
+	if lookupErr != nil {
+		c.infra.Logger.WarningWithContextf(ctx, "[Audit] Skipping %s for IAM %s: %v", entity.AuditActionIAMPolicyUpdate, iamID, lookupErr)
+		return
+	}
+
+	before, _ := json.Marshal(map[string]string{"policy_name": payload.OldPolicyName})
+	after, _ := json.Marshal(map[string]string{"policy_name": payload.NewPolicyName})
+	recordAudit(ctx, c.infra, c.repository, iamUser.UserId.String(), &entity.AuditLog{
+		Action:     entity.AuditActionIAMPolicyUpdate,
+		TargetType: entity.AuditTargetIAMUser,
+		TargetID:   iamID.String(),
+		TargetName: iamUser.Name,
+		Before:     before,
+		After:      after,
+	}, err)
+}
+
 func (c *IAMConsumer) executeUpdatePolicy(ctx context.Context, iamID uuid.UUID, oldPolicyName, newPolicyName string, policyJSON []byte) error {
 	// Step 1: Delete old policy from MinIO
 	if err := c.infra.Minio.DeletePolicy(ctx, oldPolicyName); err != nil {
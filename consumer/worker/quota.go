@@ -0,0 +1,26 @@
+package worker
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/tnqbao/gau-cloud-orchestrator/infra"
+	"github.com/tnqbao/gau-cloud-orchestrator/repository"
+)
+
+// releaseQuotaUsage removes deleted bytes and objects from the owner's account quota and,
+// when bucketID is known, from the bucket quota. Failures are logged, never retried:
+// the account usage is recalculated whenever a bucket is purged.
+func releaseQuotaUsage(ctx context.Context, inf *infra.Infra, repo *repository.Repository, ownerID, bucketID string, bytes, objects int64) {
+	owner, err := uuid.Parse(ownerID)
+	if err != nil {
+		inf.Logger.WarningWithContextf(ctx, "[Quota] Cannot release usage: invalid owner ID '%s'", ownerID)
+		return
+	}
+	// Messages published before quotas existed carry no bucket ID; only the account is released
+	bucket, _ := uuid.Parse(bucketID)
+
+	if err := repo.StorageQuotaRepo.ReleaseUsage(owner, bucket, bytes, objects); err != nil {
+		inf.Logger.ErrorWithContextf(ctx, err, "[Quota] Failed to release %d bytes for owner %s: %v", bytes, ownerID, err)
+	}
+}
@@ -3,16 +3,21 @@ package worker
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"path"
 	"path/filepath"
 	"time"
 
 	"github.com/google/uuid"
 	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/tnqbao/gau-cloud-orchestrator/conflict"
 	"github.com/tnqbao/gau-cloud-orchestrator/entity"
 	"github.com/tnqbao/gau-cloud-orchestrator/infra"
 	"github.com/tnqbao/gau-cloud-orchestrator/infra/produce"
+	"github.com/tnqbao/gau-cloud-orchestrator/quotawarn"
 	"github.com/tnqbao/gau-cloud-orchestrator/repository"
+	"gorm.io/gorm"
 )
 
 type UploadConsumer struct {
@@ -33,6 +38,12 @@ func (c *UploadConsumer) Start(ctx context.Context) error {
 	if err := c.startComposeCompletedConsumer(ctx); err != nil {
 		return fmt.Errorf("failed to start upload consumer: %w", err)
 	}
+	// Without the upload-service, chunks are composed here
+	if _, native := c.infra.Uploader.(*infra.MinioUploader); native {
+		if err := c.startChunkCompleteConsumer(ctx); err != nil {
+			return fmt.Errorf("failed to start upload consumer: %w", err)
+		}
+	}
 	return nil
 }
 
@@ -55,7 +66,7 @@ func (c *UploadConsumer) startComposeCompletedConsumer(ctx context.Context) erro
 
 	c.infra.Logger.InfoWithContextf(ctx, "[Upload Consumer] Started listening for compose_completed on queue: %s", produce.ComposeCompletedQueue)
 
-	go func() {
+	spawn(func() {
 		for {
 			select {
 			case <-ctx.Done():
@@ -69,37 +80,61 @@ func (c *UploadConsumer) startComposeCompletedConsumer(ctx context.Context) erro
 				c.handleComposeCompleted(ctx, msg)
 			}
 		}
-	}()
+	})
 
 	return nil
 }
 
+// composeOutcome tells how the result of a compose was handled
+type composeOutcome int
+
+const (
+	composeDone    composeOutcome = iota // handled, including uploads that failed or were rejected
+	composeRetry                         // a transient failure, the message is delivered again
+	composeInvalid                       // the message cannot be handled and is dropped
+)
+
+// settle acknowledges msg according to outcome
+func settle(msg amqp.Delivery, outcome composeOutcome) {
+	switch outcome {
+	case composeRetry:
+		_ = msg.Nack(false, true)
+	case composeInvalid:
+		_ = msg.Nack(false, false)
+	default:
+		_ = msg.Ack(false)
+	}
+}
+
 // handleComposeCompleted processes compose_completed messages from upload-service
-// 1. Parse the message with file hash and final path
-// 2. Create object record in database
-// 3. Update upload session status to completed
 func (c *UploadConsumer) handleComposeCompleted(ctx context.Context, msg amqp.Delivery) {
 	c.infra.Logger.InfoWithContextf(ctx, "[Upload Consumer] Received compose_completed message")
 
 	var payload produce.ComposeCompletedMessage
 	if err := json.Unmarshal(msg.Body, &payload); err != nil {
 		c.infra.Logger.ErrorWithContextf(ctx, err, "[Upload Consumer] Failed to unmarshal compose_completed message")
-		_ = msg.Nack(false, false)
+		settle(msg, composeInvalid)
 		return
 	}
 
+	settle(msg, c.completeUpload(ctx, payload))
+}
+
+// completeUpload records the result of composing a chunked upload
+// 1. Parse the payload with file hash and final path
+// 2. Create object record in database
+// 3. Update upload session status to completed
+func (c *UploadConsumer) completeUpload(ctx context.Context, payload produce.ComposeCompletedMessage) composeOutcome {
 	uploadID, err := uuid.Parse(payload.UploadID)
 	if err != nil {
 		c.infra.Logger.ErrorWithContextf(ctx, err, "[Upload Consumer] Invalid upload ID")
-		_ = msg.Nack(false, false)
-		return
+		return composeInvalid
 	}
 
 	bucketID, err := uuid.Parse(payload.BucketID)
 	if err != nil {
 		c.infra.Logger.ErrorWithContextf(ctx, err, "[Upload Consumer] Invalid bucket ID")
-		_ = msg.Nack(false, false)
-		return
+		return composeInvalid
 	}
 
 	c.infra.Logger.InfoWithContextf(ctx, "[Upload Consumer] Processing compose_completed for upload %s, success=%v", uploadID, payload.Success)
@@ -108,8 +143,14 @@ func (c *UploadConsumer) handleComposeCompleted(ctx context.Context, msg amqp.De
 	if !payload.Success {
 		c.infra.Logger.ErrorWithContextf(ctx, nil, "[Upload Consumer] Compose failed: %s", payload.Error)
 		c.updateSessionStatus(uploadID, entity.UploadStatusFailed)
-		_ = msg.Ack(false)
-		return
+		recordAudit(ctx, c.infra, c.repository, payload.UserID, &entity.AuditLog{
+			Action:     entity.AuditActionObjectCreate,
+			TargetType: entity.AuditTargetUploadSession,
+			TargetID:   uploadID.String(),
+			TargetName: path.Join(payload.CustomPath, payload.FileName),
+		}, fmt.Errorf("compose failed: %s", payload.Error))
+		publishUploadEvent(ctx, c.infra, c.repository, uploadID, infra.UploadEventFailed, "", payload.Error)
+		return composeDone
 	}
 
 	// Update session with file hash
@@ -126,6 +167,17 @@ func (c *UploadConsumer) handleComposeCompleted(ctx context.Context, msg amqp.De
 	// Construct URL part (hash + extension)
 	urlPart := fmt.Sprintf("%s%s", payload.FileHash, ext)
 
+	session, err := c.repository.UploadSessionRepo.FindByID(uploadID)
+	if err != nil {
+		c.infra.Logger.ErrorWithContextf(ctx, err, "[Upload Consumer] Failed to load session %s: %v", uploadID, err)
+		return composeRetry
+	}
+	bucket, err := c.repository.BucketRepo.FindByID(bucketID)
+	if err != nil {
+		c.infra.Logger.ErrorWithContextf(ctx, err, "[Upload Consumer] Failed to load bucket %s: %v", bucketID, err)
+		return composeRetry
+	}
+
 	// Create object record in database
 	object := &entity.Object{
 		ID:           uuid.New(),
@@ -140,21 +192,63 @@ func (c *UploadConsumer) handleComposeCompleted(ctx context.Context, msg amqp.De
 		FileHash:     payload.FileHash,
 	}
 
-	if err := c.repository.ObjectRepo.Create(object); err != nil {
+	// Apply the session's conflict mode; a concurrent upload taking the name first means resolving again
+	mode := session.ConflictMode
+	if !conflict.ValidMode(mode) {
+		mode = conflict.Mode(bucket, "")
+	}
+	stored, replaced, err := conflict.New(c.infra, c.repository).Store(ctx, bucket, payload.CustomPath, payload.FileName, mode, object)
+	if errors.Is(err, conflict.ErrNameTaken) {
+		c.rejectUpload(ctx, bucket, uploadID, payload, urlPart)
+		return composeDone
+	}
+	if err != nil {
 		c.infra.Logger.ErrorWithContextf(ctx, err, "[Upload Consumer] Failed to save object to database")
 		c.updateSessionStatus(uploadID, entity.UploadStatusFailed)
-		_ = msg.Nack(false, true)
-		return
+		recordAudit(ctx, c.infra, c.repository, payload.UserID, &entity.AuditLog{
+			Action:     entity.AuditActionObjectCreate,
+			TargetType: entity.AuditTargetUploadSession,
+			TargetID:   uploadID.String(),
+			TargetName: path.Join(payload.CustomPath, payload.FileName),
+		}, err)
+		return composeRetry
 	}
+	object = stored
 
-	// Mark upload as completed
-	c.updateSessionStatus(uploadID, entity.UploadStatusCompleted)
+	// Mark upload as completed, or hand archives to the extraction worker;
+	// either way its bytes move from the session reservation to committed usage
+	if !c.queueExtraction(ctx, session, object) {
+		c.updateSessionStatus(uploadID, entity.UploadStatusCompleted)
+		session.Status = entity.UploadStatusCompleted
+	}
+
+	if ownerID, err := uuid.Parse(payload.UserID); err == nil {
+		quotas, err := c.repository.StorageQuotaRepo.AddUsage(ownerID, bucketID, payload.FileSize, 1)
+		if err != nil {
+			c.infra.Logger.ErrorWithContextf(ctx, err, "[Upload Consumer] Failed to add quota usage for upload %s: %v", uploadID, err)
+		} else {
+			quotawarn.Notify(ctx, c.infra, c.repository, quotas)
+		}
+		recordUsage(ctx, c.infra, c.repository, ownerID, bucketID, entity.MetricChunkedUploadCount, 1)
+	}
+
+	audit := &entity.AuditLog{
+		Action:     entity.AuditActionObjectCreate,
+		TargetType: entity.AuditTargetObject,
+		TargetID:   object.ID.String(),
+		TargetName: path.Join(object.ParentPath, object.OriginName),
+	}
+	audit.After, _ = json.Marshal(object)
+	if replaced != nil {
+		audit.Before, _ = json.Marshal(replaced)
+	}
+	recordAudit(ctx, c.infra, c.repository, payload.UserID, audit, nil)
+	publishUploadEvent(ctx, c.infra, c.repository, uploadID, infra.UploadEventForStatus(session.Status), object.ID.String(), "")
 
 	c.infra.Logger.InfoWithContextf(ctx, "[Upload Consumer] Successfully completed upload %s, object %s created (hash: %s)",
 		uploadID, object.ID, payload.FileHash)
 
-	// Acknowledge the message
-	_ = msg.Ack(false)
+	return composeDone
 }
 
 func (c *UploadConsumer) updateSessionStatus(uploadID uuid.UUID, status entity.UploadStatus) {
@@ -162,3 +256,46 @@ func (c *UploadConsumer) updateSessionStatus(uploadID uuid.UUID, status entity.U
 		c.infra.Logger.WarningWithContextf(context.Background(), "[Upload Consumer] Failed to update session status: %v", err)
 	}
 }
+
+// rejectUpload fails a session whose name was taken under reject mode and removes the composed
+// file, unless an identical file already stored in the folder shares its key
+func (c *UploadConsumer) rejectUpload(ctx context.Context, bucket *entity.Bucket, uploadID uuid.UUID, payload produce.ComposeCompletedMessage, urlPart string) {
+	name := path.Join(payload.CustomPath, payload.FileName)
+	c.infra.Logger.WarningWithContextf(ctx, "[Upload Consumer] Rejected upload %s: '%s' already exists", uploadID, name)
+	if err := c.repository.UploadSessionRepo.MarkFailed(uploadID, fmt.Sprintf("an object named '%s' already exists", name)); err != nil {
+		c.infra.Logger.WarningWithContextf(ctx, "[Upload Consumer] Failed to update session status: %v", err)
+	}
+
+	key := path.Join(payload.CustomPath, urlPart)
+	_, err := c.repository.BlobRepo.FindByKey(bucket.ID, key)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if err := c.infra.Minio.DeleteObject(ctx, bucket.Name, key); err != nil {
+			c.infra.Logger.ErrorWithContextf(ctx, err, "[Upload Consumer] Failed to remove rejected upload '%s': %v", key, err)
+		}
+	} else if err != nil {
+		c.infra.Logger.ErrorWithContextf(ctx, err, "[Upload Consumer] Failed to check references of '%s': %v", key, err)
+	}
+
+	recordAudit(ctx, c.infra, c.repository, payload.UserID, &entity.AuditLog{
+		Action:     entity.AuditActionObjectCreate,
+		TargetType: entity.AuditTargetUploadSession,
+		TargetID:   uploadID.String(),
+		TargetName: name,
+	}, conflict.ErrNameTaken)
+	publishUploadEvent(ctx, c.infra, c.repository, uploadID, infra.UploadEventFailed, "", "")
+}
+
+// queueExtraction hands the session to the extraction worker when it is an archive upload.
+// It returns false for plain uploads.
+func (c *UploadConsumer) queueExtraction(ctx context.Context, session *entity.UploadSession, archive *entity.Object) bool {
+	if session.UploadType != entity.UploadTypeArchive {
+		return false
+	}
+
+	status, err := c.infra.Produce.UploadService.QueueArchiveExtraction(ctx, c.repository.UploadSessionRepo, session, archive)
+	if err != nil {
+		c.infra.Logger.ErrorWithContextf(ctx, err, "[Upload Consumer] Failed to hand upload %s to extraction: %v", session.ID, err)
+	}
+	session.Status = status
+	return true
+}
@@ -0,0 +1,30 @@
+package worker
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/tnqbao/gau-cloud-orchestrator/infra"
+	"github.com/tnqbao/gau-cloud-orchestrator/repository"
+)
+
+// publishUploadEvent tells the API replicas streaming the upload's bucket about a step of the upload,
+// describing the session as it is now stored. A final step also queues the session's callback.
+func publishUploadEvent(ctx context.Context, inf *infra.Infra, repo *repository.Repository, uploadID uuid.UUID, eventType, objectID, errMessage string) {
+	session, err := repo.UploadSessionRepo.FindByID(uploadID)
+	if err != nil {
+		inf.Logger.WarningWithContextf(ctx, "[Upload Events] Failed to load upload %s for a %s event: %v", uploadID, eventType, err)
+		return
+	}
+
+	event := infra.NewUploadEvent(eventType, session)
+	event.ObjectID = objectID
+	event.Error = errMessage
+	if event.Error == "" {
+		event.Error = session.ErrorMessage
+	}
+	if err := inf.UploadEvents.Publish(ctx, event); err != nil {
+		inf.Logger.WarningWithContextf(ctx, "[Upload Events] Failed to publish %s event of upload %s: %v", eventType, uploadID, err)
+	}
+	inf.QueueUploadCallback(ctx, session, event)
+}
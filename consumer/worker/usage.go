@@ -0,0 +1,109 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tnqbao/gau-cloud-orchestrator/entity"
+	"github.com/tnqbao/gau-cloud-orchestrator/infra"
+	"github.com/tnqbao/gau-cloud-orchestrator/repository"
+)
+
+const usageSampleLockPrefix = "usage:sample:"
+
+// recordUsage writes one usage sample for a bucket. The consumer records few samples,
+// so they are written directly instead of going through the HTTP usage meter.
+func recordUsage(ctx context.Context, inf *infra.Infra, repo *repository.Repository, ownerID, bucketID uuid.UUID, metricType string, value float64) {
+	metric := entity.Metric{
+		ID:         uuid.New(),
+		OwnerID:    ownerID,
+		EntityType: entity.MetricEntityBucket,
+		EntityID:   bucketID,
+		MetricType: metricType,
+		Value:      value,
+		Timestamp:  time.Now(),
+	}
+	if err := repo.MetricRepo.CreateBatch([]entity.Metric{metric}); err != nil {
+		inf.Logger.ErrorWithContextf(ctx, err, "[Usage] Failed to record %s for bucket %s: %v", metricType, bucketID, err)
+	}
+}
+
+// StoredBytesSampler records the stored bytes of every bucket once per interval
+type StoredBytesSampler struct {
+	infra      *infra.Infra
+	repository *repository.Repository
+	interval   time.Duration
+}
+
+func NewStoredBytesSampler(infra *infra.Infra, repo *repository.Repository, interval time.Duration) *StoredBytesSampler {
+	return &StoredBytesSampler{
+		infra:      infra,
+		repository: repo,
+		interval:   interval,
+	}
+}
+
+// Start samples immediately and then on every tick until ctx is cancelled
+func (s *StoredBytesSampler) Start(ctx context.Context) {
+	spawn(func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			s.sample(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+
+	s.infra.Logger.InfoWithContextf(ctx, "[Usage] Stored bytes sampler started, interval %s", s.interval)
+}
+
+// sample takes one sample per interval slot. The slot lock keeps replicas of the
+// consumer from writing duplicate samples, which would skew the averages.
+func (s *StoredBytesSampler) sample(ctx context.Context) {
+	slot := time.Now().Unix() / int64(s.interval.Seconds())
+	lockKey := fmt.Sprintf("%s%d", usageSampleLockPrefix, slot)
+	acquired, err := s.infra.Redis.SetNX(ctx, lockKey, "1", s.interval)
+	if err != nil {
+		s.infra.Logger.ErrorWithContextf(ctx, err, "[Usage] Failed to acquire sample lock: %v", err)
+		return
+	}
+	if !acquired {
+		return
+	}
+
+	buckets, err := s.repository.MetricRepo.StoredBytesByBucket()
+	if err != nil {
+		s.infra.Logger.ErrorWithContextf(ctx, err, "[Usage] Failed to read stored bytes: %v", err)
+		return
+	}
+
+	now := time.Now()
+	metrics := make([]entity.Metric, 0, len(buckets))
+	for _, bucket := range buckets {
+		metrics = append(metrics, entity.Metric{
+			ID:         uuid.New(),
+			OwnerID:    bucket.OwnerID,
+			EntityType: entity.MetricEntityBucket,
+			EntityID:   bucket.BucketID,
+			MetricType: entity.MetricStoredBytes,
+			Value:      float64(bucket.Bytes),
+			Timestamp:  now,
+		})
+	}
+
+	if err := s.repository.MetricRepo.CreateBatch(metrics); err != nil {
+		s.infra.Logger.ErrorWithContextf(ctx, err, "[Usage] Failed to record stored bytes: %v", err)
+		// Let another replica (or the next tick) retry this slot
+		_ = s.infra.Redis.Delete(ctx, lockKey)
+		return
+	}
+
+	s.infra.Logger.InfoWithContextf(ctx, "[Usage] Sampled stored bytes of %d buckets", len(metrics))
+}
@@ -0,0 +1,93 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+// AuditResult is the outcome of an audited operation
+type AuditResult string
+
+const (
+	AuditResultSuccess AuditResult = "success"
+	AuditResultFailure AuditResult = "failure"
+	AuditResultDenied  AuditResult = "denied"
+)
+
+// Actor types recorded in the audit log
+const (
+	AuditActorUser    = "user"
+	AuditActorIAMUser = "iam_user"
+	AuditActorSystem  = "system"
+)
+
+// Audited actions, in "<resource>.<verb>" form
+const (
+	AuditActionBucketCreate         = "bucket.create"
+	AuditActionBucketDelete         = "bucket.delete"
+	AuditActionBucketPurge          = "bucket.purge"
+	AuditActionBucketAccessUpdate   = "bucket.access.update"
+	AuditActionBucketConflictUpdate = "bucket.conflict.update"
+
+	AuditActionIAMCreate            = "iam.create"
+	AuditActionIAMDelete            = "iam.delete"
+	AuditActionIAMCredentialsUpdate = "iam.credentials.update"
+	AuditActionIAMPolicyUpdate      = "iam.policy.update"
+
+	AuditActionObjectUpload     = "object.upload"
+	AuditActionObjectCreate     = "object.create"
+	AuditActionObjectDelete     = "object.delete"
+	AuditActionObjectPathDelete = "object.path.delete"
+	AuditActionObjectPurge      = "object.purge"
+	AuditActionObjectCopy       = "object.copy"
+	AuditActionObjectMove       = "object.move"
+	AuditActionObjectRename     = "object.rename"
+
+	AuditActionFolderCreate = "folder.create"
+	AuditActionFolderCopy   = "folder.copy"
+	AuditActionFolderMove   = "folder.move"
+	AuditActionFolderRename = "folder.rename"
+
+	AuditActionUploadInit     = "upload.init"
+	AuditActionUploadComplete = "upload.complete"
+	AuditActionUploadAbort    = "upload.abort"
+	AuditActionUploadExtend   = "upload.extend"
+	AuditActionUploadExtract  = "upload.extract"
+
+	AuditActionQuotaUpdate = "quota.update"
+)
+
+// Audit target types
+const (
+	AuditTargetBucket        = "bucket"
+	AuditTargetIAMUser       = "iam_user"
+	AuditTargetObject        = "object"
+	AuditTargetPath          = "path"
+	AuditTargetUploadSession = "upload_session"
+	AuditTargetQuota         = "quota"
+	AuditTargetFolder        = "folder"
+)
+
+// AuditLog is an append-only record of a mutating operation.
+// OwnerID is the account whose resources were affected and scopes who can read the entry.
+type AuditLog struct {
+	ID         uuid.UUID      `json:"id" gorm:"type:uuid;primaryKey"`
+	OwnerID    uuid.UUID      `json:"owner_id" gorm:"type:uuid;not null;index"`
+	ActorID    *uuid.UUID     `json:"actor_id,omitempty" gorm:"type:uuid;index"`
+	ActorType  string         `json:"actor_type" gorm:"type:varchar(32);not null"`
+	AuthMethod string         `json:"auth_method,omitempty" gorm:"type:varchar(16)"`
+	IAMUserID  *uuid.UUID     `json:"iam_user_id,omitempty" gorm:"type:uuid;index"`
+	Action     string         `json:"action" gorm:"type:varchar(64);not null;index"`
+	TargetType string         `json:"target_type" gorm:"type:varchar(32);not null"`
+	TargetID   string         `json:"target_id,omitempty" gorm:"type:varchar(255)"`
+	TargetName string         `json:"target_name,omitempty" gorm:"type:varchar(1024)"`
+	RequestID  string         `json:"request_id,omitempty" gorm:"type:varchar(64)"`
+	IPAddress  string         `json:"ip_address,omitempty" gorm:"type:varchar(64)"`
+	Result     AuditResult    `json:"result" gorm:"type:varchar(16);not null"`
+	Error      string         `json:"error,omitempty" gorm:"type:text"`
+	Before     datatypes.JSON `json:"before,omitempty" gorm:"type:jsonb"`
+	After      datatypes.JSON `json:"after,omitempty" gorm:"type:jsonb"`
+	CreatedAt  time.Time      `json:"created_at" gorm:"not null;autoCreateTime;index"`
+}
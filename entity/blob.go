@@ -0,0 +1,46 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Blob is a stored file in MinIO shared by every object whose storage key points at it.
+// A bucket keeps one blob per content hash: an upload of content the bucket already stores,
+// in any folder and under any name, adds a reference to that blob and the copy it wrote is
+// removed. Content without a hash is a blob of its own key. The file is only deleted
+// from storage once its last reference is gone.
+type Blob struct {
+	ID         uuid.UUID `json:"id" gorm:"type:uuid;primaryKey"`
+	BucketID   uuid.UUID `json:"bucket_id" gorm:"type:uuid;not null;uniqueIndex:uq_blobs_bucket_key,priority:1;uniqueIndex:uq_blobs_bucket_hash,priority:1,where:file_hash <> ''"`
+	StorageKey string    `json:"storage_key" gorm:"type:varchar(1024);not null;uniqueIndex:uq_blobs_bucket_key,priority:2"`
+	FileHash   string    `json:"file_hash" gorm:"type:varchar(255);uniqueIndex:uq_blobs_bucket_hash,priority:2,where:file_hash <> ''"`
+	Size       int64     `json:"size" gorm:"not null"`
+	RefCount   int64     `json:"ref_count" gorm:"not null;default:0"` // objects stored under the key
+	CreatedAt  time.Time `json:"created_at" gorm:"not null;autoCreateTime"`
+	UpdatedAt  time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+
+	Bucket *Bucket `json:"-" gorm:"foreignKey:BucketID;constraint:OnDelete:CASCADE"`
+}
+
+// OrphanedBlobKey is the file of a duplicate blob folded into the blob sharing its content,
+// waiting to be deleted from storage
+type OrphanedBlobKey struct {
+	BucketID   uuid.UUID `gorm:"type:uuid;primaryKey"`
+	StorageKey string    `gorm:"type:varchar(1024);primaryKey"`
+	CreatedAt  time.Time `gorm:"not null;autoCreateTime"`
+
+	Bucket *Bucket `gorm:"foreignKey:BucketID;constraint:OnDelete:CASCADE"`
+}
+
+// DedupReport summarizes how much storage shared blobs save
+type DedupReport struct {
+	Objects      int64   `json:"objects"`
+	LogicalBytes int64   `json:"logical_bytes"` // sum of object sizes
+	Blobs        int64   `json:"blobs"`
+	StoredBytes  int64   `json:"stored_bytes"` // sum of blob sizes
+	SharedBlobs  int64   `json:"shared_blobs"` // blobs referenced by more than one object
+	SavedBytes   int64   `json:"saved_bytes"`  // LogicalBytes - StoredBytes
+	SavedPercent float64 `json:"saved_percent"`
+}
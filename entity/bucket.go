@@ -2,11 +2,21 @@ package entity
 
 import "github.com/google/uuid"
 
+// Conflict modes decide what an upload does when its name is already taken in the folder
+const (
+	ConflictOverwrite = "overwrite" // replace the existing object
+	ConflictRename    = "rename"    // store the upload as "name (n).ext"
+	ConflictReject    = "reject"    // fail the upload
+	ConflictVersion   = "version"   // replace the existing object and keep its content as a version
+)
+
 type Bucket struct {
 	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey"`
 	Name      string    `json:"name" binding:"required,min=3,max=63" gorm:"uniqueIndex;not null"`
 	Region    string    `json:"region" binding:"required" gorm:"not null"`
 	CreatedAt string    `json:"created_at" gorm:"not null"`
 	OwnerID   uuid.UUID `json:"owner_id" binding:"required" gorm:"type:uuid;not null;index"`
-	Objects   []Object  `json:"objects,omitempty" gorm:"foreignKey:BucketID;constraint:OnDelete:CASCADE"`
+	// ConflictMode applies to uploads that do not choose one
+	ConflictMode string   `json:"conflict_mode" gorm:"type:varchar(16);not null;default:'rename'"`
+	Objects      []Object `json:"objects,omitempty" gorm:"foreignKey:BucketID;constraint:OnDelete:CASCADE"`
 }
@@ -0,0 +1,24 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Folder is a materialized node of a bucket's folder hierarchy. Folders are created
+// implicitly by uploads or explicitly (empty folders), and carry the totals of every
+// object stored below them.
+type Folder struct {
+	ID          uuid.UUID `json:"id" gorm:"type:uuid;primaryKey"`
+	BucketID    uuid.UUID `json:"bucket_id" gorm:"type:uuid;not null;uniqueIndex:idx_folders_bucket_path;index:idx_folders_bucket_parent_name,priority:1"`
+	Path        string    `json:"path" gorm:"type:varchar(1024);not null;uniqueIndex:idx_folders_bucket_path"`                    // full path, e.g. "photos/2024"
+	ParentPath  string    `json:"parent_path" gorm:"type:varchar(1024);not null;index:idx_folders_bucket_parent_name,priority:2"` // "" for top-level folders
+	Name        string    `json:"name" gorm:"type:varchar(512);not null;index:idx_folders_bucket_parent_name,priority:3"`
+	ObjectCount int64     `json:"object_count" gorm:"not null;default:0"` // objects in this folder and all subfolders
+	Size        int64     `json:"size" gorm:"not null;default:0"`         // bytes in this folder and all subfolders
+	CreatedAt   time.Time `json:"created_at" gorm:"not null;autoCreateTime"`
+	UpdatedAt   time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+
+	Bucket *Bucket `json:"bucket,omitempty" gorm:"foreignKey:BucketID;constraint:OnDelete:CASCADE"`
+}
@@ -1,12 +1,42 @@
 package entity
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Metric entity types
+const (
+	MetricEntityBucket = "Bucket"
+)
+
+// Usage metric types recorded against a bucket
+const (
+	MetricStoredBytes        = "StoredBytes"        // sampled periodically, averaged when aggregated
+	MetricUploadBytes        = "UploadBytes"        // bytes received by direct and chunked uploads
+	MetricDownloadBytes      = "DownloadBytes"      // bytes streamed to clients
+	MetricRequestCount       = "RequestCount"       // requests, broken down by Operation
+	MetricChunkedUploadCount = "ChunkedUploadCount" // chunked uploads that completed
+)
 
 type Metric struct {
 	ID         uuid.UUID `json:"id" gorm:"type:uuid;primaryKey"`
-	EntityType string    `json:"entity_type" binding:"required" gorm:"not null;index:idx_entity"` // e.g., "Function", "Stack", "StackResource", "Object"
+	OwnerID    uuid.UUID `json:"owner_id" gorm:"type:uuid;not null;index"`                        // account the usage is charged to
+	EntityType string    `json:"entity_type" binding:"required" gorm:"not null;index:idx_entity"` // e.g., "Function", "Stack", "StackResource", "Bucket"
 	EntityID   uuid.UUID `json:"entity_id" binding:"required" gorm:"type:uuid;not null;index:idx_entity"`
 	MetricType string    `json:"metric_type" binding:"required" gorm:"not null;index"` // e.g., "CPUUtilization", "MemoryUsage", "RequestCount"
+	Operation  string    `json:"operation,omitempty" gorm:"type:varchar(64)"`          // e.g., "UploadObject" for RequestCount
 	Value      float64   `json:"value" binding:"required,min=0" gorm:"not null"`
-	Timestamp  string    `json:"timestamp" binding:"required" gorm:"not null;index"`
+	Timestamp  time.Time `json:"timestamp" binding:"required" gorm:"not null;index"`
+}
+
+// UsageRow is one aggregated line of a usage report
+type UsageRow struct {
+	Period     time.Time `json:"period"`
+	BucketID   uuid.UUID `json:"bucket_id"`
+	BucketName string    `json:"bucket_name,omitempty"`
+	MetricType string    `json:"metric_type"`
+	Operation  string    `json:"operation,omitempty"`
+	Value      float64   `json:"value"`
 }
@@ -1,6 +1,7 @@
 package entity
 
 import (
+	"path"
 	"time"
 
 	"github.com/google/uuid"
@@ -8,15 +9,32 @@ import (
 
 type Object struct {
 	ID           uuid.UUID `json:"id" gorm:"type:uuid;primaryKey"`
-	BucketID     uuid.UUID `json:"bucket_id" gorm:"type:uuid;not null;index"`
+	BucketID     uuid.UUID `json:"bucket_id" gorm:"type:uuid;not null;index;index:idx_objects_bucket_path_name,priority:1;uniqueIndex:uq_objects_bucket_path_name,priority:1"`
 	ContentType  string    `json:"content_type" gorm:"type:varchar(255)"`
-	OriginName   string    `json:"origin_name" gorm:"type:varchar(512);not null"`
-	ParentPath   string    `json:"parent_path" gorm:"type:varchar(1024)"`
+	OriginName   string    `json:"origin_name" gorm:"type:varchar(512);not null;index:idx_objects_bucket_path_name,priority:3;uniqueIndex:uq_objects_bucket_path_name,priority:3"`
+	ParentPath   string    `json:"parent_path" gorm:"type:varchar(1024);not null;index:idx_objects_bucket_path_name,priority:2;uniqueIndex:uq_objects_bucket_path_name,priority:2"`
 	CreatedAt    time.Time `json:"created_at" gorm:"not null;autoCreateTime"`
 	LastModified time.Time `json:"last_modified" gorm:"autoUpdateTime"`
 	Size         int64     `json:"size" gorm:"not null"`
 	URL          string    `json:"url" gorm:"type:varchar(1024);not null"` // hash.ext format
 	FileHash     string    `json:"file_hash" gorm:"type:varchar(255);index"`
+	Version      int       `json:"version" gorm:"not null;default:1"`     // incremented when the content is replaced
+	BlobKey      string    `json:"storage_key" gorm:"type:varchar(1024)"` // key of the blob holding the content, set when stored
 
 	Bucket *Bucket `json:"bucket,omitempty" gorm:"foreignKey:BucketID;constraint:OnDelete:CASCADE"`
 }
+
+// StorageKey is the key of the object's content in its MinIO bucket. A stored object points at the
+// blob of its bucket holding the content, which may lie in another folder when the content is shared;
+// until then it is the key the content was written to: <parent_path>/<url>
+func (o *Object) StorageKey() string {
+	if o.BlobKey != "" {
+		return o.BlobKey
+	}
+	return o.WrittenKey()
+}
+
+// WrittenKey is the key an upload of the object writes its content to: <parent_path>/<url>
+func (o *Object) WrittenKey() string {
+	return path.Join(o.ParentPath, o.URL)
+}
@@ -0,0 +1,31 @@
+package entity
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ObjectVersion is a previous content of an object, kept when an upload replaces it in
+// version conflict mode. Its blob is copied to its own key so later uploads cannot overwrite it.
+type ObjectVersion struct {
+	ID          uuid.UUID `json:"id" gorm:"type:uuid;primaryKey"`
+	ObjectID    uuid.UUID `json:"object_id" gorm:"type:uuid;not null;uniqueIndex:uq_object_versions_object_version,priority:1"`
+	BucketID    uuid.UUID `json:"bucket_id" gorm:"type:uuid;not null;index"`
+	Version     int       `json:"version" gorm:"not null;uniqueIndex:uq_object_versions_object_version,priority:2"`
+	ContentType string    `json:"content_type" gorm:"type:varchar(255)"`
+	Size        int64     `json:"size" gorm:"not null"`
+	StorageKey  string    `json:"-" gorm:"type:varchar(1024);not null"`
+	FileHash    string    `json:"file_hash" gorm:"type:varchar(255)"`
+	CreatedAt   time.Time `json:"created_at" gorm:"not null"`  // when this content was uploaded
+	ReplacedAt  time.Time `json:"replaced_at" gorm:"not null"` // when a newer upload replaced it
+
+	Object *Object `json:"-" gorm:"foreignKey:ObjectID;constraint:OnDelete:CASCADE"`
+	Bucket *Bucket `json:"-" gorm:"foreignKey:BucketID;constraint:OnDelete:CASCADE"`
+}
+
+// VersionStorageKey is the key a replaced content of objectID is kept under
+func VersionStorageKey(objectID uuid.UUID, version int, url string) string {
+	return fmt.Sprintf(".versions/%s/%d/%s", objectID, version, url)
+}
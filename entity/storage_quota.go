@@ -0,0 +1,77 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// QuotaScope is the level a storage quota applies to
+type QuotaScope string
+
+const (
+	QuotaScopeAccount QuotaScope = "account"
+	QuotaScopeBucket  QuotaScope = "bucket"
+)
+
+// Usage thresholds, in percent of a limit, at which the owner is warned
+const (
+	QuotaWarningPercent  = 80
+	QuotaExceededPercent = 100
+)
+
+// QuotaLimits are the configurable limits of a quota. Zero means unlimited.
+type QuotaLimits struct {
+	MaxBytes      int64 `json:"max_bytes" gorm:"not null;default:0"`
+	MaxObjects    int64 `json:"max_objects" gorm:"not null;default:0"`
+	MaxObjectSize int64 `json:"max_object_size" gorm:"not null;default:0"`
+}
+
+// StorageQuota holds the limits and committed usage of an account or a bucket.
+// Bytes held by active chunked upload sessions are not stored here; they are
+// summed from upload_sessions when a reservation is checked.
+type StorageQuota struct {
+	ID       uuid.UUID  `json:"id" gorm:"type:uuid;primaryKey"`
+	OwnerID  uuid.UUID  `json:"owner_id" gorm:"type:uuid;not null;index"`
+	BucketID *uuid.UUID `json:"bucket_id,omitempty" gorm:"type:uuid;index"`
+	Scope    QuotaScope `json:"scope" gorm:"type:varchar(16);not null"`
+	QuotaLimits
+
+	UsedBytes     int64  `json:"used_bytes" gorm:"not null;default:0"`
+	ObjectCount   int64  `json:"object_count" gorm:"not null;default:0"`
+	NotifyEmail   string `json:"notify_email,omitempty" gorm:"type:varchar(255)"`
+	WarnedPercent int    `json:"-" gorm:"not null;default:0"` // highest threshold already warned about
+
+	// Filled in when reading usage, not persisted
+	ReservedBytes   int64 `json:"reserved_bytes" gorm:"-"`
+	ReservedObjects int64 `json:"reserved_objects" gorm:"-"`
+
+	CreatedAt time.Time `json:"created_at" gorm:"not null;autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// UsagePercent returns the highest committed usage across the byte and object limits
+func (q *StorageQuota) UsagePercent() int {
+	percent := 0
+	if q.MaxBytes > 0 {
+		percent = int(q.UsedBytes * 100 / q.MaxBytes)
+	}
+	if q.MaxObjects > 0 {
+		if p := int(q.ObjectCount * 100 / q.MaxObjects); p > percent {
+			percent = p
+		}
+	}
+	return percent
+}
+
+// WarningPercent returns the warning threshold the current usage has reached, or 0
+func (q *StorageQuota) WarningPercent() int {
+	switch percent := q.UsagePercent(); {
+	case percent >= QuotaExceededPercent:
+		return QuotaExceededPercent
+	case percent >= QuotaWarningPercent:
+		return QuotaWarningPercent
+	default:
+		return 0
+	}
+}
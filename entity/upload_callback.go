@@ -0,0 +1,21 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UploadCallbackDelivery is one attempt to POST the result of an upload to its callback URL
+type UploadCallbackDelivery struct {
+	ID         uuid.UUID `json:"id" gorm:"type:uuid;primaryKey"`
+	UploadID   uuid.UUID `json:"upload_id" gorm:"type:uuid;not null;index"`
+	Event      string    `json:"event" gorm:"type:varchar(32);not null"`
+	Attempt    int       `json:"attempt" gorm:"not null"`
+	URL        string    `json:"url" gorm:"type:varchar(2048);not null"`
+	StatusCode int       `json:"status_code"` // 0 when no response was received
+	Success    bool      `json:"success"`
+	Error      string    `json:"error,omitempty" gorm:"type:text"`
+	DurationMs int64     `json:"duration_ms"`
+	CreatedAt  time.Time `json:"created_at" gorm:"not null;autoCreateTime"`
+}
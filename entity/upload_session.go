@@ -16,6 +16,23 @@ const (
 	UploadStatusProcessing UploadStatus = "PROCESSING"
 	UploadStatusFailed     UploadStatus = "FAILED"
 	UploadStatusExpired    UploadStatus = "EXPIRED"
+	UploadStatusExtracting UploadStatus = "EXTRACTING"
+)
+
+// ChunkBucket is the bucket the upload-service stores the chunks of every session in
+const ChunkBucket = "pending"
+
+// Upload types of a session
+const (
+	UploadTypeFile    = "file"    // stored as a single object
+	UploadTypeArchive = "archive" // stored, then extracted into ExtractPath
+)
+
+// Upload modes of a session
+const (
+	UploadModeProxy  = "proxy"  // chunks are sent through the orchestrator and the upload-service
+	UploadModeDirect = "direct" // parts are PUT straight to MinIO on presigned multipart URLs
+	UploadModeTus    = "tus"    // bytes are appended through the tus protocol, stored as chunks like proxy uploads
 )
 
 // UploadSession represents a chunked upload session
@@ -34,6 +51,17 @@ type UploadSession struct {
 	TempBucket     string       `json:"temp_bucket" gorm:"type:varchar(255);not null"`
 	TempPrefix     string       `json:"temp_prefix" gorm:"type:varchar(512);not null"`
 	FileHash       string       `json:"file_hash" gorm:"type:varchar(255)"`
+	UploadType     string       `json:"upload_type" gorm:"type:varchar(32);not null;default:'file'"`
+	ExtractPath    string       `json:"extract_path,omitempty" gorm:"type:varchar(1024)"`
+	Extraction     Extraction   `json:"extraction" gorm:"embedded;embeddedPrefix:extract_"`
+	ConflictMode   string       `json:"conflict_mode" gorm:"type:varchar(16);not null;default:'rename'"`
+	ErrorMessage   string       `json:"error_message,omitempty" gorm:"type:text"`
+	UploadMode     string       `json:"upload_mode" gorm:"type:varchar(16);not null;default:'proxy'"`
+	MultipartID    string       `json:"-" gorm:"type:varchar(255)"`                       // MinIO multipart upload of a direct session
+	StorageKey     string       `json:"-" gorm:"type:varchar(1024)"`                      // key a direct session is written to in its bucket
+	UploadOffset   int64        `json:"upload_offset" gorm:"not null;default:0"`          // bytes received by a tus session
+	CallbackURL    string       `json:"callback_url,omitempty" gorm:"type:varchar(2048)"` // POSTed the result once the upload completes or fails
+	CallbackSecret string       `json:"-" gorm:"type:varchar(255)"`                       // signs the callback
 	CreatedAt      time.Time    `json:"created_at" gorm:"not null;autoCreateTime"`
 	UpdatedAt      time.Time    `json:"updated_at" gorm:"autoUpdateTime"`
 	ExpiresAt      time.Time    `json:"expires_at" gorm:"not null;index"`
@@ -41,6 +69,51 @@ type UploadSession struct {
 	Bucket *Bucket `json:"bucket,omitempty" gorm:"foreignKey:BucketID;constraint:OnDelete:CASCADE"`
 }
 
+// ChunkPrefix is the prefix of the session's chunks in ChunkBucket
+func (s *UploadSession) ChunkPrefix() string {
+	return s.ID.String() + "/"
+}
+
+// Active reports whether the session still accepts chunks
+func (s *UploadSession) Active() bool {
+	return (s.Status == UploadStatusInit || s.Status == UploadStatusUploading) && time.Now().Before(s.ExpiresAt)
+}
+
+// Expired reports whether the session ran out of time while it still accepted chunks
+func (s *UploadSession) Expired() bool {
+	if s.Status == UploadStatusExpired {
+		return true
+	}
+	return (s.Status == UploadStatusInit || s.Status == UploadStatusUploading) && time.Now().After(s.ExpiresAt)
+}
+
+// ReservedBytes is the part of the quotas held by the session: its whole file size until it
+// completes, fails or expires
+func (s *UploadSession) ReservedBytes() int64 {
+	if s.Active() || s.Status == UploadStatusProcessing {
+		return s.FileSize
+	}
+	return 0
+}
+
+// UploadedBytes is the offset of a tus session, and is estimated from the number of chunks
+// uploaded for the others
+func (s *UploadSession) UploadedBytes() int64 {
+	if s.UploadMode == UploadModeTus {
+		return s.UploadOffset
+	}
+	return min(int64(s.UploadedChunks)*s.ChunkSize, s.FileSize)
+}
+
+// Extraction reports the progress and result of extracting an archive upload
+type Extraction struct {
+	TotalEntries int    `json:"total_entries"` // 0 until known; tar archives are only counted as they are read
+	Entries      int    `json:"entries"`       // files extracted into objects
+	Bytes        int64  `json:"bytes"`
+	Skipped      int    `json:"skipped"` // links, special files and names already taken
+	Error        string `json:"error,omitempty" gorm:"type:text"`
+}
+
 // ChunkInfo represents information about a single chunk
 type ChunkInfo struct {
 	Index      int       `json:"index"`
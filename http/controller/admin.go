@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tnqbao/gau-cloud-orchestrator/utils"
+)
+
+// AdminSystemStatus returns the full dependency report together with the
+// authorization service circuit breaker state.
+// GET /api/v1/cloud/admin/status
+func (ctrl *Controller) AdminSystemStatus(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	report := ctrl.Infra.Health.Check(ctx)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":             http.StatusOK,
+		"health":             report,
+		"auth_breaker_state": ctrl.Infra.AuthorizationService.Breaker.State(),
+	})
+}
+
+// AdminListUserBuckets lists the buckets of any user
+// GET /api/v1/cloud/admin/users/:user_id/buckets
+func (ctrl *Controller) AdminListUserBuckets(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	userID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Admin] Invalid user_id format: %v", err)
+		utils.JSON400(c, "Invalid user_id format")
+		return
+	}
+
+	ctrl.Infra.Logger.InfoWithContextf(ctx, "[Admin] Listing buckets for user_id: %s (requested by %s)", userID, c.GetString("user_id"))
+	buckets, err := ctrl.Repository.BucketRepo.FindByOwnerID(userID)
+	if err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Admin] Failed to list buckets: %v", err)
+		utils.JSON500(c, "Failed to list buckets")
+		return
+	}
+
+	utils.JSON200(c, gin.H{
+		"buckets": buckets,
+	})
+}
+
+// AdminListUserIAMs lists the IAM users of any user
+// GET /api/v1/cloud/admin/users/:user_id/iam
+func (ctrl *Controller) AdminListUserIAMs(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	userID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Admin] Invalid user_id format: %v", err)
+		utils.JSON400(c, "Invalid user_id format")
+		return
+	}
+
+	ctrl.Infra.Logger.InfoWithContextf(ctx, "[Admin] Listing IAM users for user_id: %s (requested by %s)", userID, c.GetString("user_id"))
+	iamUsers, err := ctrl.Repository.IAMUserRepo.ListByUserID(userID)
+	if err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Admin] Failed to list IAM users: %v", err)
+		utils.JSON500(c, "Failed to list IAM users")
+		return
+	}
+
+	utils.JSON200(c, gin.H{"iam_users": iamUsers})
+}
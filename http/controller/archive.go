@@ -0,0 +1,225 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tnqbao/gau-cloud-orchestrator/entity"
+	"github.com/tnqbao/gau-cloud-orchestrator/http/controller/dto"
+	"github.com/tnqbao/gau-cloud-orchestrator/utils"
+	"gorm.io/gorm"
+)
+
+// archiveBatchSize is the number of objects loaded at a time while streaming a folder
+const archiveBatchSize = 200
+
+// DownloadArchive streams a ZIP or tar.gz of a folder subtree or of selected objects.
+// Entries are read from MinIO one at a time and written straight to the response;
+// folders keep their structure and files their original names.
+// POST /api/v1/cloud/buckets/:id/archive
+func (ctrl *Controller) DownloadArchive(c *gin.Context) {
+	bucket, ok := ctrl.ownedBucket(c, "[Archive]")
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+
+	var req dto.ArchiveRequestDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Archive] Failed to bind JSON: %v", err)
+		utils.JSON400(c, "Invalid request payload")
+		return
+	}
+	if (req.Path == nil) == (len(req.ObjectIDs) == 0) {
+		utils.JSON400(c, "Exactly one of path or object_ids is required")
+		return
+	}
+	format := req.Format
+	if format == "" {
+		format = utils.ArchiveFormatZip
+	}
+
+	var (
+		root    string
+		folders []entity.Folder
+		objects []entity.Object
+		srcPath string
+	)
+	if req.Path != nil {
+		var err error
+		if srcPath, err = normalizeObjectPath(*req.Path); err != nil {
+			utils.JSON400(c, err.Error())
+			return
+		}
+		if srcPath != "" {
+			if _, err := ctrl.Repository.FolderRepo.FindByPath(bucket.ID, srcPath); err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					utils.JSON404(c, "Folder not found")
+					return
+				}
+				ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Archive] Failed to load folder '%s': %v", srcPath, err)
+				utils.JSON500(c, "Failed to load folder")
+				return
+			}
+		}
+		if folders, err = ctrl.Repository.FolderRepo.FindTree(bucket.ID, srcPath); err != nil {
+			ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Archive] Failed to load folders under '%s': %v", srcPath, err)
+			utils.JSON500(c, "Failed to load folder")
+			return
+		}
+		root = path.Base(srcPath)
+		if srcPath == "" {
+			root = bucket.Name
+		}
+	} else {
+		ids := make([]uuid.UUID, 0, len(req.ObjectIDs))
+		seen := make(map[uuid.UUID]bool, len(req.ObjectIDs))
+		for _, raw := range req.ObjectIDs {
+			id := uuid.MustParse(raw) // validated by the binding
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+
+		var err error
+		if objects, err = ctrl.Repository.ObjectRepo.FindByIDsInBucket(bucket.ID, ids); err != nil {
+			ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Archive] Failed to load objects: %v", err)
+			utils.JSON500(c, "Failed to load objects")
+			return
+		}
+		if len(objects) != len(ids) {
+			utils.JSON404(c, "Some objects were not found in this bucket")
+			return
+		}
+		root = bucket.Name
+	}
+
+	c.Header("Content-Type", utils.ArchiveContentType(format))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.%s\"", root, format))
+	c.Status(200)
+
+	counter := &countingWriter{w: c.Writer}
+	archive, _ := utils.NewArchiveWriter(counter, format) // format validated by the binding
+	names := make(map[string]int)
+
+	var streamErr error
+	if req.Path != nil {
+		streamErr = ctrl.streamFolderArchive(c, archive, names, bucket, srcPath, root, folders)
+	} else {
+		streamErr = ctrl.streamObjectsArchive(c, archive, names, bucket, root, objects)
+	}
+	if streamErr == nil {
+		streamErr = archive.Close()
+	}
+	// Charge the bytes actually sent, including partial transfers
+	ctrl.Infra.UsageMeter.Add(bucket.OwnerID, bucket.ID, entity.MetricDownloadBytes, "", float64(counter.n))
+	if streamErr != nil {
+		// Headers are already sent; leaving out the archive trailer makes clients reject the file
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, streamErr, "[Archive] Failed to stream archive of bucket '%s': %v", bucket.Name, streamErr)
+		return
+	}
+
+	ctrl.Infra.Logger.InfoWithContextf(ctx, "[Archive] Streamed %s archive '%s' from bucket '%s' (%d bytes)", format, root, bucket.Name, counter.n)
+}
+
+// streamFolderArchive writes every folder and object at srcPath or below it, rooted at root
+func (ctrl *Controller) streamFolderArchive(c *gin.Context, archive *utils.ArchiveWriter, names map[string]int, bucket *entity.Bucket, srcPath, root string, folders []entity.Folder) error {
+	ctx := c.Request.Context()
+
+	// The bucket root has no folder row; a folder root is the first row of its tree
+	if srcPath == "" {
+		if err := archive.AddDir(root, time.Now()); err != nil {
+			return err
+		}
+	}
+	for i := range folders {
+		if err := archive.AddDir(archiveEntryName(root, srcPath, folders[i].Path), folders[i].CreatedAt); err != nil {
+			return err
+		}
+	}
+
+	afterID := uuid.Nil
+	for {
+		objects, err := ctrl.Repository.ObjectRepo.FindUnderPath(bucket.ID, srcPath, afterID, archiveBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(objects) == 0 {
+			return nil
+		}
+
+		for i := range objects {
+			afterID = objects[i].ID
+			name := archiveEntryName(root, srcPath, path.Join(objects[i].ParentPath, objects[i].OriginName))
+			if err := ctrl.addArchiveObject(c, archive, names, bucket, &objects[i], name); err != nil {
+				return err
+			}
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// streamObjectsArchive writes the selected objects under their full bucket paths, rooted at root
+func (ctrl *Controller) streamObjectsArchive(c *gin.Context, archive *utils.ArchiveWriter, names map[string]int, bucket *entity.Bucket, root string, objects []entity.Object) error {
+	for i := range objects {
+		name := path.Join(root, objects[i].ParentPath, objects[i].OriginName)
+		if err := ctrl.addArchiveObject(c, archive, names, bucket, &objects[i], name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ctrl *Controller) addArchiveObject(c *gin.Context, archive *utils.ArchiveWriter, names map[string]int, bucket *entity.Bucket, object *entity.Object, name string) error {
+	reader, info, err := ctrl.Infra.Minio.GetObject(c.Request.Context(), bucket.Name, object.StorageKey())
+	if err != nil {
+		return fmt.Errorf("object %s: %w", object.ID, err)
+	}
+	defer reader.Close()
+
+	if _, err := archive.AddFile(uniqueArchiveName(names, name), info.Size, object.LastModified, reader); err != nil {
+		return fmt.Errorf("object %s: %w", object.ID, err)
+	}
+	return nil
+}
+
+// archiveEntryName maps p, which is srcPath or below it, to the same position below root
+func archiveEntryName(root, srcPath, p string) string {
+	if srcPath == "" {
+		return path.Join(root, p)
+	}
+	return path.Join(root, strings.TrimPrefix(p, srcPath))
+}
+
+// uniqueArchiveName suffixes repeated entry names as "name (2).ext", "name (3).ext", ...
+func uniqueArchiveName(names map[string]int, name string) string {
+	names[name]++
+	n := names[name]
+	if n == 1 {
+		return name
+	}
+	ext := path.Ext(name)
+	return fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(name, ext), n, ext)
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
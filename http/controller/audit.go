@@ -0,0 +1,289 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tnqbao/gau-cloud-orchestrator/entity"
+	"github.com/tnqbao/gau-cloud-orchestrator/infra"
+	"github.com/tnqbao/gau-cloud-orchestrator/repository"
+	"github.com/tnqbao/gau-cloud-orchestrator/utils"
+)
+
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 500
+	auditExportBatchSize = 500
+)
+
+// auditEvent describes a mutating operation to be recorded in the audit log
+type auditEvent struct {
+	OwnerID    uuid.UUID
+	Action     string
+	TargetType string
+	TargetID   string
+	TargetName string
+	Result     entity.AuditResult // defaults to success, or failure when Err is set
+	Err        error
+	Before     interface{}
+	After      interface{}
+}
+
+// recordAudit appends an audit entry for the current request, taking the
+// actor, auth method, IAM user, request ID and IP from the request context.
+// A failed write is logged but never fails the request.
+func (ctrl *Controller) recordAudit(c *gin.Context, event auditEvent) {
+	ctx := c.Request.Context()
+
+	log := &entity.AuditLog{
+		OwnerID:    event.OwnerID,
+		ActorType:  entity.AuditActorSystem,
+		Action:     event.Action,
+		TargetType: event.TargetType,
+		TargetID:   event.TargetID,
+		TargetName: event.TargetName,
+		RequestID:  infra.RequestIDFromContext(ctx),
+		IPAddress:  c.ClientIP(),
+		Result:     event.Result,
+		Before:     auditSnapshot(event.Before),
+		After:      auditSnapshot(event.After),
+	}
+
+	if identity, ok := infra.RequestIdentityFromContext(ctx); ok {
+		log.AuthMethod = identity.AuthMethod
+		if actorID, err := uuid.Parse(identity.UserID); err == nil {
+			log.ActorID = &actorID
+			log.ActorType = entity.AuditActorUser
+		}
+		if iamUserID, err := uuid.Parse(identity.IAMUserID); err == nil {
+			log.IAMUserID = &iamUserID
+			log.ActorType = entity.AuditActorIAMUser
+		}
+	}
+
+	if event.Err != nil {
+		log.Error = event.Err.Error()
+		if log.Result == "" {
+			log.Result = entity.AuditResultFailure
+		}
+	}
+	if log.Result == "" {
+		log.Result = entity.AuditResultSuccess
+	}
+
+	if err := ctrl.Repository.AuditLogRepo.Create(log); err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Audit] Failed to record %s on %s %s: %v", event.Action, event.TargetType, event.TargetID, err)
+	}
+}
+
+// auditSnapshot marshals a before/after state, returning nil for empty states
+func auditSnapshot(state interface{}) []byte {
+	if state == nil {
+		return nil
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// iamAuditState is the audited view of an IAM user, without the secret key
+func iamAuditState(iamUser *entity.IAMUser) gin.H {
+	return gin.H{
+		"id":         iamUser.ID,
+		"name":       iamUser.Name,
+		"email":      iamUser.Email,
+		"role":       iamUser.Role,
+		"access_key": MaskAccessKey(iamUser.AccessKey),
+	}
+}
+
+// auditObjectName is the display path of an object, e.g. "docs/report.pdf"
+func auditObjectName(parentPath, originName string) string {
+	return path.Join(parentPath, originName)
+}
+
+// ListAuditLogs returns the caller's audit trail, newest first
+// GET /api/v1/cloud/audit?page=1&limit=50&action=&target_type=&target_id=&actor_id=&iam_user_id=&result=&from=&to=
+func (ctrl *Controller) ListAuditLogs(c *gin.Context) {
+	ownerID, ok := ctrl.auditOwnerFromContext(c)
+	if !ok {
+		return
+	}
+	ctrl.listAuditLogs(c, &ownerID)
+}
+
+// ExportAuditLogs streams the caller's audit trail as JSON Lines, oldest first
+// GET /api/v1/cloud/audit/export?<same filters as ListAuditLogs>
+func (ctrl *Controller) ExportAuditLogs(c *gin.Context) {
+	ownerID, ok := ctrl.auditOwnerFromContext(c)
+	if !ok {
+		return
+	}
+	ctrl.exportAuditLogs(c, &ownerID)
+}
+
+// AdminListAuditLogs lists audit entries across all accounts; owner_id is an optional filter
+// GET /api/v1/cloud/admin/audit
+func (ctrl *Controller) AdminListAuditLogs(c *gin.Context) {
+	ownerID, ok := ctrl.optionalAuditOwner(c)
+	if !ok {
+		return
+	}
+	ctrl.listAuditLogs(c, ownerID)
+}
+
+// AdminExportAuditLogs exports audit entries across all accounts as JSON Lines
+// GET /api/v1/cloud/admin/audit/export
+func (ctrl *Controller) AdminExportAuditLogs(c *gin.Context) {
+	ownerID, ok := ctrl.optionalAuditOwner(c)
+	if !ok {
+		return
+	}
+	ctrl.exportAuditLogs(c, ownerID)
+}
+
+func (ctrl *Controller) listAuditLogs(c *gin.Context, ownerID *uuid.UUID) {
+	ctx := c.Request.Context()
+
+	filter, err := parseAuditFilter(c)
+	if err != nil {
+		utils.JSON400(c, err.Error())
+		return
+	}
+	filter.OwnerID = ownerID
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultAuditPageSize)))
+	if limit < 1 {
+		limit = defaultAuditPageSize
+	}
+	if limit > maxAuditPageSize {
+		limit = maxAuditPageSize
+	}
+
+	logs, total, err := ctrl.Repository.AuditLogRepo.List(filter, page, limit)
+	if err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Audit] Failed to list audit logs: %v", err)
+		utils.JSON500(c, "Failed to list audit logs")
+		return
+	}
+
+	utils.JSON200(c, gin.H{
+		"audit_logs": logs,
+		"pagination": gin.H{
+			"page":        page,
+			"limit":       limit,
+			"total":       total,
+			"total_pages": (total + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
+
+func (ctrl *Controller) exportAuditLogs(c *gin.Context, ownerID *uuid.UUID) {
+	ctx := c.Request.Context()
+
+	filter, err := parseAuditFilter(c)
+	if err != nil {
+		utils.JSON400(c, err.Error())
+		return
+	}
+	filter.OwnerID = ownerID
+
+	fileName := fmt.Sprintf("audit-%s.jsonl", time.Now().UTC().Format("20060102T150405Z"))
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, fileName))
+	c.Status(http.StatusOK)
+
+	encoder := json.NewEncoder(c.Writer)
+	exported := 0
+	err = ctrl.Repository.AuditLogRepo.ForEachBatch(filter, auditExportBatchSize, func(logs []entity.AuditLog) error {
+		for i := range logs {
+			if err := encoder.Encode(&logs[i]); err != nil {
+				return err
+			}
+		}
+		exported += len(logs)
+		c.Writer.Flush()
+		return ctx.Err()
+	})
+	if err != nil {
+		// Headers are already sent, so the client sees a truncated file
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Audit] Export aborted after %d entries: %v", exported, err)
+		return
+	}
+
+	ctrl.Infra.Logger.InfoWithContextf(ctx, "[Audit] Exported %d audit entries", exported)
+}
+
+// auditOwnerFromContext returns the account the caller may read audit entries for
+func (ctrl *Controller) auditOwnerFromContext(c *gin.Context) (uuid.UUID, bool) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		utils.JSON401(c, "Unauthorized: user_id not found")
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
+func (ctrl *Controller) optionalAuditOwner(c *gin.Context) (*uuid.UUID, bool) {
+	raw := c.Query("owner_id")
+	if raw == "" {
+		return nil, true
+	}
+	ownerID, err := uuid.Parse(raw)
+	if err != nil {
+		utils.JSON400(c, "Invalid owner_id format")
+		return nil, false
+	}
+	return &ownerID, true
+}
+
+// parseAuditFilter reads the audit filters from the query string. from/to are RFC3339.
+func parseAuditFilter(c *gin.Context) (repository.AuditLogFilter, error) {
+	filter := repository.AuditLogFilter{
+		Action:     c.Query("action"),
+		TargetType: c.Query("target_type"),
+		TargetID:   c.Query("target_id"),
+		Result:     c.Query("result"),
+	}
+
+	for name, dest := range map[string]**uuid.UUID{
+		"actor_id":    &filter.ActorID,
+		"iam_user_id": &filter.IAMUserID,
+	} {
+		if raw := c.Query(name); raw != "" {
+			id, err := uuid.Parse(raw)
+			if err != nil {
+				return filter, errors.New("Invalid " + name + " format")
+			}
+			*dest = &id
+		}
+	}
+
+	for name, dest := range map[string]**time.Time{
+		"from": &filter.From,
+		"to":   &filter.To,
+	} {
+		if raw := c.Query(name); raw != "" {
+			t, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				return filter, errors.New("Invalid " + name + " format, expected RFC3339")
+			}
+			*dest = &t
+		}
+	}
+
+	return filter, nil
+}
@@ -54,16 +54,29 @@ func (ctrl *Controller) CreateBucket(c *gin.Context) {
 	err = ctrl.Infra.Minio.CreateBucket(ctx, req.Name, req.Region)
 	if err != nil {
 		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Bucket] Failed to create bucket on MinIO: %v", err)
+		ctrl.recordAudit(c, auditEvent{
+			OwnerID:    userID,
+			Action:     entity.AuditActionBucketCreate,
+			TargetType: entity.AuditTargetBucket,
+			TargetName: req.Name,
+			Err:        err,
+		})
 		utils.JSON500(c, "Failed to create bucket on MinIO")
 		return
 	}
 
+	conflictMode := req.ConflictMode
+	if conflictMode == "" {
+		conflictMode = entity.ConflictRename
+	}
+
 	bucket := &entity.Bucket{
-		ID:        uuid.New(),
-		Name:      req.Name,
-		Region:    req.Region,
-		CreatedAt: time.Now().Format(time.RFC3339),
-		OwnerID:   userID,
+		ID:           uuid.New(),
+		Name:         req.Name,
+		Region:       req.Region,
+		CreatedAt:    time.Now().Format(time.RFC3339),
+		OwnerID:      userID,
+		ConflictMode: conflictMode,
 	}
 
 	err = ctrl.Repository.BucketRepo.Create(bucket)
@@ -74,6 +87,13 @@ func (ctrl *Controller) CreateBucket(c *gin.Context) {
 			ctrl.Infra.Logger.ErrorWithContextf(ctx, rollbackErr, "[Bucket] Failed to rollback MinIO bucket after database error: %v", rollbackErr)
 		}
 		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Bucket] Failed to create bucket in database: %v", err)
+		ctrl.recordAudit(c, auditEvent{
+			OwnerID:    userID,
+			Action:     entity.AuditActionBucketCreate,
+			TargetType: entity.AuditTargetBucket,
+			TargetName: req.Name,
+			Err:        err,
+		})
 		utils.JSON500(c, "Failed to create bucket in database")
 		return
 	}
@@ -88,6 +108,15 @@ func (ctrl *Controller) CreateBucket(c *gin.Context) {
 		ctrl.Infra.Logger.InfoWithContextf(ctx, "[Bucket] Published update policy message for bucket: %s", req.Name)
 	}
 
+	ctrl.recordAudit(c, auditEvent{
+		OwnerID:    userID,
+		Action:     entity.AuditActionBucketCreate,
+		TargetType: entity.AuditTargetBucket,
+		TargetID:   bucket.ID.String(),
+		TargetName: bucket.Name,
+		After:      bucket,
+	})
+
 	ctrl.Infra.Logger.InfoWithContextf(ctx, "[Bucket] Successfully created bucket: %s", bucket.ID)
 	utils.JSON200(c, gin.H{
 		"message": "Bucket created successfully",
@@ -137,6 +166,14 @@ func (ctrl *Controller) DeleteBucketByID(c *gin.Context) {
 	// Check if the user owns this bucket
 	if bucket.OwnerID != userID {
 		ctrl.Infra.Logger.WarningWithContextf(ctx, "[Bucket] User %s attempted to delete bucket %s owned by %s", userID, bucketID, bucket.OwnerID)
+		ctrl.recordAudit(c, auditEvent{
+			OwnerID:    bucket.OwnerID,
+			Action:     entity.AuditActionBucketDelete,
+			TargetType: entity.AuditTargetBucket,
+			TargetID:   bucketID.String(),
+			TargetName: bucket.Name,
+			Result:     entity.AuditResultDenied,
+		})
 		utils.JSON403(c, "Forbidden: you don't have permission to delete this bucket")
 		return
 	}
@@ -145,6 +182,14 @@ func (ctrl *Controller) DeleteBucketByID(c *gin.Context) {
 	err = ctrl.Repository.BucketRepo.Delete(bucketID)
 	if err != nil {
 		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Bucket] Failed to delete bucket from database: %v", err)
+		ctrl.recordAudit(c, auditEvent{
+			OwnerID:    userID,
+			Action:     entity.AuditActionBucketDelete,
+			TargetType: entity.AuditTargetBucket,
+			TargetID:   bucketID.String(),
+			TargetName: bucket.Name,
+			Err:        err,
+		})
 		utils.JSON500(c, "Failed to delete bucket from database")
 		return
 	}
@@ -160,6 +205,15 @@ func (ctrl *Controller) DeleteBucketByID(c *gin.Context) {
 		ctrl.Infra.Logger.InfoWithContextf(ctx, "[Bucket] Published delete bucket message for bucket: %s", bucket.Name)
 	}
 
+	ctrl.recordAudit(c, auditEvent{
+		OwnerID:    userID,
+		Action:     entity.AuditActionBucketDelete,
+		TargetType: entity.AuditTargetBucket,
+		TargetID:   bucketID.String(),
+		TargetName: bucket.Name,
+		Before:     bucket,
+	})
+
 	ctrl.Infra.Logger.InfoWithContextf(ctx, "[Bucket] Successfully deleted bucket record: %s", bucketID)
 	utils.JSON200(c, gin.H{
 		"message": "Bucket deletion initiated successfully",
@@ -190,6 +244,32 @@ func (ctrl *Controller) ListBuckets(c *gin.Context) {
 		return
 	}
 
+	// An access key only sees the buckets its S3 policy lets it list
+	policy, restricted, err := ctrl.accessKeyPolicy(c)
+	if err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Bucket] Failed to load access key policy: %v", err)
+		utils.JSON500(c, "Failed to list buckets")
+		return
+	}
+	if restricted {
+		listable := make([]entity.Bucket, 0, len(buckets))
+		for _, bucket := range buckets {
+			if policy == nil {
+				break
+			}
+			allowed, err := utils.PolicyAllows(policy, utils.S3ListBucket, bucket.Name)
+			if err != nil {
+				ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Bucket] Failed to evaluate access key policy: %v", err)
+				utils.JSON500(c, "Failed to list buckets")
+				return
+			}
+			if allowed {
+				listable = append(listable, bucket)
+			}
+		}
+		buckets = listable
+	}
+
 	ctrl.Infra.Logger.InfoWithContextf(ctx, "[Bucket] Successfully listed %d buckets for user_id: %s", len(buckets), userID)
 	utils.JSON200(c, gin.H{
 		"buckets": buckets,
@@ -247,6 +327,14 @@ func (ctrl *Controller) UpdateBucketAccess(c *gin.Context) {
 	// Check if the user owns this bucket
 	if bucket.OwnerID != userID {
 		ctrl.Infra.Logger.WarningWithContextf(ctx, "[Bucket] User %s attempted to update bucket %s owned by %s", userID, bucketID, bucket.OwnerID)
+		ctrl.recordAudit(c, auditEvent{
+			OwnerID:    bucket.OwnerID,
+			Action:     entity.AuditActionBucketAccessUpdate,
+			TargetType: entity.AuditTargetBucket,
+			TargetID:   bucketID.String(),
+			TargetName: bucket.Name,
+			Result:     entity.AuditResultDenied,
+		})
 		utils.JSON403(c, "Forbidden: you don't have permission to modify this bucket")
 		return
 	}
@@ -265,14 +353,30 @@ func (ctrl *Controller) UpdateBucketAccess(c *gin.Context) {
 		return
 	}
 
+	// Current access is only needed for the audit trail, so a lookup failure is not fatal
+	previousAccess, _ := ctrl.Infra.Minio.GetBucketPolicy(ctx, bucket.Name)
+	accessEvent := auditEvent{
+		OwnerID:    userID,
+		Action:     entity.AuditActionBucketAccessUpdate,
+		TargetType: entity.AuditTargetBucket,
+		TargetID:   bucketID.String(),
+		TargetName: bucket.Name,
+		Before:     gin.H{"access": previousAccess},
+		After:      gin.H{"access": req.Access},
+	}
+
 	// Update bucket access policy in MinIO
 	err = ctrl.Infra.Minio.SetBucketPolicy(ctx, bucket.Name, req.Access)
 	if err != nil {
 		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Bucket] Failed to update bucket access policy: %v", err)
+		accessEvent.Err = err
+		ctrl.recordAudit(c, accessEvent)
 		utils.JSON500(c, "Failed to update bucket access policy")
 		return
 	}
 
+	ctrl.recordAudit(c, accessEvent)
+
 	ctrl.Infra.Logger.InfoWithContextf(ctx, "[Bucket] Successfully updated bucket %s access to %s", bucket.Name, req.Access)
 	utils.JSON200(c, gin.H{
 		"message": "Bucket access updated successfully",
@@ -342,3 +446,48 @@ func (ctrl *Controller) GetBucketAccess(c *gin.Context) {
 		"access": access,
 	})
 }
+
+// UpdateBucketConflictMode sets what uploads into the bucket do when the name is already taken,
+// unless an upload chooses otherwise
+// PUT /api/v1/cloud/buckets/:id/conflict
+func (ctrl *Controller) UpdateBucketConflictMode(c *gin.Context) {
+	bucket, ok := ctrl.ownedBucket(c, "[Bucket]")
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+
+	var req dto.UpdateBucketConflictModeRequestDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Bucket] Failed to bind JSON: %v", err)
+		utils.JSON400(c, "Invalid request payload. Mode must be 'overwrite', 'rename', 'reject' or 'version'")
+		return
+	}
+
+	event := auditEvent{
+		OwnerID:    bucket.OwnerID,
+		Action:     entity.AuditActionBucketConflictUpdate,
+		TargetType: entity.AuditTargetBucket,
+		TargetID:   bucket.ID.String(),
+		TargetName: bucket.Name,
+		Before:     gin.H{"conflict_mode": bucket.ConflictMode},
+		After:      gin.H{"conflict_mode": req.Mode},
+	}
+
+	if err := ctrl.Repository.BucketRepo.UpdateConflictMode(bucket.ID, req.Mode); err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Bucket] Failed to update conflict mode of bucket %s: %v", bucket.Name, err)
+		event.Err = err
+		ctrl.recordAudit(c, event)
+		utils.JSON500(c, "Failed to update conflict mode")
+		return
+	}
+
+	ctrl.recordAudit(c, event)
+
+	ctrl.Infra.Logger.InfoWithContextf(ctx, "[Bucket] Set conflict mode of bucket %s to %s", bucket.Name, req.Mode)
+	utils.JSON200(c, gin.H{
+		"message":       "Bucket conflict mode updated successfully",
+		"bucket":        bucket.Name,
+		"conflict_mode": req.Mode,
+	})
+}
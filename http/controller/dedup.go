@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tnqbao/gau-cloud-orchestrator/utils"
+)
+
+// GetDedupReport reports how much storage the caller's objects save by sharing blobs
+// GET /api/v1/cloud/usage/dedup
+func (ctrl *Controller) GetDedupReport(c *gin.Context) {
+	ownerID, ok := ctrl.auditOwnerFromContext(c)
+	if !ok {
+		return
+	}
+	ctrl.getDedupReport(c, ownerID, uuid.Nil)
+}
+
+// GetBucketDedupReport reports how much storage a bucket saves by sharing blobs
+// GET /api/v1/cloud/buckets/:id/dedup
+func (ctrl *Controller) GetBucketDedupReport(c *gin.Context) {
+	bucket, ok := ctrl.ownedBucket(c, "[Dedup]")
+	if !ok {
+		return
+	}
+	ctrl.getDedupReport(c, bucket.OwnerID, bucket.ID)
+}
+
+func (ctrl *Controller) getDedupReport(c *gin.Context, ownerID, bucketID uuid.UUID) {
+	ctx := c.Request.Context()
+
+	report, err := ctrl.Repository.BlobRepo.DedupReport(ownerID, bucketID)
+	if err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Dedup] Failed to build dedup report for owner %s: %v", ownerID, err)
+		utils.JSON500(c, "Failed to build dedup report")
+		return
+	}
+
+	response := gin.H{
+		"owner_id": ownerID,
+		"report":   report,
+		"saved":    utils.FormatBytes(report.SavedBytes),
+	}
+	if bucketID != uuid.Nil {
+		response["bucket_id"] = bucketID
+	}
+	utils.JSON200(c, response)
+}
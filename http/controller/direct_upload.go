@@ -0,0 +1,367 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/minio/minio-go/v7"
+	"github.com/tnqbao/gau-cloud-orchestrator/conflict"
+	"github.com/tnqbao/gau-cloud-orchestrator/entity"
+	"github.com/tnqbao/gau-cloud-orchestrator/http/controller/dto"
+	"github.com/tnqbao/gau-cloud-orchestrator/infra"
+	"github.com/tnqbao/gau-cloud-orchestrator/quotawarn"
+	"github.com/tnqbao/gau-cloud-orchestrator/utils"
+)
+
+const (
+	// MinDirectPartSize is the smallest part storage accepts, except for the last one (5MB)
+	MinDirectPartSize int64 = 5 * 1024 * 1024
+	// MaxDirectPartSize is the largest part a direct upload may use (512MB)
+	MaxDirectPartSize int64 = 512 * 1024 * 1024
+	// MaxDirectParts is the largest number of parts of a multipart upload
+	MaxDirectParts = 10000
+	// MaxPresignedParts is the largest number of part URLs returned at once
+	MaxPresignedParts = 1000
+	// PresignedPartExpiry is how long a part URL stays valid
+	PresignedPartExpiry = time.Hour
+)
+
+// directPartSize decides the part size of a direct upload: the client's preference within the
+// storage limits, raised when the file would need more than MaxDirectParts parts
+func directPartSize(fileSize, preferred int64) (int64, error) {
+	partSize := DefaultChunkSize
+	if preferred > 0 {
+		partSize = min(max(preferred, MinDirectPartSize), MaxDirectPartSize)
+	}
+	if minSize := (fileSize + MaxDirectParts - 1) / MaxDirectParts; partSize < minSize {
+		partSize = minSize
+	}
+	if partSize > MaxDirectPartSize {
+		return 0, fmt.Errorf("File is too large for a direct upload (maximum %s)", utils.FormatBytes(MaxDirectPartSize*MaxDirectParts))
+	}
+	return partSize, nil
+}
+
+// startDirectUpload opens the multipart upload a direct session writes to. The key is named after
+// the session, so it never collides with another object; the name is resolved on completion.
+func (ctrl *Controller) startDirectUpload(ctx context.Context, bucket *entity.Bucket, session *entity.UploadSession) error {
+	ext := filepath.Ext(session.FileName)
+	if ext == "" {
+		ext = ".bin"
+	}
+	key := path.Join(session.CustomPath, session.ID.String()+ext)
+
+	multipartID, err := ctrl.Infra.Minio.NewMultipartUpload(ctx, bucket.Name, key, session.ContentType)
+	if err != nil {
+		return err
+	}
+	session.StorageKey = key
+	session.MultipartID = multipartID
+	return nil
+}
+
+// abortDirectUpload discards the multipart upload of a direct session and the parts sent so far
+func (ctrl *Controller) abortDirectUpload(ctx context.Context, bucketName string, session *entity.UploadSession) {
+	if session.UploadMode != entity.UploadModeDirect || session.MultipartID == "" {
+		return
+	}
+	if err := ctrl.Infra.Minio.AbortMultipartUpload(ctx, bucketName, session.StorageKey, session.MultipartID); err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Object] Failed to abort multipart upload of session %s: %v", session.ID, err)
+	}
+}
+
+// presignParts returns the URLs of count parts of a direct session starting at part from
+func (ctrl *Controller) presignParts(ctx context.Context, bucket *entity.Bucket, session *entity.UploadSession, from, count int) ([]gin.H, error) {
+	expiry := min(time.Until(session.ExpiresAt), PresignedPartExpiry)
+	if expiry <= 0 {
+		return nil, errors.New("upload session has expired")
+	}
+
+	last := min(from+count-1, session.TotalChunks)
+	parts := make([]gin.H, 0, max(last-from+1, 0))
+	for n := from; n <= last; n++ {
+		u, err := ctrl.Infra.Minio.PresignUploadPart(ctx, bucket.Name, session.StorageKey, session.MultipartID, n, expiry)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, gin.H{
+			"part_number": n,
+			"size":        directPartLength(session, n),
+			"url":         u,
+		})
+	}
+	return parts, nil
+}
+
+// directPartLength is the size part n of a direct session must have; only the last one is shorter
+func directPartLength(session *entity.UploadSession, n int) int64 {
+	if n < session.TotalChunks {
+		return session.ChunkSize
+	}
+	return session.FileSize - int64(session.TotalChunks-1)*session.ChunkSize
+}
+
+// GetDirectUploadParts presigns part URLs of a direct upload and reports the parts storage has received,
+// so a client can resume after its URLs expired
+// GET /api/v1/cloud/buckets/:id/chunked/:upload_id/parts?from=1&count=1000
+func (ctrl *Controller) GetDirectUploadParts(c *gin.Context) {
+	ctx := c.Request.Context()
+	bucket, ok := ctrl.ownedBucket(c, "[Object]")
+	if !ok {
+		return
+	}
+
+	uploadID, err := uuid.Parse(c.Param("upload_id"))
+	if err != nil {
+		utils.JSON400(c, "Invalid upload_id format")
+		return
+	}
+
+	from, err := strconv.Atoi(c.DefaultQuery("from", "1"))
+	if err != nil || from < 1 {
+		utils.JSON400(c, "from must be a part number starting at 1")
+		return
+	}
+	count, err := strconv.Atoi(c.DefaultQuery("count", strconv.Itoa(MaxPresignedParts)))
+	if err != nil || count < 1 {
+		utils.JSON400(c, "count must be a positive number")
+		return
+	}
+	count = min(count, MaxPresignedParts)
+
+	session, err := ctrl.Repository.UploadSessionRepo.FindByIDAndBucketID(uploadID, bucket.ID)
+	if err != nil {
+		utils.JSON404(c, "Upload session not found")
+		return
+	}
+	if session.UploadMode != entity.UploadModeDirect {
+		utils.JSON400(c, "This upload sends its chunks through the chunk endpoint")
+		return
+	}
+	if !session.Active() {
+		utils.JSON400(c, fmt.Sprintf("Upload session is not active, current status: %s", session.Status))
+		return
+	}
+
+	parts, err := ctrl.presignParts(ctx, bucket, session, from, count)
+	if err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Object] Failed to presign parts of upload %s: %v", uploadID, err)
+		utils.JSON500(c, "Failed to presign part URLs")
+		return
+	}
+
+	received, err := ctrl.Infra.Minio.ListUploadedParts(ctx, bucket.Name, session.StorageKey, session.MultipartID)
+	if err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Object] Failed to list parts of upload %s: %v", uploadID, err)
+		utils.JSON500(c, "Failed to list uploaded parts")
+		return
+	}
+	uploaded := make([]gin.H, 0, len(received))
+	for _, p := range received {
+		uploaded = append(uploaded, gin.H{
+			"part_number": p.PartNumber,
+			"etag":        strings.Trim(p.ETag, `"`),
+			"size":        p.Size,
+		})
+	}
+
+	utils.JSON200(c, gin.H{
+		"upload_id":    uploadID.String(),
+		"total_chunks": session.TotalChunks,
+		"chunk_size":   session.ChunkSize,
+		"parts":        parts,
+		"uploaded":     uploaded,
+		"expires_at":   session.ExpiresAt.Format(time.RFC3339),
+	})
+}
+
+// completeDirectUpload checks the declared parts against those storage received, completes the
+// multipart upload and records the object, without going through the upload-service.
+// Direct uploads are not hashed, since their bytes never pass through a service that could do it.
+func (ctrl *Controller) completeDirectUpload(c *gin.Context, bucket *entity.Bucket, session *entity.UploadSession, declared []dto.CompletedPart) {
+	ctx := c.Request.Context()
+	uploadID := session.ID
+
+	if len(declared) != session.TotalChunks {
+		utils.JSON400(c, fmt.Sprintf("Missing parts: expected %d, got %d", session.TotalChunks, len(declared)))
+		return
+	}
+	sort.Slice(declared, func(i, j int) bool { return declared[i].PartNumber < declared[j].PartNumber })
+
+	received, err := ctrl.Infra.Minio.ListUploadedParts(ctx, bucket.Name, session.StorageKey, session.MultipartID)
+	if err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Object] Failed to list parts of upload %s: %v", uploadID, err)
+		utils.JSON500(c, "Failed to list uploaded parts")
+		return
+	}
+	stored := make(map[int]minio.ObjectPart, len(received))
+	for _, p := range received {
+		stored[p.PartNumber] = p
+	}
+
+	parts := make([]minio.CompletePart, 0, len(declared))
+	for i, p := range declared {
+		n := i + 1
+		if p.PartNumber != n {
+			utils.JSON400(c, fmt.Sprintf("Missing part %d", n))
+			return
+		}
+		got, ok := stored[n]
+		if !ok {
+			utils.JSON400(c, fmt.Sprintf("Part %d has not been uploaded", n))
+			return
+		}
+		etag := strings.Trim(p.ETag, `"`)
+		if etag != strings.Trim(got.ETag, `"`) {
+			utils.JSON400(c, fmt.Sprintf("ETag of part %d does not match the uploaded part", n))
+			return
+		}
+		if want := directPartLength(session, n); got.Size != want {
+			utils.JSON400(c, fmt.Sprintf("Part %d has %d bytes, expected %d", n, got.Size, want))
+			return
+		}
+		parts = append(parts, minio.CompletePart{PartNumber: n, ETag: etag})
+	}
+
+	if err := ctrl.Repository.UploadSessionRepo.UpdateStatus(uploadID, entity.UploadStatusProcessing); err != nil {
+		ctrl.Infra.Logger.WarningWithContextf(ctx, "[Object] Failed to update session status: %v", err)
+	}
+	session.Status = entity.UploadStatusProcessing
+	session.UploadedChunks = session.TotalChunks
+	ctrl.publishUploadEvent(ctx, session, infra.NewUploadEvent(infra.UploadEventProcessing, session))
+
+	if err := ctrl.Infra.Minio.CompleteMultipartUpload(ctx, bucket.Name, session.StorageKey, session.MultipartID, parts); err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Object] Failed to complete multipart upload %s: %v", uploadID, err)
+		_ = ctrl.Repository.UploadSessionRepo.UpdateStatus(uploadID, entity.UploadStatusUploading)
+		ctrl.recordAudit(c, auditEvent{
+			OwnerID:    bucket.OwnerID,
+			Action:     entity.AuditActionUploadComplete,
+			TargetType: entity.AuditTargetUploadSession,
+			TargetID:   uploadID.String(),
+			TargetName: auditObjectName(session.CustomPath, session.FileName),
+			Err:        err,
+		})
+		utils.JSON500(c, "Failed to complete upload")
+		return
+	}
+
+	object := &entity.Object{
+		ID:           uuid.New(),
+		BucketID:     bucket.ID,
+		ContentType:  session.ContentType,
+		OriginName:   session.FileName,
+		ParentPath:   session.CustomPath,
+		CreatedAt:    time.Now(),
+		LastModified: time.Now(),
+		Size:         session.FileSize,
+		URL:          path.Base(session.StorageKey),
+	}
+
+	mode := session.ConflictMode
+	if !conflict.ValidMode(mode) {
+		mode = conflict.Mode(bucket, "")
+	}
+	object, replaced, err := conflict.New(ctrl.Infra, ctrl.Repository).Store(ctx, bucket, session.CustomPath, session.FileName, mode, object)
+	if err != nil {
+		// The assembled file is only reachable through the object row that could not be created
+		message := "failed to save object"
+		if errors.Is(err, conflict.ErrNameTaken) {
+			message = fmt.Sprintf("an object named '%s' already exists", auditObjectName(session.CustomPath, session.FileName))
+		}
+		if err := ctrl.Repository.UploadSessionRepo.MarkFailed(uploadID, message); err != nil {
+			ctrl.Infra.Logger.WarningWithContextf(ctx, "[Object] Failed to update session status: %v", err)
+		}
+		session.Status = entity.UploadStatusFailed
+		failed := infra.NewUploadEvent(infra.UploadEventFailed, session)
+		failed.Error = message
+		ctrl.publishUploadEvent(ctx, session, failed)
+		if err := ctrl.Infra.Minio.DeleteObject(ctx, bucket.Name, session.StorageKey); err != nil {
+			ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Object] Failed to remove unsaved upload '%s': %v", session.StorageKey, err)
+		}
+		ctrl.recordAudit(c, auditEvent{
+			OwnerID:    bucket.OwnerID,
+			Action:     entity.AuditActionObjectCreate,
+			TargetType: entity.AuditTargetUploadSession,
+			TargetID:   uploadID.String(),
+			TargetName: auditObjectName(session.CustomPath, session.FileName),
+			Err:        err,
+		})
+		if errors.Is(err, conflict.ErrNameTaken) {
+			utils.JSON409(c, "An object named '"+session.FileName+"' already exists at this path")
+			return
+		}
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Object] Failed to save object of upload %s: %v", uploadID, err)
+		utils.JSON500(c, "Failed to save object metadata")
+		return
+	}
+
+	// Archives go to the extraction worker; either way the reservation becomes committed usage
+	status := ctrl.finishDirectSession(ctx, session, object)
+	session.Status = status
+	finished := infra.NewUploadEvent(infra.UploadEventForStatus(status), session)
+	finished.ObjectID = object.ID.String()
+	ctrl.publishUploadEvent(ctx, session, finished)
+	quotas, err := ctrl.Repository.StorageQuotaRepo.AddUsage(bucket.OwnerID, bucket.ID, session.FileSize, 1)
+	if err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Object] Failed to add quota usage for upload %s: %v", uploadID, err)
+	} else {
+		quotawarn.Notify(ctx, ctrl.Infra, ctrl.Repository, quotas)
+	}
+	ctrl.Infra.UsageMeter.Add(bucket.OwnerID, bucket.ID, entity.MetricUploadBytes, "", float64(session.FileSize))
+	ctrl.Infra.UsageMeter.Add(bucket.OwnerID, bucket.ID, entity.MetricChunkedUploadCount, "", 1)
+
+	var before interface{}
+	if replaced != nil {
+		before = replaced
+	}
+	ctrl.recordAudit(c, auditEvent{
+		OwnerID:    bucket.OwnerID,
+		Action:     entity.AuditActionObjectCreate,
+		TargetType: entity.AuditTargetObject,
+		TargetID:   object.ID.String(),
+		TargetName: auditObjectName(object.ParentPath, object.OriginName),
+		Before:     before,
+		After:      object,
+	})
+
+	ctrl.Infra.Logger.InfoWithContextf(ctx, "[Object] Direct upload %s completed, object %s created (%d parts)", uploadID, object.ID, len(parts))
+
+	utils.JSON200(c, gin.H{
+		"message":       "Upload completed",
+		"upload_id":     uploadID.String(),
+		"status":        status,
+		"upload_mode":   entity.UploadModeDirect,
+		"upload_type":   session.UploadType,
+		"object":        object,
+		"cdn_url":       ctrl.Infra.Uploader.GetCDNURL(bucket.Name, object.StorageKey()),
+		"conflict_mode": mode,
+		"renamed":       object.OriginName != session.FileName,
+		"replaced":      before != nil,
+		"status_url":    fmt.Sprintf("/api/v1/cloud/buckets/%s/chunked/%s/status", bucket.ID, uploadID),
+	})
+}
+
+// finishDirectSession completes a direct session, or queues its archive for extraction, and returns its new status
+func (ctrl *Controller) finishDirectSession(ctx context.Context, session *entity.UploadSession, archive *entity.Object) entity.UploadStatus {
+	if session.UploadType != entity.UploadTypeArchive {
+		if err := ctrl.Repository.UploadSessionRepo.UpdateStatus(session.ID, entity.UploadStatusCompleted); err != nil {
+			ctrl.Infra.Logger.WarningWithContextf(ctx, "[Object] Failed to update session status: %v", err)
+		}
+		return entity.UploadStatusCompleted
+	}
+
+	status, err := ctrl.Infra.Produce.UploadService.QueueArchiveExtraction(ctx, ctrl.Repository.UploadSessionRepo, session, archive)
+	if err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Object] Failed to hand upload %s to extraction: %v", session.ID, err)
+	}
+	return status
+}
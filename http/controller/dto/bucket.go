@@ -1,10 +1,15 @@
 package dto
 
 type CreateBucketRequestDTO struct {
-	Name   string `json:"name" binding:"required,min=3,max=63"`
-	Region string `json:"region" binding:"required"`
+	Name         string `json:"name" binding:"required,min=3,max=63"`
+	Region       string `json:"region" binding:"required"`
+	ConflictMode string `json:"conflict_mode" binding:"omitempty,oneof=overwrite rename reject version"`
 }
 
 type UpdateBucketAccessRequestDTO struct {
 	Access string `json:"access" binding:"required,oneof=public private"`
 }
+
+type UpdateBucketConflictModeRequestDTO struct {
+	Mode string `json:"mode" binding:"required,oneof=overwrite rename reject version"`
+}
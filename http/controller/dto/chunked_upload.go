@@ -5,8 +5,13 @@ type InitUploadRequest struct {
 	FileName           string `json:"file_name" binding:"required"`
 	FileSize           int64  `json:"file_size" binding:"required,gt=0"`
 	ContentType        string `json:"content_type"`
-	Path               string `json:"path"`                 // Optional custom path
-	PreferredChunkSize int64  `json:"preferred_chunk_size"` // Optional: Client's preferred chunk size (server will decide final value)
+	Path               string `json:"path"`                                                                         // Optional custom path
+	PreferredChunkSize int64  `json:"preferred_chunk_size"`                                                         // Optional: Client's preferred chunk size (server will decide final value)
+	Conflict           string `json:"conflict" binding:"omitempty,oneof=overwrite rename reject version"`           // Optional: defaults to the bucket's conflict mode
+	FileHash           string `json:"file_hash" binding:"omitempty,len=64,hexadecimal"`                             // Optional: SHA-256 of the file, stored content with this hash can complete the upload instantly
+	Mode               string `json:"mode" binding:"omitempty,oneof=proxy direct"`                                  // Optional: "direct" returns presigned part URLs instead of proxying chunks
+	CallbackURL        string `json:"callback_url" binding:"omitempty,max=2048"`                                    // Optional: receives a signed POST once the upload completes or fails
+	CallbackSecret     string `json:"callback_secret" binding:"required_with=CallbackURL,omitempty,min=16,max=255"` // Key the callback is signed with, required with callback_url
 }
 
 // InitUploadResponse represents the response after initializing a chunked upload
@@ -35,7 +40,18 @@ type UploadChunkResponse struct {
 
 // CompleteUploadRequest represents the request to complete a chunked upload
 type CompleteUploadRequest struct {
-	UploadID string `json:"upload_id" binding:"required"`
+	UploadID    string          `json:"upload_id" binding:"required"`
+	Extract     bool            `json:"extract"`                                   // Extract a .zip, .tar or .tar.gz upload into objects
+	ExtractPath *string         `json:"extract_path" binding:"omitempty,max=1024"` // Folder receiving the entries (default: the upload path)
+	ChallengeID string          `json:"challenge_id"`                              // Instant upload challenge returned by init
+	Proof       string          `json:"proof" binding:"required_with=ChallengeID"` // Hex SHA-256 of the challenged range
+	Parts       []CompletedPart `json:"parts" binding:"omitempty,max=10000,dive"`  // ETags of the parts of a direct upload
+}
+
+// CompletedPart is a part a direct upload PUT to its presigned URL, with the ETag storage returned
+type CompletedPart struct {
+	PartNumber int    `json:"part_number" binding:"required,gte=1,lte=10000"`
+	ETag       string `json:"etag" binding:"required"`
 }
 
 // CompleteUploadResponse represents the response after completing a chunked upload
@@ -60,3 +76,14 @@ type UploadProgressResponse struct {
 type AbortUploadRequest struct {
 	UploadID string `json:"upload_id" binding:"required"`
 }
+
+// ExtendUploadRequest moves the expiry of an in-progress chunked upload, e.g. to pause it
+type ExtendUploadRequest struct {
+	ExpiresIn int64 `json:"expires_in" binding:"required,gt=0"` // Seconds from now, capped at the maximum session lifetime
+}
+
+// AbortUploadsRequest aborts several chunked uploads at once
+type AbortUploadsRequest struct {
+	UploadIDs []string `json:"upload_ids" binding:"omitempty,max=1000,dive,uuid"`
+	All       bool     `json:"all"` // Abort every session that is not being processed
+}
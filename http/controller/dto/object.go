@@ -1 +1,51 @@
 package dto
+
+import "time"
+
+// ListObjectsQueryDTO holds the pagination, sorting and filter parameters of an object listing
+type ListObjectsQueryDTO struct {
+	Limit          int        `form:"limit" binding:"omitempty,min=1,max=1000"`
+	Cursor         string     `form:"cursor"`                                                  // next_cursor from the previous page
+	Sort           string     `form:"sort" binding:"omitempty,oneof=name size last_modified"`  // default: name
+	Order          string     `form:"order" binding:"omitempty,oneof=asc desc"`                // default: asc
+	ContentType    string     `form:"content_type"`                                            // exact type, or prefix such as "image/*"
+	MinSize        *int64     `form:"min_size" binding:"omitempty,min=0"`                      // bytes, inclusive
+	MaxSize        *int64     `form:"max_size" binding:"omitempty,min=0"`                      // bytes, inclusive
+	ModifiedAfter  *time.Time `form:"modified_after" time_format:"2006-01-02T15:04:05Z07:00"`  // RFC3339, inclusive
+	ModifiedBefore *time.Time `form:"modified_before" time_format:"2006-01-02T15:04:05Z07:00"` // RFC3339, exclusive
+}
+
+// CreateFolderRequestDTO creates an empty folder; missing parent folders are created too
+type CreateFolderRequestDTO struct {
+	Path string `json:"path" binding:"required,max=1024"` // e.g. "photos/2024"
+}
+
+// TransferObjectRequestDTO copies or moves an object. The destination defaults to the
+// source bucket, the object's current folder and its current name.
+type TransferObjectRequestDTO struct {
+	DestBucketID string  `json:"dest_bucket_id" binding:"omitempty,uuid"`
+	DestPath     *string `json:"dest_path" binding:"omitempty,max=1024"` // "" is the bucket root
+	Name         string  `json:"name" binding:"omitempty,max=512"`
+}
+
+// RenameRequestDTO renames an object or folder in place
+type RenameRequestDTO struct {
+	Path string `json:"path" binding:"max=1024"` // folder to rename; ignored for objects
+	Name string `json:"name" binding:"required,max=512"`
+}
+
+// TransferFolderRequestDTO copies or moves a folder with everything below it.
+// DestPath is the new full path of the folder, e.g. moving "a/b" to "x/b".
+type TransferFolderRequestDTO struct {
+	Path         string `json:"path" binding:"required,max=1024"`
+	DestBucketID string `json:"dest_bucket_id" binding:"omitempty,uuid"`
+	DestPath     string `json:"dest_path" binding:"required,max=1024"`
+}
+
+// ArchiveRequestDTO selects what to download as one archive: either a folder with
+// everything below it, or an explicit list of objects
+type ArchiveRequestDTO struct {
+	Path      *string  `json:"path" binding:"omitempty,max=1024"` // "" is the whole bucket
+	ObjectIDs []string `json:"object_ids" binding:"omitempty,max=1000,dive,uuid"`
+	Format    string   `json:"format" binding:"omitempty,oneof=zip tar.gz"` // default: zip
+}
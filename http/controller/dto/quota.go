@@ -0,0 +1,14 @@
+package dto
+
+// UpdateQuotaLimitsRequestDTO changes quota limits; omitted fields are left unchanged and 0 means unlimited
+type UpdateQuotaLimitsRequestDTO struct {
+	MaxBytes      *int64  `json:"max_bytes" binding:"omitempty,min=0"`
+	MaxObjects    *int64  `json:"max_objects" binding:"omitempty,min=0"`
+	MaxObjectSize *int64  `json:"max_object_size" binding:"omitempty,min=0"`
+	NotifyEmail   *string `json:"notify_email" binding:"omitempty,email"`
+}
+
+// UpdateQuotaNotificationRequestDTO sets where quota warnings for the account are sent
+type UpdateQuotaNotificationRequestDTO struct {
+	NotifyEmail string `json:"notify_email" binding:"required,email"`
+}
@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tnqbao/gau-cloud-orchestrator/entity"
+	"github.com/tnqbao/gau-cloud-orchestrator/http/controller/dto"
+	"github.com/tnqbao/gau-cloud-orchestrator/repository"
+	"github.com/tnqbao/gau-cloud-orchestrator/utils"
+	"gorm.io/gorm"
+)
+
+// CreateFolder creates an empty folder (and any missing parents) in a bucket
+// POST /api/v1/cloud/buckets/:id/folders
+func (ctrl *Controller) CreateFolder(c *gin.Context) {
+	bucket, ok := ctrl.ownedBucket(c, "[Folder]")
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+
+	var req dto.CreateFolderRequestDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Folder] Failed to bind JSON: %v", err)
+		utils.JSON400(c, "Invalid request payload")
+		return
+	}
+
+	folderPath, err := normalizeObjectPath(req.Path)
+	if err != nil {
+		utils.JSON400(c, err.Error())
+		return
+	}
+	if folderPath == "" {
+		utils.JSON400(c, "path is required")
+		return
+	}
+
+	folder, err := ctrl.Repository.FolderRepo.Create(bucket.ID, folderPath)
+	if err != nil {
+		if errors.Is(err, repository.ErrFolderExists) {
+			utils.JSON409(c, "Folder already exists")
+			return
+		}
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Folder] Failed to create folder '%s': %v", folderPath, err)
+		ctrl.recordAudit(c, auditEvent{
+			OwnerID:    bucket.OwnerID,
+			Action:     entity.AuditActionFolderCreate,
+			TargetType: entity.AuditTargetFolder,
+			TargetName: folderPath,
+			Err:        err,
+		})
+		utils.JSON500(c, "Failed to create folder")
+		return
+	}
+
+	ctrl.recordAudit(c, auditEvent{
+		OwnerID:    bucket.OwnerID,
+		Action:     entity.AuditActionFolderCreate,
+		TargetType: entity.AuditTargetFolder,
+		TargetID:   folder.ID.String(),
+		TargetName: folder.Path,
+		After:      folder,
+	})
+
+	ctrl.Infra.Logger.InfoWithContextf(ctx, "[Folder] Created folder '%s' in bucket '%s'", folder.Path, bucket.Name)
+	utils.JSON200(c, gin.H{
+		"message": "Folder created successfully",
+		"folder":  folder,
+	})
+}
+
+// GetFolder returns a folder with its aggregate size and object count, and its direct subfolders.
+// The bucket root has no folder record, only subfolders are returned for it.
+// GET /api/v1/cloud/buckets/:id/folders/*path
+func (ctrl *Controller) GetFolder(c *gin.Context) {
+	bucket, ok := ctrl.ownedBucket(c, "[Folder]")
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+
+	folderPath, err := normalizeObjectPath(c.Param("path"))
+	if err != nil {
+		utils.JSON400(c, err.Error())
+		return
+	}
+
+	var folder *entity.Folder
+	if folderPath != "" {
+		folder, err = ctrl.Repository.FolderRepo.FindByPath(bucket.ID, folderPath)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				utils.JSON404(c, "Folder not found")
+				return
+			}
+			ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Folder] Failed to load folder '%s': %v", folderPath, err)
+			utils.JSON500(c, "Failed to load folder")
+			return
+		}
+	}
+
+	children, err := ctrl.Repository.FolderRepo.ListChildren(bucket.ID, folderPath, false)
+	if err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Folder] Failed to list subfolders of '%s': %v", folderPath, err)
+		utils.JSON500(c, "Failed to list folders")
+		return
+	}
+
+	utils.JSON200(c, gin.H{
+		"path":         folderPath,
+		"folder":       folder,
+		"folders":      children,
+		"folder_count": len(children),
+	})
+}
@@ -0,0 +1,38 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tnqbao/gau-cloud-orchestrator/infra"
+)
+
+// Liveness reports that the process is running and able to serve requests.
+// It never touches dependencies so a slow backend can't get the pod restarted.
+// GET /healthz
+func (ctrl *Controller) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": infra.HealthStatusUp,
+	})
+}
+
+// Readiness checks every dependency and returns a per-dependency report.
+// Responds 503 when a dependency gating readiness is down so the pod is taken out of rotation;
+// optional dependencies are reported without failing it.
+// GET /readyz
+func (ctrl *Controller) Readiness(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	report := ctrl.Infra.Health.Check(ctx)
+	if !report.Healthy() {
+		for _, dep := range report.Dependencies {
+			if dep.Status != infra.HealthStatusUp {
+				ctrl.Infra.Logger.WarningWithContextf(ctx, "[Health] Dependency %s is down: %s", dep.Name, dep.Error)
+			}
+		}
+		c.JSON(http.StatusServiceUnavailable, report)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
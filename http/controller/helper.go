@@ -1,15 +1,21 @@
 package controller
 
 import (
-	"fmt"
+	"errors"
 	"mime/multipart"
+	"path"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/tnqbao/gau-cloud-orchestrator/conflict"
 	"github.com/tnqbao/gau-cloud-orchestrator/entity"
+	"github.com/tnqbao/gau-cloud-orchestrator/infra"
+	"github.com/tnqbao/gau-cloud-orchestrator/quotawarn"
 	"github.com/tnqbao/gau-cloud-orchestrator/utils"
+	"gorm.io/gorm"
 )
 
 func BuildPolicyJSON(role string) []byte {
@@ -111,7 +117,27 @@ func BuildPolicyJSON(role string) []byte {
 }
 
 // Object
-func (ctrl *Controller) handleSmallFileUpload(c *gin.Context, fileHeader *multipart.FileHeader, bucket *entity.Bucket, bucketID uuid.UUID, customPath, contentType string) {
+
+// normalizeObjectPath cleans a client-supplied folder path: trims whitespace and
+// leading/trailing slashes, converts backslashes and collapses double slashes.
+// Paths containing ".." are rejected.
+func normalizeObjectPath(raw string) (string, error) {
+	p := strings.TrimSpace(raw)
+	if p == "" {
+		return "", nil
+	}
+	p = strings.Trim(p, "/\\")
+	p = strings.ReplaceAll(p, "\\", "/")
+	for strings.Contains(p, "//") {
+		p = strings.ReplaceAll(p, "//", "/")
+	}
+	if strings.Contains(p, "..") {
+		return "", errors.New("Invalid path: path cannot contain '..'")
+	}
+	return p, nil
+}
+
+func (ctrl *Controller) handleSmallFileUpload(c *gin.Context, fileHeader *multipart.FileHeader, bucket *entity.Bucket, bucketID uuid.UUID, customPath, contentType, conflictMode string) {
 	ctx := c.Request.Context()
 
 	// Open the file for reading
@@ -123,22 +149,56 @@ func (ctrl *Controller) handleSmallFileUpload(c *gin.Context, fileHeader *multip
 	}
 	defer file.Close()
 
-	// Forward to upload service with is_hash=false to preserve original filename
-	uploadResponse, err := ctrl.Infra.UploadService.UploadFile(
+	// Check the name before anything is stored, so a rejected upload costs nothing
+	resolver := conflict.New(ctrl.Infra, ctrl.Repository)
+	plan, err := resolver.Resolve(bucket, customPath, fileHeader.Filename, conflictMode)
+	if errors.Is(err, conflict.ErrNameTaken) {
+		utils.JSON409(c, "An object named '"+fileHeader.Filename+"' already exists at this path")
+		return
+	}
+	if err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Object] Failed to resolve name conflict: %v", err)
+		utils.JSON500(c, "Failed to check existing objects")
+		return
+	}
+	storageName := conflict.StorageName(plan.Name)
+
+	// Reserve the space before forwarding so concurrent uploads cannot overshoot the quota
+	if err := ctrl.ensureQuotas(c, bucket); err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Object] Failed to load storage quota: %v", err)
+		utils.JSON500(c, "Failed to load storage quota")
+		return
+	}
+	quotas, err := ctrl.Repository.StorageQuotaRepo.ReserveUsage(bucket.OwnerID, bucketID, fileHeader.Size)
+	if err != nil {
+		ctrl.respondQuotaExceeded(c, err)
+		return
+	}
+
+	// Forward to upload service with is_hash=false, so the file is stored under storageName
+	uploadResponse, err := ctrl.Infra.Uploader.UploadFile(
 		file,
-		fileHeader.Filename,
+		storageName,
 		contentType,
 		bucket.Name,
 		customPath,
-		false, // is_hash: false to keep original filename
+		false, // is_hash: false to store under storageName
 	)
 	if err != nil {
 		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Object] Failed to upload file to upload service: %v", err)
+		ctrl.releaseUsage(ctx, bucket.OwnerID, bucketID, fileHeader.Size)
+		ctrl.recordAudit(c, auditEvent{
+			OwnerID:    bucket.OwnerID,
+			Action:     entity.AuditActionObjectUpload,
+			TargetType: entity.AuditTargetObject,
+			TargetName: auditObjectName(customPath, plan.Name),
+			Err:        err,
+		})
 		utils.JSON500(c, "Failed to upload file: "+err.Error())
 		return
 	}
 
-	// Extract URL from upload response (original filename preserved)
+	// Extract URL from upload response (storageName)
 	urlPart := filepath.Base(uploadResponse.FilePath)
 
 	// Create object entity with info from upload response
@@ -155,37 +215,113 @@ func (ctrl *Controller) handleSmallFileUpload(c *gin.Context, fileHeader *multip
 		FileHash:     uploadResponse.FileHash,
 	}
 
-	// Save object to database
-	err = ctrl.Repository.ObjectRepo.Create(object)
+	// Save object to database, as a new object or as the new content of the existing one. The
+	// content has a key of its own, so a name taken meanwhile is resolved again under the mode.
+	object, replaced, err := resolver.Store(ctx, bucket, customPath, fileHeader.Filename, conflictMode, object)
 	if err != nil {
 		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Object] Failed to save object to database: %v", err)
+		// Nothing references the key written above
+		storageKey := path.Join(customPath, urlPart)
+		if err := ctrl.Infra.Minio.DeleteObject(ctx, bucket.Name, storageKey); err != nil {
+			ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Object] Failed to remove uncommitted upload '%s': %v", storageKey, err)
+		}
+		ctrl.releaseUsage(ctx, bucket.OwnerID, bucketID, fileHeader.Size)
+		ctrl.recordAudit(c, auditEvent{
+			OwnerID:    bucket.OwnerID,
+			Action:     entity.AuditActionObjectUpload,
+			TargetType: entity.AuditTargetObject,
+			TargetName: auditObjectName(customPath, fileHeader.Filename),
+			Err:        err,
+		})
+		if errors.Is(err, conflict.ErrNameTaken) {
+			utils.JSON409(c, "An object named '"+fileHeader.Filename+"' already exists at this path")
+			return
+		}
 		utils.JSON500(c, "Failed to save object metadata")
 		return
 	}
 
-	ctrl.Infra.Logger.InfoWithContextf(ctx, "[Object] Successfully uploaded object: %s", object.ID)
+	var before interface{}
+	if replaced != nil {
+		before = replaced
+	}
+	ctrl.recordAudit(c, auditEvent{
+		OwnerID:    bucket.OwnerID,
+		Action:     entity.AuditActionObjectUpload,
+		TargetType: entity.AuditTargetObject,
+		TargetID:   object.ID.String(),
+		TargetName: auditObjectName(object.ParentPath, object.OriginName),
+		Before:     before,
+		After:      object,
+	})
+
+	quotawarn.Notify(ctx, ctrl.Infra, ctrl.Repository, quotas)
+	ctrl.Infra.UsageMeter.Add(bucket.OwnerID, bucketID, entity.MetricUploadBytes, "", float64(fileHeader.Size))
+
+	ctrl.Infra.Logger.InfoWithContextf(ctx, "[Object] Successfully uploaded object: %s (conflict mode %s)", object.ID, conflictMode)
 
-	// Build CDN URL for the uploaded file
-	cdnURL := ctrl.Infra.UploadService.GetCDNURL(bucket.Name, uploadResponse.FilePath)
+	// Build CDN URL for the blob serving the file, which may be shared content stored elsewhere
+	cdnURL := ctrl.Infra.Uploader.GetCDNURL(bucket.Name, object.StorageKey())
 
 	utils.JSON200(c, gin.H{
-		"message":    "File uploaded successfully",
-		"object":     object,
-		"cdn_url":    cdnURL,
-		"duplicated": uploadResponse.Duplicated,
+		"message":       "File uploaded successfully",
+		"object":        object,
+		"cdn_url":       cdnURL,
+		"duplicated":    uploadResponse.Duplicated,
+		"conflict_mode": conflictMode,
+		"renamed":       object.OriginName != fileHeader.Filename,
+		"replaced":      replaced != nil,
 	})
 }
 
-// formatBytes formats bytes into human-readable string
-func formatBytes(bytes int64) string {
-	const unit = 1024
-	if bytes < unit {
-		return fmt.Sprintf("%d B", bytes)
+// ownedBucket loads the bucket from the :id path parameter and checks that the caller owns it.
+// On failure the error response is written and false is returned.
+func (ctrl *Controller) ownedBucket(c *gin.Context, logPrefix string) (*entity.Bucket, bool) {
+	ctx := c.Request.Context()
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		utils.JSON401(c, "Unauthorized: user_id not found")
+		return nil, false
+	}
+
+	bucketID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.JSON400(c, "Invalid bucket_id format")
+		return nil, false
+	}
+
+	bucket, err := ctrl.Repository.BucketRepo.FindByID(bucketID)
+	if err != nil {
+		utils.JSON404(c, "Bucket not found")
+		return nil, false
 	}
-	div, exp := int64(unit), 0
-	for n := bytes / unit; n >= unit; n /= unit {
-		div *= unit
-		exp++
+
+	if bucket.OwnerID != userID {
+		ctrl.Infra.Logger.WarningWithContextf(ctx, "%s User %s attempted to access bucket %s owned by %s", logPrefix, userID, bucketID, bucket.OwnerID)
+		utils.JSON403(c, "Forbidden: you don't have permission to access this bucket")
+		return nil, false
+	}
+
+	return bucket, true
+}
+
+// accessKeyPolicy returns the S3 policy of the IAM access key that signed the request. restricted
+// is false for token requests, which are not bound by any policy; a restricted request without a
+// policy gets a nil one and may touch no bucket.
+func (ctrl *Controller) accessKeyPolicy(c *gin.Context) (policy []byte, restricted bool, err error) {
+	if c.GetString("auth_method") != infra.AuthMethodHMAC {
+		return nil, false, nil
+	}
+	iamID, err := uuid.Parse(c.GetString("iam_user_id"))
+	if err != nil {
+		return nil, true, nil
+	}
+	p, err := ctrl.Repository.IAMPolicyRepo.GetByIAMIDAndType(iamID, "s3")
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, true, nil
+	}
+	if err != nil {
+		return nil, true, err
 	}
-	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
+	return p.Policy, true, nil
 }
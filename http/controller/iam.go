@@ -83,6 +83,13 @@ func (ctrl *Controller) CreateIAM(c *gin.Context) {
 	err = ctrl.Infra.Minio.CreateIAMUserWithCustomPolicy(ctx, req.AccessKey, req.SecretKey, policyName, policyBytes)
 	if err != nil {
 		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[IAM] Failed to create IAM user on MinIO: %v", err)
+		ctrl.recordAudit(c, auditEvent{
+			OwnerID:    userID,
+			Action:     entity.AuditActionIAMCreate,
+			TargetType: entity.AuditTargetIAMUser,
+			TargetName: req.Name,
+			Err:        err,
+		})
 		utils.JSON500(c, "Failed to create IAM user on MinIO")
 		return
 	}
@@ -110,6 +117,13 @@ func (ctrl *Controller) CreateIAM(c *gin.Context) {
 			ctrl.Infra.Logger.ErrorWithContextf(ctx, rollbackErr, "[IAM] Failed to rollback MinIO policy after database error: %v", rollbackErr)
 		}
 		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[IAM] Failed to create IAM user in database: %v", err)
+		ctrl.recordAudit(c, auditEvent{
+			OwnerID:    userID,
+			Action:     entity.AuditActionIAMCreate,
+			TargetType: entity.AuditTargetIAMUser,
+			TargetName: req.Name,
+			Err:        err,
+		})
 		utils.JSON500(c, "Failed to create IAM user in database")
 		return
 	}
@@ -129,10 +143,26 @@ func (ctrl *Controller) CreateIAM(c *gin.Context) {
 		_ = ctrl.Infra.Minio.DeleteIAMUser(ctx, req.AccessKey)
 		_ = ctrl.Infra.Minio.DeletePolicy(ctx, policyName)
 		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[IAM] Failed to create IAM policy in database: %v", err)
+		ctrl.recordAudit(c, auditEvent{
+			OwnerID:    userID,
+			Action:     entity.AuditActionIAMCreate,
+			TargetType: entity.AuditTargetIAMUser,
+			TargetName: req.Name,
+			Err:        err,
+		})
 		utils.JSON500(c, "Failed to create IAM policy in database")
 		return
 	}
 
+	ctrl.recordAudit(c, auditEvent{
+		OwnerID:    userID,
+		Action:     entity.AuditActionIAMCreate,
+		TargetType: entity.AuditTargetIAMUser,
+		TargetID:   iamUser.ID.String(),
+		TargetName: iamUser.Name,
+		After:      iamAuditState(iamUser),
+	})
+
 	ctrl.Infra.Logger.InfoWithContextf(ctx, "[IAM] Successfully created IAM with ID: %s, UserID: %s, Name: %s, AccessKey: %s, PolicyName: %s",
 		iamUser.ID.String(), iamUser.UserId.String(), iamUser.Name, MaskAccessKey(iamUser.AccessKey), policyName)
 
@@ -197,6 +227,14 @@ func (ctrl *Controller) DeleteIAMByID(c *gin.Context) {
 	err = ctrl.Repository.IAMUserRepo.Delete(iamID)
 	if err != nil {
 		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[IAM] Failed to delete IAM user from database: %v", err)
+		ctrl.recordAudit(c, auditEvent{
+			OwnerID:    iamUser.UserId,
+			Action:     entity.AuditActionIAMDelete,
+			TargetType: entity.AuditTargetIAMUser,
+			TargetID:   iamID.String(),
+			TargetName: iamUser.Name,
+			Err:        err,
+		})
 		utils.JSON500(c, "Failed to delete IAM user from database")
 		return
 	}
@@ -207,6 +245,15 @@ func (ctrl *Controller) DeleteIAMByID(c *gin.Context) {
 		ctrl.Infra.Logger.WarningWithContextf(ctx, "[IAM] MinIO deletion failed but DB deletion succeeded for user: %s", iamUser.AccessKey)
 	}
 
+	ctrl.recordAudit(c, auditEvent{
+		OwnerID:    iamUser.UserId,
+		Action:     entity.AuditActionIAMDelete,
+		TargetType: entity.AuditTargetIAMUser,
+		TargetID:   iamID.String(),
+		TargetName: iamUser.Name,
+		Before:     iamAuditState(iamUser),
+	})
+
 	ctrl.Infra.Logger.InfoWithContextf(ctx, "[IAM] Successfully deleted IAM user with ID: %s", iamID.String())
 	utils.JSON200(c, gin.H{"message": "IAM user deleted successfully"})
 }
@@ -308,6 +355,14 @@ func (ctrl *Controller) UpdateIAMCredentials(c *gin.Context) {
 	// Verify that this IAM belongs to the authenticated user
 	if iamUser.UserId != userID {
 		ctrl.Infra.Logger.WarningWithContextf(ctx, "[IAM] User %s attempted to update IAM %s owned by user %s", userID.String(), iamID.String(), iamUser.UserId.String())
+		ctrl.recordAudit(c, auditEvent{
+			OwnerID:    iamUser.UserId,
+			Action:     entity.AuditActionIAMCredentialsUpdate,
+			TargetType: entity.AuditTargetIAMUser,
+			TargetID:   iamID.String(),
+			TargetName: iamUser.Name,
+			Result:     entity.AuditResultDenied,
+		})
 		utils.JSON403(c, "Forbidden: You don't have permission to update this IAM")
 		return
 	}
@@ -336,6 +391,14 @@ func (ctrl *Controller) UpdateIAMCredentials(c *gin.Context) {
 	}
 
 	oldPolicy := policies[0]
+	credentialsEvent := auditEvent{
+		OwnerID:    userID,
+		Action:     entity.AuditActionIAMCredentialsUpdate,
+		TargetType: entity.AuditTargetIAMUser,
+		TargetID:   iamID.String(),
+		TargetName: iamUser.Name,
+		Before:     iamAuditState(iamUser),
+	}
 	oldPolicyName := iamUser.AccessKey + "-s3-policy"
 	newPolicyName := req.AccessKey + "-s3-policy"
 
@@ -343,6 +406,8 @@ func (ctrl *Controller) UpdateIAMCredentials(c *gin.Context) {
 	err = ctrl.Infra.Minio.DeleteIAMUser(ctx, iamUser.AccessKey)
 	if err != nil {
 		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[IAM] Failed to delete old IAM user from MinIO: %v", err)
+		credentialsEvent.Err = err
+		ctrl.recordAudit(c, credentialsEvent)
 		utils.JSON500(c, "Failed to delete old IAM user from MinIO")
 		return
 	}
@@ -353,6 +418,8 @@ func (ctrl *Controller) UpdateIAMCredentials(c *gin.Context) {
 		// Rollback: Recreate old user
 		_ = ctrl.Infra.Minio.CreateIAMUser(ctx, iamUser.AccessKey, iamUser.SecretKey)
 		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[IAM] Failed to create new IAM user on MinIO: %v", err)
+		credentialsEvent.Err = err
+		ctrl.recordAudit(c, credentialsEvent)
 		utils.JSON500(c, "Failed to create new IAM user on MinIO")
 		return
 	}
@@ -367,10 +434,15 @@ func (ctrl *Controller) UpdateIAMCredentials(c *gin.Context) {
 		_ = ctrl.Infra.Minio.DeleteIAMUser(ctx, req.AccessKey)
 		_ = ctrl.Infra.Minio.CreateIAMUser(ctx, iamUser.AccessKey, iamUser.SecretKey)
 		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[IAM] Failed to update IAM user in database: %v", err)
+		credentialsEvent.Err = err
+		ctrl.recordAudit(c, credentialsEvent)
 		utils.JSON500(c, "Failed to update IAM user in database")
 		return
 	}
 
+	credentialsEvent.After = iamAuditState(iamUser)
+	ctrl.recordAudit(c, credentialsEvent)
+
 	ctrl.Infra.Logger.InfoWithContextf(ctx, "[IAM] Successfully updated IAM credentials for ID: %s", iamID.String())
 
 	// Step 4: Async update policy on MinIO via message queue
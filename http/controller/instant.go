@@ -0,0 +1,290 @@
+package controller
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tnqbao/gau-cloud-orchestrator/conflict"
+	"github.com/tnqbao/gau-cloud-orchestrator/entity"
+	"github.com/tnqbao/gau-cloud-orchestrator/infra"
+	"github.com/tnqbao/gau-cloud-orchestrator/instant"
+	"github.com/tnqbao/gau-cloud-orchestrator/quotawarn"
+	"github.com/tnqbao/gau-cloud-orchestrator/repository"
+	"github.com/tnqbao/gau-cloud-orchestrator/utils"
+)
+
+// declareInstantUpload answers a direct upload that sends file_hash instead of a file. When the
+// owner already stores content with that hash and size in a bucket the caller may read, a challenge
+// is returned: the client completes the upload by sending challenge_id and, as proof, the hex SHA-256
+// of the hex-decoded nonce followed by the challenged range.
+func (ctrl *Controller) declareInstantUpload(c *gin.Context, bucket *entity.Bucket, customPath, conflictMode string) {
+	ctx := c.Request.Context()
+
+	fileHash := strings.ToLower(c.PostForm("file_hash"))
+	if !utils.IsSHA256Hex(fileHash) {
+		utils.JSON400(c, "file_hash must be a hex-encoded SHA-256")
+		return
+	}
+	fileName := strings.TrimSpace(c.PostForm("file_name"))
+	if fileName == "" || strings.ContainsAny(fileName, "/\\") {
+		utils.JSON400(c, "file_name is required and cannot contain slashes")
+		return
+	}
+	fileSize, err := strconv.ParseInt(c.PostForm("file_size"), 10, 64)
+	if err != nil || fileSize <= 0 {
+		utils.JSON400(c, "file_size must be a positive number of bytes")
+		return
+	}
+
+	// Reject mode fails fast, before the client is asked for a proof
+	if _, err := conflict.New(ctrl.Infra, ctrl.Repository).Resolve(bucket, customPath, fileName, conflictMode); err != nil {
+		if errors.Is(err, conflict.ErrNameTaken) {
+			utils.JSON409(c, "An object named '"+fileName+"' already exists at this path")
+			return
+		}
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Instant] Failed to resolve name conflict: %v", err)
+		utils.JSON500(c, "Failed to check existing objects")
+		return
+	}
+
+	ch := &instant.Challenge{
+		OwnerID:      bucket.OwnerID,
+		BucketID:     bucket.ID,
+		FileName:     fileName,
+		FileSize:     fileSize,
+		FileHash:     fileHash,
+		ContentType:  c.PostForm("content_type"),
+		Path:         customPath,
+		ConflictMode: conflictMode,
+	}
+	if !ctrl.issueInstantChallenge(c, bucket, ch) {
+		utils.JSON404(c, "No stored content matches file_hash, upload the file instead")
+		return
+	}
+
+	utils.JSON200(c, gin.H{
+		"message": "Content already stored, send the proof to complete the upload",
+		"instant": instantChallengeResponse(ch),
+	})
+}
+
+// issueInstantChallenge looks for stored content matching ch in the buckets the caller may read and
+// stores a challenge for it. It returns false when the upload has to transfer the file.
+func (ctrl *Controller) issueInstantChallenge(c *gin.Context, bucket *entity.Bucket, ch *instant.Challenge) bool {
+	ctx := c.Request.Context()
+	readable, err := ctrl.readableBucketIDs(c, bucket.OwnerID)
+	if err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Instant] Failed to resolve readable buckets: %v", err)
+		return false
+	}
+
+	s := instant.New(ctrl.Infra, ctrl.Repository)
+	source, err := s.Find(bucket, readable, ch.FileHash, ch.FileSize)
+	if err != nil {
+		if !errors.Is(err, instant.ErrNoMatch) {
+			ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Instant] Failed to look up content %s: %v", ch.FileHash, err)
+		}
+		return false
+	}
+
+	ch.SourceObjectID = source.ID
+	ch.SourceBucketID = source.BucketID
+	if err := s.Challenge(ctx, ch); err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Instant] Failed to store challenge: %v", err)
+		return false
+	}
+	return true
+}
+
+// readableBucketIDs returns the buckets of ownerID whose objects the caller's credentials may read.
+// A token with object read access reads every bucket of its owner; an access key reads the buckets
+// its S3 policy grants s3:GetObject on.
+func (ctrl *Controller) readableBucketIDs(c *gin.Context, ownerID uuid.UUID) ([]uuid.UUID, error) {
+	granted := utils.ResolvePermissions(c.GetString("permission"), ctrl.Config.EnvConfig.Authz.DefaultRole)
+	if !utils.HasPermission(granted, utils.PermObjectRead) {
+		return nil, nil
+	}
+	buckets, err := ctrl.Repository.BucketRepo.FindByOwnerID(ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	policy, restricted, err := ctrl.accessKeyPolicy(c)
+	if err != nil {
+		return nil, err
+	}
+	if restricted && policy == nil {
+		return nil, nil
+	}
+
+	readable := make([]uuid.UUID, 0, len(buckets))
+	for i := range buckets {
+		if policy != nil {
+			allowed, err := utils.PolicyAllows(policy, utils.S3GetObject, buckets[i].Name)
+			if err != nil {
+				return nil, err
+			}
+			if !allowed {
+				continue
+			}
+		}
+		readable = append(readable, buckets[i].ID)
+	}
+	return readable, nil
+}
+
+func instantChallengeResponse(ch *instant.Challenge) gin.H {
+	return gin.H{
+		"challenge_id": ch.ID.String(),
+		"nonce":        ch.Nonce,
+		"offset":       ch.Offset,
+		"length":       ch.Length,
+		"expires_at":   ch.ExpiresAt.Format(time.RFC3339),
+	}
+}
+
+// completeInstantUpload checks the proof of a challenge and stores the upload from the content
+// already held. session is the chunked upload the challenge was issued for, nil for direct uploads.
+func (ctrl *Controller) completeInstantUpload(c *gin.Context, bucket *entity.Bucket, session *entity.UploadSession, challengeIDStr, proof string) {
+	ctx := c.Request.Context()
+
+	challengeID, err := uuid.Parse(challengeIDStr)
+	if err != nil {
+		utils.JSON400(c, "Invalid challenge_id format")
+		return
+	}
+	if proof == "" {
+		utils.JSON400(c, "proof is required")
+		return
+	}
+
+	s := instant.New(ctrl.Infra, ctrl.Repository)
+	ch, source, sourceBucket, err := s.Verify(ctx, bucket.OwnerID, challengeID, proof)
+	if err == nil {
+		uploadID := uuid.Nil
+		if session != nil {
+			uploadID = session.ID
+		}
+		if ch.BucketID != bucket.ID || ch.UploadID != uploadID {
+			err = instant.ErrChallengeNotFound
+		}
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, instant.ErrChallengeNotFound):
+			utils.JSON404(c, "Challenge not found or expired")
+		case errors.Is(err, instant.ErrProofMismatch):
+			ctrl.Infra.Logger.WarningWithContextf(ctx, "[Instant] Wrong proof for challenge %s", challengeID)
+			ctrl.recordAudit(c, auditEvent{
+				OwnerID:    bucket.OwnerID,
+				Action:     entity.AuditActionObjectUpload,
+				TargetType: entity.AuditTargetBucket,
+				TargetID:   bucket.ID.String(),
+				TargetName: bucket.Name,
+				Result:     entity.AuditResultDenied,
+			})
+			utils.JSON403(c, "Proof does not match the requested range")
+		case errors.Is(err, instant.ErrNoMatch):
+			utils.JSON404(c, "The stored content is no longer available, upload the file instead")
+		default:
+			ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Instant] Failed to verify challenge %s: %v", challengeID, err)
+			utils.JSON500(c, "Failed to verify proof")
+		}
+		return
+	}
+
+	action := entity.AuditActionObjectUpload
+	if session != nil {
+		action = entity.AuditActionObjectCreate
+	}
+	if err := ctrl.ensureQuotas(c, bucket); err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Instant] Failed to load storage quota: %v", err)
+		utils.JSON500(c, "Failed to load storage quota")
+		return
+	}
+
+	result, err := s.Complete(ctx, ch, source, sourceBucket, bucket)
+	if err != nil {
+		if errors.Is(err, repository.ErrQuotaExceeded) {
+			ctrl.respondQuotaExceeded(c, err)
+			return
+		}
+		ctrl.recordAudit(c, auditEvent{
+			OwnerID:    bucket.OwnerID,
+			Action:     action,
+			TargetType: entity.AuditTargetObject,
+			TargetName: auditObjectName(ch.Path, ch.FileName),
+			Err:        err,
+		})
+		switch {
+		case errors.Is(err, conflict.ErrNameTaken):
+			if session != nil {
+				message := "an object named '" + auditObjectName(ch.Path, ch.FileName) + "' already exists"
+				if err := ctrl.Repository.UploadSessionRepo.MarkFailed(session.ID, message); err != nil {
+					ctrl.Infra.Logger.WarningWithContextf(ctx, "[Instant] Failed to update session status: %v", err)
+				}
+				session.Status = entity.UploadStatusFailed
+				failed := infra.NewUploadEvent(infra.UploadEventFailed, session)
+				failed.Error = message
+				ctrl.publishUploadEvent(ctx, session, failed)
+			}
+			utils.JSON409(c, "An object named '"+ch.FileName+"' already exists at this path")
+		case errors.Is(err, conflict.ErrChanged):
+			utils.JSON409(c, "Another upload to '"+ch.FileName+"' finished first, please retry")
+		default:
+			ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Instant] Failed to store instant upload of '%s': %v", ch.FileName, err)
+			utils.JSON500(c, "Failed to store object")
+		}
+		return
+	}
+	object := result.Object
+
+	if session != nil {
+		// Chunks sent before the proof are no longer needed
+		ctrl.removeChunks(*session)
+		ctrl.Infra.UsageMeter.Add(bucket.OwnerID, bucket.ID, entity.MetricChunkedUploadCount, "", 1)
+		session.Status = entity.UploadStatusCompleted
+		completed := infra.NewUploadEvent(infra.UploadEventCompleted, session)
+		completed.ObjectID = object.ID.String()
+		ctrl.publishUploadEvent(ctx, session, completed)
+	}
+
+	var before interface{}
+	if result.Replaced != nil {
+		before = result.Replaced
+	}
+	ctrl.recordAudit(c, auditEvent{
+		OwnerID:    bucket.OwnerID,
+		Action:     action,
+		TargetType: entity.AuditTargetObject,
+		TargetID:   object.ID.String(),
+		TargetName: auditObjectName(object.ParentPath, object.OriginName),
+		Before:     before,
+		After:      object,
+	})
+
+	quotawarn.Notify(ctx, ctrl.Infra, ctrl.Repository, result.Quotas)
+
+	ctrl.Infra.Logger.InfoWithContextf(ctx, "[Instant] Stored object %s from content of object %s (copied: %v, conflict mode %s)",
+		object.ID, source.ID, result.Copied, result.Plan.Mode)
+
+	response := gin.H{
+		"message":       "File stored from existing content",
+		"object":        object,
+		"cdn_url":       ctrl.Infra.Uploader.GetCDNURL(bucket.Name, object.StorageKey()),
+		"instant":       true,
+		"copied":        result.Copied,
+		"conflict_mode": result.Plan.Mode,
+		"renamed":       result.Plan.Renamed(ch.FileName),
+		"replaced":      before != nil,
+	}
+	if session != nil {
+		response["upload_id"] = session.ID.String()
+		response["status"] = entity.UploadStatusCompleted
+	}
+	utils.JSON200(c, response)
+}
@@ -1,6 +1,8 @@
 package controller
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"strings"
@@ -8,9 +10,13 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/tnqbao/gau-cloud-orchestrator/conflict"
 	"github.com/tnqbao/gau-cloud-orchestrator/entity"
 	"github.com/tnqbao/gau-cloud-orchestrator/http/controller/dto"
+	"github.com/tnqbao/gau-cloud-orchestrator/infra"
 	"github.com/tnqbao/gau-cloud-orchestrator/infra/produce"
+	"github.com/tnqbao/gau-cloud-orchestrator/instant"
+	"github.com/tnqbao/gau-cloud-orchestrator/repository"
 	"github.com/tnqbao/gau-cloud-orchestrator/utils"
 )
 
@@ -21,6 +27,10 @@ const (
 	MaxChunkSize int64 = 15 * 1024 * 1024
 	// UploadSessionExpiry is the default expiry time for upload sessions (24 hours)
 	UploadSessionExpiry = 24 * time.Hour
+	// MaxUploadSessionExpiry is the furthest a session's expiry can be extended from now (7 days)
+	MaxUploadSessionExpiry = 7 * 24 * time.Hour
+	// DefaultObjectPageSize is the number of objects per page when no limit is given
+	DefaultObjectPageSize = 100
 )
 
 func (ctrl *Controller) UploadObject(c *gin.Context) {
@@ -65,36 +75,55 @@ func (ctrl *Controller) UploadObject(c *gin.Context) {
 	// Check if the user owns this bucket
 	if bucket.OwnerID != userID {
 		ctrl.Infra.Logger.WarningWithContextf(ctx, "[Object] User %s attempted to upload to bucket %s owned by %s", userID, bucketID, bucket.OwnerID)
+		ctrl.recordAudit(c, auditEvent{
+			OwnerID:    bucket.OwnerID,
+			Action:     entity.AuditActionObjectUpload,
+			TargetType: entity.AuditTargetBucket,
+			TargetID:   bucketID.String(),
+			TargetName: bucket.Name,
+			Result:     entity.AuditResultDenied,
+		})
 		utils.JSON403(c, "Forbidden: you don't have permission to upload to this bucket")
 		return
 	}
 
-	// Get file from multipart form
+	// Get file from multipart form; without one, a declared file_hash or an answered challenge is an instant upload
 	fileHeader, err := c.FormFile("file")
-	if err != nil {
+	if err != nil && c.PostForm("file_hash") == "" && c.PostForm("challenge_id") == "" {
 		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Object] Failed to get file from form data")
 		utils.JSON400(c, "Failed to get file: "+err.Error())
 		return
 	}
 
+	// The form parser has consumed the body, so a declared X-Content-SHA256 can be checked before forwarding
+	if err := utils.VerifyBodyHash(c); err != nil {
+		ctrl.Infra.Logger.WarningWithContextf(ctx, "[Object] Body hash verification failed: %v", err)
+		utils.JSON400(c, err.Error())
+		return
+	}
+
 	// Optional: Get custom file path/folder (supports nested paths like abc/def)
-	customPath := strings.TrimSpace(c.PostForm("path"))
-	if customPath != "" {
-		// Clean and normalize path: remove leading/trailing slashes, replace backslashes
-		customPath = strings.Trim(customPath, "/\\")
-		customPath = strings.ReplaceAll(customPath, "\\", "/")
-
-		// Remove any double slashes
-		for strings.Contains(customPath, "//") {
-			customPath = strings.ReplaceAll(customPath, "//", "/")
-		}
+	customPath, err := normalizeObjectPath(c.PostForm("path"))
+	if err != nil {
+		ctrl.Infra.Logger.WarningWithContextf(ctx, "[Object] Invalid path contains ..")
+		utils.JSON400(c, err.Error())
+		return
+	}
+
+	// Optional: what to do when the folder already has an object with this name
+	conflictMode := c.PostForm("conflict")
+	if conflictMode != "" && !conflict.ValidMode(conflictMode) {
+		utils.JSON400(c, "Invalid conflict mode: must be one of "+strings.Join(conflict.Modes, ", "))
+		return
+	}
 
-		// Validate path doesn't contain dangerous characters
-		if strings.Contains(customPath, "..") {
-			ctrl.Infra.Logger.WarningWithContextf(ctx, "[Object] Invalid path contains ..")
-			utils.JSON400(c, "Invalid path: path cannot contain '..'")
+	if fileHeader == nil {
+		if challengeID := c.PostForm("challenge_id"); challengeID != "" {
+			ctrl.completeInstantUpload(c, bucket, nil, challengeID, c.PostForm("proof"))
 			return
 		}
+		ctrl.declareInstantUpload(c, bucket, customPath, conflict.Mode(bucket, conflictMode))
+		return
 	}
 
 	// Get content type from file header
@@ -121,7 +150,7 @@ func (ctrl *Controller) UploadObject(c *gin.Context) {
 		utils.JSON413(c, gin.H{
 			"error":     "FILE_TOO_LARGE",
 			"message":   "File size exceeds the maximum allowed for direct upload",
-			"hint":      "Use chunked upload API for files larger than " + formatBytes(largeFileThreshold),
+			"hint":      "Use chunked upload API for files larger than " + utils.FormatBytes(largeFileThreshold),
 			"file_size": fileHeader.Size,
 			"threshold": largeFileThreshold,
 			"endpoints": gin.H{
@@ -134,7 +163,7 @@ func (ctrl *Controller) UploadObject(c *gin.Context) {
 	}
 
 	// Small file flow: use existing direct upload
-	ctrl.handleSmallFileUpload(c, fileHeader, bucket, bucketID, customPath, contentType)
+	ctrl.handleSmallFileUpload(c, fileHeader, bucket, bucketID, customPath, contentType, conflict.Mode(bucket, conflictMode))
 }
 
 func (ctrl *Controller) ListObjectsByPath(c *gin.Context) {
@@ -192,31 +221,95 @@ func (ctrl *Controller) ListObjectsByPath(c *gin.Context) {
 	// Clean the path
 	parentPath = strings.TrimSpace(parentPath)
 
+	var query dto.ListObjectsQueryDTO
+	if err := c.ShouldBindQuery(&query); err != nil {
+		ctrl.Infra.Logger.WarningWithContextf(ctx, "[Object] Invalid list query: %v", err)
+		utils.JSON400(c, "Invalid query parameters: "+err.Error())
+		return
+	}
+	if query.MinSize != nil && query.MaxSize != nil && *query.MinSize > *query.MaxSize {
+		utils.JSON400(c, "min_size must not be greater than max_size")
+		return
+	}
+
+	opts := repository.ObjectListOptions{
+		Limit:          query.Limit,
+		Sort:           query.Sort,
+		Desc:           query.Order == "desc",
+		ContentType:    query.ContentType,
+		MinSize:        query.MinSize,
+		MaxSize:        query.MaxSize,
+		ModifiedAfter:  query.ModifiedAfter,
+		ModifiedBefore: query.ModifiedBefore,
+	}
+	if opts.Limit == 0 {
+		opts.Limit = DefaultObjectPageSize
+	}
+	if opts.Sort == "" {
+		opts.Sort = repository.ObjectSortName
+	}
+	order := query.Order
+	if order == "" {
+		order = "asc"
+	}
+	if query.Cursor != "" {
+		cursor, err := repository.DecodeObjectCursor(query.Cursor)
+		if err != nil {
+			utils.JSON400(c, "Invalid cursor")
+			return
+		}
+		opts.Cursor = cursor
+	}
+
 	ctrl.Infra.Logger.InfoWithContextf(ctx, "[Object] Listing objects in bucket '%s' at path '%s'", bucket.Name, parentPath)
 
-	// Get objects at this path
-	objects, err := ctrl.Repository.ObjectRepo.FindByBucketIDAndPath(bucketID, parentPath)
+	// Get one page of objects at this path
+	objects, next, err := ctrl.Repository.ObjectRepo.ListByBucketIDAndPath(bucketID, parentPath, opts)
 	if err != nil {
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			utils.JSON400(c, "Invalid cursor: it does not match the requested sort and order")
+			return
+		}
 		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Object] Failed to list objects: %v", err)
 		utils.JSON500(c, "Failed to list objects")
 		return
 	}
 
-	// Get folders at this path
-	folders, err := ctrl.Repository.ObjectRepo.FindFoldersByBucketIDAndPath(bucketID, parentPath)
-	if err != nil {
-		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Object] Failed to list folders: %v", err)
-		utils.JSON500(c, "Failed to list folders")
-		return
+	// Folders are returned with the first page only
+	folders := []entity.Folder{}
+	if opts.Cursor == nil {
+		folders, err = ctrl.Repository.FolderRepo.ListChildren(bucketID, parentPath, opts.Desc)
+		if err != nil {
+			ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Object] Failed to list folders: %v", err)
+			utils.JSON500(c, "Failed to list folders")
+			return
+		}
+	}
+
+	// "folders" keeps the plain names existing clients read, the aggregates are in "folder_details"
+	folderNames := make([]string, len(folders))
+	for i := range folders {
+		folderNames[i] = folders[i].Name
+	}
+
+	nextCursor := ""
+	if next != nil {
+		nextCursor = next.Encode()
 	}
 
 	ctrl.Infra.Logger.InfoWithContextf(ctx, "[Object] Found %d objects and %d folders in bucket '%s' at path '%s'", len(objects), len(folders), bucket.Name, parentPath)
 	utils.JSON200(c, gin.H{
-		"path":         parentPath,
-		"objects":      objects,
-		"folders":      folders,
-		"object_count": len(objects),
-		"folder_count": len(folders),
+		"path":           parentPath,
+		"objects":        objects,
+		"folders":        folderNames,
+		"folder_details": folders,
+		"object_count":   len(objects),
+		"folder_count":   len(folders),
+		"sort":           opts.Sort,
+		"order":          order,
+		"limit":          opts.Limit,
+		"next_cursor":    nextCursor,
+		"has_more":       next != nil,
 	})
 }
 
@@ -277,6 +370,13 @@ func (ctrl *Controller) DeleteObject(c *gin.Context) {
 	// Check if the user owns this bucket
 	if bucket.OwnerID != userID {
 		ctrl.Infra.Logger.WarningWithContextf(ctx, "[Object] User %s attempted to delete object in bucket %s owned by %s", userID, bucketID, bucket.OwnerID)
+		ctrl.recordAudit(c, auditEvent{
+			OwnerID:    bucket.OwnerID,
+			Action:     entity.AuditActionObjectDelete,
+			TargetType: entity.AuditTargetObject,
+			TargetID:   objectID.String(),
+			Result:     entity.AuditResultDenied,
+		})
 		utils.JSON403(c, "Forbidden: you don't have permission to delete objects in this bucket")
 		return
 	}
@@ -298,29 +398,60 @@ func (ctrl *Controller) DeleteObject(c *gin.Context) {
 
 	ctrl.Infra.Logger.InfoWithContextf(ctx, "[Object] Deleting object '%s' from bucket '%s'", objectID, bucket.Name)
 
-	// Store object URL for message before deletion
-	objectURL := object.URL
+	// Kept versions go with the object row, so their blobs are looked up first
+	versions, err := ctrl.Repository.ObjectVersionRepo.FindByObjectID(objectID)
+	if err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Object] Failed to load versions of object %s: %v", objectID, err)
+		utils.JSON500(c, "Failed to delete object")
+		return
+	}
 
-	// Delete object from database
-	err = ctrl.Repository.ObjectRepo.Delete(objectID)
+	// Delete object from database; the blob stays while other objects reference it
+	unreferenced, err := ctrl.Repository.ObjectRepo.Delete(objectID)
 	if err != nil {
 		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Object] Failed to delete object from database: %v", err)
+		ctrl.recordAudit(c, auditEvent{
+			OwnerID:    bucket.OwnerID,
+			Action:     entity.AuditActionObjectDelete,
+			TargetType: entity.AuditTargetObject,
+			TargetID:   objectID.String(),
+			TargetName: auditObjectName(object.ParentPath, object.OriginName),
+			Err:        err,
+		})
 		utils.JSON500(c, "Failed to delete object")
 		return
 	}
 
-	// Publish message to consumer to delete object from MinIO storage
-	deleteMsg := produce.DeleteObjectMessage{
-		BucketName: bucket.Name,
-		ObjectPath: objectURL, // URL field contains the hash.ext format path in MinIO
-		UserID:     userIDStr,
-	}
-	if err := ctrl.Infra.Produce.UploadService.PublishDeleteObject(ctx, deleteMsg); err != nil {
-		// Log the error but don't fail the request - DB record is already deleted
-		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Object] Failed to publish delete message for object %s: %v", objectID, err)
+	if unreferenced {
+		// Publish message to consumer to delete object from MinIO storage
+		deleteMsg := produce.DeleteObjectMessage{
+			BucketName: bucket.Name,
+			ObjectPath: object.StorageKey(),
+			UserID:     userIDStr,
+			BucketID:   bucketID.String(),
+			Size:       object.Size,
+		}
+		if err := ctrl.Infra.Produce.UploadService.PublishDeleteObject(ctx, deleteMsg); err != nil {
+			// Log the error but don't fail the request - DB record is already deleted
+			ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Object] Failed to publish delete message for object %s: %v", objectID, err)
+		} else {
+			ctrl.Infra.Logger.InfoWithContextf(ctx, "[Object] Published delete message for object %s in bucket %s", objectID, bucket.Name)
+		}
 	} else {
-		ctrl.Infra.Logger.InfoWithContextf(ctx, "[Object] Published delete message for object %s in bucket %s", objectID, bucket.Name)
-	}
+		// The blob is still shared, so only the object's usage goes
+		ctrl.Infra.Logger.InfoWithContextf(ctx, "[Object] Kept shared blob '%s' of object %s", object.StorageKey(), objectID)
+		ctrl.releaseUsage(ctx, bucket.OwnerID, bucketID, object.Size)
+	}
+	ctrl.purgeVersions(c, bucket, versions)
+
+	ctrl.recordAudit(c, auditEvent{
+		OwnerID:    bucket.OwnerID,
+		Action:     entity.AuditActionObjectDelete,
+		TargetType: entity.AuditTargetObject,
+		TargetID:   objectID.String(),
+		TargetName: auditObjectName(object.ParentPath, object.OriginName),
+		Before:     object,
+	})
 
 	ctrl.Infra.Logger.InfoWithContextf(ctx, "[Object] Successfully deleted object: %s", objectID)
 	utils.JSON200(c, gin.H{
@@ -410,7 +541,7 @@ func (ctrl *Controller) DownloadObject(c *gin.Context) {
 	ctrl.Infra.Logger.InfoWithContextf(ctx, "[Object] Streaming download for object '%s' from bucket '%s'", objectID, bucket.Name)
 
 	// Get object stream from MinIO
-	minioObject, objectInfo, err := ctrl.Infra.Minio.GetObject(ctx, bucket.Name, object.URL)
+	minioObject, objectInfo, err := ctrl.Infra.Minio.GetObject(ctx, bucket.Name, object.StorageKey())
 	if err != nil {
 		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Object] Failed to get object from MinIO: %v", err)
 		utils.JSON500(c, "Failed to retrieve object")
@@ -430,7 +561,9 @@ func (ctrl *Controller) DownloadObject(c *gin.Context) {
 
 	// Stream directly to client without buffering in RAM
 	c.Status(200)
-	_, err = io.Copy(c.Writer, minioObject)
+	written, err := io.Copy(c.Writer, minioObject)
+	// Charge the bytes actually sent, including partial transfers
+	ctrl.Infra.UsageMeter.Add(userID, bucketID, entity.MetricDownloadBytes, "", float64(written))
 	if err != nil {
 		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Object] Failed to stream object to client: %v", err)
 		// Can't send error response since we've already started writing
@@ -493,25 +626,63 @@ func (ctrl *Controller) DeleteObjectsByPath(c *gin.Context) {
 	// Check if the user owns this bucket
 	if bucket.OwnerID != userID {
 		ctrl.Infra.Logger.WarningWithContextf(ctx, "[Object] User %s attempted to delete path in bucket %s owned by %s", userID, bucketID, bucket.OwnerID)
+		ctrl.recordAudit(c, auditEvent{
+			OwnerID:    bucket.OwnerID,
+			Action:     entity.AuditActionObjectPathDelete,
+			TargetType: entity.AuditTargetPath,
+			TargetID:   bucketID.String(),
+			TargetName: deletePath,
+			Result:     entity.AuditResultDenied,
+		})
 		utils.JSON403(c, "Forbidden: you don't have permission to delete objects in this bucket")
 		return
 	}
 
 	ctrl.Infra.Logger.InfoWithContextf(ctx, "[Object] Deleting all objects at path '%s' in bucket '%s'", deletePath, bucket.Name)
 
+	versions, err := ctrl.Repository.ObjectVersionRepo.FindUnderPath(bucketID, deletePath)
+	if err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Object] Failed to load versions under '%s': %v", deletePath, err)
+		utils.JSON500(c, "Failed to delete objects")
+		return
+	}
+
 	// Delete all objects with this path prefix from database and get hashes for cleanup
-	deletedObjects, err := ctrl.Repository.ObjectRepo.DeleteByBucketIDAndPathPrefix(bucketID, deletePath)
+	deletedObjects, unreferenced, err := ctrl.Repository.ObjectRepo.DeleteByBucketIDAndPathPrefix(bucketID, deletePath)
 	if err != nil {
 		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Object] Failed to delete objects from database: %v", err)
+		ctrl.recordAudit(c, auditEvent{
+			OwnerID:    bucket.OwnerID,
+			Action:     entity.AuditActionObjectPathDelete,
+			TargetType: entity.AuditTargetPath,
+			TargetID:   bucketID.String(),
+			TargetName: deletePath,
+			Err:        err,
+		})
 		utils.JSON500(c, "Failed to delete objects")
 		return
 	}
 
 	// Publish message to consumer to delete path from MinIO storage
+	var deletedSize int64
+	for _, obj := range deletedObjects {
+		deletedSize += obj.Size
+	}
+	// Blobs under the path go with it, shared blobs stored elsewhere are named one by one
+	var outside []string
+	for _, key := range unreferenced {
+		if !strings.HasPrefix(key, deletePath+"/") {
+			outside = append(outside, key)
+		}
+	}
 	deleteMsg := produce.DeletePathMessage{
-		BucketName: bucket.Name,
-		Path:       deletePath,
-		UserID:     userIDStr,
+		BucketName:  bucket.Name,
+		Path:        deletePath,
+		UserID:      userIDStr,
+		BucketID:    bucketID.String(),
+		Size:        deletedSize,
+		ObjectCount: int64(len(deletedObjects)),
+		Keys:        outside,
 	}
 	if err := ctrl.Infra.Produce.UploadService.PublishDeletePath(ctx, deleteMsg); err != nil {
 		// Log the error but don't fail the request - DB records are already deleted
@@ -519,6 +690,16 @@ func (ctrl *Controller) DeleteObjectsByPath(c *gin.Context) {
 	} else {
 		ctrl.Infra.Logger.InfoWithContextf(ctx, "[Object] Published delete path message for path '%s' in bucket '%s'", deletePath, bucket.Name)
 	}
+	ctrl.purgeVersions(c, bucket, versions)
+
+	ctrl.recordAudit(c, auditEvent{
+		OwnerID:    bucket.OwnerID,
+		Action:     entity.AuditActionObjectPathDelete,
+		TargetType: entity.AuditTargetPath,
+		TargetID:   bucketID.String(),
+		TargetName: deletePath,
+		Before:     gin.H{"bucket": bucket.Name, "deleted_count": len(deletedObjects)},
+	})
 
 	ctrl.Infra.Logger.InfoWithContextf(ctx, "[Object] Successfully deleted %d objects at path '%s'", len(deletedObjects), deletePath)
 	utils.JSON200(c, gin.H{
@@ -564,6 +745,14 @@ func (ctrl *Controller) InitChunkedUpload(c *gin.Context) {
 	}
 
 	if bucket.OwnerID != userID {
+		ctrl.recordAudit(c, auditEvent{
+			OwnerID:    bucket.OwnerID,
+			Action:     entity.AuditActionUploadInit,
+			TargetType: entity.AuditTargetBucket,
+			TargetID:   bucketID.String(),
+			TargetName: bucket.Name,
+			Result:     entity.AuditResultDenied,
+		})
 		utils.JSON403(c, "Forbidden: you don't have permission to access this bucket")
 		return
 	}
@@ -608,6 +797,16 @@ func (ctrl *Controller) InitChunkedUpload(c *gin.Context) {
 		}
 	}
 
+	// Direct parts skip the proxy, so only the storage limits on part size and count apply
+	uploadMode := entity.UploadModeProxy
+	if req.Mode == entity.UploadModeDirect {
+		uploadMode = entity.UploadModeDirect
+		if chunkSize, err = directPartSize(req.FileSize, req.PreferredChunkSize); err != nil {
+			utils.JSON400(c, err.Error())
+			return
+		}
+	}
+
 	// 3. Calculate total chunks based on SERVER-DECIDED chunk size
 	totalChunks := int((req.FileSize + chunkSize - 1) / chunkSize)
 
@@ -615,17 +814,10 @@ func (ctrl *Controller) InitChunkedUpload(c *gin.Context) {
 	tempBucket := ctrl.Config.EnvConfig.LargeFile.TempBucket
 	tempPrefix := fmt.Sprintf("pending/%s/", uploadID.String())
 
-	customPath := strings.TrimSpace(req.Path)
-	if customPath != "" {
-		customPath = strings.Trim(customPath, "/\\")
-		customPath = strings.ReplaceAll(customPath, "\\", "/")
-		for strings.Contains(customPath, "//") {
-			customPath = strings.ReplaceAll(customPath, "//", "/")
-		}
-		if strings.Contains(customPath, "..") {
-			utils.JSON400(c, "Invalid path: path cannot contain '..'")
-			return
-		}
+	customPath, err := normalizeObjectPath(req.Path)
+	if err != nil {
+		utils.JSON400(c, err.Error())
+		return
 	}
 
 	contentType := req.ContentType
@@ -633,6 +825,25 @@ func (ctrl *Controller) InitChunkedUpload(c *gin.Context) {
 		contentType = "application/octet-stream"
 	}
 
+	if req.CallbackURL != "" {
+		if err := validateCallbackURL(req.CallbackURL); err != nil {
+			utils.JSON400(c, err.Error())
+			return
+		}
+	}
+
+	// Reject mode fails fast; the other modes are applied once the file is composed
+	conflictMode := conflict.Mode(bucket, req.Conflict)
+	if _, err := conflict.New(ctrl.Infra, ctrl.Repository).Resolve(bucket, customPath, req.FileName, conflictMode); err != nil {
+		if errors.Is(err, conflict.ErrNameTaken) {
+			utils.JSON409(c, "An object named '"+req.FileName+"' already exists at this path")
+			return
+		}
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Object] Failed to resolve name conflict: %v", err)
+		utils.JSON500(c, "Failed to check existing objects")
+		return
+	}
+
 	if err := ctrl.Infra.Minio.EnsureBucket(ctx, tempBucket); err != nil {
 		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Object] Failed to ensure pending bucket")
 		utils.JSON500(c, "Failed to prepare upload storage")
@@ -653,26 +864,99 @@ func (ctrl *Controller) InitChunkedUpload(c *gin.Context) {
 		Status:         entity.UploadStatusInit,
 		TempBucket:     tempBucket,
 		TempPrefix:     tempPrefix,
+		ConflictMode:   conflictMode,
+		UploadMode:     uploadMode,
+		CallbackURL:    req.CallbackURL,
+		CallbackSecret: req.CallbackSecret,
 		ExpiresAt:      time.Now().Add(UploadSessionExpiry),
 	}
 
-	if err := ctrl.Repository.UploadSessionRepo.Create(session); err != nil {
+	// The session reserves its file size against the quotas until it completes, fails or expires
+	if err := ctrl.ensureQuotas(c, bucket); err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Object] Failed to load storage quota: %v", err)
+		utils.JSON500(c, "Failed to load storage quota")
+		return
+	}
+
+	if uploadMode == entity.UploadModeDirect {
+		if err := ctrl.startDirectUpload(ctx, bucket, session); err != nil {
+			ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Object] Failed to start multipart upload: %v", err)
+			utils.JSON500(c, "Failed to prepare upload storage")
+			return
+		}
+	}
+
+	if err := ctrl.Repository.StorageQuotaRepo.ReserveSession(session); err != nil {
+		ctrl.abortDirectUpload(ctx, bucket.Name, session)
+		if errors.Is(err, repository.ErrQuotaExceeded) {
+			ctrl.respondQuotaExceeded(c, err)
+			return
+		}
 		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Object] Failed to create upload session")
+		ctrl.recordAudit(c, auditEvent{
+			OwnerID:    bucket.OwnerID,
+			Action:     entity.AuditActionUploadInit,
+			TargetType: entity.AuditTargetUploadSession,
+			TargetID:   uploadID.String(),
+			TargetName: auditObjectName(customPath, req.FileName),
+			Err:        err,
+		})
 		utils.JSON500(c, "Failed to initialize upload session")
 		return
 	}
 
+	ctrl.recordAudit(c, auditEvent{
+		OwnerID:    bucket.OwnerID,
+		Action:     entity.AuditActionUploadInit,
+		TargetType: entity.AuditTargetUploadSession,
+		TargetID:   uploadID.String(),
+		TargetName: auditObjectName(customPath, req.FileName),
+		After:      session,
+	})
+
 	ctrl.Infra.Logger.InfoWithContextf(ctx, "[Object] Initialized upload session %s for file '%s' (%d bytes, %d chunks of %d bytes each)",
 		uploadID, req.FileName, req.FileSize, totalChunks, chunkSize)
 
 	// Server returns the CONTRACT that client MUST follow
-	utils.JSON200(c, gin.H{
-		"upload_id":    uploadID.String(),
-		"chunk_size":   chunkSize,   // Client MUST use this chunk size
-		"total_chunks": totalChunks, // Expected number of chunks
-		"temp_prefix":  tempPrefix,
-		"expires_at":   session.ExpiresAt.Format(time.RFC3339),
-	})
+	response := gin.H{
+		"upload_id":     uploadID.String(),
+		"chunk_size":    chunkSize,   // Client MUST use this chunk size
+		"total_chunks":  totalChunks, // Expected number of chunks
+		"temp_prefix":   tempPrefix,
+		"conflict_mode": conflictMode,
+		"upload_mode":   uploadMode,
+		"expires_at":    session.ExpiresAt.Format(time.RFC3339),
+	}
+	if session.CallbackURL != "" {
+		response["callback_url"] = session.CallbackURL
+	}
+	if uploadMode == entity.UploadModeDirect {
+		parts, err := ctrl.presignParts(ctx, bucket, session, 1, MaxPresignedParts)
+		if err != nil {
+			ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Object] Failed to presign parts of upload %s: %v", uploadID, err)
+		}
+		response["parts"] = parts
+		response["parts_url"] = fmt.Sprintf("/api/v1/cloud/buckets/%s/chunked/%s/parts", bucketID, uploadID)
+	}
+
+	// Content already stored can complete the session without any chunk, once the client proves it holds it
+	if req.FileHash != "" {
+		ch := &instant.Challenge{
+			OwnerID:      bucket.OwnerID,
+			BucketID:     bucketID,
+			UploadID:     uploadID,
+			FileName:     req.FileName,
+			FileSize:     req.FileSize,
+			FileHash:     strings.ToLower(req.FileHash),
+			ContentType:  req.ContentType,
+			Path:         customPath,
+			ConflictMode: conflictMode,
+		}
+		if ctrl.issueInstantChallenge(c, bucket, ch) {
+			response["instant"] = instantChallengeResponse(ch)
+		}
+	}
+	utils.JSON200(c, response)
 }
 
 // UploadChunk handles uploading a single chunk
@@ -740,6 +1024,15 @@ func (ctrl *Controller) UploadChunk(c *gin.Context) {
 		return
 	}
 
+	if session.UploadMode == entity.UploadModeDirect {
+		utils.JSON400(c, "This upload sends its parts to the presigned URLs returned by init")
+		return
+	}
+	if session.UploadMode == entity.UploadModeTus {
+		utils.JSON400(c, "This upload is written through the tus endpoint")
+		return
+	}
+
 	if session.Status != entity.UploadStatusInit && session.Status != entity.UploadStatusUploading {
 		utils.JSON400(c, fmt.Sprintf("Upload session is not active, current status: %s", session.Status))
 		return
@@ -755,8 +1048,8 @@ func (ctrl *Controller) UploadChunk(c *gin.Context) {
 		return
 	}
 
-	// Check if UploadService is configured
-	if ctrl.Infra.UploadService == nil {
+	// Check if an uploader is configured
+	if ctrl.Infra.Uploader == nil {
 		utils.JSON500(c, "Upload service is not configured")
 		return
 	}
@@ -766,9 +1059,19 @@ func (ctrl *Controller) UploadChunk(c *gin.Context) {
 
 	file, header, err := c.Request.FormFile("chunk")
 	if err == nil {
+		defer file.Close()
+		// Multipart body is fully parsed, verify a declared hash before forwarding
+		if err := utils.VerifyBodyHash(c); err != nil {
+			ctrl.Infra.Logger.WarningWithContextf(ctx, "[Object] Chunk %d body hash verification failed: %v", chunkIndex, err)
+			utils.JSON400(c, err.Error())
+			return
+		}
 		chunkReader = file
 		chunkSize = header.Size
-		defer file.Close()
+	} else if errors.Is(err, utils.ErrBodyHashMismatch) {
+		ctrl.Infra.Logger.WarningWithContextf(ctx, "[Object] Chunk %d body hash verification failed: %v", chunkIndex, err)
+		utils.JSON400(c, err.Error())
+		return
 	} else {
 		chunkReader = c.Request.Body
 		chunkSize = c.Request.ContentLength
@@ -793,7 +1096,7 @@ func (ctrl *Controller) UploadChunk(c *gin.Context) {
 	// - bucket: "pending"
 	// - path: "{upload_id}"
 	// - is_hash: false (to preserve original chunk filename)
-	uploadResp, err := ctrl.Infra.UploadService.UploadChunkToService(
+	uploadResp, err := ctrl.Infra.Uploader.UploadChunkToService(
 		chunkReader,
 		chunkFileName,
 		"application/octet-stream",
@@ -801,14 +1104,30 @@ func (ctrl *Controller) UploadChunk(c *gin.Context) {
 		uploadID.String(),
 	)
 	if err != nil {
+		// A hash mismatch on a raw body aborts the stream to upload-service mid-request
+		if errors.Is(err, utils.ErrBodyHashMismatch) {
+			ctrl.Infra.Logger.WarningWithContextf(ctx, "[Object] Chunk %d aborted, body hash verification failed: %v", chunkIndex, err)
+			utils.JSON400(c, err.Error())
+			return
+		}
 		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Object] Failed to upload chunk %d via upload-service", chunkIndex)
 		utils.JSON500(c, "Failed to upload chunk")
 		return
 	}
 
+	// Roll back if the declared hash could not be confirmed: the chunk is not
+	// counted, so it is overwritten when the client retries this chunk_index
+	if err := utils.VerifyBodyHash(c); err != nil {
+		ctrl.Infra.Logger.WarningWithContextf(ctx, "[Object] Chunk %d rejected, body hash verification failed: %v", chunkIndex, err)
+		utils.JSON400(c, err.Error())
+		return
+	}
+
 	ctrl.Infra.Logger.InfoWithContextf(ctx, "[Object] Upload-service response: bucket=%s, path=%s, hash=%s",
 		uploadResp.Bucket, uploadResp.FilePath, uploadResp.FileHash)
 
+	ctrl.Infra.UsageMeter.Add(userID, bucketID, entity.MetricUploadBytes, "", float64(chunkSize))
+
 	if err := ctrl.Repository.UploadSessionRepo.IncrementUploadedChunks(uploadID); err != nil {
 		ctrl.Infra.Logger.WarningWithContextf(ctx, "[Object] Failed to update upload progress: %v", err)
 	}
@@ -818,6 +1137,9 @@ func (ctrl *Controller) UploadChunk(c *gin.Context) {
 	if updatedSession != nil {
 		uploadedChunks = updatedSession.UploadedChunks
 	}
+	progress := *session
+	progress.Status, progress.UploadedChunks = entity.UploadStatusUploading, uploadedChunks
+	ctrl.publishUploadEvent(ctx, &progress, infra.NewUploadEvent(infra.UploadEventChunkReceived, &progress))
 
 	ctrl.Infra.Logger.InfoWithContextf(ctx, "[Object] Chunk %d uploaded successfully via upload-service (%d/%d)",
 		chunkIndex, uploadedChunks, session.TotalChunks)
@@ -861,6 +1183,14 @@ func (ctrl *Controller) CompleteChunkedUpload(c *gin.Context) {
 	}
 
 	if bucket.OwnerID != userID {
+		ctrl.recordAudit(c, auditEvent{
+			OwnerID:    bucket.OwnerID,
+			Action:     entity.AuditActionUploadComplete,
+			TargetType: entity.AuditTargetBucket,
+			TargetID:   bucketID.String(),
+			TargetName: bucket.Name,
+			Result:     entity.AuditResultDenied,
+		})
 		utils.JSON403(c, "Forbidden: you don't have permission to access this bucket")
 		return
 	}
@@ -893,12 +1223,94 @@ func (ctrl *Controller) CompleteChunkedUpload(c *gin.Context) {
 		return
 	}
 
-	// Verify all chunks have been uploaded by checking session uploaded count
-	if session.UploadedChunks != session.TotalChunks {
+	// A proven instant upload needs none of the chunks
+	if req.ChallengeID != "" {
+		ctrl.completeInstantUpload(c, bucket, session, req.ChallengeID, req.Proof)
+		return
+	}
+
+	// Verify all chunks have been uploaded by checking session uploaded count; direct parts are checked against storage
+	if session.UploadMode != entity.UploadModeDirect && session.UploadedChunks != session.TotalChunks {
 		utils.JSON400(c, fmt.Sprintf("Missing chunks: expected %d, uploaded %d", session.TotalChunks, session.UploadedChunks))
 		return
 	}
 
+	// Archives are extracted by the consumer once the upload-service has composed them
+	uploadType := entity.UploadTypeFile
+	extractPath := ""
+	if req.Extract {
+		if utils.ArchiveFormatFromName(session.FileName) == "" {
+			utils.JSON400(c, "Only .zip, .tar, .tar.gz and .tgz uploads can be extracted")
+			return
+		}
+		extractPath = session.CustomPath
+		if req.ExtractPath != nil {
+			if extractPath, err = normalizeObjectPath(*req.ExtractPath); err != nil {
+				utils.JSON400(c, err.Error())
+				return
+			}
+		}
+		uploadType = entity.UploadTypeArchive
+	}
+	if err := ctrl.Repository.UploadSessionRepo.UpdateUploadType(uploadID, uploadType, extractPath); err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Object] Failed to save upload type of %s: %v", uploadID, err)
+		utils.JSON500(c, "Failed to save extraction options")
+		return
+	}
+
+	if session.UploadMode == entity.UploadModeDirect {
+		session.UploadType, session.ExtractPath = uploadType, extractPath
+		ctrl.completeDirectUpload(c, bucket, session, req.Parts)
+		return
+	}
+
+	if err := ctrl.queueChunkCompose(ctx, bucket, session, uploadType); err != nil {
+		ctrl.recordAudit(c, auditEvent{
+			OwnerID:    bucket.OwnerID,
+			Action:     entity.AuditActionUploadComplete,
+			TargetType: entity.AuditTargetUploadSession,
+			TargetID:   uploadID.String(),
+			TargetName: auditObjectName(session.CustomPath, session.FileName),
+			Err:        err,
+		})
+		utils.JSON500(c, "Failed to queue file for processing")
+		return
+	}
+
+	// The object itself is recorded by the consumer once the chunks are composed
+	ctrl.recordAudit(c, auditEvent{
+		OwnerID:    bucket.OwnerID,
+		Action:     entity.AuditActionUploadComplete,
+		TargetType: entity.AuditTargetUploadSession,
+		TargetID:   uploadID.String(),
+		TargetName: auditObjectName(session.CustomPath, session.FileName),
+	})
+
+	ctrl.Infra.Logger.InfoWithContextf(ctx, "[Object] Upload session %s queued for processing (%d chunks)", uploadID, session.TotalChunks)
+
+	// Return immediately - client should poll for status
+	response := gin.H{
+		"message":      "Upload accepted for processing",
+		"upload_id":    uploadID.String(),
+		"status":       "processing",
+		"upload_type":  uploadType,
+		"total_chunks": session.TotalChunks,
+		"file_name":    session.FileName,
+		"file_size":    session.FileSize,
+		"status_url":   fmt.Sprintf("/api/v1/cloud/buckets/%s/chunked/%s/status", bucketID, uploadID),
+	}
+	if req.Extract {
+		response["extract_path"] = extractPath
+	}
+	utils.JSON202(c, response)
+}
+
+// queueChunkCompose moves a session whose chunks are all stored to PROCESSING and publishes it for
+// composing; the consumer records the object once it is composed. The status is reverted when the
+// message cannot be published.
+func (ctrl *Controller) queueChunkCompose(ctx context.Context, bucket *entity.Bucket, session *entity.UploadSession, uploadType string) error {
+	uploadID := session.ID
+
 	// Update session status to processing IMMEDIATELY (fast operation)
 	if err := ctrl.Repository.UploadSessionRepo.UpdateStatus(uploadID, entity.UploadStatusProcessing); err != nil {
 		ctrl.Infra.Logger.WarningWithContextf(ctx, "[Object] Failed to update session status: %v", err)
@@ -908,9 +1320,9 @@ func (ctrl *Controller) CompleteChunkedUpload(c *gin.Context) {
 	// Upload-service will compose chunks and move to final destination
 	msg := produce.ChunkCompleteMessage{
 		UploadID:     uploadID.String(),
-		BucketID:     bucketID.String(),
+		BucketID:     bucket.ID.String(),
 		BucketName:   bucket.Name,
-		UserID:       userID.String(),
+		UserID:       session.UserID.String(),
 		TempBucket:   "pending",                             // Chunks are in "pending" bucket
 		TempPrefix:   fmt.Sprintf("%s/", uploadID.String()), // Path is upload_id/
 		FileName:     session.FileName,
@@ -923,6 +1335,7 @@ func (ctrl *Controller) CompleteChunkedUpload(c *gin.Context) {
 		Metadata: map[string]string{
 			"content_type": session.ContentType,
 			"custom_path":  session.CustomPath,
+			"upload_type":  uploadType,
 		},
 	}
 
@@ -930,22 +1343,12 @@ func (ctrl *Controller) CompleteChunkedUpload(c *gin.Context) {
 		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Object] Failed to publish chunk_complete message to queue")
 		// Revert status
 		_ = ctrl.Repository.UploadSessionRepo.UpdateStatus(uploadID, entity.UploadStatusUploading)
-		utils.JSON500(c, "Failed to queue file for processing")
-		return
+		return err
 	}
 
-	ctrl.Infra.Logger.InfoWithContextf(ctx, "[Object] Upload session %s queued for processing (%d chunks)", uploadID, session.TotalChunks)
-
-	// Return immediately - client should poll for status
-	utils.JSON202(c, gin.H{
-		"message":      "Upload accepted for processing",
-		"upload_id":    uploadID.String(),
-		"status":       "processing",
-		"total_chunks": session.TotalChunks,
-		"file_name":    session.FileName,
-		"file_size":    session.FileSize,
-		"status_url":   fmt.Sprintf("/api/v1/cloud/buckets/%s/chunked/%s/status", bucketID, uploadID),
-	})
+	session.Status = entity.UploadStatusProcessing
+	ctrl.publishUploadEvent(ctx, session, infra.NewUploadEvent(infra.UploadEventProcessing, session))
+	return nil
 }
 
 // GetUploadProgress returns the current progress of an upload session
@@ -996,6 +1399,16 @@ func (ctrl *Controller) GetUploadProgress(c *gin.Context) {
 		return
 	}
 
+	// Direct parts go straight to storage, which is the only place counting them
+	if session.UploadMode == entity.UploadModeDirect && session.Active() {
+		parts, err := ctrl.Infra.Minio.ListUploadedParts(ctx, bucket.Name, session.StorageKey, session.MultipartID)
+		if err != nil {
+			ctrl.Infra.Logger.WarningWithContextf(ctx, "[Object] Failed to list parts of upload %s: %v", uploadID, err)
+		} else {
+			session.UploadedChunks = len(parts)
+		}
+	}
+
 	progress := float64(0)
 	if session.TotalChunks > 0 {
 		progress = float64(session.UploadedChunks) / float64(session.TotalChunks) * 100
@@ -1040,6 +1453,14 @@ func (ctrl *Controller) AbortChunkedUpload(c *gin.Context) {
 	}
 
 	if bucket.OwnerID != userID {
+		ctrl.recordAudit(c, auditEvent{
+			OwnerID:    bucket.OwnerID,
+			Action:     entity.AuditActionUploadAbort,
+			TargetType: entity.AuditTargetBucket,
+			TargetID:   bucketID.String(),
+			TargetName: bucket.Name,
+			Result:     entity.AuditResultDenied,
+		})
 		utils.JSON403(c, "Forbidden: you don't have permission to access this bucket")
 		return
 	}
@@ -1058,17 +1479,31 @@ func (ctrl *Controller) AbortChunkedUpload(c *gin.Context) {
 		return
 	}
 
-	go func() {
-		// Cleanup chunks from pending bucket
-		_ = ctrl.Infra.Minio.DeleteObjectsWithPrefix(ctx, session.TempBucket, session.TempPrefix)
-	}()
-
 	if err := ctrl.Repository.UploadSessionRepo.Delete(uploadID); err != nil {
 		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Object] Failed to delete upload session")
+		ctrl.recordAudit(c, auditEvent{
+			OwnerID:    bucket.OwnerID,
+			Action:     entity.AuditActionUploadAbort,
+			TargetType: entity.AuditTargetUploadSession,
+			TargetID:   uploadID.String(),
+			TargetName: auditObjectName(session.CustomPath, session.FileName),
+			Err:        err,
+		})
 		utils.JSON500(c, "Failed to abort upload")
 		return
 	}
 
+	ctrl.recordAudit(c, auditEvent{
+		OwnerID:    bucket.OwnerID,
+		Action:     entity.AuditActionUploadAbort,
+		TargetType: entity.AuditTargetUploadSession,
+		TargetID:   uploadID.String(),
+		TargetName: auditObjectName(session.CustomPath, session.FileName),
+		Before:     session,
+	})
+
+	ctrl.removeChunks(*session)
+
 	ctrl.Infra.Logger.InfoWithContextf(ctx, "[Object] Upload session aborted: %s", uploadID)
 
 	utils.JSON200(c, gin.H{
@@ -1184,10 +1619,26 @@ func (ctrl *Controller) GetChunkedUploadStatus(c *gin.Context) {
 			}
 		}
 
+	case entity.UploadStatusExtracting:
+		response["message"] = fmt.Sprintf("Archive stored, extracting entries: %d extracted", session.Extraction.Entries)
+		response["is_complete"] = false
+		response["file_hash"] = session.FileHash
+		if session.Extraction.TotalEntries > 0 {
+			response["extract_progress"] = float64(session.Extraction.Entries+session.Extraction.Skipped) / float64(session.Extraction.TotalEntries) * 100
+		}
+
 	case entity.UploadStatusFailed:
 		response["message"] = "Upload failed during processing"
 		response["is_complete"] = true
 		response["error"] = "An error occurred while processing the upload. Please try again."
+		if session.ErrorMessage != "" {
+			response["error"] = session.ErrorMessage
+		}
+		if session.Extraction.Error != "" {
+			// The archive itself was stored; only extracting it failed
+			response["message"] = "Archive extraction failed"
+			response["error"] = session.Extraction.Error
+		}
 
 	case entity.UploadStatusExpired:
 		response["message"] = "Upload session has expired"
@@ -1195,8 +1646,15 @@ func (ctrl *Controller) GetChunkedUploadStatus(c *gin.Context) {
 		response["error"] = "The upload session has expired. Please start a new upload."
 	}
 
-	// Check if session is expired
-	if time.Now().After(session.ExpiresAt) && session.Status != entity.UploadStatusCompleted {
+	if session.UploadType == entity.UploadTypeArchive {
+		response["upload_type"] = session.UploadType
+		response["extract_path"] = session.ExtractPath
+		response["extraction"] = session.Extraction
+	}
+
+	// Check if session is expired; an archive keeps extracting past the upload deadline
+	if time.Now().After(session.ExpiresAt) && session.Status != entity.UploadStatusCompleted &&
+		session.Status != entity.UploadStatusExtracting && session.Extraction.Error == "" && session.ErrorMessage == "" {
 		response["status"] = string(entity.UploadStatusExpired)
 		response["message"] = "Upload session has expired"
 		response["is_complete"] = true
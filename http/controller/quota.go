@@ -0,0 +1,335 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tnqbao/gau-cloud-orchestrator/entity"
+	"github.com/tnqbao/gau-cloud-orchestrator/http/controller/dto"
+	"github.com/tnqbao/gau-cloud-orchestrator/quotawarn"
+	"github.com/tnqbao/gau-cloud-orchestrator/repository"
+	"github.com/tnqbao/gau-cloud-orchestrator/utils"
+)
+
+// accountQuotaDefaults are the limits a new account quota starts with
+func (ctrl *Controller) accountQuotaDefaults() entity.QuotaLimits {
+	cfg := ctrl.Config.EnvConfig.Quota
+	return entity.QuotaLimits{
+		MaxBytes:      cfg.AccountMaxBytes,
+		MaxObjects:    cfg.AccountMaxObjects,
+		MaxObjectSize: cfg.MaxObjectSize,
+	}
+}
+
+// bucketQuotaDefaults are the limits a new bucket quota starts with
+func (ctrl *Controller) bucketQuotaDefaults() entity.QuotaLimits {
+	cfg := ctrl.Config.EnvConfig.Quota
+	return entity.QuotaLimits{
+		MaxBytes:      cfg.BucketMaxBytes,
+		MaxObjects:    cfg.BucketMaxObjects,
+		MaxObjectSize: cfg.MaxObjectSize,
+	}
+}
+
+// ensureQuotas makes sure the account and bucket quotas exist before a reservation
+func (ctrl *Controller) ensureQuotas(c *gin.Context, bucket *entity.Bucket) error {
+	if _, err := ctrl.Repository.StorageQuotaRepo.EnsureAccount(bucket.OwnerID, ctrl.accountQuotaDefaults(), c.GetString("email")); err != nil {
+		return err
+	}
+	_, err := ctrl.Repository.StorageQuotaRepo.EnsureBucket(bucket.OwnerID, bucket.ID, ctrl.bucketQuotaDefaults())
+	return err
+}
+
+// respondQuotaExceeded writes a 413 for a rejected reservation, or a 500 for any other error
+func (ctrl *Controller) respondQuotaExceeded(c *gin.Context, err error) {
+	var exceeded *repository.QuotaExceededError
+	if !errors.As(err, &exceeded) {
+		ctrl.Infra.Logger.ErrorWithContextf(c.Request.Context(), err, "[Quota] Failed to reserve storage: %v", err)
+		utils.JSON500(c, "Failed to reserve storage")
+		return
+	}
+
+	ctrl.Infra.Logger.WarningWithContextf(c.Request.Context(), "[Quota] Upload rejected: %v", exceeded)
+	utils.JSON413(c, gin.H{
+		"error":   "QUOTA_EXCEEDED",
+		"message": fmt.Sprintf("Upload exceeds the %s quota", exceeded.Scope),
+		"scope":   exceeded.Scope,
+		"limit":   exceeded.Limit,
+		"max":     exceeded.Max,
+	})
+}
+
+// releaseUsage gives back space reserved by ReserveUsage for an upload that failed
+func (ctrl *Controller) releaseUsage(ctx context.Context, ownerID, bucketID uuid.UUID, size int64) {
+	if err := ctrl.Repository.StorageQuotaRepo.ReleaseUsage(ownerID, bucketID, size, 1); err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Quota] Failed to release %d reserved bytes: %v", size, err)
+	}
+}
+
+// GetQuota returns the caller's account quota and the quotas of their buckets
+// GET /api/v1/cloud/quota
+func (ctrl *Controller) GetQuota(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		utils.JSON401(c, "Unauthorized: user_id not found")
+		return
+	}
+
+	ctrl.Infra.Logger.InfoWithContextf(ctx, "[Quota] Getting quotas for user_id: %s", userID)
+	ctrl.respondOwnerQuotas(c, userID, c.GetString("email"))
+}
+
+// UpdateQuotaNotification sets the address that receives the caller's quota warnings
+// PUT /api/v1/cloud/quota
+func (ctrl *Controller) UpdateQuotaNotification(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		utils.JSON401(c, "Unauthorized: user_id not found")
+		return
+	}
+
+	var req dto.UpdateQuotaNotificationRequestDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Quota] Failed to bind JSON: %v", err)
+		utils.JSON400(c, "Invalid request payload")
+		return
+	}
+
+	quota, err := ctrl.Repository.StorageQuotaRepo.EnsureAccount(userID, ctrl.accountQuotaDefaults(), c.GetString("email"))
+	if err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Quota] Failed to load account quota: %v", err)
+		utils.JSON500(c, "Failed to load quota")
+		return
+	}
+
+	ctrl.saveQuota(c, quota, dto.UpdateQuotaLimitsRequestDTO{NotifyEmail: &req.NotifyEmail}, "")
+}
+
+// GetBucketQuota returns the quota of one of the caller's buckets
+// GET /api/v1/cloud/buckets/:id/quota
+func (ctrl *Controller) GetBucketQuota(c *gin.Context) {
+	bucket, ok := ctrl.ownedBucketForQuota(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+
+	quota, err := ctrl.Repository.StorageQuotaRepo.EnsureBucket(bucket.OwnerID, bucket.ID, ctrl.bucketQuotaDefaults())
+	if err == nil {
+		err = ctrl.Repository.StorageQuotaRepo.FillReserved(quota)
+	}
+	if err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Quota] Failed to load bucket quota: %v", err)
+		utils.JSON500(c, "Failed to load quota")
+		return
+	}
+
+	utils.JSON200(c, gin.H{"quota": quota})
+}
+
+// UpdateBucketQuota sets the limits of one of the caller's buckets. The account
+// quota still applies on top of it.
+// PUT /api/v1/cloud/buckets/:id/quota
+func (ctrl *Controller) UpdateBucketQuota(c *gin.Context) {
+	bucket, ok := ctrl.ownedBucketForQuota(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+
+	var req dto.UpdateQuotaLimitsRequestDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Quota] Failed to bind JSON: %v", err)
+		utils.JSON400(c, "Invalid request payload")
+		return
+	}
+	// Notifications are configured once per account
+	req.NotifyEmail = nil
+
+	quota, err := ctrl.Repository.StorageQuotaRepo.EnsureBucket(bucket.OwnerID, bucket.ID, ctrl.bucketQuotaDefaults())
+	if err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Quota] Failed to load bucket quota: %v", err)
+		utils.JSON500(c, "Failed to load quota")
+		return
+	}
+
+	ctrl.saveQuota(c, quota, req, bucket.Name)
+}
+
+// AdminGetUserQuota returns the account and bucket quotas of any user
+// GET /api/v1/cloud/admin/users/:user_id/quota
+func (ctrl *Controller) AdminGetUserQuota(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		utils.JSON400(c, "Invalid user_id format")
+		return
+	}
+
+	ctrl.respondOwnerQuotas(c, userID, "")
+}
+
+// AdminUpdateUserQuota sets the account limits of any user
+// PUT /api/v1/cloud/admin/users/:user_id/quota
+func (ctrl *Controller) AdminUpdateUserQuota(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		utils.JSON400(c, "Invalid user_id format")
+		return
+	}
+
+	var req dto.UpdateQuotaLimitsRequestDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Admin] Failed to bind JSON: %v", err)
+		utils.JSON400(c, "Invalid request payload")
+		return
+	}
+
+	quota, err := ctrl.Repository.StorageQuotaRepo.EnsureAccount(userID, ctrl.accountQuotaDefaults(), "")
+	if err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Admin] Failed to load account quota: %v", err)
+		utils.JSON500(c, "Failed to load quota")
+		return
+	}
+
+	ctrl.Infra.Logger.InfoWithContextf(ctx, "[Admin] Updating account quota of user_id: %s (requested by %s)", userID, c.GetString("user_id"))
+	ctrl.saveQuota(c, quota, req, "")
+}
+
+func (ctrl *Controller) respondOwnerQuotas(c *gin.Context, ownerID uuid.UUID, email string) {
+	ctx := c.Request.Context()
+
+	if _, err := ctrl.Repository.StorageQuotaRepo.EnsureAccount(ownerID, ctrl.accountQuotaDefaults(), email); err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Quota] Failed to load account quota: %v", err)
+		utils.JSON500(c, "Failed to load quota")
+		return
+	}
+
+	quotas, err := ctrl.Repository.StorageQuotaRepo.FindByOwnerID(ownerID)
+	if err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Quota] Failed to list quotas: %v", err)
+		utils.JSON500(c, "Failed to load quota")
+		return
+	}
+
+	var account *entity.StorageQuota
+	buckets := make([]entity.StorageQuota, 0, len(quotas))
+	for i := range quotas {
+		if err := ctrl.Repository.StorageQuotaRepo.FillReserved(&quotas[i]); err != nil {
+			ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Quota] Failed to sum reserved storage: %v", err)
+			utils.JSON500(c, "Failed to load quota")
+			return
+		}
+		if quotas[i].Scope == entity.QuotaScopeAccount {
+			account = &quotas[i]
+		} else {
+			buckets = append(buckets, quotas[i])
+		}
+	}
+
+	utils.JSON200(c, gin.H{
+		"account": account,
+		"buckets": buckets,
+	})
+}
+
+// saveQuota applies the requested changes, audits them and returns the updated quota
+func (ctrl *Controller) saveQuota(c *gin.Context, quota *entity.StorageQuota, req dto.UpdateQuotaLimitsRequestDTO, bucketName string) {
+	ctx := c.Request.Context()
+	before := *quota
+
+	if req.MaxBytes != nil {
+		quota.MaxBytes = *req.MaxBytes
+	}
+	if req.MaxObjects != nil {
+		quota.MaxObjects = *req.MaxObjects
+	}
+	if req.MaxObjectSize != nil {
+		quota.MaxObjectSize = *req.MaxObjectSize
+	}
+	if req.NotifyEmail != nil {
+		quota.NotifyEmail = *req.NotifyEmail
+	}
+
+	event := auditEvent{
+		OwnerID:    quota.OwnerID,
+		Action:     entity.AuditActionQuotaUpdate,
+		TargetType: entity.AuditTargetQuota,
+		TargetID:   quota.ID.String(),
+		TargetName: string(quota.Scope),
+		Before:     before,
+		After:      quota,
+	}
+	if bucketName != "" {
+		event.TargetName = bucketName
+	}
+
+	if err := ctrl.Repository.StorageQuotaRepo.Update(quota); err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Quota] Failed to update quota: %v", err)
+		event.Err = err
+		ctrl.recordAudit(c, event)
+		utils.JSON500(c, "Failed to update quota")
+		return
+	}
+	ctrl.recordAudit(c, event)
+
+	if err := ctrl.Repository.StorageQuotaRepo.FillReserved(quota); err != nil {
+		ctrl.Infra.Logger.WarningWithContextf(ctx, "[Quota] Failed to sum reserved storage: %v", err)
+	}
+
+	// Lowering a limit can put existing usage over a threshold
+	quotawarn.Notify(ctx, ctrl.Infra, ctrl.Repository, ctrl.quotasForWarning(quota))
+
+	ctrl.Infra.Logger.InfoWithContextf(ctx, "[Quota] Updated %s quota %s", quota.Scope, quota.ID)
+	utils.JSON200(c, gin.H{
+		"message": "Quota updated successfully",
+		"quota":   quota,
+	})
+}
+
+// quotasForWarning pairs a bucket quota with its account quota so the warning
+// can be addressed to the account's notify_email
+func (ctrl *Controller) quotasForWarning(quota *entity.StorageQuota) []entity.StorageQuota {
+	if quota.Scope == entity.QuotaScopeAccount {
+		return []entity.StorageQuota{*quota}
+	}
+	account, err := ctrl.Repository.StorageQuotaRepo.FindAccount(quota.OwnerID)
+	if err != nil {
+		return []entity.StorageQuota{*quota}
+	}
+	return []entity.StorageQuota{*account, *quota}
+}
+
+func (ctrl *Controller) ownedBucketForQuota(c *gin.Context) (*entity.Bucket, bool) {
+	ctx := c.Request.Context()
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		utils.JSON401(c, "Unauthorized: user_id not found")
+		return nil, false
+	}
+
+	bucketID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.JSON400(c, "Invalid bucket_id format")
+		return nil, false
+	}
+
+	bucket, err := ctrl.Repository.BucketRepo.FindByID(bucketID)
+	if err != nil {
+		utils.JSON404(c, "Bucket not found")
+		return nil, false
+	}
+
+	if bucket.OwnerID != userID {
+		ctrl.Infra.Logger.WarningWithContextf(ctx, "[Quota] User %s attempted to access quota of bucket %s owned by %s", userID, bucketID, bucket.OwnerID)
+		utils.JSON403(c, "Forbidden: you don't have permission to access this bucket")
+		return nil, false
+	}
+
+	return bucket, true
+}
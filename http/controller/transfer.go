@@ -0,0 +1,404 @@
+package controller
+
+import (
+	"errors"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tnqbao/gau-cloud-orchestrator/entity"
+	"github.com/tnqbao/gau-cloud-orchestrator/http/controller/dto"
+	"github.com/tnqbao/gau-cloud-orchestrator/infra/produce"
+	"github.com/tnqbao/gau-cloud-orchestrator/repository"
+	"github.com/tnqbao/gau-cloud-orchestrator/transfer"
+	"github.com/tnqbao/gau-cloud-orchestrator/utils"
+	"gorm.io/gorm"
+)
+
+// FolderTransferSyncLimit is the largest folder (in objects) copied or moved within the request;
+// bigger folders are handed to the object transfer consumer
+const FolderTransferSyncLimit = 100
+
+// CopyObject copies an object server-side to another folder or bucket of the caller
+// POST /api/v1/cloud/buckets/:id/objects/:object_id/copy
+func (ctrl *Controller) CopyObject(c *gin.Context) {
+	ctrl.transferObject(c, transfer.ModeCopy)
+}
+
+// MoveObject moves an object to another folder or bucket of the caller, optionally renaming it
+// POST /api/v1/cloud/buckets/:id/objects/:object_id/move
+func (ctrl *Controller) MoveObject(c *gin.Context) {
+	ctrl.transferObject(c, transfer.ModeMove)
+}
+
+// RenameObject renames an object in place; the stored bytes are not touched
+// POST /api/v1/cloud/buckets/:id/objects/:object_id/rename
+func (ctrl *Controller) RenameObject(c *gin.Context) {
+	bucket, ok := ctrl.ownedBucket(c, "[Transfer]")
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+
+	var req dto.RenameRequestDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Transfer] Failed to bind JSON: %v", err)
+		utils.JSON400(c, "Invalid request payload")
+		return
+	}
+	if err := validateEntryName(req.Name); err != nil {
+		utils.JSON400(c, err.Error())
+		return
+	}
+
+	object, ok := ctrl.bucketObject(c, bucket)
+	if !ok {
+		return
+	}
+
+	before := *object
+	result, err := transfer.New(ctrl.Infra, ctrl.Repository).Object(ctx, transfer.ModeMove, bucket, object, bucket, object.ParentPath, req.Name)
+	ctrl.recordAudit(c, auditEvent{
+		OwnerID:    bucket.OwnerID,
+		Action:     entity.AuditActionObjectRename,
+		TargetType: entity.AuditTargetObject,
+		TargetID:   object.ID.String(),
+		TargetName: auditObjectName(before.ParentPath, before.OriginName),
+		Err:        err,
+		Before:     before,
+		After:      transferAuditAfter(result, err),
+	})
+	if err != nil {
+		ctrl.respondTransferError(c, err)
+		return
+	}
+
+	utils.JSON200(c, gin.H{
+		"message": "Object renamed successfully",
+		"object":  result,
+	})
+}
+
+func (ctrl *Controller) transferObject(c *gin.Context, mode transfer.Mode) {
+	bucket, ok := ctrl.ownedBucket(c, "[Transfer]")
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+
+	var req dto.TransferObjectRequestDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Transfer] Failed to bind JSON: %v", err)
+		utils.JSON400(c, "Invalid request payload")
+		return
+	}
+	if req.Name != "" {
+		if err := validateEntryName(req.Name); err != nil {
+			utils.JSON400(c, err.Error())
+			return
+		}
+	}
+
+	object, ok := ctrl.bucketObject(c, bucket)
+	if !ok {
+		return
+	}
+
+	dst, ok := ctrl.destinationBucket(c, bucket, req.DestBucketID)
+	if !ok {
+		return
+	}
+
+	dstPath := object.ParentPath
+	if req.DestPath != nil {
+		var err error
+		if dstPath, err = normalizeObjectPath(*req.DestPath); err != nil {
+			utils.JSON400(c, err.Error())
+			return
+		}
+	}
+
+	if mode == transfer.ModeCopy || dst.ID != bucket.ID {
+		if err := ctrl.ensureQuotas(c, dst); err != nil {
+			ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Transfer] Failed to load storage quota: %v", err)
+			utils.JSON500(c, "Failed to load storage quota")
+			return
+		}
+	}
+
+	action := entity.AuditActionObjectCopy
+	if mode == transfer.ModeMove {
+		action = entity.AuditActionObjectMove
+	}
+
+	before := *object
+	result, err := transfer.New(ctrl.Infra, ctrl.Repository).Object(ctx, mode, bucket, object, dst, dstPath, req.Name)
+	ctrl.recordAudit(c, auditEvent{
+		OwnerID:    bucket.OwnerID,
+		Action:     action,
+		TargetType: entity.AuditTargetObject,
+		TargetID:   before.ID.String(),
+		TargetName: auditObjectName(before.ParentPath, before.OriginName),
+		Err:        err,
+		Before:     before,
+		After:      transferAuditAfter(result, err),
+	})
+	if err != nil {
+		ctrl.respondTransferError(c, err)
+		return
+	}
+
+	ctrl.Infra.Logger.InfoWithContextf(ctx, "[Transfer] %s object '%s' from bucket '%s' to '%s' in bucket '%s'",
+		mode, before.ID, bucket.Name, auditObjectName(result.ParentPath, result.OriginName), dst.Name)
+	utils.JSON200(c, gin.H{
+		"message": "Object " + string(mode) + " completed successfully",
+		"object":  result,
+	})
+}
+
+// CopyFolder copies a folder with everything below it to a new path, in this or another bucket
+// POST /api/v1/cloud/buckets/:id/folders/copy
+func (ctrl *Controller) CopyFolder(c *gin.Context) {
+	ctrl.transferFolder(c, transfer.ModeCopy)
+}
+
+// MoveFolder moves a folder with everything below it to a new path, in this or another bucket
+// POST /api/v1/cloud/buckets/:id/folders/move
+func (ctrl *Controller) MoveFolder(c *gin.Context) {
+	ctrl.transferFolder(c, transfer.ModeMove)
+}
+
+// RenameFolder renames a folder in place
+// POST /api/v1/cloud/buckets/:id/folders/rename
+func (ctrl *Controller) RenameFolder(c *gin.Context) {
+	bucket, ok := ctrl.ownedBucket(c, "[Transfer]")
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+
+	var req dto.RenameRequestDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Transfer] Failed to bind JSON: %v", err)
+		utils.JSON400(c, "Invalid request payload")
+		return
+	}
+	if err := validateEntryName(req.Name); err != nil {
+		utils.JSON400(c, err.Error())
+		return
+	}
+
+	srcPath, err := normalizeObjectPath(req.Path)
+	if err != nil || srcPath == "" {
+		utils.JSON400(c, "Invalid path: a folder path is required")
+		return
+	}
+
+	parent := path.Dir(srcPath)
+	if parent == "." {
+		parent = ""
+	}
+	ctrl.runFolderTransfer(c, transfer.ModeMove, entity.AuditActionFolderRename, bucket, srcPath, bucket, path.Join(parent, req.Name))
+}
+
+func (ctrl *Controller) transferFolder(c *gin.Context, mode transfer.Mode) {
+	bucket, ok := ctrl.ownedBucket(c, "[Transfer]")
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+
+	var req dto.TransferFolderRequestDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Transfer] Failed to bind JSON: %v", err)
+		utils.JSON400(c, "Invalid request payload")
+		return
+	}
+
+	srcPath, err := normalizeObjectPath(req.Path)
+	if err != nil || srcPath == "" {
+		utils.JSON400(c, "Invalid path: a folder path is required")
+		return
+	}
+	dstPath, err := normalizeObjectPath(req.DestPath)
+	if err != nil || dstPath == "" {
+		utils.JSON400(c, "Invalid dest_path: a folder path is required")
+		return
+	}
+
+	dst, ok := ctrl.destinationBucket(c, bucket, req.DestBucketID)
+	if !ok {
+		return
+	}
+
+	action := entity.AuditActionFolderCopy
+	if mode == transfer.ModeMove {
+		action = entity.AuditActionFolderMove
+	}
+	ctrl.runFolderTransfer(c, mode, action, bucket, srcPath, dst, dstPath)
+}
+
+// runFolderTransfer transfers small folders within the request and queues larger ones
+func (ctrl *Controller) runFolderTransfer(c *gin.Context, mode transfer.Mode, action string, src *entity.Bucket, srcPath string, dst *entity.Bucket, dstPath string) {
+	ctx := c.Request.Context()
+
+	if src.ID == dst.ID && (dstPath == srcPath || strings.HasPrefix(dstPath, srcPath+"/")) {
+		utils.JSON400(c, transfer.ErrInvalidDestination.Error())
+		return
+	}
+
+	if _, err := ctrl.Repository.FolderRepo.FindByPath(src.ID, srcPath); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.JSON404(c, "Folder not found")
+			return
+		}
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Transfer] Failed to load folder '%s': %v", srcPath, err)
+		utils.JSON500(c, "Failed to load folder")
+		return
+	}
+
+	if _, err := ctrl.Repository.FolderRepo.FindByPath(dst.ID, dstPath); err == nil {
+		utils.JSON409(c, "Destination folder already exists")
+		return
+	}
+
+	if mode == transfer.ModeCopy || dst.ID != src.ID {
+		if err := ctrl.ensureQuotas(c, dst); err != nil {
+			ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Transfer] Failed to load storage quota: %v", err)
+			utils.JSON500(c, "Failed to load storage quota")
+			return
+		}
+	}
+
+	count, err := ctrl.Repository.ObjectRepo.CountUnderPath(src.ID, srcPath)
+	if err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Transfer] Failed to count objects under '%s': %v", srcPath, err)
+		utils.JSON500(c, "Failed to load folder")
+		return
+	}
+
+	if count > FolderTransferSyncLimit {
+		msg := produce.ObjectTransferMessage{
+			Mode:           string(mode),
+			UserID:         c.GetString("user_id"),
+			SourceBucketID: src.ID.String(),
+			SourcePath:     srcPath,
+			DestBucketID:   dst.ID.String(),
+			DestPath:       dstPath,
+		}
+		if err := ctrl.Infra.Produce.UploadService.PublishObjectTransfer(ctx, msg); err != nil {
+			ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Transfer] Failed to publish folder transfer: %v", err)
+			utils.JSON500(c, "Failed to queue folder transfer")
+			return
+		}
+
+		ctrl.Infra.Logger.InfoWithContextf(ctx, "[Transfer] Queued %s of folder '%s' (%d objects) in bucket '%s'", mode, srcPath, count, src.Name)
+		utils.JSON202(c, gin.H{
+			"message":      "Folder " + string(mode) + " queued",
+			"path":         srcPath,
+			"dest_path":    dstPath,
+			"object_count": count,
+			"status":       "queued",
+		})
+		return
+	}
+
+	result, err := transfer.New(ctrl.Infra, ctrl.Repository).Folder(ctx, mode, src, srcPath, dst, dstPath)
+	ctrl.recordAudit(c, auditEvent{
+		OwnerID:    src.OwnerID,
+		Action:     action,
+		TargetType: entity.AuditTargetFolder,
+		TargetID:   src.ID.String(),
+		TargetName: srcPath,
+		Err:        err,
+		After:      gin.H{"bucket": dst.Name, "path": dstPath, "result": result},
+	})
+	if err != nil {
+		ctrl.respondTransferError(c, err)
+		return
+	}
+
+	ctrl.Infra.Logger.InfoWithContextf(ctx, "[Transfer] %s folder '%s' to '%s': %d transferred, %d skipped",
+		mode, srcPath, dstPath, result.Transferred, result.Skipped)
+	utils.JSON200(c, gin.H{
+		"message":   "Folder " + string(mode) + " completed",
+		"path":      srcPath,
+		"dest_path": dstPath,
+		"result":    result,
+		"status":    "completed",
+	})
+}
+
+// bucketObject loads the :object_id object and checks that it belongs to bucket
+func (ctrl *Controller) bucketObject(c *gin.Context, bucket *entity.Bucket) (*entity.Object, bool) {
+	objectID, err := uuid.Parse(c.Param("object_id"))
+	if err != nil {
+		utils.JSON400(c, "Invalid object_id format")
+		return nil, false
+	}
+
+	object, err := ctrl.Repository.ObjectRepo.FindByID(objectID)
+	if err != nil || object.BucketID != bucket.ID {
+		utils.JSON404(c, "Object not found in this bucket")
+		return nil, false
+	}
+	return object, true
+}
+
+// destinationBucket resolves dest_bucket_id, defaulting to the source bucket. The caller
+// must own the destination too.
+func (ctrl *Controller) destinationBucket(c *gin.Context, src *entity.Bucket, destBucketID string) (*entity.Bucket, bool) {
+	if destBucketID == "" || destBucketID == src.ID.String() {
+		return src, true
+	}
+
+	bucketID, err := uuid.Parse(destBucketID)
+	if err != nil {
+		utils.JSON400(c, "Invalid dest_bucket_id format")
+		return nil, false
+	}
+
+	dst, err := ctrl.Repository.BucketRepo.FindByID(bucketID)
+	if err != nil {
+		utils.JSON404(c, "Destination bucket not found")
+		return nil, false
+	}
+	if dst.OwnerID != src.OwnerID {
+		ctrl.Infra.Logger.WarningWithContextf(c.Request.Context(), "[Transfer] User %s attempted to transfer into bucket %s owned by %s", src.OwnerID, dst.ID, dst.OwnerID)
+		utils.JSON403(c, "Forbidden: you don't have permission to access the destination bucket")
+		return nil, false
+	}
+	return dst, true
+}
+
+func (ctrl *Controller) respondTransferError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, transfer.ErrConflict):
+		utils.JSON409(c, err.Error())
+	case errors.Is(err, transfer.ErrInvalidDestination):
+		utils.JSON400(c, err.Error())
+	case errors.Is(err, repository.ErrQuotaExceeded):
+		ctrl.respondQuotaExceeded(c, err)
+	default:
+		ctrl.Infra.Logger.ErrorWithContextf(c.Request.Context(), err, "[Transfer] Transfer failed: %v", err)
+		utils.JSON500(c, "Failed to transfer")
+	}
+}
+
+// transferAuditAfter returns the transferred object for the audit entry. On a conflict the
+// transfer returns the object occupying the destination, which must not be recorded as the result.
+func transferAuditAfter(result *entity.Object, err error) interface{} {
+	if err != nil {
+		return nil
+	}
+	return result
+}
+
+// validateEntryName checks a new object or folder name
+func validateEntryName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\\") {
+		return errors.New("Invalid name: it must not be empty or contain '/' or '\\'")
+	}
+	return nil
+}
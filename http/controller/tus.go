@@ -0,0 +1,580 @@
+package controller
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tnqbao/gau-cloud-orchestrator/conflict"
+	"github.com/tnqbao/gau-cloud-orchestrator/entity"
+	"github.com/tnqbao/gau-cloud-orchestrator/infra"
+	"github.com/tnqbao/gau-cloud-orchestrator/repository"
+	"github.com/tnqbao/gau-cloud-orchestrator/utils"
+)
+
+// tus 1.0 (https://tus.io/protocols/resumable-upload) core protocol with the creation, termination,
+// checksum and expiration extensions. A tus upload is an upload session in UploadModeTus: the bytes
+// of each PATCH are cut into chunks of ChunkSize like the chunked API, so a finished upload goes
+// through the same compose pipeline, and the session's UploadOffset counts the bytes received.
+const (
+	TusVersion = "1.0.0"
+
+	tusExtensions         = "creation,termination,checksum,expiration"
+	tusChecksumAlgorithms = "sha1,sha256,md5"
+	tusContentType        = "application/offset+octet-stream"
+
+	// StatusChecksumMismatch is returned when a PATCH body does not match its Upload-Checksum
+	StatusChecksumMismatch = 460
+
+	// tusLockTTL bounds how long a PATCH holds its upload, in case the lock is never released
+	tusLockTTL    = 30 * time.Minute
+	tusLockPrefix = "tus_lock:"
+)
+
+// TusOptions describes the protocol version and extensions supported
+// OPTIONS /api/v1/cloud/buckets/:id/tus
+func (ctrl *Controller) TusOptions(c *gin.Context) {
+	c.Header("Tus-Resumable", TusVersion)
+	c.Header("Tus-Version", TusVersion)
+	c.Header("Tus-Extension", tusExtensions)
+	c.Header("Tus-Checksum-Algorithm", tusChecksumAlgorithms)
+	c.Status(http.StatusNoContent)
+}
+
+// TusCreateUpload creates a tus upload; the file name, content type, folder and conflict mode are
+// read from the filename, filetype, path and conflict keys of Upload-Metadata
+// POST /api/v1/cloud/buckets/:id/tus
+func (ctrl *Controller) TusCreateUpload(c *gin.Context) {
+	ctx := c.Request.Context()
+	if !tusResumable(c) {
+		return
+	}
+	bucket, ok := ctrl.ownedBucket(c, "[Tus]")
+	if !ok {
+		return
+	}
+
+	if c.GetHeader("Upload-Defer-Length") != "" {
+		utils.JSON400(c, "Upload-Defer-Length is not supported, send Upload-Length")
+		return
+	}
+	fileSize, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || fileSize <= 0 {
+		utils.JSON400(c, "Upload-Length must be a positive number of bytes")
+		return
+	}
+	metadata, err := parseTusMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		utils.JSON400(c, err.Error())
+		return
+	}
+
+	fileName := strings.TrimSpace(metadata["filename"])
+	if fileName == "" || strings.ContainsAny(fileName, "/\\") {
+		utils.JSON400(c, "Upload-Metadata filename is required and cannot contain slashes")
+		return
+	}
+	customPath, err := normalizeObjectPath(metadata["path"])
+	if err != nil {
+		utils.JSON400(c, err.Error())
+		return
+	}
+	contentType := metadata["filetype"]
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	if mode := metadata["conflict"]; mode != "" && !conflict.ValidMode(mode) {
+		utils.JSON400(c, "Invalid conflict mode: must be one of "+strings.Join(conflict.Modes, ", "))
+		return
+	}
+
+	// Reject mode fails fast; the other modes are applied once the file is composed
+	conflictMode := conflict.Mode(bucket, metadata["conflict"])
+	if _, err := conflict.New(ctrl.Infra, ctrl.Repository).Resolve(bucket, customPath, fileName, conflictMode); err != nil {
+		if errors.Is(err, conflict.ErrNameTaken) {
+			utils.JSON409(c, "An object named '"+fileName+"' already exists at this path")
+			return
+		}
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Tus] Failed to resolve name conflict: %v", err)
+		utils.JSON500(c, "Failed to check existing objects")
+		return
+	}
+
+	if err := ctrl.Infra.Minio.EnsureBucket(ctx, entity.ChunkBucket); err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Tus] Failed to ensure pending bucket")
+		utils.JSON500(c, "Failed to prepare upload storage")
+		return
+	}
+
+	uploadID := uuid.New()
+	session := &entity.UploadSession{
+		ID:           uploadID,
+		BucketID:     bucket.ID,
+		UserID:       bucket.OwnerID,
+		FileName:     fileName,
+		FileSize:     fileSize,
+		ContentType:  contentType,
+		CustomPath:   customPath,
+		ChunkSize:    DefaultChunkSize,
+		TotalChunks:  int((fileSize + DefaultChunkSize - 1) / DefaultChunkSize),
+		Status:       entity.UploadStatusInit,
+		TempBucket:   entity.ChunkBucket,
+		TempPrefix:   uploadID.String() + "/",
+		ConflictMode: conflictMode,
+		UploadMode:   entity.UploadModeTus,
+		ExpiresAt:    time.Now().Add(UploadSessionExpiry),
+	}
+
+	// The session reserves its file size against the quotas until it completes, fails or expires
+	if err := ctrl.ensureQuotas(c, bucket); err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Tus] Failed to load storage quota: %v", err)
+		utils.JSON500(c, "Failed to load storage quota")
+		return
+	}
+	if err := ctrl.Repository.StorageQuotaRepo.ReserveSession(session); err != nil {
+		if errors.Is(err, repository.ErrQuotaExceeded) {
+			ctrl.respondQuotaExceeded(c, err)
+			return
+		}
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Tus] Failed to create upload session")
+		ctrl.recordAudit(c, auditEvent{
+			OwnerID:    bucket.OwnerID,
+			Action:     entity.AuditActionUploadInit,
+			TargetType: entity.AuditTargetUploadSession,
+			TargetID:   uploadID.String(),
+			TargetName: auditObjectName(customPath, fileName),
+			Err:        err,
+		})
+		utils.JSON500(c, "Failed to initialize upload session")
+		return
+	}
+
+	ctrl.recordAudit(c, auditEvent{
+		OwnerID:    bucket.OwnerID,
+		Action:     entity.AuditActionUploadInit,
+		TargetType: entity.AuditTargetUploadSession,
+		TargetID:   uploadID.String(),
+		TargetName: auditObjectName(customPath, fileName),
+		After:      session,
+	})
+
+	ctrl.Infra.Logger.InfoWithContextf(ctx, "[Tus] Created upload %s for file '%s' (%d bytes)", uploadID, fileName, fileSize)
+
+	c.Header("Location", fmt.Sprintf("/api/v1/cloud/buckets/%s/tus/%s", bucket.ID, uploadID))
+	c.Header("Upload-Expires", session.ExpiresAt.UTC().Format(http.TimeFormat))
+	c.Status(http.StatusCreated)
+}
+
+// TusUploadOffset returns the offset a tus upload resumes from
+// HEAD /api/v1/cloud/buckets/:id/tus/:upload_id
+func (ctrl *Controller) TusUploadOffset(c *gin.Context) {
+	if !tusResumable(c) {
+		return
+	}
+	_, session, ok := ctrl.tusSession(c)
+	if !ok {
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	if session.Expired() {
+		c.Status(http.StatusGone)
+		return
+	}
+	c.Header("Upload-Offset", strconv.FormatInt(session.UploadOffset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(session.FileSize, 10))
+	if session.Active() {
+		c.Header("Upload-Expires", session.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+	c.Status(http.StatusOK)
+}
+
+// TusAppend writes the body of a PATCH at Upload-Offset. The last byte queues the upload for composing.
+// PATCH /api/v1/cloud/buckets/:id/tus/:upload_id
+func (ctrl *Controller) TusAppend(c *gin.Context) {
+	ctx := c.Request.Context()
+	if !tusResumable(c) {
+		return
+	}
+	bucket, session, ok := ctrl.tusSession(c)
+	if !ok {
+		return
+	}
+
+	if c.ContentType() != tusContentType {
+		tusError(c, http.StatusUnsupportedMediaType, "Content-Type must be "+tusContentType)
+		return
+	}
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		utils.JSON400(c, "Upload-Offset must be a non-negative number of bytes")
+		return
+	}
+	checksum, expected, err := parseTusChecksum(c.GetHeader("Upload-Checksum"))
+	if err != nil {
+		utils.JSON400(c, err.Error())
+		return
+	}
+
+	// Chunks are rewritten from their start, so two requests on one upload would overwrite each other.
+	// The lock holds a token of this request, so it never releases a lock taken over after expiring.
+	lockKey := tusLockPrefix + session.ID.String()
+	lockToken := uuid.NewString()
+	locked, err := ctrl.Infra.Redis.SetNX(ctx, lockKey, lockToken, tusLockTTL)
+	if err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Tus] Failed to lock upload %s: %v", session.ID, err)
+		utils.JSON500(c, "Failed to lock upload")
+		return
+	}
+	if !locked {
+		utils.JSON409(c, "Upload is being written by another request")
+		return
+	}
+	defer func() {
+		released, err := ctrl.Infra.Redis.DeleteIfValue(context.Background(), lockKey, lockToken)
+		if err != nil {
+			ctrl.Infra.Logger.WarningWithContextf(ctx, "[Tus] Failed to unlock upload %s: %v", session.ID, err)
+		} else if !released {
+			ctrl.Infra.Logger.WarningWithContextf(ctx, "[Tus] Lock of upload %s expired while it was written", session.ID)
+		}
+	}()
+
+	// A request that held the lock before may have moved the offset since the session was loaded
+	session, err = ctrl.Repository.UploadSessionRepo.FindByIDAndBucketID(session.ID, bucket.ID)
+	if err != nil {
+		utils.JSON404(c, "Upload not found")
+		return
+	}
+	if session.Expired() {
+		tusError(c, http.StatusGone, "Upload has expired")
+		return
+	}
+	if !session.Active() {
+		utils.JSON409(c, "Upload no longer accepts data, current status: "+string(session.Status))
+		return
+	}
+	if offset != session.UploadOffset {
+		utils.JSON409(c, fmt.Sprintf("Upload-Offset %d does not match the upload offset %d", offset, session.UploadOffset))
+		return
+	}
+	remaining := session.FileSize - offset
+	if c.Request.ContentLength > remaining {
+		tusError(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("Body of %d bytes exceeds the %d bytes left of Upload-Length", c.Request.ContentLength, remaining))
+		return
+	}
+
+	// A body without Content-Length is only found to be too long while it is read
+	limited := &tusBodyLimit{r: c.Request.Body, n: remaining}
+	var body io.Reader = limited
+	if checksum != nil {
+		body = io.TeeReader(body, checksum)
+	}
+	written, writeErr := ctrl.appendTusChunks(ctx, session, offset, body)
+	if limited.exceeded {
+		tusError(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("Body exceeds the %d bytes left of Upload-Length", remaining))
+		return
+	}
+	// The offset is not advanced past bytes that fail a declared X-Content-SHA256, the client sends them again
+	if writeErr == nil || errors.Is(writeErr, utils.ErrBodyHashMismatch) {
+		if err := utils.VerifyBodyHash(c); err != nil {
+			ctrl.Infra.Logger.WarningWithContextf(ctx, "[Tus] Upload %s rejected at offset %d, body hash verification failed: %v", session.ID, offset, err)
+			utils.JSON400(c, err.Error())
+			return
+		}
+	}
+	_, bodyHashed := c.Get(utils.BodyHashVerifierKey)
+	if writeErr != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, writeErr, "[Tus] Failed to write upload %s at offset %d: %v", session.ID, offset+written, writeErr)
+		// Whole chunks stored before the failure still count, unless a checksum or body hash covers the body
+		if checksum != nil || bodyHashed {
+			written = 0
+		}
+	} else if checksum != nil && !bytes.Equal(checksum.Sum(nil), expected) {
+		ctrl.Infra.Logger.WarningWithContextf(ctx, "[Tus] Checksum mismatch for upload %s at offset %d", session.ID, offset)
+		tusError(c, StatusChecksumMismatch, "Checksum Mismatch")
+		return
+	}
+
+	newOffset := offset + written
+	if written > 0 {
+		uploadedChunks := int(newOffset / session.ChunkSize)
+		if newOffset == session.FileSize {
+			uploadedChunks = session.TotalChunks
+		}
+		advanced, err := ctrl.Repository.UploadSessionRepo.AdvanceOffset(session.ID, offset, newOffset, uploadedChunks)
+		if err != nil {
+			ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Tus] Failed to save offset of upload %s: %v", session.ID, err)
+			utils.JSON500(c, "Failed to save upload offset")
+			return
+		}
+		if !advanced {
+			utils.JSON409(c, "Upload changed while the request was written")
+			return
+		}
+		ctrl.Infra.UsageMeter.Add(bucket.OwnerID, bucket.ID, entity.MetricUploadBytes, "", float64(written))
+		session.Status, session.UploadOffset, session.UploadedChunks = entity.UploadStatusUploading, newOffset, uploadedChunks
+		ctrl.publishUploadEvent(ctx, session, infra.NewUploadEvent(infra.UploadEventChunkReceived, session))
+	}
+	if writeErr != nil {
+		utils.JSON500(c, "Failed to store upload data")
+		return
+	}
+
+	// A failed publish leaves the upload at its full length, so a PATCH with an empty body retries it
+	if newOffset == session.FileSize {
+		if err := ctrl.queueChunkCompose(ctx, bucket, session, entity.UploadTypeFile); err != nil {
+			ctrl.recordAudit(c, auditEvent{
+				OwnerID:    bucket.OwnerID,
+				Action:     entity.AuditActionUploadComplete,
+				TargetType: entity.AuditTargetUploadSession,
+				TargetID:   session.ID.String(),
+				TargetName: auditObjectName(session.CustomPath, session.FileName),
+				Err:        err,
+			})
+			utils.JSON500(c, "Failed to queue file for processing")
+			return
+		}
+		ctrl.recordAudit(c, auditEvent{
+			OwnerID:    bucket.OwnerID,
+			Action:     entity.AuditActionUploadComplete,
+			TargetType: entity.AuditTargetUploadSession,
+			TargetID:   session.ID.String(),
+			TargetName: auditObjectName(session.CustomPath, session.FileName),
+		})
+		ctrl.Infra.Logger.InfoWithContextf(ctx, "[Tus] Upload %s received in full, queued for processing", session.ID)
+	} else {
+		c.Header("Upload-Expires", session.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+
+	c.Header("Upload-Offset", strconv.FormatInt(newOffset, 10))
+	c.Status(http.StatusNoContent)
+}
+
+// TusTerminate aborts a tus upload and deletes what was received
+// DELETE /api/v1/cloud/buckets/:id/tus/:upload_id
+func (ctrl *Controller) TusTerminate(c *gin.Context) {
+	ctx := c.Request.Context()
+	if !tusResumable(c) {
+		return
+	}
+	bucket, session, ok := ctrl.tusSession(c)
+	if !ok {
+		return
+	}
+
+	sessions, err := ctrl.Repository.UploadSessionRepo.DeleteAbortable(bucket.OwnerID, bucket.ID, []uuid.UUID{session.ID})
+	if err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Tus] Failed to terminate upload %s: %v", session.ID, err)
+		utils.JSON500(c, "Failed to terminate upload")
+		return
+	}
+	if len(sessions) == 0 {
+		utils.JSON409(c, "Upload can no longer be terminated, current status: "+string(session.Status))
+		return
+	}
+
+	ctrl.removeChunks(sessions[0])
+	ctrl.recordAudit(c, auditEvent{
+		OwnerID:    bucket.OwnerID,
+		Action:     entity.AuditActionUploadAbort,
+		TargetType: entity.AuditTargetUploadSession,
+		TargetID:   session.ID.String(),
+		TargetName: auditObjectName(session.CustomPath, session.FileName),
+		Before:     &sessions[0],
+	})
+
+	ctrl.Infra.Logger.InfoWithContextf(ctx, "[Tus] Terminated upload %s", session.ID)
+	c.Status(http.StatusNoContent)
+}
+
+// appendTusChunks stores body from offset on as chunks of session.ChunkSize. A chunk the offset
+// falls inside is rewritten with its stored head followed by the new bytes. It returns the bytes of
+// body held by chunks stored in full, which is every byte unless an error is returned.
+func (ctrl *Controller) appendTusChunks(ctx context.Context, session *entity.UploadSession, offset int64, body io.Reader) (int64, error) {
+	reader := bufio.NewReader(body)
+	index := int(offset / session.ChunkSize)
+	kept := offset % session.ChunkSize
+
+	var written int64
+	for {
+		if _, err := reader.Peek(1); err == io.EOF {
+			return written, nil
+		} else if err != nil {
+			return written, err
+		}
+
+		chunkName := fmt.Sprintf("chunk_%05d.part", index)
+		part := &countingReader{r: io.LimitReader(reader, session.ChunkSize-kept)}
+		var chunk io.Reader = part
+		var head io.ReadCloser
+		if kept > 0 {
+			var err error
+			if head, err = ctrl.Infra.Minio.GetObjectRange(ctx, entity.ChunkBucket, session.ChunkPrefix()+chunkName, 0, kept); err != nil {
+				return written, err
+			}
+			chunk = io.MultiReader(head, part)
+		}
+
+		_, err := ctrl.Infra.Uploader.UploadChunkToService(chunk, chunkName, "application/octet-stream", entity.ChunkBucket, session.ID.String())
+		if head != nil {
+			head.Close()
+		}
+		if err != nil {
+			return written, err
+		}
+		written += part.n
+
+		// The body ended inside this chunk
+		if part.n < session.ChunkSize-kept {
+			return written, nil
+		}
+		index++
+		kept = 0
+	}
+}
+
+// tusSession loads the tus upload named in the path from a bucket of the caller
+func (ctrl *Controller) tusSession(c *gin.Context) (*entity.Bucket, *entity.UploadSession, bool) {
+	bucket, ok := ctrl.ownedBucket(c, "[Tus]")
+	if !ok {
+		return nil, nil, false
+	}
+	uploadID, err := uuid.Parse(c.Param("upload_id"))
+	if err != nil {
+		utils.JSON400(c, "Invalid upload_id format")
+		return nil, nil, false
+	}
+	session, err := ctrl.Repository.UploadSessionRepo.FindByIDAndBucketID(uploadID, bucket.ID)
+	if err != nil || session.UploadMode != entity.UploadModeTus {
+		utils.JSON404(c, "Upload not found")
+		return nil, nil, false
+	}
+	return bucket, session, true
+}
+
+// tusResumable sets the protocol version on the response and rejects clients speaking another one
+func tusResumable(c *gin.Context) bool {
+	c.Header("Tus-Resumable", TusVersion)
+	if c.GetHeader("Tus-Resumable") != TusVersion {
+		c.Header("Tus-Version", TusVersion)
+		tusError(c, http.StatusPreconditionFailed, "Tus-Resumable "+TusVersion+" is required")
+		return false
+	}
+	return true
+}
+
+func tusError(c *gin.Context, status int, err string) {
+	c.JSON(status, gin.H{
+		"error":  err,
+		"status": status,
+	})
+}
+
+// parseTusMetadata decodes Upload-Metadata: comma-separated keys, each followed by a space and its
+// base64 value, which may be left out
+func parseTusMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, errors.New("Upload-Metadata has an empty key")
+		}
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("Upload-Metadata value of %s is not base64", key)
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
+
+// parseTusChecksum reads Upload-Checksum, the algorithm and the base64 digest of the body.
+// It returns a nil hash when the header is absent.
+func parseTusChecksum(header string) (hash.Hash, []byte, error) {
+	if header == "" {
+		return nil, nil, nil
+	}
+	algorithm, encoded, ok := strings.Cut(header, " ")
+	if !ok {
+		return nil, nil, errors.New("Upload-Checksum must be an algorithm and a base64 digest")
+	}
+	expected, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, nil, errors.New("Upload-Checksum digest is not base64")
+	}
+
+	var h hash.Hash
+	switch algorithm {
+	case "sha1":
+		h = sha1.New()
+	case "sha256":
+		h = sha256.New()
+	case "md5":
+		h = md5.New()
+	default:
+		return nil, nil, fmt.Errorf("Upload-Checksum algorithm %s is not supported, use one of %s", algorithm, tusChecksumAlgorithms)
+	}
+	return h, expected, nil
+}
+
+// errTusBodyTooLarge is returned when a PATCH body runs past the length of the upload
+var errTusBodyTooLarge = errors.New("body exceeds the upload length")
+
+// tusBodyLimit reads up to n bytes of r and fails, rather than stopping, when r holds more
+type tusBodyLimit struct {
+	r        io.Reader
+	n        int64
+	exceeded bool
+}
+
+func (l *tusBodyLimit) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		// Probe for one more byte, the body must end here
+		var probe [1]byte
+		for {
+			n, err := l.r.Read(probe[:])
+			if n > 0 {
+				l.exceeded = true
+				return 0, errTusBodyTooLarge
+			}
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	return n, err
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"errors"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tnqbao/gau-cloud-orchestrator/utils"
+)
+
+// ListUploadCallbacks returns the delivery log of an upload's completion callback
+// GET /api/v1/cloud/buckets/:id/chunked/:upload_id/callbacks
+func (ctrl *Controller) ListUploadCallbacks(c *gin.Context) {
+	ctx := c.Request.Context()
+	bucket, ok := ctrl.ownedBucket(c, "[Upload]")
+	if !ok {
+		return
+	}
+
+	uploadID, err := uuid.Parse(c.Param("upload_id"))
+	if err != nil {
+		utils.JSON400(c, "Invalid upload_id format")
+		return
+	}
+
+	session, err := ctrl.Repository.UploadSessionRepo.FindByIDAndBucketID(uploadID, bucket.ID)
+	if err != nil {
+		utils.JSON404(c, "Upload session not found")
+		return
+	}
+
+	deliveries, err := ctrl.Repository.UploadCallbackRepo.ListByUploadID(uploadID)
+	if err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Upload] Failed to list callback deliveries of upload %s: %v", uploadID, err)
+		utils.JSON500(c, "Failed to list callback deliveries")
+		return
+	}
+
+	utils.JSON200(c, gin.H{
+		"upload_id":    uploadID,
+		"callback_url": session.CallbackURL,
+		"deliveries":   deliveries,
+		"count":        len(deliveries),
+	})
+}
+
+// validateCallbackURL accepts absolute http and https URLs
+func validateCallbackURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return errors.New("callback_url must be an absolute http or https URL")
+	}
+	if u.User != nil {
+		return errors.New("callback_url must not carry credentials, sign with callback_secret instead")
+	}
+	return nil
+}
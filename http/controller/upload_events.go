@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tnqbao/gau-cloud-orchestrator/entity"
+	"github.com/tnqbao/gau-cloud-orchestrator/infra"
+	"github.com/tnqbao/gau-cloud-orchestrator/utils"
+)
+
+// uploadEventHeartbeat keeps idle streams open through proxies that close silent connections
+const uploadEventHeartbeat = 15 * time.Second
+
+// StreamUploadEvents streams the progress of one upload as Server-Sent Events: its current status,
+// then chunk_received, processing, extracting, completed and failed events. The stream ends after
+// completed or failed.
+// GET /api/v1/cloud/buckets/:id/chunked/:upload_id/events
+func (ctrl *Controller) StreamUploadEvents(c *gin.Context) {
+	ctx := c.Request.Context()
+	bucket, ok := ctrl.ownedBucket(c, "[Upload]")
+	if !ok {
+		return
+	}
+
+	uploadID, err := uuid.Parse(c.Param("upload_id"))
+	if err != nil {
+		utils.JSON400(c, "Invalid upload_id format")
+		return
+	}
+
+	// Subscribe before reading the session, so no step between the two is missed
+	events, err := ctrl.Infra.UploadEvents.Subscribe(ctx, bucket.ID)
+	if err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Upload] Failed to follow upload %s: %v", uploadID, err)
+		utils.JSON500(c, "Failed to follow upload")
+		return
+	}
+
+	session, err := ctrl.Repository.UploadSessionRepo.FindByIDAndBucketID(uploadID, bucket.ID)
+	if err != nil {
+		utils.JSON404(c, "Upload session not found")
+		return
+	}
+
+	current := infra.NewUploadEvent(infra.UploadEventStatus, session)
+	current.Error = session.ErrorMessage
+	if session.Extraction.Error != "" {
+		current.Error = session.Extraction.Error
+	}
+	if session.Expired() {
+		current.Status = string(entity.UploadStatusExpired)
+	}
+
+	streamUploadEvents(c, events, &current, func(event *infra.UploadEvent) bool {
+		return event.UploadID == uploadID
+	})
+}
+
+// StreamBucketUploadEvents streams the progress of every upload into a bucket as Server-Sent Events
+// GET /api/v1/cloud/buckets/:id/chunked/events
+func (ctrl *Controller) StreamBucketUploadEvents(c *gin.Context) {
+	ctx := c.Request.Context()
+	bucket, ok := ctrl.ownedBucket(c, "[Upload]")
+	if !ok {
+		return
+	}
+
+	events, err := ctrl.Infra.UploadEvents.Subscribe(ctx, bucket.ID)
+	if err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Upload] Failed to follow uploads of bucket %s: %v", bucket.ID, err)
+		utils.JSON500(c, "Failed to follow uploads")
+		return
+	}
+
+	streamUploadEvents(c, events, nil, nil)
+}
+
+// streamUploadEvents writes first, when set, then the events accepted by match until the client
+// leaves. When following a single upload (match set), the stream ends after its final event.
+func streamUploadEvents(c *gin.Context, events <-chan infra.UploadEvent, first *infra.UploadEvent, match func(*infra.UploadEvent) bool) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(200)
+
+	if first != nil {
+		c.SSEvent(first.Type, first)
+		c.Writer.Flush()
+		if match != nil && isFinalUploadStatus(entity.UploadStatus(first.Status)) {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(uploadEventHeartbeat)
+	defer heartbeat.Stop()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-heartbeat.C:
+			_, err := fmt.Fprint(w, ": ping\n\n")
+			return err == nil
+		case event, ok := <-events:
+			if !ok {
+				return false
+			}
+			if match != nil && !match(&event) {
+				return true
+			}
+			c.SSEvent(event.Type, event)
+			return match == nil || !event.Final()
+		}
+	})
+}
+
+func isFinalUploadStatus(status entity.UploadStatus) bool {
+	return status == entity.UploadStatusCompleted || status == entity.UploadStatusFailed || status == entity.UploadStatusExpired
+}
+
+// publishUploadEvent tells the streams following the session's bucket about a step of the upload.
+// A final step also queues the session's callback.
+func (ctrl *Controller) publishUploadEvent(ctx context.Context, session *entity.UploadSession, event infra.UploadEvent) {
+	if err := ctrl.Infra.UploadEvents.Publish(ctx, event); err != nil {
+		ctrl.Infra.Logger.WarningWithContextf(ctx, "[Upload] Failed to publish %s event of upload %s: %v", event.Type, event.UploadID, err)
+	}
+	ctrl.Infra.QueueUploadCallback(ctx, session, event)
+}
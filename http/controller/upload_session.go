@@ -0,0 +1,250 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tnqbao/gau-cloud-orchestrator/entity"
+	"github.com/tnqbao/gau-cloud-orchestrator/http/controller/dto"
+	"github.com/tnqbao/gau-cloud-orchestrator/utils"
+)
+
+// ListChunkedUploads lists the in-progress chunked uploads of a bucket
+// GET /api/v1/cloud/buckets/:id/chunked
+func (ctrl *Controller) ListChunkedUploads(c *gin.Context) {
+	ctx := c.Request.Context()
+	bucket, ok := ctrl.ownedBucket(c, "[Upload]")
+	if !ok {
+		return
+	}
+
+	sessions, err := ctrl.Repository.UploadSessionRepo.FindActiveByBucketID(bucket.ID)
+	if err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Upload] Failed to list upload sessions of bucket %s: %v", bucket.ID, err)
+		utils.JSON500(c, "Failed to list uploads")
+		return
+	}
+
+	response := uploadSessionList(sessions)
+	response["bucket_id"] = bucket.ID
+	utils.JSON200(c, response)
+}
+
+// ListUserChunkedUploads lists the in-progress chunked uploads of the caller across buckets
+// GET /api/v1/cloud/uploads
+func (ctrl *Controller) ListUserChunkedUploads(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID, ok := ctrl.auditOwnerFromContext(c)
+	if !ok {
+		return
+	}
+
+	sessions, err := ctrl.Repository.UploadSessionRepo.FindActiveByUserID(userID)
+	if err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Upload] Failed to list upload sessions of user %s: %v", userID, err)
+		utils.JSON500(c, "Failed to list uploads")
+		return
+	}
+
+	utils.JSON200(c, uploadSessionList(sessions))
+}
+
+// ExtendChunkedUpload moves the expiry of an active session, so a paused upload can be resumed later
+// POST /api/v1/cloud/buckets/:id/chunked/:upload_id/extend
+func (ctrl *Controller) ExtendChunkedUpload(c *gin.Context) {
+	ctx := c.Request.Context()
+	bucket, ok := ctrl.ownedBucket(c, "[Upload]")
+	if !ok {
+		return
+	}
+
+	uploadID, err := uuid.Parse(c.Param("upload_id"))
+	if err != nil {
+		utils.JSON400(c, "Invalid upload_id format")
+		return
+	}
+
+	var req dto.ExtendUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.JSON400(c, "Invalid request body: "+err.Error())
+		return
+	}
+
+	session, err := ctrl.Repository.UploadSessionRepo.FindByIDAndBucketID(uploadID, bucket.ID)
+	if err != nil {
+		utils.JSON404(c, "Upload session not found")
+		return
+	}
+
+	// An expired session no longer holds its quota reservation, so it cannot be revived
+	extendBy := min(time.Duration(req.ExpiresIn)*time.Second, MaxUploadSessionExpiry)
+	expiresAt := time.Now().Add(extendBy)
+	extended, err := ctrl.Repository.UploadSessionRepo.UpdateExpiry(uploadID, expiresAt)
+	if err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Upload] Failed to extend upload session %s: %v", uploadID, err)
+		utils.JSON500(c, "Failed to extend upload")
+		return
+	}
+	if !extended {
+		utils.JSON400(c, "Upload session is no longer active, current status: "+string(session.Status))
+		return
+	}
+
+	before := *session
+	session.ExpiresAt = expiresAt
+	ctrl.recordAudit(c, auditEvent{
+		OwnerID:    bucket.OwnerID,
+		Action:     entity.AuditActionUploadExtend,
+		TargetType: entity.AuditTargetUploadSession,
+		TargetID:   uploadID.String(),
+		TargetName: auditObjectName(session.CustomPath, session.FileName),
+		Before:     &before,
+		After:      session,
+	})
+
+	ctrl.Infra.Logger.InfoWithContextf(ctx, "[Upload] Upload session %s extended until %s", uploadID, expiresAt.Format(time.RFC3339))
+
+	utils.JSON200(c, gin.H{
+		"message": "Upload session extended",
+		"upload":  uploadSessionView(session),
+	})
+}
+
+// AbortChunkedUploads aborts several sessions of a bucket and deletes their chunks
+// POST /api/v1/cloud/buckets/:id/chunked/abort
+func (ctrl *Controller) AbortChunkedUploads(c *gin.Context) {
+	bucket, ok := ctrl.ownedBucket(c, "[Upload]")
+	if !ok {
+		return
+	}
+	ctrl.abortChunkedUploads(c, bucket.OwnerID, bucket.ID)
+}
+
+// AbortUserChunkedUploads aborts several sessions of the caller across buckets and deletes their chunks
+// POST /api/v1/cloud/uploads/abort
+func (ctrl *Controller) AbortUserChunkedUploads(c *gin.Context) {
+	userID, ok := ctrl.auditOwnerFromContext(c)
+	if !ok {
+		return
+	}
+	ctrl.abortChunkedUploads(c, userID, uuid.Nil)
+}
+
+// abortChunkedUploads deletes the listed sessions, or all of them, of userID in bucketID (uuid.Nil
+// for every bucket). Sessions being composed or extracted are left alone.
+func (ctrl *Controller) abortChunkedUploads(c *gin.Context, userID, bucketID uuid.UUID) {
+	ctx := c.Request.Context()
+
+	var req dto.AbortUploadsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.JSON400(c, "Invalid request body: "+err.Error())
+		return
+	}
+	if !req.All && len(req.UploadIDs) == 0 {
+		utils.JSON400(c, "Either upload_ids or all is required")
+		return
+	}
+
+	var ids []uuid.UUID
+	if !req.All {
+		ids = make([]uuid.UUID, 0, len(req.UploadIDs))
+		for _, raw := range req.UploadIDs {
+			ids = append(ids, uuid.MustParse(raw))
+		}
+	}
+
+	sessions, err := ctrl.Repository.UploadSessionRepo.DeleteAbortable(userID, bucketID, ids)
+	if err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Upload] Failed to abort upload sessions of user %s: %v", userID, err)
+		utils.JSON500(c, "Failed to abort uploads")
+		return
+	}
+
+	aborted := make([]string, 0, len(sessions))
+	var released int64
+	for i := range sessions {
+		session := sessions[i]
+		released += session.ReservedBytes()
+		aborted = append(aborted, session.ID.String())
+		ctrl.removeChunks(session)
+		ctrl.recordAudit(c, auditEvent{
+			OwnerID:    userID,
+			Action:     entity.AuditActionUploadAbort,
+			TargetType: entity.AuditTargetUploadSession,
+			TargetID:   session.ID.String(),
+			TargetName: auditObjectName(session.CustomPath, session.FileName),
+			Before:     &session,
+		})
+	}
+
+	ctrl.Infra.Logger.InfoWithContextf(ctx, "[Upload] Aborted %d upload sessions of user %s", len(sessions), userID)
+
+	utils.JSON200(c, gin.H{
+		"message":        "Uploads aborted",
+		"aborted":        aborted,
+		"count":          len(aborted),
+		"bytes_released": released,
+	})
+}
+
+// removeChunks deletes the chunks of a session, or the parts of a direct one, in the background;
+// the request may end before it is done
+func (ctrl *Controller) removeChunks(session entity.UploadSession) {
+	go func() {
+		ctx := context.Background()
+		if session.UploadMode == entity.UploadModeDirect {
+			bucket, err := ctrl.Repository.BucketRepo.FindByID(session.BucketID)
+			if err != nil {
+				ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Upload] Failed to load bucket of upload %s: %v", session.ID, err)
+				return
+			}
+			ctrl.abortDirectUpload(ctx, bucket.Name, &session)
+			return
+		}
+		if err := ctrl.Infra.Minio.DeleteObjectsWithPrefix(ctx, entity.ChunkBucket, session.ChunkPrefix()); err != nil {
+			ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Upload] Failed to remove chunks of upload %s: %v", session.ID, err)
+		}
+	}()
+}
+
+func uploadSessionList(sessions []entity.UploadSession) gin.H {
+	uploads := make([]gin.H, 0, len(sessions))
+	var reserved int64
+	for i := range sessions {
+		reserved += sessions[i].ReservedBytes()
+		uploads = append(uploads, uploadSessionView(&sessions[i]))
+	}
+	return gin.H{
+		"uploads":        uploads,
+		"count":          len(uploads),
+		"bytes_reserved": reserved,
+	}
+}
+
+func uploadSessionView(session *entity.UploadSession) gin.H {
+	progress := float64(0)
+	if session.TotalChunks > 0 {
+		progress = float64(session.UploadedChunks) / float64(session.TotalChunks) * 100
+	}
+	return gin.H{
+		"upload_id":       session.ID.String(),
+		"bucket_id":       session.BucketID,
+		"file_name":       session.FileName,
+		"path":            session.CustomPath,
+		"file_size":       session.FileSize,
+		"chunk_size":      session.ChunkSize,
+		"uploaded_chunks": session.UploadedChunks,
+		"total_chunks":    session.TotalChunks,
+		"uploaded_bytes":  session.UploadedBytes(),
+		"progress":        progress,
+		"status":          string(session.Status),
+		"expired":         time.Now().After(session.ExpiresAt),
+		"bytes_reserved":  session.ReservedBytes(),
+		"conflict_mode":   session.ConflictMode,
+		"created_at":      session.CreatedAt.Format(time.RFC3339),
+		"updated_at":      session.UpdatedAt.Format(time.RFC3339),
+		"expires_at":      session.ExpiresAt.Format(time.RFC3339),
+	}
+}
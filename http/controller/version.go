@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tnqbao/gau-cloud-orchestrator/entity"
+	"github.com/tnqbao/gau-cloud-orchestrator/infra/produce"
+	"github.com/tnqbao/gau-cloud-orchestrator/utils"
+	"gorm.io/gorm"
+)
+
+// ListObjectVersions lists the previous contents kept for an object replaced in version conflict mode
+// GET /api/v1/cloud/buckets/:id/versions/:object_id
+func (ctrl *Controller) ListObjectVersions(c *gin.Context) {
+	bucket, ok := ctrl.ownedBucket(c, "[Version]")
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+
+	objectID, err := uuid.Parse(c.Param("object_id"))
+	if err != nil {
+		utils.JSON400(c, "Invalid object_id format")
+		return
+	}
+
+	object, err := ctrl.Repository.ObjectRepo.FindByID(objectID)
+	if err != nil || object.BucketID != bucket.ID {
+		if err == nil || errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.JSON404(c, "Object not found in this bucket")
+			return
+		}
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Version] Failed to load object %s: %v", objectID, err)
+		utils.JSON500(c, "Failed to load object")
+		return
+	}
+
+	versions, err := ctrl.Repository.ObjectVersionRepo.FindByObjectID(objectID)
+	if err != nil {
+		ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Version] Failed to list versions of object %s: %v", objectID, err)
+		utils.JSON500(c, "Failed to list versions")
+		return
+	}
+
+	utils.JSON200(c, gin.H{
+		"object":   object,
+		"versions": versions,
+		"count":    len(versions),
+	})
+}
+
+// purgeVersions queues the blobs of deleted versions for removal; their bytes are released
+// from the quotas once they are gone
+func (ctrl *Controller) purgeVersions(c *gin.Context, bucket *entity.Bucket, versions []entity.ObjectVersion) {
+	ctx := c.Request.Context()
+	for i := range versions {
+		msg := produce.DeleteObjectMessage{
+			BucketName: bucket.Name,
+			ObjectPath: versions[i].StorageKey,
+			UserID:     bucket.OwnerID.String(),
+			BucketID:   bucket.ID.String(),
+			Size:       versions[i].Size,
+			Version:    true,
+		}
+		if err := ctrl.Infra.Produce.UploadService.PublishDeleteObject(ctx, msg); err != nil {
+			ctrl.Infra.Logger.ErrorWithContextf(ctx, err, "[Version] Failed to publish delete message for version %s: %v", versions[i].ID, err)
+		}
+	}
+}
@@ -1,7 +1,14 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/tnqbao/gau-cloud-orchestrator/config"
@@ -25,8 +32,86 @@ func main() {
 
 	router := routes.SetupRouter(ctrl)
 
-	log.Println("HTTP Server started on :8080")
-	if err := router.Run(":8080"); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	// Flush buffered usage counters periodically; the final flush runs once the server has drained
+	meterCtx, stopMeter := context.WithCancel(context.Background())
+	meterDone := make(chan struct{})
+	go func() {
+		defer close(meterDone)
+		infra.UsageMeter.Run(meterCtx, time.Duration(cfg.EnvConfig.Usage.FlushInterval)*time.Second,
+			repo.MetricRepo.CreateBatch,
+			func(err error) {
+				infra.Logger.ErrorWithContextf(meterCtx, err, "[Usage] Failed to flush usage metrics: %v", err)
+			})
+	}()
+
+	serverCfg := cfg.EnvConfig.HTTPServer
+	server := &http.Server{
+		Addr:              serverCfg.Addr,
+		Handler:           router,
+		ReadHeaderTimeout: time.Duration(serverCfg.ReadHeaderTimeout) * time.Second,
+		ReadTimeout:       time.Duration(serverCfg.ReadTimeout) * time.Second,
+		WriteTimeout:      time.Duration(serverCfg.WriteTimeout) * time.Second,
+		IdleTimeout:       time.Duration(serverCfg.IdleTimeout) * time.Second,
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Printf("HTTP Server started on %s", server.Addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
+
+	// Wait for interrupt signal or a fatal server error
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+
+	var failure error
+	select {
+	case sig := <-quit:
+		log.Printf("Received signal %s, shutting down HTTP server...", sig)
+	case failure = <-serverErr:
+		log.Printf("HTTP server failed: %v", failure)
 	}
+
+	// Fail readiness first and keep serving until the load balancer has seen it,
+	// otherwise new traffic keeps arriving until the listener closes
+	infra.Health.SetShuttingDown()
+	if drainDelay := time.Duration(serverCfg.DrainDelay) * time.Second; failure == nil && drainDelay > 0 {
+		log.Printf("Waiting %s for readiness to propagate before draining", drainDelay)
+		time.Sleep(drainDelay)
+	}
+
+	shutdownTimeout := time.Duration(serverCfg.ShutdownTimeout) * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	// Stop accepting new connections and drain in-flight requests
+	// (streaming downloads, chunk uploads) until the deadline
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("HTTP server did not drain within %s, forcing close: %v", shutdownTimeout, err)
+		_ = server.Close()
+	} else {
+		log.Println("HTTP server drained all in-flight requests")
+	}
+
+	// Persist the usage counted while draining before the database pool closes
+	stopMeter()
+	<-meterDone
+
+	// Close infra clients and flush OTel exporters with a fresh deadline,
+	// the drain above may have used up the previous one
+	infraCtx, infraCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer infraCancel()
+
+	if err := infra.Shutdown(infraCtx); err != nil {
+		log.Printf("Error while shutting down infra: %v", err)
+	}
+
+	// Exit non-zero so the orchestrator restarts a server that never listened
+	if failure != nil {
+		log.Fatalf("HTTP server exited after failure: %v", failure)
+	}
+
+	log.Println("HTTP server exited properly")
 }
@@ -0,0 +1,31 @@
+package middlewares
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tnqbao/gau-cloud-orchestrator/infra"
+)
+
+// AccessLogMiddleware writes one log line per request once it completes,
+// recording the caller and how they authenticated (JWT or HMAC with the IAM user)
+func AccessLogMiddleware(logger *infra.LoggerClient) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		fields := map[string]interface{}{
+			"method":     c.Request.Method,
+			"path":       c.Request.URL.Path,
+			"route":      c.FullPath(),
+			"status":     c.Writer.Status(),
+			"latency_ms": time.Since(start).Milliseconds(),
+			"client_ip":  c.ClientIP(),
+		}
+
+		// user_id, auth_method and iam_user_id are added by the logger from the
+		// request identity that the auth middlewares attached on success
+		logger.InfoWithContext(c.Request.Context(), "[Access] Request completed", fields)
+	}
+}
@@ -0,0 +1,355 @@
+package middlewares
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tnqbao/gau-cloud-orchestrator/config"
+	"github.com/tnqbao/gau-cloud-orchestrator/infra"
+	"github.com/tnqbao/gau-cloud-orchestrator/repository"
+	"github.com/tnqbao/gau-cloud-orchestrator/utils"
+)
+
+const (
+	// TimestampTolerance is the maximum allowed time difference in seconds
+	TimestampTolerance = 300
+
+	// Accepted values of the X-Signature-Version header
+	SignatureVersion1 = "1"
+	SignatureVersion2 = "2"
+
+	// MinNonceLength and MaxNonceLength bound the X-Nonce header
+	MinNonceLength = 16
+	MaxNonceLength = 128
+
+	hmacNonceKeyPrefix = "hmac:nonce:"
+)
+
+// DualAuthMiddleware creates a middleware that supports dual authentication:
+// 1. JWT authentication (Bearer header, cookie or access_token query, as AuthMiddleware)
+// 2. HMAC signature authentication (programmatic access with IAM access keys)
+//
+// Authentication is OR logic - either method is acceptable. The method used and,
+// for HMAC, the IAM user are recorded on the request for auditing.
+//
+// A declared X-Content-SHA256 body is read and verified before the handler runs.
+func DualAuthMiddleware(
+	validator *TokenValidator,
+	iamRepo *repository.IAMUserRepository,
+	redis *infra.RedisClient,
+	cfg *config.EnvConfig,
+) gin.HandlerFunc {
+	return dualAuth(validator, iamRepo, redis, cfg, false)
+}
+
+// StreamingDualAuthMiddleware is DualAuthMiddleware for upload routes whose handlers
+// stream the body and verify a declared X-Content-SHA256 themselves through
+// utils.VerifyBodyHash before committing anything. Only routes whose handlers do
+// that may use it, the body would otherwise be acted on unverified.
+func StreamingDualAuthMiddleware(
+	validator *TokenValidator,
+	iamRepo *repository.IAMUserRepository,
+	redis *infra.RedisClient,
+	cfg *config.EnvConfig,
+) gin.HandlerFunc {
+	return dualAuth(validator, iamRepo, redis, cfg, true)
+}
+
+func dualAuth(
+	validator *TokenValidator,
+	iamRepo *repository.IAMUserRepository,
+	redis *infra.RedisClient,
+	cfg *config.EnvConfig,
+	streamBody bool,
+) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+
+		// HMAC signature authentication flow
+		if strings.HasPrefix(authHeader, "HMAC ") {
+			handleHMACAuth(c, iamRepo, redis, cfg, authHeader, streamBody)
+			return
+		}
+
+		if authHeader != "" && !strings.HasPrefix(authHeader, "Bearer ") {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization type. Use 'Bearer' or 'HMAC'"})
+			c.Abort()
+			return
+		}
+
+		// JWT authentication flow
+		tokenStr := extractJWT(c)
+		if tokenStr == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization token is required"})
+			c.Abort()
+			return
+		}
+
+		handleJWTAuth(c, validator, tokenStr)
+	}
+}
+
+// handleHMACAuth processes HMAC signature authentication
+// Header format: Authorization: HMAC <accessKey>:<signature>
+// Required headers: X-Timestamp
+//
+// Signature versions (selected with X-Signature-Version):
+//   - 1 (default): METHOD, PATH, TIMESTAMP and body hash; accepted only with HMAC_ALLOW_V1=true.
+//     X-Nonce is optional but enforced when present.
+//   - 2: additionally signs sorted query parameters and the headers listed in
+//     X-Signed-Headers (must include host, x-nonce, x-timestamp, and content-type
+//     when the request has a body type). X-Nonce is required.
+//
+// Body hash modes (selected with X-Content-SHA256, either version):
+//   - absent: the body is buffered and hashed before the handler runs (small payloads only);
+//     refused with streamBody, where the body can be a whole file
+//   - <hex sha256>: the declared hash is signed. With streamBody the body is verified while it
+//     streams, handlers abort and roll back through utils.VerifyBodyHash on mismatch; otherwise
+//     it is buffered and verified before the handler runs
+//   - UNSIGNED-PAYLOAD: the body is not covered by the signature
+//
+// Nonces are remembered in Redis for the timestamp tolerance window, so a
+// signed request can't be replayed within it.
+func handleHMACAuth(c *gin.Context, iamRepo *repository.IAMUserRepository, redis *infra.RedisClient, cfg *config.EnvConfig, authHeader string, streamBody bool) {
+	// Parse HMAC header: HMAC <accessKey>:<signature>
+	hmacValue := strings.TrimPrefix(authHeader, "HMAC ")
+	parts := strings.SplitN(hmacValue, ":", 2)
+	if len(parts) != 2 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid HMAC authorization format. Expected: HMAC <accessKey>:<signature>"})
+		c.Abort()
+		return
+	}
+
+	accessKey := parts[0]
+	clientSignature := parts[1]
+
+	if accessKey == "" || clientSignature == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Access key and signature are required"})
+		c.Abort()
+		return
+	}
+
+	// Resolve signature version
+	version := c.GetHeader("X-Signature-Version")
+	if version == "" {
+		version = SignatureVersion1
+	}
+	if version != SignatureVersion1 && version != SignatureVersion2 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unsupported X-Signature-Version. Use '1' or '2'"})
+		c.Abort()
+		return
+	}
+	if version == SignatureVersion1 && !cfg.HMAC.AllowV1 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Signature version 1 is disabled, use X-Signature-Version: 2"})
+		c.Abort()
+		return
+	}
+
+	// Validate X-Timestamp header
+	timestampStr := c.GetHeader("X-Timestamp")
+	if timestampStr == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "X-Timestamp header is required"})
+		c.Abort()
+		return
+	}
+
+	timestamp, err := strconv.ParseInt(timestampStr, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid X-Timestamp format"})
+		c.Abort()
+		return
+	}
+
+	// Anti-replay protection: check timestamp is within tolerance
+	serverTime := time.Now().Unix()
+	if utils.Abs(serverTime-timestamp) > TimestampTolerance {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Request timestamp expired"})
+		c.Abort()
+		return
+	}
+
+	// Validate X-Nonce header
+	nonce := c.GetHeader("X-Nonce")
+	if nonce == "" && version == SignatureVersion2 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "X-Nonce header is required"})
+		c.Abort()
+		return
+	}
+	if nonce != "" && (len(nonce) < MinNonceLength || len(nonce) > MaxNonceLength) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid X-Nonce length"})
+		c.Abort()
+		return
+	}
+
+	// Load IAM user by access key
+	iamUser, err := iamRepo.GetByAccessKey(accessKey)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid access key"})
+		c.Abort()
+		return
+	}
+
+	// Resolve the body hash that goes into the string to sign
+	var bodyHash string
+	declaredHash := c.GetHeader("X-Content-SHA256")
+	switch {
+	case declaredHash == utils.UnsignedPayload:
+		// Body is not covered by the signature
+		bodyHash = utils.UnsignedPayload
+
+	case declaredHash != "":
+		// Declared hash: sign the declared value and verify it while the body
+		// streams to the handler, instead of buffering the whole payload here
+		declaredHash = strings.ToLower(declaredHash)
+		if !utils.IsSHA256Hex(declaredHash) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "X-Content-SHA256 must be a hex SHA-256 or UNSIGNED-PAYLOAD"})
+			c.Abort()
+			return
+		}
+		bodyHash = declaredHash
+
+	case streamBody:
+		// Upload bodies can be whole files and are never buffered in memory
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "X-Content-SHA256 is required on upload routes, send the body's hex SHA-256 or UNSIGNED-PAYLOAD"})
+		c.Abort()
+		return
+
+	default:
+		// Read request body for hashing
+		var bodyBytes []byte
+		if c.Request.Body != nil {
+			bodyBytes, err = io.ReadAll(c.Request.Body)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+				c.Abort()
+				return
+			}
+			// Restore body for subsequent handlers
+			c.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+		}
+
+		// Compute body hash
+		bodyHash = utils.HashBodySHA256(bodyBytes)
+	}
+
+	var stringToSign string
+	if version == SignatureVersion2 {
+		signedHeaders := utils.ParseSignedHeaders(c.GetHeader("X-Signed-Headers"))
+		if missing := missingSignedHeaders(c, signedHeaders); missing != "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "X-Signed-Headers must include " + missing})
+			c.Abort()
+			return
+		}
+
+		canonicalHeaders := utils.BuildCanonicalHeaders(signedHeaders, func(name string) string {
+			// Go moves the Host header out of the header map
+			if name == "host" {
+				return c.Request.Host
+			}
+			return c.Request.Header.Get(name)
+		})
+
+		stringToSign = utils.BuildStringToSignV2(
+			c.Request.Method,
+			c.Request.URL.Path,
+			utils.BuildCanonicalQuery(c.Request.URL.Query()),
+			canonicalHeaders,
+			strings.Join(signedHeaders, ";"),
+			timestamp,
+			nonce,
+			bodyHash,
+		)
+	} else {
+		// Build string_to_sign: METHOD\nPATH\nTIMESTAMP\nSHA256(body)
+		stringToSign = utils.BuildStringToSign(
+			c.Request.Method,
+			c.Request.URL.Path,
+			timestamp,
+			bodyHash,
+		)
+	}
+
+	// Compute server signature
+	serverSignature := utils.ComputeHMACSHA256(iamUser.SecretKey, stringToSign)
+
+	// Constant-time comparison to prevent timing attacks
+	if !utils.SecureCompare(serverSignature, clientSignature) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid signature"})
+		c.Abort()
+		return
+	}
+
+	// Record the nonce only after the signature checks out, so unsigned
+	// requests can't burn nonces. The key lives for the whole window in
+	// which the timestamp would still be accepted.
+	if nonce != "" {
+		nonceKey := hmacNonceKeyPrefix + accessKey + ":" + nonce
+		fresh, err := redis.SetNX(c.Request.Context(), nonceKey, timestamp, 2*TimestampTolerance*time.Second)
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Unable to verify request nonce"})
+			c.Abort()
+			return
+		}
+		if !fresh {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Nonce has already been used"})
+			c.Abort()
+			return
+		}
+	}
+
+	// Wrap the body only once the request is authenticated. Streaming routes verify it in their
+	// handlers through utils.VerifyBodyHash after consuming the body and before committing anything;
+	// on any other route it is read and verified here, so no handler can act on an unverified one.
+	// The choice belongs to the route, never to unsigned request headers such as Content-Type.
+	if declaredHash != "" && declaredHash != utils.UnsignedPayload && c.Request.Body != nil {
+		verifier := utils.NewHashVerifyingReader(c.Request.Body, declaredHash)
+		if streamBody {
+			c.Request.Body = verifier
+			c.Set(utils.BodyHashVerifierKey, verifier)
+		} else {
+			bodyBytes, err := io.ReadAll(verifier)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				c.Abort()
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+		}
+	}
+
+	// Authentication successful - inject user info to context
+	c.Set("user_id", iamUser.UserId.String())
+	c.Set("iam_user_id", iamUser.ID.String())
+	c.Set("auth_method", infra.AuthMethodHMAC)
+	c.Set("signature_version", version)
+	c.Set("permission", utils.AccessKeyPermissions(iamUser.Role))
+	setRequestIdentity(c)
+
+	c.Next()
+}
+
+// missingSignedHeaders returns the first header that v2 requires to be signed
+// but is absent from signedHeaders, or "" when the list is complete
+func missingSignedHeaders(c *gin.Context, signedHeaders []string) string {
+	signed := make(map[string]bool, len(signedHeaders))
+	for _, name := range signedHeaders {
+		signed[name] = true
+	}
+
+	required := []string{"host", "x-nonce", "x-timestamp"}
+	if c.GetHeader("Content-Type") != "" {
+		required = append(required, "content-type")
+	}
+
+	for _, name := range required {
+		if !signed[name] {
+			return name
+		}
+	}
+	return ""
+}
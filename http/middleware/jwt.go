@@ -4,57 +4,69 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
-	"github.com/tnqbao/gau-cloud-orchestrator/config"
 	"github.com/tnqbao/gau-cloud-orchestrator/infra"
 	"github.com/tnqbao/gau-cloud-orchestrator/utils"
 )
 
-func AuthMiddleware(authService *infra.AuthorizationService, config *config.EnvConfig) gin.HandlerFunc {
+func AuthMiddleware(validator *TokenValidator) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var tokenStr string
-
-		tokenStr = utils.ExtractToken(c)
-
-		if tokenStr == "" {
-			tokenStr = c.Query("access_token")
-		}
-
-		if tokenStr == "" {
-			tokenStr = c.Param("token")
-		}
-
+		tokenStr := extractJWT(c)
 		if tokenStr == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization token is required"})
 			c.Abort()
 			return
 		}
 
-		if err := authService.CheckAccessToken(tokenStr); err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
-			c.Abort()
-			return
-		}
+		handleJWTAuth(c, validator, tokenStr)
+	}
+}
 
-		parsedToken, err := utils.ParseToken(tokenStr, config)
-		if err != nil || !parsedToken.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
-			return
-		}
+// extractJWT looks for an access token in the cookie or Bearer header,
+// then the access_token query parameter, then the :token path parameter
+func extractJWT(c *gin.Context) string {
+	tokenStr := utils.ExtractToken(c)
 
-		if claims, ok := parsedToken.Claims.(jwt.MapClaims); ok {
-			if err := utils.InjectClaimsToContext(c, claims); err != nil {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid claims"})
-				c.Abort()
-				return
-			}
-		} else {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
-			c.Abort()
-			return
-		}
+	if tokenStr == "" {
+		tokenStr = c.Query("access_token")
+	}
+
+	if tokenStr == "" {
+		tokenStr = c.Param("token")
+	}
+
+	return tokenStr
+}
+
+// handleJWTAuth validates the token and injects its claims into the context
+func handleJWTAuth(c *gin.Context, validator *TokenValidator, tokenStr string) {
+	// Verify token (local signature check, cached result or authorization service)
+	claims, err := validator.Validate(c.Request.Context(), tokenStr)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		c.Abort()
+		return
+	}
+
+	// Inject claims to context
+	if err := utils.InjectClaimsToContext(c, claims); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid claims"})
+		c.Abort()
+		return
+	}
+
+	c.Set("auth_method", infra.AuthMethodJWT)
+	setRequestIdentity(c)
+
+	c.Next()
+}
 
-		c.Next()
+// setRequestIdentity copies the authenticated caller from the gin context into
+// the request context, so controller and infra logs record who made the request
+func setRequestIdentity(c *gin.Context) {
+	identity := infra.RequestIdentity{
+		UserID:     c.GetString("user_id"),
+		IAMUserID:  c.GetString("iam_user_id"),
+		AuthMethod: c.GetString("auth_method"),
 	}
+	c.Request = c.Request.WithContext(infra.WithRequestIdentity(c.Request.Context(), identity))
 }
@@ -6,23 +6,45 @@ import (
 )
 
 type Middlewares struct {
-	CORSMiddleware       gin.HandlerFunc
-	AuthMiddleware       gin.HandlerFunc
-	UploadAuthMiddleware gin.HandlerFunc
+	CORSMiddleware     gin.HandlerFunc
+	AuthMiddleware     gin.HandlerFunc
+	DualAuthMiddleware gin.HandlerFunc
+	// StreamingDualAuthMiddleware leaves declared body hashes to the handler, upload routes only
+	StreamingDualAuthMiddleware gin.HandlerFunc
+	AccessLogMiddleware         gin.HandlerFunc
+	RequirePermission           func(permissions ...string) gin.HandlerFunc
+	RateLimit                   func(group string) gin.HandlerFunc
+	UsageMeter                  gin.HandlerFunc
 }
 
 func NewMiddlewares(ctrl *controller.Controller) (*Middlewares, error) {
 	cors := CORSMiddleware(ctrl.Config.EnvConfig)
-	auth := AuthMiddleware(ctrl.Infra.AuthorizationService, ctrl.Config.EnvConfig)
-	uploadAuth := UploadAuthMiddleware(
-		ctrl.Infra.AuthorizationService,
+	tokenValidator := NewTokenValidator(ctrl.Infra, ctrl.Config.EnvConfig)
+	auth := AuthMiddleware(tokenValidator)
+	dualAuth := DualAuthMiddleware(
+		tokenValidator,
 		ctrl.Repository.IAMUserRepo,
+		ctrl.Infra.Redis,
 		ctrl.Config.EnvConfig,
 	)
+	streamingDualAuth := StreamingDualAuthMiddleware(
+		tokenValidator,
+		ctrl.Repository.IAMUserRepo,
+		ctrl.Infra.Redis,
+		ctrl.Config.EnvConfig,
+	)
+
+	requirePermission := PermissionMiddleware(ctrl.Config.EnvConfig, ctrl.Repository)
+	rateLimit := RateLimitMiddleware(ctrl.Infra, ctrl.Config.EnvConfig)
 
 	return &Middlewares{
-		CORSMiddleware:       cors,
-		AuthMiddleware:       auth,
-		UploadAuthMiddleware: uploadAuth,
+		CORSMiddleware:              cors,
+		AuthMiddleware:              auth,
+		DualAuthMiddleware:          dualAuth,
+		StreamingDualAuthMiddleware: streamingDualAuth,
+		AccessLogMiddleware:         AccessLogMiddleware(ctrl.Infra.Logger),
+		RequirePermission:           requirePermission,
+		RateLimit:                   rateLimit,
+		UsageMeter:                  UsageMeterMiddleware(ctrl.Infra.UsageMeter),
 	}, nil
 }
@@ -0,0 +1,112 @@
+package middlewares
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tnqbao/gau-cloud-orchestrator/config"
+	"github.com/tnqbao/gau-cloud-orchestrator/infra"
+	"github.com/tnqbao/gau-cloud-orchestrator/repository"
+	"github.com/tnqbao/gau-cloud-orchestrator/utils"
+	"gorm.io/gorm"
+)
+
+// PermissionMiddleware returns a factory for route-level permission checks.
+// Must run after AuthMiddleware or UploadAuthMiddleware, which set the
+// "permission" value in the context. Every listed permission is required.
+// Requests signed with an IAM access key on a /buckets/:id route must also
+// be granted the matching action on that bucket by the key's S3 policy.
+func PermissionMiddleware(cfg *config.EnvConfig, repo *repository.Repository) func(permissions ...string) gin.HandlerFunc {
+	return func(permissions ...string) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			granted := utils.ResolvePermissions(c.GetString("permission"), cfg.Authz.DefaultRole)
+
+			for _, required := range permissions {
+				if !utils.HasPermission(granted, required) {
+					utils.JSON403(c, "Forbidden: missing permission '"+required+"'")
+					c.Abort()
+					return
+				}
+			}
+
+			if c.GetString("auth_method") == infra.AuthMethodHMAC && c.Param("id") != "" {
+				if !bucketPolicyAllows(c, repo, permissions) {
+					c.Abort()
+					return
+				}
+			}
+
+			c.Next()
+		}
+	}
+}
+
+// bucketPolicyAllows checks the S3 policy of the calling access key against the :id bucket and
+// answers the request itself when it does not grant every required permission. A bucket that
+// does not exist is left to the handler, which answers 404.
+func bucketPolicyAllows(c *gin.Context, repo *repository.Repository, permissions []string) bool {
+	bucketID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.JSON400(c, "Invalid bucket_id format")
+		return false
+	}
+	bucket, err := repo.BucketRepo.FindByID(bucketID)
+	if err != nil {
+		return true
+	}
+
+	iamID, err := uuid.Parse(c.GetString("iam_user_id"))
+	if err != nil {
+		utils.JSON401(c, "Unauthorized: iam_user_id not found")
+		return false
+	}
+	policy, err := repo.IAMPolicyRepo.GetByIAMIDAndType(iamID, "s3")
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.JSON403(c, "Forbidden: access key has no S3 policy")
+		return false
+	}
+	if err != nil {
+		utils.JSON500(c, "Failed to load access key policy")
+		return false
+	}
+
+	for _, required := range permissions {
+		action := s3ActionFor(required, c.Request.Method)
+		if action == "" {
+			continue
+		}
+		allowed, err := utils.PolicyAllows(policy.Policy, action, bucket.Name)
+		if err != nil {
+			utils.JSON500(c, "Failed to evaluate access key policy")
+			return false
+		}
+		if !allowed {
+			utils.JSON403(c, "Forbidden: access key policy does not allow '"+action+"' on this bucket")
+			return false
+		}
+	}
+	return true
+}
+
+// s3ActionFor maps a route permission to the S3 action the access key policy must grant
+func s3ActionFor(permission, method string) string {
+	switch permission {
+	case utils.PermObjectRead:
+		return utils.S3GetObject
+	case utils.PermObjectWrite:
+		if method == http.MethodDelete {
+			return utils.S3DeleteObject
+		}
+		return utils.S3PutObject
+	case utils.PermBucketRead:
+		return utils.S3ListBucket
+	case utils.PermBucketWrite:
+		if method == http.MethodDelete {
+			return utils.S3DeleteBucket
+		}
+		return utils.S3PutBucketPolicy
+	}
+	return ""
+}
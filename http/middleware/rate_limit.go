@@ -0,0 +1,78 @@
+package middlewares
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tnqbao/gau-cloud-orchestrator/config"
+	"github.com/tnqbao/gau-cloud-orchestrator/infra"
+	"github.com/tnqbao/gau-cloud-orchestrator/utils"
+)
+
+// rateLimitRemainingKey holds the remaining requests of the tightest limit a request has passed
+const rateLimitRemainingKey = "rate_limit_remaining"
+
+// RateLimitMiddleware returns a factory for per-group rate limits.
+// The ip group is keyed by the client IP and runs ahead of authentication, so failed credentials
+// are throttled too. Every other group must run after authentication so requests are keyed by
+// iam_user_id (HMAC) or user_id (JWT); unauthenticated requests fall back to the client IP.
+// When several limits apply, the RateLimit headers describe the tightest one.
+// If Redis is unavailable the request is let through rather than rejected.
+func RateLimitMiddleware(i *infra.Infra, cfg *config.EnvConfig) func(group string) gin.HandlerFunc {
+	return func(group string) gin.HandlerFunc {
+		rule, ok := cfg.RateLimit.Groups[group]
+		if !cfg.RateLimit.Enabled || !ok || rule.Requests <= 0 || rule.Window <= 0 {
+			return func(c *gin.Context) { c.Next() }
+		}
+		window := time.Duration(rule.Window) * time.Second
+		identity := rateLimitIdentity
+		if group == config.RateLimitGroupIP {
+			identity = func(c *gin.Context) string { return "ip:" + c.ClientIP() }
+		}
+
+		return func(c *gin.Context) {
+			key := group + ":" + identity(c)
+
+			result, err := i.RateLimiter.Allow(c.Request.Context(), key, rule.Requests, window)
+			if err != nil {
+				i.Logger.WarningWithContextf(c.Request.Context(), "[RateLimit] Limiter unavailable, allowing request: %v", err)
+				c.Next()
+				return
+			}
+
+			resetSeconds := int(math.Ceil(result.ResetAfter.Seconds()))
+			if remaining, seen := c.Get(rateLimitRemainingKey); !seen || !result.Allowed || result.Remaining < remaining.(int) {
+				c.Set(rateLimitRemainingKey, result.Remaining)
+				c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+				c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+				c.Header("RateLimit-Reset", strconv.Itoa(resetSeconds))
+				c.Header("RateLimit-Policy", strconv.Itoa(rule.Requests)+";w="+strconv.Itoa(rule.Window))
+			}
+
+			if !result.Allowed {
+				if resetSeconds < 1 {
+					resetSeconds = 1
+				}
+				c.Header("Retry-After", strconv.Itoa(resetSeconds))
+				utils.JSON429(c, "Too many requests, please retry later")
+				c.Abort()
+				return
+			}
+
+			c.Next()
+		}
+	}
+}
+
+// rateLimitIdentity picks the most specific caller identity available
+func rateLimitIdentity(c *gin.Context) string {
+	if iamUserID := c.GetString("iam_user_id"); iamUserID != "" {
+		return "iam:" + iamUserID
+	}
+	if userID := c.GetString("user_id"); userID != "" {
+		return "user:" + userID
+	}
+	return "ip:" + c.ClientIP()
+}
@@ -0,0 +1,100 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/tnqbao/gau-cloud-orchestrator/config"
+	"github.com/tnqbao/gau-cloud-orchestrator/infra"
+	"github.com/tnqbao/gau-cloud-orchestrator/utils"
+)
+
+var (
+	errInvalidToken  = errors.New("invalid or expired token")
+	errInvalidClaims = errors.New("invalid token claims")
+	errRevokedToken  = errors.New("token has been revoked")
+)
+
+// TokenValidator validates access tokens without calling the authorization
+// service on every request:
+//  1. the signature and expiry are checked locally with utils.ParseToken
+//  2. a cached result (keyed by token hash) is used when present
+//  3. on a cache miss the authorization service is asked and the answer is cached
+//  4. if the service is down or its circuit breaker is open, the local check is trusted
+type TokenValidator struct {
+	authService *infra.AuthorizationService
+	tokenCache  *infra.TokenCache
+	logger      *infra.LoggerClient
+	cfg         *config.EnvConfig
+}
+
+func NewTokenValidator(i *infra.Infra, cfg *config.EnvConfig) *TokenValidator {
+	return &TokenValidator{
+		authService: i.AuthorizationService,
+		tokenCache:  i.TokenCache,
+		logger:      i.Logger,
+		cfg:         cfg,
+	}
+}
+
+// Validate returns the token claims if the token is accepted
+func (v *TokenValidator) Validate(ctx context.Context, tokenStr string) (jwt.MapClaims, error) {
+	parsedToken, err := utils.ParseToken(tokenStr, v.cfg)
+	if err != nil || !parsedToken.Valid {
+		return nil, errInvalidToken
+	}
+
+	claims, ok := parsedToken.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errInvalidClaims
+	}
+
+	var expiresAt time.Time
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		expiresAt = exp.Time
+	}
+
+	tokenHash := infra.HashToken(tokenStr)
+
+	status, err := v.tokenCache.Lookup(ctx, tokenHash)
+	if err != nil {
+		// Cache outage should not block auth, fall through to the service
+		v.logger.WarningWithContextf(ctx, "[Auth] Token cache lookup failed: %v", err)
+	}
+
+	switch status {
+	case infra.TokenStatusValid:
+		return claims, nil
+	case infra.TokenStatusInvalid:
+		return nil, errInvalidToken
+	case infra.TokenStatusRevoked:
+		return nil, errRevokedToken
+	}
+
+	err = v.authService.CheckAccessToken(ctx, tokenStr)
+	switch {
+	case err == nil:
+		if cacheErr := v.tokenCache.Store(ctx, tokenHash, infra.TokenStatusValid, expiresAt); cacheErr != nil {
+			v.logger.WarningWithContextf(ctx, "[Auth] Failed to cache token validation: %v", cacheErr)
+		}
+		return claims, nil
+
+	case errors.Is(err, infra.ErrInvalidToken):
+		if cacheErr := v.tokenCache.Store(ctx, tokenHash, infra.TokenStatusInvalid, expiresAt); cacheErr != nil {
+			v.logger.WarningWithContextf(ctx, "[Auth] Failed to cache token validation: %v", cacheErr)
+		}
+		return nil, errInvalidToken
+
+	case errors.Is(err, infra.ErrAuthServiceUnavailable):
+		// Degrade to local verification, the result is not cached so the
+		// service is consulted again once it recovers
+		v.logger.WarningWithContextf(ctx, "[Auth] Authorization service unavailable, using local token verification: %v", err)
+		return claims, nil
+
+	default:
+		v.logger.ErrorWithContextf(ctx, err, "[Auth] Token validation failed: %v", err)
+		return nil, errInvalidToken
+	}
+}
@@ -0,0 +1,43 @@
+package middlewares
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/tnqbao/gau-cloud-orchestrator/entity"
+	"github.com/tnqbao/gau-cloud-orchestrator/infra"
+)
+
+// UsageMeterMiddleware counts completed requests per account, bucket and operation.
+// The operation is the controller method serving the route, e.g. "UploadObject".
+// Requests rejected by authentication, authorization or rate limiting are not charged.
+func UsageMeterMiddleware(meter *infra.UsageMeter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		status := c.Writer.Status()
+		if status == http.StatusUnauthorized || status == http.StatusForbidden || status == http.StatusTooManyRequests {
+			return
+		}
+		ownerID, err := uuid.Parse(c.GetString("user_id"))
+		if err != nil {
+			return
+		}
+		// Account-level routes (list/create bucket) are charged to the nil bucket
+		bucketID, _ := uuid.Parse(c.Param("id"))
+
+		meter.Add(ownerID, bucketID, entity.MetricRequestCount, operationName(c.HandlerName()), 1)
+	}
+}
+
+// operationName trims a gin handler name such as
+// "github.com/.../controller.(*Controller).UploadObject-fm" to "UploadObject"
+func operationName(handlerName string) string {
+	name := strings.TrimSuffix(handlerName, "-fm")
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
@@ -2,8 +2,10 @@ package routes
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/tnqbao/gau-cloud-orchestrator/config"
 	"github.com/tnqbao/gau-cloud-orchestrator/http/controller"
 	middlewares "github.com/tnqbao/gau-cloud-orchestrator/http/middleware"
+	"github.com/tnqbao/gau-cloud-orchestrator/utils"
 )
 
 func SetupRouter(ctrl *controller.Controller) *gin.Engine {
@@ -13,50 +15,144 @@ func SetupRouter(ctrl *controller.Controller) *gin.Engine {
 		panic(err)
 	}
 	r.Use(middlewares.CORSMiddleware(ctrl.Config.EnvConfig))
+	r.Use(middlewares.RequestIDMiddleware())
+
+	// Health probes (no auth)
+	r.GET("/healthz", ctrl.Liveness)
+	r.GET("/readyz", ctrl.Readiness)
+
+	perm := middles.RequirePermission
+	limit := middles.RateLimit
 
 	apiRoutes := r.Group("/api/v1/cloud")
 	{
-		apiRoutes.Use(middles.AuthMiddleware)
+		// Throttled by client IP before any credentials are checked
+		apiRoutes.Use(middles.AccessLogMiddleware, limit(config.RateLimitGroupIP))
 
+		// IAM management - JWT only
 		aimRoutes := apiRoutes.Group("/iam")
 		{
-			aimRoutes.POST("/", ctrl.CreateIAM)
-			aimRoutes.GET("/", ctrl.ListIAMs)
-			aimRoutes.DELETE("/:id", ctrl.DeleteIAMByID)
-			aimRoutes.PUT("/:id", ctrl.UpdateIAMCredentials)
+			aimRoutes.Use(middles.AuthMiddleware, limit(config.RateLimitGroupAPI))
+			aimRoutes.POST("/", perm(utils.PermIAMAdmin), ctrl.CreateIAM)
+			aimRoutes.GET("/", perm(utils.PermIAMRead), ctrl.ListIAMs)
+			aimRoutes.DELETE("/:id", perm(utils.PermIAMAdmin), ctrl.DeleteIAMByID)
+			aimRoutes.PUT("/:id", perm(utils.PermIAMAdmin), ctrl.UpdateIAMCredentials)
 			//aimRoutes.PUT("/credentials/update", ctrl.UpdateIAMCredentials)
 		}
 
+		// Bucket and object routes - dual auth (JWT or HMAC)
 		bucketRoutes := apiRoutes.Group("/buckets")
 		{
-			bucketRoutes.POST("/", ctrl.CreateBucket)
-			bucketRoutes.GET("/", ctrl.ListBuckets)
-			bucketRoutes.DELETE("/:id", ctrl.DeleteBucketByID)
-			bucketRoutes.PUT("/:id/access", ctrl.UpdateBucketAccess)
-			bucketRoutes.GET("/:id/access", ctrl.GetBucketAccess)
+			bucketRoutes.Use(middles.DualAuthMiddleware, limit(config.RateLimitGroupAPI), middles.UsageMeter)
+			bucketRoutes.POST("/", perm(utils.PermBucketWrite), ctrl.CreateBucket)
+			bucketRoutes.GET("/", perm(utils.PermBucketRead), ctrl.ListBuckets)
+			bucketRoutes.DELETE("/:id", perm(utils.PermBucketWrite), ctrl.DeleteBucketByID)
+			bucketRoutes.PUT("/:id/access", perm(utils.PermBucketWrite), ctrl.UpdateBucketAccess)
+			bucketRoutes.GET("/:id/access", perm(utils.PermBucketRead), ctrl.GetBucketAccess)
+			bucketRoutes.GET("/:id/quota", perm(utils.PermBucketRead), ctrl.GetBucketQuota)
+			bucketRoutes.PUT("/:id/quota", perm(utils.PermBucketWrite), ctrl.UpdateBucketQuota)
+			bucketRoutes.PUT("/:id/conflict", perm(utils.PermBucketWrite), ctrl.UpdateBucketConflictMode)
+			bucketRoutes.GET("/:id/dedup", perm(utils.PermBucketRead), ctrl.GetBucketDedupReport)
+
+			// Object routes (nested under bucket)
+			bucketRoutes.GET("/:id/objects/*path", perm(utils.PermObjectRead), ctrl.ListObjectsByPath)
+			bucketRoutes.DELETE("/:id/objects/:object_id", perm(utils.PermObjectWrite), ctrl.DeleteObject)
+			bucketRoutes.GET("/:id/download/:object_id", perm(utils.PermObjectRead), ctrl.DownloadObject)
+			bucketRoutes.GET("/:id/versions/:object_id", perm(utils.PermObjectRead), ctrl.ListObjectVersions)
+			bucketRoutes.POST("/:id/archive", perm(utils.PermObjectRead), ctrl.DownloadArchive)
+			bucketRoutes.DELETE("/:id/objects/path/*path", perm(utils.PermObjectWrite), ctrl.DeleteObjectsByPath)
+			bucketRoutes.POST("/:id/objects/:object_id/copy", perm(utils.PermObjectWrite), ctrl.CopyObject)
+			bucketRoutes.POST("/:id/objects/:object_id/move", perm(utils.PermObjectWrite), ctrl.MoveObject)
+			bucketRoutes.POST("/:id/objects/:object_id/rename", perm(utils.PermObjectWrite), ctrl.RenameObject)
 
-			// Object routes (nested under bucket) - JWT only
-			bucketRoutes.GET("/:id/objects/*path", ctrl.ListObjectsByPath)
-			bucketRoutes.DELETE("/:id/objects/:object_id", ctrl.DeleteObject)
-			bucketRoutes.GET("/:id/download/:object_id", ctrl.DownloadObject)
-			bucketRoutes.DELETE("/:id/objects/path/*path", ctrl.DeleteObjectsByPath)
+			// Folder routes
+			bucketRoutes.POST("/:id/folders", perm(utils.PermObjectWrite), ctrl.CreateFolder)
+			bucketRoutes.POST("/:id/folders/copy", perm(utils.PermObjectWrite), ctrl.CopyFolder)
+			bucketRoutes.POST("/:id/folders/move", perm(utils.PermObjectWrite), ctrl.MoveFolder)
+			bucketRoutes.POST("/:id/folders/rename", perm(utils.PermObjectWrite), ctrl.RenameFolder)
+			bucketRoutes.GET("/:id/folders/*path", perm(utils.PermObjectRead), ctrl.GetFolder)
 
 			// Chunked upload routes (separate from /objects to avoid wildcard conflict)
-			bucketRoutes.POST("/:id/chunked/init", ctrl.InitChunkedUpload)
-			bucketRoutes.POST("/:id/chunked/chunk", ctrl.UploadChunk)
-			bucketRoutes.POST("/:id/chunked/complete", ctrl.CompleteChunkedUpload)
-			bucketRoutes.GET("/:id/chunked/:upload_id/progress", ctrl.GetUploadProgress)
-			bucketRoutes.GET("/:id/chunked/:upload_id/status", ctrl.GetChunkedUploadStatus)
-			bucketRoutes.DELETE("/:id/chunked/:upload_id", ctrl.AbortChunkedUpload)
+			bucketRoutes.POST("/:id/chunked/init", perm(utils.PermObjectWrite), limit(config.RateLimitGroupUpload), ctrl.InitChunkedUpload)
+			bucketRoutes.POST("/:id/chunked/complete", perm(utils.PermObjectWrite), limit(config.RateLimitGroupUpload), ctrl.CompleteChunkedUpload)
+			bucketRoutes.GET("/:id/chunked/:upload_id/progress", perm(utils.PermObjectRead), ctrl.GetUploadProgress)
+			bucketRoutes.GET("/:id/chunked/:upload_id/status", perm(utils.PermObjectRead), ctrl.GetChunkedUploadStatus)
+			bucketRoutes.GET("/:id/chunked/:upload_id/parts", perm(utils.PermObjectWrite), ctrl.GetDirectUploadParts)
+			bucketRoutes.DELETE("/:id/chunked/:upload_id", perm(utils.PermObjectWrite), ctrl.AbortChunkedUpload)
+			bucketRoutes.GET("/:id/chunked", perm(utils.PermObjectRead), ctrl.ListChunkedUploads)
+			bucketRoutes.GET("/:id/chunked/events", perm(utils.PermObjectRead), ctrl.StreamBucketUploadEvents)
+			bucketRoutes.GET("/:id/chunked/:upload_id/events", perm(utils.PermObjectRead), ctrl.StreamUploadEvents)
+			bucketRoutes.GET("/:id/chunked/:upload_id/callbacks", perm(utils.PermObjectRead), ctrl.ListUploadCallbacks)
+			bucketRoutes.POST("/:id/chunked/:upload_id/extend", perm(utils.PermObjectWrite), ctrl.ExtendChunkedUpload)
+			bucketRoutes.POST("/:id/chunked/abort", perm(utils.PermObjectWrite), ctrl.AbortChunkedUploads)
+
+			// tus 1.0 resumable uploads
+			bucketRoutes.POST("/:id/tus", perm(utils.PermObjectWrite), limit(config.RateLimitGroupUpload), ctrl.TusCreateUpload)
+			bucketRoutes.HEAD("/:id/tus/:upload_id", perm(utils.PermObjectWrite), ctrl.TusUploadOffset)
+			bucketRoutes.DELETE("/:id/tus/:upload_id", perm(utils.PermObjectWrite), ctrl.TusTerminate)
 		}
 
-	}
+		// Upload routes - dual auth, with handlers that stream the body and verify a declared
+		// X-Content-SHA256 themselves. Every other route must stay on DualAuthMiddleware.
+		streamedRoutes := apiRoutes.Group("/buckets")
+		{
+			streamedRoutes.Use(middles.StreamingDualAuthMiddleware, limit(config.RateLimitGroupAPI), middles.UsageMeter)
+			streamedRoutes.POST("/:id/objects", perm(utils.PermObjectWrite), limit(config.RateLimitGroupUpload), ctrl.UploadObject)
+			streamedRoutes.POST("/:id/chunked/chunk", perm(utils.PermObjectWrite), limit(config.RateLimitGroupUpload), ctrl.UploadChunk)
+			streamedRoutes.PATCH("/:id/tus/:upload_id", perm(utils.PermObjectWrite), limit(config.RateLimitGroupUpload), ctrl.TusAppend)
+		}
 
-	// Upload routes with dual auth (JWT or HMAC)
-	uploadRoutes := r.Group("/api/v1/cloud/buckets")
-	{
-		uploadRoutes.Use(middles.UploadAuthMiddleware)
-		uploadRoutes.POST("/:id/objects", ctrl.UploadObject)
+		// tus discovery needs no credentials
+		apiRoutes.OPTIONS("/buckets/:id/tus", ctrl.TusOptions)
+		apiRoutes.OPTIONS("/buckets/:id/tus/:upload_id", ctrl.TusOptions)
+
+		// Storage quota of the caller's account - JWT only
+		quotaRoutes := apiRoutes.Group("/quota")
+		{
+			quotaRoutes.Use(middles.AuthMiddleware, limit(config.RateLimitGroupAPI))
+			quotaRoutes.GET("/", perm(utils.PermBucketRead), ctrl.GetQuota)
+			quotaRoutes.PUT("/", perm(utils.PermBucketWrite), ctrl.UpdateQuotaNotification)
+		}
+
+		// Audit trail of the caller's account - JWT only
+		auditRoutes := apiRoutes.Group("/audit")
+		{
+			auditRoutes.Use(middles.AuthMiddleware, limit(config.RateLimitGroupAPI), perm(utils.PermAuditRead))
+			auditRoutes.GET("/", ctrl.ListAuditLogs)
+			auditRoutes.GET("/export", ctrl.ExportAuditLogs)
+		}
+
+		// In-progress chunked uploads of the caller across buckets - JWT only
+		uploadRoutes := apiRoutes.Group("/uploads")
+		{
+			uploadRoutes.Use(middles.AuthMiddleware, limit(config.RateLimitGroupAPI))
+			uploadRoutes.GET("/", perm(utils.PermObjectRead), ctrl.ListUserChunkedUploads)
+			uploadRoutes.POST("/abort", perm(utils.PermObjectWrite), ctrl.AbortUserChunkedUploads)
+		}
+
+		// Usage reports of the caller's account - JWT only
+		usageRoutes := apiRoutes.Group("/usage")
+		{
+			usageRoutes.Use(middles.AuthMiddleware, limit(config.RateLimitGroupAPI), perm(utils.PermUsageRead))
+			usageRoutes.GET("/", ctrl.GetUsage)
+			usageRoutes.GET("/export", ctrl.ExportUsage)
+			usageRoutes.GET("/dedup", ctrl.GetDedupReport)
+		}
+
+		// Admin-only routes - JWT only
+		adminRoutes := apiRoutes.Group("/admin")
+		{
+			adminRoutes.Use(middles.AuthMiddleware, perm(utils.PermAdmin), limit(config.RateLimitGroupAdmin))
+			adminRoutes.GET("/status", ctrl.AdminSystemStatus)
+			adminRoutes.GET("/users/:user_id/buckets", ctrl.AdminListUserBuckets)
+			adminRoutes.GET("/users/:user_id/iam", ctrl.AdminListUserIAMs)
+			adminRoutes.GET("/users/:user_id/quota", ctrl.AdminGetUserQuota)
+			adminRoutes.PUT("/users/:user_id/quota", ctrl.AdminUpdateUserQuota)
+			adminRoutes.GET("/audit", ctrl.AdminListAuditLogs)
+			adminRoutes.GET("/audit/export", ctrl.AdminExportAuditLogs)
+			adminRoutes.GET("/usage", ctrl.AdminGetUsage)
+			adminRoutes.GET("/usage/export", ctrl.AdminExportUsage)
+		}
 	}
 
 	return r
@@ -2,7 +2,9 @@ package infra
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -27,9 +29,18 @@ type RenewTokenResponse struct {
 	ExpiresIn   int    `json:"expires_in"`
 }
 
+var (
+	// ErrInvalidToken means the authorization service rejected the token
+	ErrInvalidToken = errors.New("invalid or expired token")
+	// ErrAuthServiceUnavailable means the authorization service could not be reached
+	// or the circuit breaker is open; callers may fall back to local verification
+	ErrAuthServiceUnavailable = errors.New("authorization service unavailable")
+)
+
 type AuthorizationService struct {
 	AuthorizationServiceURL string
 	PrivateKey              string
+	Breaker                 *CircuitBreaker
 }
 
 func InitAuthorizationService(config *config.EnvConfig) *AuthorizationService {
@@ -46,6 +57,10 @@ func InitAuthorizationService(config *config.EnvConfig) *AuthorizationService {
 	return &AuthorizationService{
 		AuthorizationServiceURL: url,
 		PrivateKey:              privateKey,
+		Breaker: NewCircuitBreaker(
+			config.AuthCache.BreakerThreshold,
+			time.Duration(config.AuthCache.BreakerCooldown)*time.Second,
+		),
 	}
 }
 
@@ -129,26 +144,45 @@ func (s *AuthorizationService) RenewAccessToken(refreshToken, deviceID, oldAcces
 	return response.AccessToken, expiry, nil
 }
 
-func (s *AuthorizationService) CheckAccessToken(token string) error {
-	url := fmt.Sprintf("%s/api/v2/authorization/token/validate?token=%s", s.AuthorizationServiceURL, token)
+// CheckAccessToken validates a token against the authorization service.
+// The token is only sent in the Authorization header, never in the URL.
+// Returns ErrInvalidToken when the service rejects the token and
+// ErrAuthServiceUnavailable on network errors, 5xx responses or an open breaker.
+func (s *AuthorizationService) CheckAccessToken(ctx context.Context, token string) error {
+	url := fmt.Sprintf("%s/api/v2/authorization/token/validate", s.AuthorizationServiceURL)
 
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+	// Built before asking the breaker, so every request it lets through records an outcome
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("Authorization", "Bearer "+token)
 	req.Header.Set("Private-Key", s.PrivateKey)
 
+	if !s.Breaker.Allow() {
+		return fmt.Errorf("%w: circuit breaker is open", ErrAuthServiceUnavailable)
+	}
+
 	client := &http.Client{Timeout: 5 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("request failed: %w", err)
+		s.Breaker.RecordFailure()
+		return fmt.Errorf("%w: %v", ErrAuthServiceUnavailable, err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode >= http.StatusInternalServerError {
+		s.Breaker.RecordFailure()
+		raw, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%w: service returned %d: %s", ErrAuthServiceUnavailable, resp.StatusCode, string(raw))
+	}
+
+	// The service answered, so it is healthy even if the token is rejected
+	s.Breaker.RecordSuccess()
+
 	if resp.StatusCode != http.StatusOK {
 		raw, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("invalid token: %s", string(raw))
+		return fmt.Errorf("%w: %s", ErrInvalidToken, string(raw))
 	}
 
 	return nil
@@ -180,3 +214,24 @@ func (s *AuthorizationService) RevokeToken(refreshToken, deviceID string) error
 
 	return nil
 }
+
+// Ping checks that the authorization service is reachable.
+// Any response below 500 means the service is up and answering requests.
+func (s *AuthorizationService) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.AuthorizationServiceURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("authorization service returned %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -31,6 +31,14 @@ func InitRedisClient(cfg *config.EnvConfig) *RedisClient {
 	return &RedisClient{Client: client}
 }
 
+func (r *RedisClient) Close() error {
+	return r.Client.Close()
+}
+
+func (r *RedisClient) Ping(ctx context.Context) error {
+	return r.Client.Ping(ctx).Err()
+}
+
 func (r *RedisClient) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
 	data, err := json.Marshal(value)
 	if err != nil {
@@ -50,6 +58,18 @@ func (r *RedisClient) Get(ctx context.Context, key string, dest interface{}) err
 	return json.Unmarshal(data, dest)
 }
 
+// GetDel reads and removes the key atomically, so only one caller can consume a value
+func (r *RedisClient) GetDel(ctx context.Context, key string, dest interface{}) error {
+	data, err := r.Client.GetDel(ctx, key).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return errors.New("key not found in cache")
+		}
+		return err
+	}
+	return json.Unmarshal(data, dest)
+}
+
 func (r *RedisClient) Delete(ctx context.Context, keys ...string) error {
 	return r.Client.Del(ctx, keys...).Err()
 }
@@ -70,6 +90,28 @@ func (r *RedisClient) SetNX(ctx context.Context, key string, value interface{},
 	return r.Client.SetNX(ctx, key, data, expiration).Result()
 }
 
+// compareAndDeleteScript deletes KEYS[1] only while it still holds ARGV[1]
+var compareAndDeleteScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// DeleteIfValue removes the key only while it still holds value, so a lock that expired and was taken
+// by another holder is not released by the previous one. It reports whether the key was removed.
+func (r *RedisClient) DeleteIfValue(ctx context.Context, key string, value interface{}) (bool, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return false, err
+	}
+	removed, err := compareAndDeleteScript.Run(ctx, r.Client, []string{key}, data).Int()
+	if err != nil {
+		return false, err
+	}
+	return removed > 0, nil
+}
+
 func (r *RedisClient) Expire(ctx context.Context, key string, expiration time.Duration) error {
 	return r.Client.Expire(ctx, key, expiration).Err()
 }
@@ -0,0 +1,88 @@
+package infra
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	BreakerStateClosed   = "closed"
+	BreakerStateOpen     = "open"
+	BreakerStateHalfOpen = "half_open"
+)
+
+// CircuitBreaker stops calling a failing dependency for a cooldown period.
+// After failureThreshold consecutive failures it opens; once the cooldown
+// elapses a single trial call is let through (half-open) and its outcome
+// decides whether the breaker closes again or re-opens.
+type CircuitBreaker struct {
+	mu               sync.Mutex
+	failureThreshold int
+	cooldown         time.Duration
+	failures         int
+	state            string
+	openedAt         time.Time
+}
+
+func NewCircuitBreaker(failureThreshold int, cooldown time.Duration) *CircuitBreaker {
+	if failureThreshold <= 0 {
+		failureThreshold = 5
+	}
+	if cooldown <= 0 {
+		cooldown = 30 * time.Second
+	}
+	return &CircuitBreaker{
+		failureThreshold: failureThreshold,
+		cooldown:         cooldown,
+		state:            BreakerStateClosed,
+	}
+}
+
+// Allow reports whether a call to the dependency may be attempted
+func (b *CircuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerStateOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+		// Cooldown elapsed: let one trial call through
+		b.state = BreakerStateHalfOpen
+		return true
+	case BreakerStateHalfOpen:
+		// A trial call is already in flight
+		return false
+	default:
+		return true
+	}
+}
+
+// RecordSuccess closes the breaker and resets the failure count
+func (b *CircuitBreaker) RecordSuccess() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.state = BreakerStateClosed
+}
+
+// RecordFailure counts a failure and opens the breaker when the threshold is reached
+func (b *CircuitBreaker) RecordFailure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.state == BreakerStateHalfOpen || b.failures >= b.failureThreshold {
+		b.state = BreakerStateOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// State returns the current breaker state
+func (b *CircuitBreaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
@@ -1,6 +1,7 @@
 package infra
 
 import (
+	"context"
 	"fmt"
 	"log"
 
@@ -28,7 +29,10 @@ func InitPostgresClient(cfg *config.EnvConfig) *PostgresClient {
 		pgHost, pgUser, pgPassword, pgDB, pgPort,
 	)
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		// Unique violations surface as gorm.ErrDuplicatedKey
+		TranslateError: true,
+	})
 	if err != nil {
 		log.Fatal("Failed to connect to database:", err)
 	}
@@ -37,3 +41,21 @@ func InitPostgresClient(cfg *config.EnvConfig) *PostgresClient {
 
 	return &PostgresClient{DB: db}
 }
+
+// Close closes the underlying connection pool
+func (p *PostgresClient) Close() error {
+	sqlDB, err := p.DB.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get database handle: %w", err)
+	}
+	return sqlDB.Close()
+}
+
+// Ping verifies the underlying connection pool can reach the database
+func (p *PostgresClient) Ping(ctx context.Context) error {
+	sqlDB, err := p.DB.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get database handle: %w", err)
+	}
+	return sqlDB.PingContext(ctx)
+}
@@ -0,0 +1,210 @@
+package infra
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tnqbao/gau-cloud-orchestrator/config"
+)
+
+// Dependency names reported by the health checker
+const (
+	HealthDepPostgres      = "postgres"
+	HealthDepRedis         = "redis"
+	HealthDepRabbitMQ      = "rabbitmq"
+	HealthDepMinio         = "minio"
+	HealthDepUploadService = "upload_service"
+	HealthDepAuthService   = "authorization_service"
+)
+
+const (
+	HealthStatusUp   = "up"
+	HealthStatusDown = "down"
+)
+
+// DependencyHealth is the result of a single dependency check
+type DependencyHealth struct {
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+	Optional  bool   `json:"optional,omitempty"` // reported only, it does not fail readiness
+}
+
+// HealthReport aggregates the results of all dependency checks
+type HealthReport struct {
+	Status       string             `json:"status"`
+	Dependencies []DependencyHealth `json:"dependencies"`
+	CheckedAt    time.Time          `json:"checked_at"`
+}
+
+// Healthy reports whether every checked dependency that gates readiness is up
+func (r *HealthReport) Healthy() bool {
+	return r.Status == HealthStatusUp
+}
+
+type healthCheck struct {
+	name     string
+	check    func(ctx context.Context) error
+	optional bool
+}
+
+// HealthChecker runs dependency checks concurrently, each bounded by a timeout
+type HealthChecker struct {
+	timeout      time.Duration
+	checks       []healthCheck
+	shuttingDown atomic.Bool
+}
+
+func InitHealthChecker(cfg *config.EnvConfig, infra *Infra) *HealthChecker {
+	timeout := time.Duration(cfg.Health.CheckTimeoutMs) * time.Millisecond
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+
+	h := &HealthChecker{timeout: timeout}
+
+	h.Register(HealthDepPostgres, infra.Postgres.Ping)
+	h.Register(HealthDepRedis, infra.Redis.Ping)
+	h.Register(HealthDepRabbitMQ, infra.RabbitMQ.Ping)
+	h.Register(HealthDepMinio, func(ctx context.Context) error {
+		// Only reachability matters here, the temp bucket is created on demand by EnsureBucket
+		_, err := infra.Minio.BucketExists(ctx, cfg.LargeFile.TempBucket)
+		return err
+	})
+	// Tokens fall back to local verification while the authorization service is down and only uploads
+	// need the upload service, so an outage of either must not take every replica out of rotation
+	h.RegisterOptional(HealthDepUploadService, infra.Uploader.Ping)
+	h.RegisterOptional(HealthDepAuthService, infra.AuthorizationService.Ping)
+
+	return h
+}
+
+// Register adds a named dependency check that fails readiness while it is down
+func (h *HealthChecker) Register(name string, check func(ctx context.Context) error) {
+	h.checks = append(h.checks, healthCheck{name: name, check: check})
+}
+
+// RegisterOptional adds a named dependency check that is reported without failing readiness
+func (h *HealthChecker) RegisterOptional(name string, check func(ctx context.Context) error) {
+	h.checks = append(h.checks, healthCheck{name: name, check: check, optional: true})
+}
+
+// SetShuttingDown makes every subsequent readiness check fail so the
+// load balancer stops routing new traffic while in-flight requests drain
+func (h *HealthChecker) SetShuttingDown() {
+	h.shuttingDown.Store(true)
+}
+
+// Check runs the registered checks and returns a per-dependency report.
+// If names is empty every registered check is run, otherwise only the named ones.
+func (h *HealthChecker) Check(ctx context.Context, names ...string) *HealthReport {
+	selected := h.checks
+	if len(names) > 0 {
+		wanted := make(map[string]bool, len(names))
+		for _, name := range names {
+			wanted[name] = true
+		}
+		selected = make([]healthCheck, 0, len(names))
+		for _, hc := range h.checks {
+			if wanted[hc.name] {
+				selected = append(selected, hc)
+			}
+		}
+	}
+
+	results := make([]DependencyHealth, len(selected))
+	var wg sync.WaitGroup
+	for i, hc := range selected {
+		wg.Add(1)
+		go func(i int, hc healthCheck) {
+			defer wg.Done()
+			results[i] = h.runCheck(ctx, hc)
+		}(i, hc)
+	}
+	wg.Wait()
+
+	report := &HealthReport{
+		Status:       HealthStatusUp,
+		Dependencies: results,
+		CheckedAt:    time.Now(),
+	}
+	if h.shuttingDown.Load() {
+		report.Status = HealthStatusDown
+		return report
+	}
+	for _, result := range results {
+		if result.Status != HealthStatusUp && !result.Optional {
+			report.Status = HealthStatusDown
+			break
+		}
+	}
+
+	return report
+}
+
+func (h *HealthChecker) runCheck(ctx context.Context, hc healthCheck) DependencyHealth {
+	checkCtx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	start := time.Now()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- hc.check(checkCtx)
+	}()
+
+	var err error
+	select {
+	case err = <-errCh:
+	case <-checkCtx.Done():
+		err = fmt.Errorf("check timed out after %s", h.timeout)
+	}
+
+	result := DependencyHealth{
+		Name:      hc.name,
+		Status:    HealthStatusUp,
+		LatencyMs: time.Since(start).Milliseconds(),
+		Optional:  hc.optional,
+	}
+	if err != nil {
+		result.Status = HealthStatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// NewServer builds a standalone HTTP server exposing /healthz and /readyz.
+// It is used by binaries that don't run the gin router, such as the consumer.
+func (h *HealthChecker) NewServer(addr string, names ...string) *http.Server {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeHealthJSON(w, http.StatusOK, map[string]string{"status": HealthStatusUp})
+	})
+
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		report := h.Check(r.Context(), names...)
+		status := http.StatusOK
+		if !report.Healthy() {
+			status = http.StatusServiceUnavailable
+		}
+		writeHealthJSON(w, status, report)
+	})
+
+	return &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+}
+
+func writeHealthJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
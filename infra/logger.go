@@ -300,6 +300,21 @@ func (l *LoggerClient) extractContextAttributes(ctx context.Context, fields map[
 		)
 	}
 
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		attrs = append(attrs, slog.String("request_id", requestID))
+	}
+
+	// Add caller identity set by the auth middlewares
+	if identity, ok := RequestIdentityFromContext(ctx); ok {
+		attrs = append(attrs,
+			slog.String("user_id", identity.UserID),
+			slog.String("auth_method", identity.AuthMethod),
+		)
+		if identity.IAMUserID != "" {
+			attrs = append(attrs, slog.String("iam_user_id", identity.IAMUserID))
+		}
+	}
+
 	// Add custom fields
 	for k, v := range fields {
 		attrs = append(attrs, slog.Any(k, v))
@@ -1,6 +1,10 @@
 package infra
 
 import (
+	"context"
+	"errors"
+	"fmt"
+
 	"github.com/tnqbao/gau-cloud-orchestrator/config"
 	"github.com/tnqbao/gau-cloud-orchestrator/infra/produce"
 )
@@ -11,9 +15,14 @@ type Infra struct {
 	Logger               *LoggerClient
 	RabbitMQ             *RabbitMQClient
 	AuthorizationService *AuthorizationService
-	UploadService        *UploadService
+	Uploader             Uploader
 	Produce              *produce.Produce
 	Minio                *MinioClient
+	Health               *HealthChecker
+	TokenCache           *TokenCache
+	RateLimiter          *RateLimiter
+	UsageMeter           *UsageMeter
+	UploadEvents         *UploadEvents
 }
 
 var infraInstance *Infra
@@ -48,11 +57,6 @@ func InitInfra(cfg *config.Config) *Infra {
 		panic("Failed to initialize Authorization service")
 	}
 
-	uploadService := InitUploadService(cfg.EnvConfig)
-	if uploadService == nil {
-		panic("Failed to initialize Upload service")
-	}
-
 	produceService := produce.InitProduce(rabbitMQ.Channel)
 	if produceService == nil {
 		panic("Failed to initialize Produce service")
@@ -63,17 +67,25 @@ func InitInfra(cfg *config.Config) *Infra {
 		panic("Failed to initialize MinIO service")
 	}
 
+	uploader := InitUploader(cfg.EnvConfig, minio, logger)
+
 	infraInstance = &Infra{
 		Redis:                redis,
 		Postgres:             postgres,
 		Logger:               logger,
 		RabbitMQ:             rabbitMQ,
 		AuthorizationService: authorizationService,
-		UploadService:        uploadService,
+		Uploader:             uploader,
 		Produce:              produceService,
 		Minio:                minio,
+		TokenCache:           InitTokenCache(cfg.EnvConfig, redis),
+		RateLimiter:          InitRateLimiter(redis),
+		UsageMeter:           InitUsageMeter(),
+		UploadEvents:         InitUploadEvents(redis),
 	}
 
+	infraInstance.Health = InitHealthChecker(cfg.EnvConfig, infraInstance)
+
 	return infraInstance
 }
 
@@ -83,3 +95,34 @@ func GetClient() *Infra {
 	}
 	return infraInstance
 }
+
+// Shutdown releases infra clients in dependency order.
+// Message queue and cache go first, then the database pool, and the OTel
+// exporters are flushed last so logs written during shutdown still get shipped.
+func (i *Infra) Shutdown(ctx context.Context) error {
+	var err error
+
+	if i.RabbitMQ != nil {
+		i.RabbitMQ.Close()
+	}
+
+	if i.Redis != nil {
+		if closeErr := i.Redis.Close(); closeErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to close redis: %w", closeErr))
+		}
+	}
+
+	if i.Postgres != nil {
+		if closeErr := i.Postgres.Close(); closeErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to close postgres: %w", closeErr))
+		}
+	}
+
+	if i.Logger != nil {
+		if shutdownErr := i.Logger.Shutdown(ctx); shutdownErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to flush telemetry: %w", shutdownErr))
+		}
+	}
+
+	return err
+}
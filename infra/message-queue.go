@@ -1,6 +1,8 @@
 package infra
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 
@@ -56,6 +58,17 @@ func (r *RabbitMQClient) Close() {
 	log.Println("RabbitMQ connection closed")
 }
 
+// Ping reports whether the connection and the shared channel are still open
+func (r *RabbitMQClient) Ping(ctx context.Context) error {
+	if r.Connection == nil || r.Connection.IsClosed() {
+		return errors.New("rabbitmq connection is closed")
+	}
+	if r.Channel == nil || r.Channel.IsClosed() {
+		return errors.New("rabbitmq channel is closed")
+	}
+	return ctx.Err()
+}
+
 func (r *RabbitMQClient) DeclareQueue(queueName string, durable, autoDelete bool) error {
 	_, err := r.Channel.QueueDeclare(
 		queueName,
@@ -3,6 +3,12 @@ package infra
 import (
 	"context"
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
 
 	"github.com/minio/madmin-go/v3"
 	"github.com/minio/minio-go/v7"
@@ -20,9 +26,10 @@ const (
 )
 
 type MinioClient struct {
-	Admin    *madmin.AdminClient
-	Client   *minio.Client
-	Endpoint string
+	Admin     *madmin.AdminClient
+	Client    *minio.Client
+	Presigner *minio.Client // signs URLs for the public endpoint; it never sends requests itself
+	Endpoint  string
 }
 
 func InitMinioClient(cfg *config.EnvConfig) *MinioClient {
@@ -54,10 +61,21 @@ func InitMinioClient(cfg *config.EnvConfig) *MinioClient {
 		panic(fmt.Sprintf("Failed to initialize MinIO client: %v", err))
 	}
 
+	// The region is fixed so presigning needs no bucket location lookup against the public endpoint
+	presigner, err := minio.New(cfg.Minio.PublicEndpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(rootUser, rootPassword, ""),
+		Secure: cfg.Minio.PublicSecure,
+		Region: cfg.Minio.Region,
+	})
+	if err != nil {
+		panic(fmt.Sprintf("Failed to initialize MinIO presign client: %v", err))
+	}
+
 	return &MinioClient{
-		Admin:    madminClient,
-		Client:   minioClient,
-		Endpoint: endpoint,
+		Admin:     madminClient,
+		Client:    minioClient,
+		Presigner: presigner,
+		Endpoint:  endpoint,
 	}
 }
 
@@ -461,6 +479,32 @@ func (m *MinioClient) DeleteObjectsWithPrefix(ctx context.Context, bucketName, p
 	return nil
 }
 
+// DeleteObjects deletes the given keys of a bucket in batches
+func (m *MinioClient) DeleteObjects(ctx context.Context, bucketName string, keys []string) error {
+	objectsCh := make(chan minio.ObjectInfo)
+
+	go func() {
+		defer close(objectsCh)
+		for _, key := range keys {
+			select {
+			case objectsCh <- minio.ObjectInfo{Key: key}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	errorCh := m.Client.RemoveObjects(ctx, bucketName, objectsCh, minio.RemoveObjectsOptions{})
+
+	for err := range errorCh {
+		if err.Err != nil {
+			return fmt.Errorf("failed to delete object %s: %w", err.ObjectName, err.Err)
+		}
+	}
+
+	return ctx.Err()
+}
+
 // GetObject retrieves an object from MinIO as a stream for direct download
 // Returns the object reader, object info, and any error
 func (m *MinioClient) GetObject(ctx context.Context, bucketName, objectPath string) (*minio.Object, *minio.ObjectInfo, error) {
@@ -486,6 +530,30 @@ func (m *MinioClient) GetObject(ctx context.Context, bucketName, objectPath stri
 	return obj, &info, nil
 }
 
+// GetObjectRange retrieves length bytes of an object starting at offset
+func (m *MinioClient) GetObjectRange(ctx context.Context, bucketName, objectPath string, offset, length int64) (*minio.Object, error) {
+	if bucketName == "" {
+		return nil, fmt.Errorf("bucketName cannot be empty")
+	}
+	if objectPath == "" {
+		return nil, fmt.Errorf("objectPath cannot be empty")
+	}
+	if offset < 0 || length <= 0 {
+		return nil, fmt.Errorf("invalid range: offset %d, length %d", offset, length)
+	}
+
+	opts := minio.GetObjectOptions{}
+	if err := opts.SetRange(offset, offset+length-1); err != nil {
+		return nil, fmt.Errorf("invalid range: %w", err)
+	}
+	obj, err := m.Client.GetObject(ctx, bucketName, objectPath, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object range: %w", err)
+	}
+
+	return obj, nil
+}
+
 // DeleteObject deletes a single object from MinIO
 func (m *MinioClient) DeleteObject(ctx context.Context, bucketName, objectPath string) error {
 	if bucketName == "" {
@@ -503,3 +571,179 @@ func (m *MinioClient) DeleteObject(ctx context.Context, bucketName, objectPath s
 	return nil
 }
 
+
+// maxSingleCopySize is the largest object S3 can copy in a single CopyObject call (5 GiB)
+const maxSingleCopySize = 5 * 1024 * 1024 * 1024
+
+// CopyObject copies an object server-side, within or across buckets, without streaming it
+// through this service. Objects above 5 GiB are copied part by part with ComposeObject.
+func (m *MinioClient) CopyObject(ctx context.Context, srcBucket, srcPath, dstBucket, dstPath string, size int64) error {
+	if srcBucket == "" || dstBucket == "" {
+		return fmt.Errorf("bucketName cannot be empty")
+	}
+	if srcPath == "" || dstPath == "" {
+		return fmt.Errorf("objectPath cannot be empty")
+	}
+
+	src := minio.CopySrcOptions{Bucket: srcBucket, Object: srcPath}
+	dst := minio.CopyDestOptions{Bucket: dstBucket, Object: dstPath}
+
+	var err error
+	if size > maxSingleCopySize {
+		_, err = m.Client.ComposeObject(ctx, dst, src)
+	} else {
+		_, err = m.Client.CopyObject(ctx, dst, src)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to copy object: %w", err)
+	}
+
+	return nil
+}
+
+// NewMultipartUpload starts a multipart upload of objectPath and returns its upload ID
+func (m *MinioClient) NewMultipartUpload(ctx context.Context, bucketName, objectPath, contentType string) (string, error) {
+	if bucketName == "" {
+		return "", fmt.Errorf("bucketName cannot be empty")
+	}
+	if objectPath == "" {
+		return "", fmt.Errorf("objectPath cannot be empty")
+	}
+
+	core := minio.Core{Client: m.Client}
+	uploadID, err := core.NewMultipartUpload(ctx, bucketName, objectPath, minio.PutObjectOptions{ContentType: contentType})
+	if err != nil {
+		return "", fmt.Errorf("failed to start multipart upload: %w", err)
+	}
+
+	return uploadID, nil
+}
+
+// PresignUploadPart returns a URL the client PUTs part partNumber of a multipart upload to
+func (m *MinioClient) PresignUploadPart(ctx context.Context, bucketName, objectPath, uploadID string, partNumber int, expiry time.Duration) (string, error) {
+	params := url.Values{}
+	params.Set("partNumber", strconv.Itoa(partNumber))
+	params.Set("uploadId", uploadID)
+
+	u, err := m.Presigner.Presign(ctx, http.MethodPut, bucketName, objectPath, expiry, params)
+	if err != nil {
+		return "", fmt.Errorf("failed to presign part %d: %w", partNumber, err)
+	}
+
+	return u.String(), nil
+}
+
+// ListUploadedParts returns the parts MinIO has received for a multipart upload, ordered by part number
+func (m *MinioClient) ListUploadedParts(ctx context.Context, bucketName, objectPath, uploadID string) ([]minio.ObjectPart, error) {
+	core := minio.Core{Client: m.Client}
+
+	var parts []minio.ObjectPart
+	marker := 0
+	for {
+		result, err := core.ListObjectParts(ctx, bucketName, objectPath, uploadID, marker, 1000)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list parts: %w", err)
+		}
+		parts = append(parts, result.ObjectParts...)
+		if !result.IsTruncated {
+			break
+		}
+		marker = result.NextPartNumberMarker
+	}
+
+	sort.Slice(parts, func(i, j int) bool { return parts[i].PartNumber < parts[j].PartNumber })
+	return parts, nil
+}
+
+// CompleteMultipartUpload assembles the parts into the object
+func (m *MinioClient) CompleteMultipartUpload(ctx context.Context, bucketName, objectPath, uploadID string, parts []minio.CompletePart) error {
+	core := minio.Core{Client: m.Client}
+	if _, err := core.CompleteMultipartUpload(ctx, bucketName, objectPath, uploadID, parts, minio.PutObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+
+	return nil
+}
+
+// AbortMultipartUpload discards a multipart upload and the parts received so far
+func (m *MinioClient) AbortMultipartUpload(ctx context.Context, bucketName, objectPath, uploadID string) error {
+	core := minio.Core{Client: m.Client}
+	if err := core.AbortMultipartUpload(ctx, bucketName, objectPath, uploadID); err != nil {
+		return fmt.Errorf("failed to abort multipart upload: %w", err)
+	}
+
+	return nil
+}
+
+// PutObject streams r into an object. size may be -1 when unknown, r is then buffered partSize bytes at a time.
+func (m *MinioClient) PutObject(ctx context.Context, bucketName, objectPath string, r io.Reader, size int64, contentType string, partSize uint64) (int64, error) {
+	if bucketName == "" {
+		return 0, fmt.Errorf("bucketName cannot be empty")
+	}
+	if objectPath == "" {
+		return 0, fmt.Errorf("objectPath cannot be empty")
+	}
+
+	info, err := m.Client.PutObject(ctx, bucketName, objectPath, r, size, minio.PutObjectOptions{
+		ContentType: contentType,
+		PartSize:    partSize,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to put object: %w", err)
+	}
+
+	return info.Size, nil
+}
+
+// ObjectExists reports whether an object is stored under objectPath
+func (m *MinioClient) ObjectExists(ctx context.Context, bucketName, objectPath string) (bool, error) {
+	_, err := m.Client.StatObject(ctx, bucketName, objectPath, minio.StatObjectOptions{})
+	if err == nil {
+		return true, nil
+	}
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return false, nil
+	}
+	return false, fmt.Errorf("failed to stat object: %w", err)
+}
+
+// ListObjects returns the objects under prefix, ordered by key
+func (m *MinioClient) ListObjects(ctx context.Context, bucketName, prefix string) ([]minio.ObjectInfo, error) {
+	var objects []minio.ObjectInfo
+	for obj := range m.Client.ListObjects(ctx, bucketName, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if obj.Err != nil {
+			return nil, fmt.Errorf("failed to list objects: %w", obj.Err)
+		}
+		objects = append(objects, obj)
+	}
+
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+// ComposeObjects concatenates source objects of srcBucket, in order, into one object server-side.
+// Every source but the last must be at least 5 MiB.
+func (m *MinioClient) ComposeObjects(ctx context.Context, srcBucket string, srcPaths []string, dstBucket, dstPath, contentType string) error {
+	if len(srcPaths) == 0 {
+		return fmt.Errorf("no objects to compose")
+	}
+
+	srcs := make([]minio.CopySrcOptions, 0, len(srcPaths))
+	for _, p := range srcPaths {
+		srcs = append(srcs, minio.CopySrcOptions{Bucket: srcBucket, Object: p})
+	}
+	dst := minio.CopyDestOptions{
+		Bucket:          dstBucket,
+		Object:          dstPath,
+		ReplaceMetadata: contentType != "",
+	}
+	if contentType != "" {
+		dst.UserMetadata = map[string]string{"Content-Type": contentType}
+	}
+
+	if _, err := m.Client.ComposeObject(ctx, dst, srcs...); err != nil {
+		return fmt.Errorf("failed to compose object: %w", err)
+	}
+
+	return nil
+}
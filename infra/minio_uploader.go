@@ -0,0 +1,147 @@
+package infra
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"path/filepath"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/tnqbao/gau-cloud-orchestrator/config"
+)
+
+// streamPartSize is the part buffered in memory while streaming a file of unknown size to MinIO
+const streamPartSize = 16 * 1024 * 1024
+
+// MinioUploader is the Uploader writing straight to MinIO. Files are hashed while they stream;
+// chunked uploads are composed by the orchestrator's upload consumer instead of the upload-service.
+type MinioUploader struct {
+	minio         *MinioClient
+	logger        *LoggerClient
+	CDNServiceURL string
+	buckets       sync.Map // names of buckets known to exist
+}
+
+func InitMinioUploader(cfg *config.EnvConfig, minio *MinioClient, logger *LoggerClient) *MinioUploader {
+	return &MinioUploader{
+		minio:         minio,
+		logger:        logger,
+		CDNServiceURL: cfg.ExternalService.CDNServiceURL,
+	}
+}
+
+// GetCDNURL returns the full CDN URL for a file
+func (u *MinioUploader) GetCDNURL(bucket string, filePath string) string {
+	return fmt.Sprintf("%s/%s/%s", u.CDNServiceURL, bucket, filePath)
+}
+
+// Ping checks that MinIO is reachable
+func (u *MinioUploader) Ping(ctx context.Context) error {
+	if _, err := u.minio.Client.ListBuckets(ctx); err != nil {
+		return fmt.Errorf("minio unreachable: %w", err)
+	}
+	return nil
+}
+
+func (u *MinioUploader) UploadFile(
+	file io.Reader,
+	filename string,
+	contentType string,
+	bucket string,
+	path string,
+	isHash bool,
+) (*UploadResponse, error) {
+	if isHash {
+		return u.putHashed(context.Background(), file, filename, contentType, bucket, path)
+	}
+	return u.put(context.Background(), file, filename, contentType, bucket, path)
+}
+
+// UploadChunkToService stores a chunk under its own name, creating the chunk bucket on first use
+func (u *MinioUploader) UploadChunkToService(
+	chunkData io.Reader,
+	filename string,
+	contentType string,
+	bucket string,
+	path string,
+) (*UploadResponse, error) {
+	ctx := context.Background()
+	if _, ok := u.buckets.Load(bucket); !ok {
+		if err := u.minio.EnsureBucket(ctx, bucket); err != nil {
+			return nil, err
+		}
+		u.buckets.Store(bucket, struct{}{})
+	}
+	return u.put(ctx, chunkData, filename, contentType, bucket, path)
+}
+
+// UploadStream uploads a file read from r, such as an archive entry, keeping its original name
+func (u *MinioUploader) UploadStream(
+	r io.Reader,
+	filename string,
+	contentType string,
+	bucket string,
+	path string,
+) (*UploadResponse, error) {
+	return u.put(context.Background(), r, filename, contentType, bucket, path)
+}
+
+// put streams r to dir/filename, hashing it on the way
+func (u *MinioUploader) put(ctx context.Context, r io.Reader, filename, contentType, bucket, dir string) (*UploadResponse, error) {
+	key := path.Join(dir, filename)
+	hasher := sha256.New()
+	size, err := u.minio.PutObject(ctx, bucket, key, io.TeeReader(r, hasher), -1, contentType, streamPartSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UploadResponse{
+		Bucket:      bucket,
+		ContentType: contentType,
+		FileHash:    hex.EncodeToString(hasher.Sum(nil)),
+		FilePath:    key,
+		Message:     "File uploaded successfully",
+		Size:        size,
+		Status:      http.StatusOK,
+	}, nil
+}
+
+// putHashed stores r as dir/<hash><ext>. The hash is only known once the file has streamed, so it
+// is written to a temporary key first and copied into place unless identical content is already there.
+func (u *MinioUploader) putHashed(ctx context.Context, r io.Reader, filename, contentType, bucket, dir string) (*UploadResponse, error) {
+	resp, err := u.put(ctx, r, ".upload-"+uuid.NewString(), contentType, bucket, dir)
+	if err != nil {
+		return nil, err
+	}
+	tempKey := resp.FilePath
+	defer func() {
+		if err := u.minio.DeleteObject(ctx, bucket, tempKey); err != nil {
+			u.logger.WarningWithContextf(ctx, "[Upload] Failed to remove temporary object %s/%s: %v", bucket, tempKey, err)
+		}
+	}()
+
+	ext := filepath.Ext(filename)
+	if ext == "" {
+		ext = ".bin"
+	}
+	resp.FilePath = path.Join(dir, resp.FileHash+ext)
+
+	exists, err := u.minio.ObjectExists(ctx, bucket, resp.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		resp.Duplicated = true
+		resp.Message = "File already exists"
+		return resp, nil
+	}
+	if err := u.minio.CopyObject(ctx, bucket, tempKey, bucket, resp.FilePath, resp.Size); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
@@ -0,0 +1,74 @@
+package produce
+
+import (
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	AuthExchange = "auth.exchange"
+
+	// TokenRevokedQueue is received from the authorization service when an access token is revoked
+	TokenRevokedQueue      = "auth.token.revoked"
+	TokenRevokedRoutingKey = "auth.token.revoked"
+)
+
+// TokenRevokedMessage is received from the authorization service on logout or token revocation.
+// Either TokenHash (hex SHA-256 of the access token) or AccessToken must be set.
+type TokenRevokedMessage struct {
+	TokenHash   string `json:"token_hash"`
+	AccessToken string `json:"access_token"`
+	UserID      string `json:"user_id"`
+	ExpiresAt   int64  `json:"expires_at"` // Unix seconds, 0 = unknown
+	Timestamp   int64  `json:"timestamp"`
+}
+
+type AuthEventService struct {
+	channel *amqp.Channel
+}
+
+func InitAuthEventService(channel *amqp.Channel) *AuthEventService {
+	service := &AuthEventService{
+		channel: channel,
+	}
+
+	// Declare exchange
+	err := channel.ExchangeDeclare(
+		AuthExchange,
+		"topic",
+		true,
+		false,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		panic("Failed to declare Auth exchange: " + err.Error())
+	}
+
+	// Declare token revoked queue (received from authorization service)
+	_, err = channel.QueueDeclare(
+		TokenRevokedQueue,
+		true,  // durable
+		false, // auto-delete
+		false, // exclusive
+		false, // no-wait
+		nil,
+	)
+	if err != nil {
+		panic("Failed to declare Token revoked queue: " + err.Error())
+	}
+
+	// Bind token revoked queue to exchange
+	err = channel.QueueBind(
+		TokenRevokedQueue,
+		TokenRevokedRoutingKey,
+		AuthExchange,
+		false,
+		nil,
+	)
+	if err != nil {
+		panic("Failed to bind Token revoked queue: " + err.Error())
+	}
+
+	return service
+}
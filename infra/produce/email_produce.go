@@ -6,6 +6,8 @@ import (
 	"fmt"
 
 	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/tnqbao/gau-cloud-orchestrator/entity"
+	"github.com/tnqbao/gau-cloud-orchestrator/utils"
 )
 
 type EmailMessage struct {
@@ -62,6 +64,23 @@ func (s *EmailService) SendEmailWarning(ctx context.Context, email, recipientNam
 	return s.publishEmail(ctx, "email.warning", message)
 }
 
+// SendQuotaWarning tells the owner that a storage quota reached percent of its limit.
+// target names what the quota covers, e.g. "Your account" or "Bucket 'photos'".
+func (s *EmailService) SendQuotaWarning(ctx context.Context, email, target string, quota *entity.StorageQuota, percent int) error {
+	content := fmt.Sprintf("%s has used %d%% of its storage quota:", target, percent)
+	if quota.MaxBytes > 0 {
+		content += fmt.Sprintf(" %s of %s.", utils.FormatBytes(quota.UsedBytes), utils.FormatBytes(quota.MaxBytes))
+	}
+	if quota.MaxObjects > 0 {
+		content += fmt.Sprintf(" %d of %d objects.", quota.ObjectCount, quota.MaxObjects)
+	}
+	if percent >= entity.QuotaExceededPercent {
+		content += " New uploads will be rejected until space is freed or the quota is raised."
+	}
+
+	return s.SendEmailWarning(ctx, email, "", content, "")
+}
+
 func (s *EmailService) publishEmail(ctx context.Context, routingKey string, message EmailMessage) error {
 	body, err := json.Marshal(message)
 	if err != nil {
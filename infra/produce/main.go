@@ -7,6 +7,7 @@ type Produce struct {
 	IAMService    *IAMService
 	BucketService *BucketService
 	UploadService *UploadProduceService
+	AuthService   *AuthEventService
 }
 
 var produceInstance *Produce
@@ -36,11 +37,17 @@ func InitProduce(channel *amqp.Channel) *Produce {
 		panic("Failed to initialize Upload produce service")
 	}
 
+	authService := InitAuthEventService(channel)
+	if authService == nil {
+		panic("Failed to initialize Auth event service")
+	}
+
 	produceInstance = &Produce{
 		EmailService:  emailService,
 		IAMService:    iamService,
 		BucketService: bucketService,
 		UploadService: uploadService,
+		AuthService:   authService,
 	}
 
 	return produceInstance
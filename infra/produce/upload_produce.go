@@ -3,9 +3,14 @@ package produce
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"time"
 
+	"github.com/google/uuid"
 	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/tnqbao/gau-cloud-orchestrator/entity"
+	"github.com/tnqbao/gau-cloud-orchestrator/utils"
 )
 
 const (
@@ -13,7 +18,8 @@ const (
 	ChunkedUploadExchange   = "upload.exchange"
 	ChunkedUploadRoutingKey = "upload.chunked"
 
-	// ChunkCompleteQueue is sent to upload-service to trigger compose and move
+	// ChunkCompleteQueue is sent to upload-service to trigger compose and move,
+	// or consumed by the orchestrator itself when it runs with the MinIO upload backend
 	ChunkCompleteQueue      = "upload.chunk_complete"
 	ChunkCompleteRoutingKey = "upload.chunk_complete"
 
@@ -28,6 +34,18 @@ const (
 	// PathDeleteQueue is for deleting all objects in a path/folder from storage
 	PathDeleteQueue      = "object.delete_path"
 	PathDeleteRoutingKey = "object.delete_path"
+
+	// ObjectTransferQueue is for copying or moving large folders in the background
+	ObjectTransferQueue      = "object.transfer"
+	ObjectTransferRoutingKey = "object.transfer"
+
+	// ArchiveExtractQueue is for extracting uploaded archives into objects
+	ArchiveExtractQueue      = "upload.extract"
+	ArchiveExtractRoutingKey = "upload.extract"
+
+	// UploadCallbackQueue is for POSTing the result of an upload to its callback URL
+	UploadCallbackQueue      = "upload.callback"
+	UploadCallbackRoutingKey = "upload.callback"
 )
 
 // ChunkedUploadMessage represents the message structure for chunked uploads
@@ -86,17 +104,55 @@ type DeleteObjectMessage struct {
 	BucketName string `json:"bucket_name"` // MinIO bucket name
 	ObjectPath string `json:"object_path"` // Object path in bucket (hash.ext format from URL field)
 	UserID     string `json:"user_id"`     // User who triggered the delete
+	BucketID   string `json:"bucket_id"`   // Bucket whose quota usage is released
+	Size       int64  `json:"size"`        // Bytes released from the quotas once deleted
+	Version    bool   `json:"version"`     // A kept previous version, which holds no object slot
 	Timestamp  int64  `json:"timestamp"`
 }
 
 // DeletePathMessage is sent to consumer to delete all objects in a path/folder from storage
 type DeletePathMessage struct {
-	BucketName string `json:"bucket_name"` // MinIO bucket name
-	Path       string `json:"path"`        // Folder path to delete (prefix)
-	UserID     string `json:"user_id"`     // User who triggered the delete
+	BucketName  string   `json:"bucket_name"`  // MinIO bucket name
+	Path        string   `json:"path"`         // Folder path to delete (prefix)
+	UserID      string   `json:"user_id"`      // User who triggered the delete
+	BucketID    string   `json:"bucket_id"`    // Bucket whose quota usage is released
+	Size        int64    `json:"size"`         // Total bytes of the deleted objects
+	ObjectCount int64    `json:"object_count"` // Number of deleted objects
+	Keys        []string `json:"keys"`         // Blobs left unreferenced outside the path
+	Timestamp   int64    `json:"timestamp"`
+}
+
+// ObjectTransferMessage is sent to consumer to copy or move a folder with everything below it
+type ObjectTransferMessage struct {
+	Mode           string `json:"mode"`             // "copy" or "move"
+	UserID         string `json:"user_id"`          // User who requested the transfer
+	SourceBucketID string `json:"source_bucket_id"` // Bucket the folder is in
+	SourcePath     string `json:"source_path"`      // Folder to transfer
+	DestBucketID   string `json:"dest_bucket_id"`   // Destination bucket (may equal the source)
+	DestPath       string `json:"dest_path"`        // New full path of the folder
+	Timestamp      int64  `json:"timestamp"`
+}
+
+// ArchiveExtractMessage is sent to consumer to extract a composed archive upload into objects
+type ArchiveExtractMessage struct {
+	UploadID   string `json:"upload_id"`   // Upload session that reports the progress
+	UserID     string `json:"user_id"`     // User who uploaded the archive
+	BucketID   string `json:"bucket_id"`   // Bucket holding the archive and receiving its entries
+	ObjectID   string `json:"object_id"`   // Object of the archive itself
+	Format     string `json:"format"`      // "zip", "tar" or "tar.gz"
+	TargetPath string `json:"target_path"` // Parent path the entries are extracted into
 	Timestamp  int64  `json:"timestamp"`
 }
 
+// UploadCallbackMessage is sent to consumer once an upload with a callback URL completes or fails
+type UploadCallbackMessage struct {
+	UploadID  string `json:"upload_id"` // Upload session holding the callback URL and secret
+	Event     string `json:"event"`     // "completed" or "failed"
+	ObjectID  string `json:"object_id"` // Object created by a completed upload
+	Error     string `json:"error"`     // Why a failed upload failed
+	Timestamp int64  `json:"timestamp"`
+}
+
 // UploadProduceService handles publishing messages for upload processing
 type UploadProduceService struct {
 	channel *amqp.Channel
@@ -247,6 +303,81 @@ func InitUploadProduceService(channel *amqp.Channel) *UploadProduceService {
 		panic("Failed to bind PathDelete queue: " + err.Error())
 	}
 
+	// Declare ObjectTransfer queue
+	_, err = channel.QueueDeclare(
+		ObjectTransferQueue,
+		true,  // durable
+		false, // auto-delete
+		false, // exclusive
+		false, // no-wait
+		nil,
+	)
+	if err != nil {
+		panic("Failed to declare ObjectTransfer queue: " + err.Error())
+	}
+
+	// Bind ObjectTransfer queue to exchange
+	err = channel.QueueBind(
+		ObjectTransferQueue,
+		ObjectTransferRoutingKey,
+		ChunkedUploadExchange,
+		false,
+		nil,
+	)
+	if err != nil {
+		panic("Failed to bind ObjectTransfer queue: " + err.Error())
+	}
+
+	// Declare ArchiveExtract queue
+	_, err = channel.QueueDeclare(
+		ArchiveExtractQueue,
+		true,  // durable
+		false, // auto-delete
+		false, // exclusive
+		false, // no-wait
+		nil,
+	)
+	if err != nil {
+		panic("Failed to declare ArchiveExtract queue: " + err.Error())
+	}
+
+	// Bind ArchiveExtract queue to exchange
+	err = channel.QueueBind(
+		ArchiveExtractQueue,
+		ArchiveExtractRoutingKey,
+		ChunkedUploadExchange,
+		false,
+		nil,
+	)
+	if err != nil {
+		panic("Failed to bind ArchiveExtract queue: " + err.Error())
+	}
+
+	// Declare UploadCallback queue
+	_, err = channel.QueueDeclare(
+		UploadCallbackQueue,
+		true,  // durable
+		false, // auto-delete
+		false, // exclusive
+		false, // no-wait
+		nil,
+	)
+	if err != nil {
+		panic("Failed to declare UploadCallback queue: " + err.Error())
+	}
+
+	// Bind UploadCallback queue to exchange
+	err = channel.QueueBind(
+		UploadCallbackQueue,
+		UploadCallbackRoutingKey,
+		ChunkedUploadExchange,
+		false,
+		nil,
+	)
+	if err != nil {
+		panic("Failed to bind UploadCallback queue: " + err.Error())
+	}
+
 	return service
 }
 
@@ -342,3 +473,103 @@ func (s *UploadProduceService) PublishDeletePath(ctx context.Context, msg Delete
 		},
 	)
 }
+
+// PublishObjectTransfer publishes a message to copy or move a folder in the background
+func (s *UploadProduceService) PublishObjectTransfer(ctx context.Context, msg ObjectTransferMessage) error {
+	msg.Timestamp = time.Now().Unix()
+
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	return s.channel.PublishWithContext(
+		ctx,
+		ChunkedUploadExchange,
+		ObjectTransferRoutingKey,
+		false,
+		false,
+		amqp.Publishing{
+			ContentType:  "application/json",
+			Body:         body,
+			DeliveryMode: amqp.Persistent,
+		},
+	)
+}
+
+// PublishArchiveExtract publishes an archive extraction job to consumer
+func (s *UploadProduceService) PublishArchiveExtract(ctx context.Context, msg ArchiveExtractMessage) error {
+	msg.Timestamp = time.Now().Unix()
+
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	return s.channel.PublishWithContext(
+		ctx,
+		ChunkedUploadExchange,
+		ArchiveExtractRoutingKey,
+		false,
+		false,
+		amqp.Publishing{
+			ContentType:  "application/json",
+			Body:         body,
+			DeliveryMode: amqp.Persistent,
+		},
+	)
+}
+
+// ExtractionStore records the extraction state of upload sessions
+type ExtractionStore interface {
+	UpdateExtraction(id uuid.UUID, extraction entity.Extraction, status entity.UploadStatus) error
+}
+
+// QueueArchiveExtraction hands a stored archive upload to the extraction worker: it publishes the job
+// and moves the session to EXTRACTING, or to FAILED when the job cannot be queued. The archive object
+// itself is kept either way. It returns the new status of the session.
+func (s *UploadProduceService) QueueArchiveExtraction(ctx context.Context, sessions ExtractionStore, session *entity.UploadSession, archive *entity.Object) (entity.UploadStatus, error) {
+	msg := ArchiveExtractMessage{
+		UploadID:   session.ID.String(),
+		UserID:     session.UserID.String(),
+		BucketID:   archive.BucketID.String(),
+		ObjectID:   archive.ID.String(),
+		Format:     utils.ArchiveFormatFromName(session.FileName),
+		TargetPath: session.ExtractPath,
+	}
+	status := entity.UploadStatusExtracting
+	extraction := entity.Extraction{}
+	var queueErr error
+	if err := s.PublishArchiveExtract(ctx, msg); err != nil {
+		status = entity.UploadStatusFailed
+		extraction.Error = "failed to queue archive extraction"
+		queueErr = fmt.Errorf("failed to queue extraction: %w", err)
+	}
+	if err := sessions.UpdateExtraction(session.ID, extraction, status); err != nil {
+		return status, errors.Join(queueErr, fmt.Errorf("failed to update session status: %w", err))
+	}
+	return status, queueErr
+}
+
+// PublishUploadCallback publishes a completion callback to be delivered by consumer
+func (s *UploadProduceService) PublishUploadCallback(ctx context.Context, msg UploadCallbackMessage) error {
+	msg.Timestamp = time.Now().Unix()
+
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	return s.channel.PublishWithContext(
+		ctx,
+		ChunkedUploadExchange,
+		UploadCallbackRoutingKey,
+		false,
+		false,
+		amqp.Publishing{
+			ContentType:  "application/json",
+			Body:         body,
+			DeliveryMode: amqp.Persistent,
+		},
+	)
+}
@@ -0,0 +1,90 @@
+package infra
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const rateLimitKeyPrefix = "ratelimit:"
+
+// slidingWindowScript implements a sliding window log on a sorted set.
+// Entries older than the window are trimmed, and the request is recorded only
+// if the remaining count is under the limit, so rejected requests don't extend the window.
+// Returns {allowed, count, oldest_entry_ms}.
+var slidingWindowScript = redis.NewScript(`
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+local member = ARGV[4]
+
+redis.call('ZREMRANGEBYSCORE', key, 0, now - window)
+local count = redis.call('ZCARD', key)
+local allowed = 0
+if count < limit then
+	redis.call('ZADD', key, now, member)
+	count = count + 1
+	allowed = 1
+end
+redis.call('PEXPIRE', key, window)
+
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+local oldestScore = now
+if oldest[2] then
+	oldestScore = tonumber(oldest[2])
+end
+return {allowed, count, oldestScore}
+`)
+
+// RateLimitResult describes the outcome of a rate limit check
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	ResetAfter time.Duration // time until the oldest request leaves the window
+}
+
+// RateLimiter is a sliding window limiter stored in Redis so limits hold across replicas
+type RateLimiter struct {
+	redis *RedisClient
+}
+
+func InitRateLimiter(redis *RedisClient) *RateLimiter {
+	return &RateLimiter{redis: redis}
+}
+
+// Allow records a request for key and reports whether it is within limit requests per window
+func (l *RateLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (*RateLimitResult, error) {
+	now := time.Now().UnixMilli()
+	windowMs := window.Milliseconds()
+
+	res, err := slidingWindowScript.Run(ctx, l.redis.Client,
+		[]string{rateLimitKeyPrefix + key},
+		now, windowMs, limit, fmt.Sprintf("%d-%s", now, uuid.NewString()),
+	).Int64Slice()
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate rate limit: %w", err)
+	}
+
+	count := int(res[1])
+	remaining := limit - count
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	resetAfter := time.Duration(res[2]+windowMs-now) * time.Millisecond
+	if resetAfter < 0 {
+		resetAfter = 0
+	}
+
+	return &RateLimitResult{
+		Allowed:    res[0] == 1,
+		Limit:      limit,
+		Remaining:  remaining,
+		ResetAfter: resetAfter,
+	}, nil
+}
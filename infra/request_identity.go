@@ -0,0 +1,44 @@
+package infra
+
+import "context"
+
+// Authentication methods recorded on each request
+const (
+	AuthMethodJWT  = "jwt"
+	AuthMethodHMAC = "hmac"
+)
+
+// RequestIdentity describes who made a request and how they authenticated.
+// It is attached to the request context by the auth middlewares so every log
+// line written while serving the request carries it.
+type RequestIdentity struct {
+	UserID     string
+	IAMUserID  string // set only for HMAC (IAM access key) requests
+	AuthMethod string
+}
+
+type requestIdentityKey struct{}
+
+// WithRequestIdentity returns a copy of ctx carrying the identity
+func WithRequestIdentity(ctx context.Context, identity RequestIdentity) context.Context {
+	return context.WithValue(ctx, requestIdentityKey{}, identity)
+}
+
+// RequestIdentityFromContext returns the identity attached to ctx, if any
+func RequestIdentityFromContext(ctx context.Context) (RequestIdentity, bool) {
+	identity, ok := ctx.Value(requestIdentityKey{}).(RequestIdentity)
+	return identity, ok
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the request ID
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request ID attached to ctx, or ""
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
@@ -0,0 +1,91 @@
+package infra
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/tnqbao/gau-cloud-orchestrator/config"
+)
+
+const tokenCacheKeyPrefix = "auth:token:"
+
+// Cached token validation results
+const (
+	TokenStatusValid   = "valid"
+	TokenStatusInvalid = "invalid"
+	TokenStatusRevoked = "revoked"
+)
+
+// TokenCache stores access token validation results in Redis keyed by the
+// SHA-256 of the token, so raw tokens never end up in the cache.
+type TokenCache struct {
+	redis     *RedisClient
+	maxTTL    time.Duration
+	revokeTTL time.Duration
+}
+
+func InitTokenCache(cfg *config.EnvConfig, redis *RedisClient) *TokenCache {
+	maxTTL := time.Duration(cfg.AuthCache.TTL) * time.Second
+	if maxTTL <= 0 {
+		maxTTL = 5 * time.Minute
+	}
+	// Revocations must outlive the token, so default to the JWT lifetime
+	revokeTTL := time.Duration(cfg.JWT.Expire) * time.Second
+	if revokeTTL < maxTTL {
+		revokeTTL = maxTTL
+	}
+	return &TokenCache{redis: redis, maxTTL: maxTTL, revokeTTL: revokeTTL}
+}
+
+// HashToken returns the hex-encoded SHA-256 of a token
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Lookup returns the cached status for a token hash, or "" on a cache miss
+func (t *TokenCache) Lookup(ctx context.Context, tokenHash string) (string, error) {
+	data, err := t.redis.Client.Get(ctx, tokenCacheKeyPrefix+tokenHash).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	var status string
+	if err := json.Unmarshal(data, &status); err != nil {
+		return "", err
+	}
+	return status, nil
+}
+
+// Store caches a validation result until the token expires, capped at the configured TTL
+func (t *TokenCache) Store(ctx context.Context, tokenHash, status string, expiresAt time.Time) error {
+	ttl := t.maxTTL
+	if !expiresAt.IsZero() {
+		if remaining := time.Until(expiresAt); remaining < ttl {
+			ttl = remaining
+		}
+	}
+	if ttl <= 0 {
+		return nil
+	}
+	return t.redis.Set(ctx, tokenCacheKeyPrefix+tokenHash, status, ttl)
+}
+
+// Revoke marks a token as revoked for the rest of its lifetime. Unlike Store
+// the TTL is not capped, so a revoked token stays rejected even when the
+// authorization service is unreachable and validation falls back to local checks.
+// A non-positive ttl means the token expiry is unknown and the JWT lifetime is used.
+func (t *TokenCache) Revoke(ctx context.Context, tokenHash string, ttl time.Duration) error {
+	if ttl <= 0 {
+		ttl = t.revokeTTL
+	}
+	return t.redis.Set(ctx, tokenCacheKeyPrefix+tokenHash, TokenStatusRevoked, ttl)
+}
@@ -0,0 +1,154 @@
+package infra
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tnqbao/gau-cloud-orchestrator/entity"
+	"github.com/tnqbao/gau-cloud-orchestrator/infra/produce"
+)
+
+// Types of upload events
+const (
+	UploadEventStatus        = "status"         // current state, sent when a client starts following an upload
+	UploadEventChunkReceived = "chunk_received" // a chunk or tus PATCH was stored
+	UploadEventProcessing    = "processing"     // every byte is stored, the file is being composed
+	UploadEventExtracting    = "extracting"     // the archive is stored and being extracted
+	UploadEventCompleted     = "completed"
+	UploadEventFailed        = "failed"
+
+	uploadEventChannelPrefix = "upload_events:"
+)
+
+// UploadEvent is a step in the progress of an upload session
+type UploadEvent struct {
+	Type           string    `json:"type"`
+	UploadID       uuid.UUID `json:"upload_id"`
+	BucketID       uuid.UUID `json:"bucket_id"`
+	Status         string    `json:"status"`
+	FileName       string    `json:"file_name"`
+	FileSize       int64     `json:"file_size"`
+	UploadedBytes  int64     `json:"uploaded_bytes"`
+	UploadedChunks int       `json:"uploaded_chunks"`
+	TotalChunks    int       `json:"total_chunks"`
+	ObjectID       string    `json:"object_id,omitempty"`
+	Error          string    `json:"error,omitempty"`
+	Timestamp      time.Time `json:"timestamp"`
+}
+
+// NewUploadEvent describes session at a step of type eventType
+func NewUploadEvent(eventType string, session *entity.UploadSession) UploadEvent {
+	return UploadEvent{
+		Type:           eventType,
+		UploadID:       session.ID,
+		BucketID:       session.BucketID,
+		Status:         string(session.Status),
+		FileName:       session.FileName,
+		FileSize:       session.FileSize,
+		UploadedBytes:  session.UploadedBytes(),
+		UploadedChunks: session.UploadedChunks,
+		TotalChunks:    session.TotalChunks,
+		Timestamp:      time.Now(),
+	}
+}
+
+// UploadEventForStatus is the event announcing that a session reached status
+func UploadEventForStatus(status entity.UploadStatus) string {
+	switch status {
+	case entity.UploadStatusProcessing:
+		return UploadEventProcessing
+	case entity.UploadStatusExtracting:
+		return UploadEventExtracting
+	case entity.UploadStatusCompleted:
+		return UploadEventCompleted
+	case entity.UploadStatusFailed, entity.UploadStatusExpired:
+		return UploadEventFailed
+	default:
+		return UploadEventChunkReceived
+	}
+}
+
+// Final reports whether no event follows this one for the upload
+func (e *UploadEvent) Final() bool {
+	return e.Type == UploadEventCompleted || e.Type == UploadEventFailed
+}
+
+// QueueUploadCallback hands the final event of an upload with a callback URL to the callback
+// consumer. The API and the workers both queue callbacks through it; other events are ignored.
+func (i *Infra) QueueUploadCallback(ctx context.Context, session *entity.UploadSession, event UploadEvent) {
+	if session.CallbackURL == "" || !event.Final() {
+		return
+	}
+	msg := produce.UploadCallbackMessage{
+		UploadID: session.ID.String(),
+		Event:    event.Type,
+		ObjectID: event.ObjectID,
+		Error:    event.Error,
+	}
+	if err := i.Produce.UploadService.PublishUploadCallback(ctx, msg); err != nil {
+		i.Logger.ErrorWithContextf(ctx, err, "[Upload Callback] Failed to queue %s callback of upload %s: %v", event.Type, session.ID, err)
+	}
+}
+
+// UploadEvents fans upload events out through Redis pub/sub, one channel per bucket, so an event
+// raised by the consumer or any API replica reaches the replica holding a client's stream
+type UploadEvents struct {
+	redis *RedisClient
+}
+
+func InitUploadEvents(redis *RedisClient) *UploadEvents {
+	return &UploadEvents{redis: redis}
+}
+
+func uploadEventChannel(bucketID uuid.UUID) string {
+	return uploadEventChannelPrefix + bucketID.String()
+}
+
+// Publish sends event to the streams following its bucket; it is lost when nobody is listening
+func (u *UploadEvents) Publish(ctx context.Context, event UploadEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return u.redis.Client.Publish(ctx, uploadEventChannel(event.BucketID), data).Err()
+}
+
+// Subscribe returns the events of a bucket published from now on. The channel is closed once ctx is done.
+func (u *UploadEvents) Subscribe(ctx context.Context, bucketID uuid.UUID) (<-chan UploadEvent, error) {
+	pubsub := u.redis.Client.Subscribe(ctx, uploadEventChannel(bucketID))
+	// Wait for the subscription, so events published after Subscribe returns are not missed
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, fmt.Errorf("failed to subscribe to upload events: %w", err)
+	}
+
+	events := make(chan UploadEvent, 16)
+	go func() {
+		defer close(events)
+		defer pubsub.Close()
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				var event UploadEvent
+				if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+					continue
+				}
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return events, nil
+}
@@ -1,6 +1,7 @@
 package infra
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -10,6 +11,7 @@ import (
 	"github.com/tnqbao/gau-cloud-orchestrator/config"
 )
 
+// UploadService is the Uploader backed by the external upload-service
 type UploadService struct {
 	UploadServiceURL string `json:"upload_service_url"`
 	CDNServiceURL    string `json:"cdn_service_url"`
@@ -49,8 +51,29 @@ func (p *UploadService) GetCDNURL(bucket string, filePath string) string {
 	return fmt.Sprintf("%s/%s/%s", p.CDNServiceURL, bucket, filePath)
 }
 
+// Ping checks that the upload service is reachable.
+// Any response below 500 means the service is up and answering requests.
+func (p *UploadService) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.UploadServiceURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("upload service returned %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
 func (p *UploadService) UploadFile(
-	file multipart.File,
+	file io.Reader,
 	filename string,
 	contentType string,
 	bucket string,
@@ -72,9 +95,20 @@ func (p *UploadService) UploadChunkToService(
 	return p.uploadFileInternalFromReader(chunkData, filename, contentType, bucket, path, false)
 }
 
+// UploadStream uploads a file read from r, such as an archive entry, keeping its original name
+func (p *UploadService) UploadStream(
+	r io.Reader,
+	filename string,
+	contentType string,
+	bucket string,
+	path string,
+) (*UploadResponse, error) {
+	return p.uploadFileInternalFromReader(r, filename, contentType, bucket, path, false)
+}
+
 // uploadFileInternal handles the actual upload logic with is_hash parameter
 func (p *UploadService) uploadFileInternal(
-	file multipart.File,
+	file io.Reader,
 	filename string,
 	contentType string,
 	bucket string,
@@ -103,45 +137,45 @@ func (p *UploadService) uploadFileInternalFromReader(
 	// Channel to capture errors from goroutine
 	errChan := make(chan error, 1)
 
-	// Write multipart form in a goroutine
+	// Write multipart form in a goroutine.
+	// On failure the pipe is closed with the error instead of writing the closing
+	// boundary, so the upload service sees an aborted request rather than a
+	// well-formed multipart body with truncated or unverified file data.
 	go func() {
-		defer pw.Close()
-		defer w.Close()
-
-		if err := w.WriteField("bucket", bucket); err != nil {
-			errChan <- fmt.Errorf("failed to write bucket field: %w", err)
-			return
-		}
-
-		if err := w.WriteField("path", path); err != nil {
-			errChan <- fmt.Errorf("failed to write path field: %w", err)
-			return
-		}
-
-		if err := w.WriteField("is_hash", fmt.Sprintf("%t", isHash)); err != nil {
-			errChan <- fmt.Errorf("failed to write is_hash field: %w", err)
-			return
-		}
-
-		h := make(map[string][]string)
-		h["Content-Disposition"] = []string{
-			fmt.Sprintf(`form-data; name="file"; filename="%s"`, filename),
-		}
-		h["Content-Type"] = []string{contentType}
-
-		fw, err := w.CreatePart(h)
-		if err != nil {
-			errChan <- fmt.Errorf("failed to create form file: %w", err)
-			return
-		}
-
-		// Stream file data directly - no buffering
-		if _, err := io.Copy(fw, fileData); err != nil {
-			errChan <- fmt.Errorf("failed to stream file data: %w", err)
-			return
-		}
-
-		errChan <- nil
+		writeErr := func() error {
+			if err := w.WriteField("bucket", bucket); err != nil {
+				return fmt.Errorf("failed to write bucket field: %w", err)
+			}
+
+			if err := w.WriteField("path", path); err != nil {
+				return fmt.Errorf("failed to write path field: %w", err)
+			}
+
+			if err := w.WriteField("is_hash", fmt.Sprintf("%t", isHash)); err != nil {
+				return fmt.Errorf("failed to write is_hash field: %w", err)
+			}
+
+			h := make(map[string][]string)
+			h["Content-Disposition"] = []string{
+				fmt.Sprintf(`form-data; name="file"; filename="%s"`, filename),
+			}
+			h["Content-Type"] = []string{contentType}
+
+			fw, err := w.CreatePart(h)
+			if err != nil {
+				return fmt.Errorf("failed to create form file: %w", err)
+			}
+
+			// Stream file data directly - no buffering
+			if _, err := io.Copy(fw, fileData); err != nil {
+				return fmt.Errorf("failed to stream file data: %w", err)
+			}
+
+			return w.Close()
+		}()
+
+		pw.CloseWithError(writeErr)
+		errChan <- writeErr
 	}()
 
 	req, err := http.NewRequest(http.MethodPost, url, pr)
@@ -0,0 +1,41 @@
+package infra
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/tnqbao/gau-cloud-orchestrator/config"
+)
+
+// Upload backends selected with UPLOAD_BACKEND
+const (
+	UploadBackendHTTP  = "http"
+	UploadBackendMinio = "minio"
+)
+
+// Uploader stores files in buckets. UploadService forwards them to the external upload-service,
+// MinioUploader writes them to MinIO itself so the orchestrator can run standalone.
+type Uploader interface {
+	// UploadFile stores a file under path, named after its hash when isHash is set
+	UploadFile(file io.Reader, filename, contentType, bucket, path string, isHash bool) (*UploadResponse, error)
+	// UploadChunkToService stores a chunk of a chunked upload under its own name
+	UploadChunkToService(chunkData io.Reader, filename, contentType, bucket, path string) (*UploadResponse, error)
+	// UploadStream stores a file read from r, such as an archive entry, under its original name
+	UploadStream(r io.Reader, filename, contentType, bucket, path string) (*UploadResponse, error)
+	// GetCDNURL returns the full CDN URL for a file
+	GetCDNURL(bucket, filePath string) string
+	// Ping checks that the backend is reachable
+	Ping(ctx context.Context) error
+}
+
+func InitUploader(cfg *config.EnvConfig, minio *MinioClient, logger *LoggerClient) Uploader {
+	switch cfg.Upload.Backend {
+	case UploadBackendHTTP:
+		return InitUploadService(cfg)
+	case UploadBackendMinio:
+		return InitMinioUploader(cfg, minio, logger)
+	default:
+		panic(fmt.Sprintf("Unknown upload backend %q, expected %q or %q", cfg.Upload.Backend, UploadBackendHTTP, UploadBackendMinio))
+	}
+}
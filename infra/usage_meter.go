@@ -0,0 +1,101 @@
+package infra
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tnqbao/gau-cloud-orchestrator/entity"
+)
+
+type usageKey struct {
+	ownerID    uuid.UUID
+	bucketID   uuid.UUID
+	metricType string
+	operation  string
+}
+
+// UsageMeter buffers usage counters in memory so the request path never writes to the database.
+// Counters are drained into one metric row per (owner, bucket, type, operation) on every flush.
+type UsageMeter struct {
+	mu       sync.Mutex
+	counters map[usageKey]float64
+}
+
+func InitUsageMeter() *UsageMeter {
+	return &UsageMeter{counters: make(map[usageKey]float64)}
+}
+
+// Add increments a counter for the bucket. Zero values and unknown owners are ignored.
+func (m *UsageMeter) Add(ownerID, bucketID uuid.UUID, metricType, operation string, value float64) {
+	if m == nil || value <= 0 || ownerID == uuid.Nil {
+		return
+	}
+	m.mu.Lock()
+	m.counters[usageKey{ownerID, bucketID, metricType, operation}] += value
+	m.mu.Unlock()
+}
+
+// Drain returns the buffered counters as metrics stamped with the current time and resets them
+func (m *UsageMeter) Drain() []entity.Metric {
+	m.mu.Lock()
+	counters := m.counters
+	m.counters = make(map[usageKey]float64, len(counters))
+	m.mu.Unlock()
+
+	now := time.Now()
+	metrics := make([]entity.Metric, 0, len(counters))
+	for key, value := range counters {
+		metrics = append(metrics, entity.Metric{
+			ID:         uuid.New(),
+			OwnerID:    key.ownerID,
+			EntityType: entity.MetricEntityBucket,
+			EntityID:   key.bucketID,
+			MetricType: key.metricType,
+			Operation:  key.operation,
+			Value:      value,
+			Timestamp:  now,
+		})
+	}
+	return metrics
+}
+
+// Run flushes the counters to sink every interval until ctx is cancelled, then flushes once more.
+// A failed flush is returned to the buffer so the counters are retried on the next tick.
+func (m *UsageMeter) Run(ctx context.Context, interval time.Duration, sink func([]entity.Metric) error, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	flush := func() {
+		metrics := m.Drain()
+		if len(metrics) == 0 {
+			return
+		}
+		if err := sink(metrics); err != nil {
+			m.restore(metrics)
+			if onError != nil {
+				onError(err)
+			}
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			flush()
+			return
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// restore puts metrics that failed to persist back into the buffer
+func (m *UsageMeter) restore(metrics []entity.Metric) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, metric := range metrics {
+		m.counters[usageKey{metric.OwnerID, metric.EntityID, metric.MetricType, metric.Operation}] += metric.Value
+	}
+}
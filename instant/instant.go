@@ -0,0 +1,328 @@
+// Package instant completes uploads whose content the owner has already stored, without
+// transferring it again. A client declaring a known SHA-256 is challenged to hash a random nonce
+// followed by a range of the content chosen by the server; only a correct proof lets the upload
+// reference or copy the stored blob, so knowing a hash alone never grants access to the content
+// behind it, and a proof computed once cannot be replayed. Only content in buckets the caller may
+// read is offered.
+package instant
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tnqbao/gau-cloud-orchestrator/conflict"
+	"github.com/tnqbao/gau-cloud-orchestrator/entity"
+	"github.com/tnqbao/gau-cloud-orchestrator/infra"
+	"github.com/tnqbao/gau-cloud-orchestrator/repository"
+	"gorm.io/gorm"
+)
+
+const (
+	// ChallengeTTL is how long a client has to answer a challenge
+	ChallengeTTL = 5 * time.Minute
+	// proofLength is the size of the range a client hashes, smaller files are hashed whole
+	proofLength = 64 * 1024
+	// nonceLength is the number of random bytes a proof starts with
+	nonceLength = 32
+
+	challengeKeyPrefix = "instant_upload:"
+)
+
+var (
+	// ErrNoMatch is returned when the owner has no stored content with the declared hash and size
+	ErrNoMatch = errors.New("no stored content matches the declared hash")
+	// ErrChallengeNotFound is returned for an unknown, expired or already answered challenge
+	ErrChallengeNotFound = errors.New("challenge not found or expired")
+	// ErrProofMismatch is returned when the proof is not the hash of the challenged range
+	ErrProofMismatch = errors.New("proof does not match the requested range")
+)
+
+// Challenge is an instant upload waiting for its proof. It is kept in Redis until answered or expired.
+type Challenge struct {
+	ID             uuid.UUID `json:"id"`
+	OwnerID        uuid.UUID `json:"owner_id"`
+	BucketID       uuid.UUID `json:"bucket_id"`
+	UploadID       uuid.UUID `json:"upload_id"` // chunked session completed by the proof, uuid.Nil for direct uploads
+	SourceObjectID uuid.UUID `json:"source_object_id"`
+	SourceBucketID uuid.UUID `json:"source_bucket_id"` // bucket the caller could read the content in
+	FileName       string    `json:"file_name"`
+	FileSize       int64     `json:"file_size"`
+	FileHash       string    `json:"file_hash"`
+	ContentType    string    `json:"content_type"`
+	Path           string    `json:"path"`
+	ConflictMode   string    `json:"conflict_mode"`
+	Offset         int64     `json:"offset"`
+	Length         int64     `json:"length"`
+	Nonce          string    `json:"nonce"` // hex-encoded, hashed before the range
+	ExpiresAt      time.Time `json:"expires_at"`
+}
+
+// Result describes a completed instant upload
+type Result struct {
+	Object   *entity.Object
+	Replaced *entity.Object // previous state of the object whose content was replaced, if any
+	Plan     *conflict.Plan
+	Copied   bool                  // the content was copied server-side rather than referenced in place
+	Quotas   []entity.StorageQuota // scopes after the usage was counted
+}
+
+type Instant struct {
+	infra      *infra.Infra
+	repository *repository.Repository
+}
+
+func New(inf *infra.Infra, repo *repository.Repository) *Instant {
+	return &Instant{infra: inf, repository: repo}
+}
+
+// Find returns a stored object with the given content from the readable buckets, the buckets of the
+// owner the caller may read objects of, preferring the target bucket when it is one of them
+func (s *Instant) Find(bucket *entity.Bucket, readable []uuid.UUID, fileHash string, size int64) (*entity.Object, error) {
+	if len(readable) == 0 {
+		return nil, ErrNoMatch
+	}
+	for _, id := range readable {
+		if id != bucket.ID {
+			continue
+		}
+		objects, err := s.repository.ObjectRepo.FindByBucketIDAndHash(bucket.ID, fileHash)
+		if err != nil {
+			return nil, err
+		}
+		for i := range objects {
+			if objects[i].Size == size {
+				return &objects[i], nil
+			}
+		}
+	}
+
+	object, err := s.repository.ObjectRepo.FindByBucketIDsAndHash(bucket.OwnerID, readable, fileHash, size)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNoMatch
+	}
+	return object, err
+}
+
+// Challenge picks a random nonce and range of the content for ch and stores ch until it is answered.
+// The caller fills in everything but the ID, nonce, range and expiry.
+func (s *Instant) Challenge(ctx context.Context, ch *Challenge) error {
+	if ch.FileSize <= 0 {
+		return ErrNoMatch
+	}
+
+	nonce := make([]byte, nonceLength)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	ch.ID = uuid.New()
+	ch.Nonce = hex.EncodeToString(nonce)
+	ch.Length = min(ch.FileSize, proofLength)
+	ch.Offset = 0
+	if span := ch.FileSize - ch.Length; span > 0 {
+		n, err := rand.Int(rand.Reader, big.NewInt(span+1))
+		if err != nil {
+			return err
+		}
+		ch.Offset = n.Int64()
+	}
+	ch.ExpiresAt = time.Now().Add(ChallengeTTL)
+
+	return s.infra.Redis.Set(ctx, challengeKeyPrefix+ch.ID.String(), ch, ChallengeTTL)
+}
+
+// Verify consumes the challenge and checks proof, the hex SHA-256 of the nonce bytes followed by the
+// challenged range, against the stored content. A challenge has a single attempt: a wrong proof needs a new challenge.
+// It returns the challenge with the object and bucket holding the content.
+func (s *Instant) Verify(ctx context.Context, ownerID, challengeID uuid.UUID, proof string) (*Challenge, *entity.Object, *entity.Bucket, error) {
+	var ch Challenge
+	if err := s.infra.Redis.GetDel(ctx, challengeKeyPrefix+challengeID.String(), &ch); err != nil {
+		return nil, nil, nil, ErrChallengeNotFound
+	}
+	if ch.OwnerID != ownerID {
+		return nil, nil, nil, ErrChallengeNotFound
+	}
+
+	// The content may have been deleted or replaced since the challenge was issued
+	source, err := s.repository.ObjectRepo.FindByID(ch.SourceObjectID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, nil, ErrNoMatch
+	}
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	// Content moved to another bucket since may no longer be readable by the caller
+	if source.FileHash != ch.FileHash || source.Size != ch.FileSize || source.BucketID != ch.SourceBucketID {
+		return nil, nil, nil, ErrNoMatch
+	}
+	sourceBucket, err := s.repository.BucketRepo.FindByID(source.BucketID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if sourceBucket.OwnerID != ownerID {
+		return nil, nil, nil, ErrNoMatch
+	}
+
+	reader, err := s.infra.Minio.GetObjectRange(ctx, sourceBucket.Name, source.StorageKey(), ch.Offset, ch.Length)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer reader.Close()
+	nonce, err := hex.DecodeString(ch.Nonce)
+	if err != nil || len(nonce) != nonceLength {
+		return nil, nil, nil, ErrChallengeNotFound
+	}
+	hasher := sha256.New()
+	hasher.Write(nonce)
+	n, err := io.Copy(hasher, reader)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to read challenged range: %w", err)
+	}
+	if n != ch.Length {
+		return nil, nil, nil, fmt.Errorf("read %d bytes of challenged range, expected %d", n, ch.Length)
+	}
+
+	expected := hex.EncodeToString(hasher.Sum(nil))
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(strings.ToLower(proof))) != 1 {
+		return nil, nil, nil, ErrProofMismatch
+	}
+	return &ch, source, sourceBucket, nil
+}
+
+// Complete stores the proven content as an object of bucket under the challenge's name, path and
+// conflict mode. The content is referenced in place when the bucket already holds it, in any
+// folder, and copied server-side otherwise. A direct upload reserves its usage here; a chunked
+// session already holds a reservation, which turns into usage as the session completes.
+func (s *Instant) Complete(ctx context.Context, ch *Challenge, source *entity.Object, sourceBucket, bucket *entity.Bucket) (*Result, error) {
+	resolver := conflict.New(s.infra, s.repository)
+	plan, err := resolver.Resolve(bucket, ch.Path, ch.FileName, ch.ConflictMode)
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{Plan: plan}
+
+	if ch.UploadID == uuid.Nil {
+		if result.Quotas, err = s.repository.StorageQuotaRepo.ReserveUsage(bucket.OwnerID, bucket.ID, source.Size); err != nil {
+			return nil, err
+		}
+	}
+
+	// The bucket's blob of the content is referenced wherever it lies. Otherwise the content is copied
+	// under a key named after the hash like chunked uploads.
+	ext := filepath.Ext(ch.FileName)
+	if ext == "" {
+		ext = ".bin"
+	}
+	url := ch.FileHash + ext
+	key := path.Join(ch.Path, url)
+	shared, err := s.repository.BlobRepo.FindByHash(bucket.ID, ch.FileHash)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		s.release(ctx, ch, bucket, source.Size)
+		return nil, err
+	}
+	if shared == nil {
+		blob, err := s.repository.BlobRepo.FindByKey(bucket.ID, key)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			s.release(ctx, ch, bucket, source.Size)
+			return nil, err
+		}
+		if blob != nil {
+			url = uuid.NewString()[:8] + "-" + url
+			key = path.Join(ch.Path, url)
+		}
+	}
+
+	// The replaced content may live under the key about to be written
+	if err := resolver.PrepareVersion(ctx, bucket, plan); err != nil {
+		s.release(ctx, ch, bucket, source.Size)
+		return nil, err
+	}
+	if shared == nil {
+		if err := s.infra.Minio.CopyObject(ctx, sourceBucket.Name, source.StorageKey(), bucket.Name, key, source.Size); err != nil {
+			resolver.Abort(ctx, bucket, plan)
+			s.release(ctx, ch, bucket, source.Size)
+			return nil, err
+		}
+		result.Copied = true
+	}
+
+	contentType := ch.ContentType
+	if contentType == "" {
+		contentType = source.ContentType
+	}
+	object := &entity.Object{
+		ID:           uuid.New(),
+		BucketID:     bucket.ID,
+		ContentType:  contentType,
+		OriginName:   plan.Name,
+		ParentPath:   ch.Path,
+		CreatedAt:    time.Now(),
+		LastModified: time.Now(),
+		Size:         source.Size,
+		URL:          url,
+		FileHash:     ch.FileHash,
+	}
+	if shared != nil {
+		object.BlobKey = shared.StorageKey
+	}
+	if plan.Existing != nil {
+		previous := *plan.Existing
+		result.Replaced = &previous
+	}
+	if result.Object, err = resolver.Commit(ctx, bucket, plan, object); err != nil {
+		s.release(ctx, ch, bucket, source.Size)
+		if result.Copied {
+			s.removeUnreferenced(ctx, bucket, key)
+		}
+		return nil, err
+	}
+
+	if ch.UploadID != uuid.Nil {
+		if err := s.repository.UploadSessionRepo.UpdateFileHash(ch.UploadID, ch.FileHash); err != nil {
+			s.infra.Logger.WarningWithContextf(ctx, "[Instant] Failed to update file hash of upload %s: %v", ch.UploadID, err)
+		}
+		if err := s.repository.UploadSessionRepo.UpdateStatus(ch.UploadID, entity.UploadStatusCompleted); err != nil {
+			s.infra.Logger.WarningWithContextf(ctx, "[Instant] Failed to complete upload session %s: %v", ch.UploadID, err)
+		}
+		if result.Quotas, err = s.repository.StorageQuotaRepo.AddUsage(bucket.OwnerID, bucket.ID, source.Size, 1); err != nil {
+			s.infra.Logger.ErrorWithContextf(ctx, err, "[Instant] Failed to add quota usage for upload %s: %v", ch.UploadID, err)
+		}
+	}
+	return result, nil
+}
+
+// release gives back the usage reserved by a direct upload that was not stored
+func (s *Instant) release(ctx context.Context, ch *Challenge, bucket *entity.Bucket, size int64) {
+	if ch.UploadID != uuid.Nil {
+		return
+	}
+	if err := s.repository.StorageQuotaRepo.ReleaseUsage(bucket.OwnerID, bucket.ID, size, 1); err != nil {
+		s.infra.Logger.ErrorWithContextf(ctx, err, "[Instant] Failed to release %d bytes of bucket %s: %v", size, bucket.ID, err)
+	}
+}
+
+// removeUnreferenced deletes a copy made for an upload that was not stored, unless another object references the key
+func (s *Instant) removeUnreferenced(ctx context.Context, bucket *entity.Bucket, key string) {
+	_, err := s.repository.BlobRepo.FindByKey(bucket.ID, key)
+	if err == nil {
+		return
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		s.infra.Logger.ErrorWithContextf(ctx, err, "[Instant] Failed to check references of '%s/%s': %v", bucket.Name, key, err)
+		return
+	}
+	if err := s.infra.Minio.DeleteObject(ctx, bucket.Name, key); err != nil {
+		s.infra.Logger.ErrorWithContextf(ctx, err, "[Instant] Failed to remove copy '%s/%s': %v", bucket.Name, key, err)
+	}
+}
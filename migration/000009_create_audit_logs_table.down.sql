@@ -0,0 +1,4 @@
+-- Drop audit_logs table and its append-only trigger
+DROP TRIGGER IF EXISTS trg_audit_logs_append_only ON audit_logs;
+DROP FUNCTION IF EXISTS audit_logs_append_only();
+DROP TABLE IF EXISTS audit_logs;
@@ -0,0 +1,39 @@
+-- Create append-only audit_logs table for mutating operations
+CREATE TABLE IF NOT EXISTS audit_logs (
+    id UUID PRIMARY KEY,
+    owner_id UUID NOT NULL,
+    actor_id UUID,
+    actor_type VARCHAR(32) NOT NULL,
+    auth_method VARCHAR(16),
+    iam_user_id UUID,
+    action VARCHAR(64) NOT NULL,
+    target_type VARCHAR(32) NOT NULL,
+    target_id VARCHAR(255),
+    target_name VARCHAR(1024),
+    request_id VARCHAR(64),
+    ip_address VARCHAR(64),
+    result VARCHAR(16) NOT NULL,
+    error TEXT,
+    before JSONB,
+    after JSONB,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Create indexes for filtered, time-ordered listing
+CREATE INDEX idx_audit_logs_owner_created_at ON audit_logs(owner_id, created_at DESC);
+CREATE INDEX idx_audit_logs_actor_id ON audit_logs(actor_id);
+CREATE INDEX idx_audit_logs_iam_user_id ON audit_logs(iam_user_id);
+CREATE INDEX idx_audit_logs_action ON audit_logs(action);
+CREATE INDEX idx_audit_logs_target ON audit_logs(target_type, target_id);
+CREATE INDEX idx_audit_logs_created_at ON audit_logs(created_at);
+
+-- Reject updates and deletes so the trail stays append-only
+CREATE OR REPLACE FUNCTION audit_logs_append_only() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_logs is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_audit_logs_append_only
+    BEFORE UPDATE OR DELETE ON audit_logs
+    FOR EACH ROW EXECUTE FUNCTION audit_logs_append_only();
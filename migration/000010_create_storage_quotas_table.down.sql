@@ -0,0 +1,4 @@
+-- Drop storage_quotas table and the upload session indexes used for reservations
+DROP INDEX IF EXISTS idx_upload_sessions_bucket_id_status;
+DROP INDEX IF EXISTS idx_upload_sessions_user_id_status;
+DROP TABLE IF EXISTS storage_quotas;
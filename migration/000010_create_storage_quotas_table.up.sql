@@ -0,0 +1,33 @@
+-- Create storage_quotas table for account and bucket level limits and usage
+CREATE TABLE IF NOT EXISTS storage_quotas (
+    id UUID PRIMARY KEY,
+    owner_id UUID NOT NULL,
+    bucket_id UUID REFERENCES buckets(id) ON DELETE CASCADE,
+    scope VARCHAR(16) NOT NULL,
+    max_bytes BIGINT NOT NULL DEFAULT 0,
+    max_objects BIGINT NOT NULL DEFAULT 0,
+    max_object_size BIGINT NOT NULL DEFAULT 0,
+    used_bytes BIGINT NOT NULL DEFAULT 0,
+    object_count BIGINT NOT NULL DEFAULT 0,
+    notify_email VARCHAR(255),
+    warned_percent INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+    CONSTRAINT chk_storage_quotas_scope CHECK (
+        (scope = 'account' AND bucket_id IS NULL) OR (scope = 'bucket' AND bucket_id IS NOT NULL)
+    )
+);
+
+-- One quota per account and one per bucket
+CREATE UNIQUE INDEX IF NOT EXISTS idx_storage_quotas_account ON storage_quotas(owner_id) WHERE scope = 'account';
+CREATE UNIQUE INDEX IF NOT EXISTS idx_storage_quotas_bucket ON storage_quotas(bucket_id) WHERE scope = 'bucket';
+
+-- Reservations sum the bytes of active chunked upload sessions
+CREATE INDEX IF NOT EXISTS idx_upload_sessions_user_id_status ON upload_sessions(user_id, status);
+CREATE INDEX IF NOT EXISTS idx_upload_sessions_bucket_id_status ON upload_sessions(bucket_id, status);
+
+COMMENT ON TABLE storage_quotas IS 'Storage limits and committed usage per account and per bucket';
+COMMENT ON COLUMN storage_quotas.max_bytes IS 'Maximum total bytes, 0 for unlimited';
+COMMENT ON COLUMN storage_quotas.max_objects IS 'Maximum object count, 0 for unlimited';
+COMMENT ON COLUMN storage_quotas.max_object_size IS 'Maximum size of a single object in bytes, 0 for unlimited';
+COMMENT ON COLUMN storage_quotas.warned_percent IS 'Highest usage threshold (80 or 100) the owner has been warned about';
@@ -0,0 +1,2 @@
+-- Drop metrics table
+DROP TABLE IF EXISTS metrics;
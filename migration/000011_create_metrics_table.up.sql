@@ -0,0 +1,22 @@
+-- Create metrics table for usage metering
+CREATE TABLE IF NOT EXISTS metrics (
+    id UUID PRIMARY KEY,
+    owner_id UUID NOT NULL,
+    entity_type VARCHAR(64) NOT NULL,
+    entity_id UUID NOT NULL,
+    metric_type VARCHAR(64) NOT NULL,
+    operation VARCHAR(64),
+    value DOUBLE PRECISION NOT NULL,
+    timestamp TIMESTAMP NOT NULL
+);
+
+-- Create indexes for per-account and per-entity reports over time
+CREATE INDEX IF NOT EXISTS idx_metrics_owner_timestamp ON metrics(owner_id, timestamp);
+CREATE INDEX IF NOT EXISTS idx_entity ON metrics(entity_type, entity_id);
+CREATE INDEX IF NOT EXISTS idx_metrics_metric_type ON metrics(metric_type);
+CREATE INDEX IF NOT EXISTS idx_metrics_timestamp ON metrics(timestamp);
+
+COMMENT ON TABLE metrics IS 'Usage samples and counters, aggregated into per-account usage reports';
+COMMENT ON COLUMN metrics.owner_id IS 'Account the usage is charged to';
+COMMENT ON COLUMN metrics.operation IS 'Controller operation for RequestCount samples';
+COMMENT ON COLUMN metrics.value IS 'Bytes or count, summed (StoredBytes is averaged) when aggregated';
@@ -0,0 +1,5 @@
+-- Drop object listing indexes
+DROP INDEX IF EXISTS idx_objects_bucket_path_prefix;
+DROP INDEX IF EXISTS idx_objects_bucket_path_modified;
+DROP INDEX IF EXISTS idx_objects_bucket_path_size;
+DROP INDEX IF EXISTS idx_objects_bucket_path_name;
@@ -0,0 +1,9 @@
+-- Composite indexes for paginated object listings.
+-- Every listing filters by (bucket_id, parent_path) and pages on (sort column, id),
+-- so each supported sort gets its own index to serve keyset pagination without a sort step.
+CREATE INDEX IF NOT EXISTS idx_objects_bucket_path_name ON objects(bucket_id, parent_path, origin_name, id);
+CREATE INDEX IF NOT EXISTS idx_objects_bucket_path_size ON objects(bucket_id, parent_path, size, id);
+CREATE INDEX IF NOT EXISTS idx_objects_bucket_path_modified ON objects(bucket_id, parent_path, last_modified, id);
+
+-- Prefix index for folder discovery (parent_path LIKE 'a/b/%')
+CREATE INDEX IF NOT EXISTS idx_objects_bucket_path_prefix ON objects(bucket_id, parent_path varchar_pattern_ops);
@@ -0,0 +1,2 @@
+-- Drop folders table
+DROP TABLE IF EXISTS folders;
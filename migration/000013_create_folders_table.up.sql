@@ -0,0 +1,44 @@
+-- Create folders table: materialized folder hierarchy per bucket
+CREATE TABLE IF NOT EXISTS folders (
+    id UUID PRIMARY KEY,
+    bucket_id UUID NOT NULL REFERENCES buckets(id) ON DELETE CASCADE,
+    path VARCHAR(1024) NOT NULL,
+    parent_path VARCHAR(1024) NOT NULL DEFAULT '',
+    name VARCHAR(512) NOT NULL,
+    object_count BIGINT NOT NULL DEFAULT 0,
+    size BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- One row per folder path in a bucket
+CREATE UNIQUE INDEX IF NOT EXISTS idx_folders_bucket_path ON folders(bucket_id, path);
+
+-- Child listing: WHERE bucket_id = ? AND parent_path = ? ORDER BY name
+CREATE INDEX IF NOT EXISTS idx_folders_bucket_parent_name ON folders(bucket_id, parent_path, name);
+
+-- Subtree deletion: WHERE bucket_id = ? AND path LIKE 'a/b/%'
+CREATE INDEX IF NOT EXISTS idx_folders_bucket_path_prefix ON folders(bucket_id, path varchar_pattern_ops);
+
+-- Backfill every folder (and its ancestors) implied by existing objects, with subtree totals
+INSERT INTO folders (id, bucket_id, path, parent_path, name, object_count, size, created_at, updated_at)
+SELECT uuid_generate_v4(), bucket_id, path, parent_path, name, COUNT(*), SUM(size), NOW(), NOW()
+FROM (
+    SELECT o.bucket_id,
+           o.size,
+           array_to_string(p.parts[1:g.n], '/') AS path,
+           array_to_string(p.parts[1:g.n - 1], '/') AS parent_path,
+           p.parts[g.n] AS name
+    FROM objects o
+    CROSS JOIN LATERAL (SELECT string_to_array(o.parent_path, '/') AS parts) p
+    CROSS JOIN LATERAL generate_series(1, cardinality(p.parts)) AS g(n)
+    WHERE o.parent_path IS NOT NULL AND o.parent_path <> ''
+) ancestors
+GROUP BY bucket_id, path, parent_path, name
+ON CONFLICT DO NOTHING;
+
+COMMENT ON TABLE folders IS 'Materialized folder hierarchy, maintained on upload, move and delete';
+COMMENT ON COLUMN folders.path IS 'Full folder path within the bucket, without leading or trailing slash';
+COMMENT ON COLUMN folders.parent_path IS 'Path of the parent folder, empty for top-level folders';
+COMMENT ON COLUMN folders.object_count IS 'Number of objects in this folder and all subfolders';
+COMMENT ON COLUMN folders.size IS 'Total bytes in this folder and all subfolders';
@@ -0,0 +1,8 @@
+ALTER TABLE upload_sessions
+    DROP COLUMN IF EXISTS extract_error,
+    DROP COLUMN IF EXISTS extract_skipped,
+    DROP COLUMN IF EXISTS extract_bytes,
+    DROP COLUMN IF EXISTS extract_entries,
+    DROP COLUMN IF EXISTS extract_total_entries,
+    DROP COLUMN IF EXISTS extract_path,
+    DROP COLUMN IF EXISTS upload_type;
@@ -0,0 +1,9 @@
+-- Archive uploads are extracted into objects after they are composed
+ALTER TABLE upload_sessions
+    ADD COLUMN IF NOT EXISTS upload_type VARCHAR(32) NOT NULL DEFAULT 'file',
+    ADD COLUMN IF NOT EXISTS extract_path VARCHAR(1024),
+    ADD COLUMN IF NOT EXISTS extract_total_entries INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS extract_entries INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS extract_bytes BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS extract_skipped INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS extract_error TEXT;
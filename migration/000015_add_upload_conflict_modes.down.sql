@@ -0,0 +1,14 @@
+DROP TABLE IF EXISTS object_versions;
+
+DROP INDEX IF EXISTS uq_objects_bucket_path_name;
+
+ALTER TABLE objects
+    ALTER COLUMN parent_path DROP NOT NULL,
+    DROP COLUMN IF EXISTS version;
+
+ALTER TABLE upload_sessions
+    DROP COLUMN IF EXISTS error_message,
+    DROP COLUMN IF EXISTS conflict_mode;
+
+ALTER TABLE buckets
+    DROP COLUMN IF EXISTS conflict_mode;
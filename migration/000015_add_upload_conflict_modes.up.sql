@@ -0,0 +1,46 @@
+-- Conflict mode applied to uploads that do not choose one
+ALTER TABLE buckets
+    ADD COLUMN IF NOT EXISTS conflict_mode VARCHAR(16) NOT NULL DEFAULT 'rename';
+
+ALTER TABLE upload_sessions
+    ADD COLUMN IF NOT EXISTS conflict_mode VARCHAR(16) NOT NULL DEFAULT 'rename',
+    ADD COLUMN IF NOT EXISTS error_message TEXT;
+
+ALTER TABLE objects
+    ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;
+
+UPDATE objects SET parent_path = '' WHERE parent_path IS NULL;
+ALTER TABLE objects ALTER COLUMN parent_path SET DEFAULT '', ALTER COLUMN parent_path SET NOT NULL;
+
+-- Existing duplicates keep the oldest row under the name; the others get "name (<id prefix>).ext"
+UPDATE objects o
+SET origin_name = CASE
+        WHEN position('.' IN substring(o.origin_name FROM 2)) = 0
+            THEN o.origin_name || ' (' || left(o.id::text, 8) || ')'
+        ELSE regexp_replace(o.origin_name, '(\.[^.]*)$', ' (' || left(o.id::text, 8) || ')\1')
+    END
+FROM (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY bucket_id, parent_path, origin_name ORDER BY created_at, id) AS n
+    FROM objects
+) d
+WHERE d.id = o.id AND d.n > 1;
+
+-- One object per name in a folder
+CREATE UNIQUE INDEX IF NOT EXISTS uq_objects_bucket_path_name ON objects(bucket_id, parent_path, origin_name);
+
+-- Previous contents of objects replaced in version mode
+CREATE TABLE IF NOT EXISTS object_versions (
+    id UUID PRIMARY KEY,
+    object_id UUID NOT NULL REFERENCES objects(id) ON DELETE CASCADE,
+    bucket_id UUID NOT NULL REFERENCES buckets(id) ON DELETE CASCADE,
+    version INT NOT NULL,
+    content_type VARCHAR(255),
+    size BIGINT NOT NULL,
+    storage_key VARCHAR(1024) NOT NULL,
+    file_hash VARCHAR(255),
+    created_at TIMESTAMP NOT NULL,
+    replaced_at TIMESTAMP NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_object_versions_object_version ON object_versions(object_id, version);
+CREATE INDEX IF NOT EXISTS idx_object_versions_bucket_id ON object_versions(bucket_id);
@@ -0,0 +1,2 @@
+-- Drop blobs table
+DROP TABLE IF EXISTS blobs;
@@ -0,0 +1,31 @@
+-- Stored files shared by the objects whose storage key points at them
+CREATE TABLE IF NOT EXISTS blobs (
+    id UUID PRIMARY KEY,
+    bucket_id UUID NOT NULL REFERENCES buckets(id) ON DELETE CASCADE,
+    storage_key VARCHAR(1024) NOT NULL,
+    file_hash VARCHAR(255),
+    size BIGINT NOT NULL,
+    ref_count BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- One row per MinIO key of a bucket
+CREATE UNIQUE INDEX IF NOT EXISTS uq_blobs_bucket_key ON blobs(bucket_id, storage_key);
+
+-- Dedup report: identical content stored under several keys
+CREATE INDEX IF NOT EXISTS idx_blobs_bucket_hash ON blobs(bucket_id, file_hash);
+
+-- Backfill the references of existing objects (storage key = parent_path/url)
+INSERT INTO blobs (id, bucket_id, storage_key, file_hash, size, ref_count, created_at, updated_at)
+SELECT uuid_generate_v4(), bucket_id, storage_key, MAX(file_hash), MAX(size), COUNT(*), MIN(created_at), NOW()
+FROM (
+    SELECT bucket_id,
+           CASE WHEN parent_path = '' THEN url ELSE parent_path || '/' || url END AS storage_key,
+           file_hash,
+           size,
+           created_at
+    FROM objects
+) o
+GROUP BY bucket_id, storage_key
+ON CONFLICT DO NOTHING;
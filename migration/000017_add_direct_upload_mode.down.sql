@@ -0,0 +1,4 @@
+ALTER TABLE upload_sessions
+    DROP COLUMN IF EXISTS storage_key,
+    DROP COLUMN IF EXISTS multipart_id,
+    DROP COLUMN IF EXISTS upload_mode;
@@ -0,0 +1,5 @@
+-- Direct uploads send their parts to presigned MinIO multipart URLs instead of through the upload-service
+ALTER TABLE upload_sessions
+    ADD COLUMN IF NOT EXISTS upload_mode VARCHAR(16) NOT NULL DEFAULT 'proxy',
+    ADD COLUMN IF NOT EXISTS multipart_id VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS storage_key VARCHAR(1024);
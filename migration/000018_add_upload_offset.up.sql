@@ -0,0 +1,3 @@
+-- tus uploads are appended at any byte, so their progress is tracked as an offset rather than in chunks
+ALTER TABLE upload_sessions
+    ADD COLUMN IF NOT EXISTS upload_offset BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS upload_callback_deliveries;
+
+ALTER TABLE upload_sessions
+    DROP COLUMN IF EXISTS callback_secret,
+    DROP COLUMN IF EXISTS callback_url;
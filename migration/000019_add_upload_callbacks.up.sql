@@ -0,0 +1,20 @@
+-- Completion callbacks: where the result of a chunked upload is POSTed, and the key it is signed with
+ALTER TABLE upload_sessions
+    ADD COLUMN IF NOT EXISTS callback_url VARCHAR(2048),
+    ADD COLUMN IF NOT EXISTS callback_secret VARCHAR(255);
+
+-- One row per attempt to deliver a completion callback
+CREATE TABLE IF NOT EXISTS upload_callback_deliveries (
+    id UUID PRIMARY KEY,
+    upload_id UUID NOT NULL REFERENCES upload_sessions(id) ON DELETE CASCADE,
+    event VARCHAR(32) NOT NULL,
+    attempt INT NOT NULL,
+    url VARCHAR(2048) NOT NULL,
+    status_code INT NOT NULL DEFAULT 0,
+    success BOOLEAN NOT NULL DEFAULT FALSE,
+    error TEXT,
+    duration_ms BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_upload_callback_deliveries_upload ON upload_callback_deliveries(upload_id, created_at);
@@ -0,0 +1,51 @@
+-- Pending deletions are cancelled, objects go back to the files under their own keys
+DROP TABLE IF EXISTS orphaned_blob_keys;
+
+-- Own keys whose file was removed in favour of a shared blob: copy source_key to storage_key
+-- in each bucket before the previous release serves these objects
+CREATE TABLE IF NOT EXISTS blob_key_restores (
+    bucket_id UUID NOT NULL REFERENCES buckets(id) ON DELETE CASCADE,
+    storage_key VARCHAR(1024) NOT NULL,
+    source_key VARCHAR(1024) NOT NULL,
+    PRIMARY KEY (bucket_id, storage_key)
+);
+
+INSERT INTO blob_key_restores (bucket_id, storage_key, source_key)
+SELECT bucket_id, own_key, MIN(blob_key)
+FROM (
+    SELECT bucket_id,
+           blob_key,
+           CASE WHEN parent_path = '' THEN url ELSE parent_path || '/' || url END AS own_key
+    FROM objects
+    WHERE blob_key IS NOT NULL AND blob_key <> ''
+) o
+WHERE own_key <> blob_key
+GROUP BY bucket_id, own_key
+ON CONFLICT DO NOTHING;
+
+DROP INDEX IF EXISTS uq_blobs_bucket_hash;
+CREATE INDEX IF NOT EXISTS idx_blobs_bucket_hash ON blobs(bucket_id, file_hash);
+
+-- Every object's own key (parent_path/url) is its storage key again, with one blob per key
+INSERT INTO blobs (id, bucket_id, storage_key, file_hash, size, ref_count, created_at, updated_at)
+SELECT uuid_generate_v4(), bucket_id, storage_key, MAX(file_hash), MAX(size), COUNT(*), MIN(created_at), NOW()
+FROM (
+    SELECT bucket_id,
+           CASE WHEN parent_path = '' THEN url ELSE parent_path || '/' || url END AS storage_key,
+           file_hash,
+           size,
+           created_at
+    FROM objects
+) o
+GROUP BY bucket_id, storage_key
+ON CONFLICT (bucket_id, storage_key) DO UPDATE SET ref_count = EXCLUDED.ref_count, updated_at = NOW();
+
+-- Blobs only referenced through blob_key are no object's own key
+DELETE FROM blobs b
+WHERE NOT EXISTS (
+    SELECT 1 FROM objects o
+    WHERE o.bucket_id = b.bucket_id
+      AND b.storage_key = CASE WHEN o.parent_path = '' THEN o.url ELSE o.parent_path || '/' || o.url END
+);
+
+ALTER TABLE objects DROP COLUMN IF EXISTS blob_key;
@@ -0,0 +1,53 @@
+-- Objects point at the blob holding their content, which may lie in another folder once shared
+ALTER TABLE objects ADD COLUMN IF NOT EXISTS blob_key VARCHAR(1024);
+
+-- Content stored more than once in a bucket is folded into its oldest blob
+WITH canonical AS (
+    SELECT DISTINCT ON (bucket_id, file_hash) bucket_id, file_hash, storage_key
+    FROM blobs
+    WHERE file_hash <> ''
+    ORDER BY bucket_id, file_hash, created_at, storage_key
+)
+UPDATE objects o
+SET blob_key = c.storage_key
+FROM canonical c
+WHERE o.bucket_id = c.bucket_id AND o.file_hash = c.file_hash;
+
+UPDATE objects
+SET blob_key = CASE WHEN parent_path = '' THEN url ELSE parent_path || '/' || url END
+WHERE blob_key IS NULL OR blob_key = '';
+
+UPDATE blobs b
+SET ref_count = r.refs, updated_at = NOW()
+FROM (
+    SELECT bucket_id, blob_key, COUNT(*) AS refs
+    FROM objects
+    GROUP BY bucket_id, blob_key
+) r
+WHERE b.bucket_id = r.bucket_id AND b.storage_key = r.blob_key;
+
+-- Files of the folded duplicates, deleted from storage by the consumer
+CREATE TABLE IF NOT EXISTS orphaned_blob_keys (
+    bucket_id UUID NOT NULL REFERENCES buckets(id) ON DELETE CASCADE,
+    storage_key VARCHAR(1024) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (bucket_id, storage_key)
+);
+
+INSERT INTO orphaned_blob_keys (bucket_id, storage_key)
+SELECT b.bucket_id, b.storage_key
+FROM blobs b
+WHERE NOT EXISTS (
+    SELECT 1 FROM objects o WHERE o.bucket_id = b.bucket_id AND o.blob_key = b.storage_key
+)
+ON CONFLICT DO NOTHING;
+
+-- The folded duplicates lose their rows
+DELETE FROM blobs b
+WHERE NOT EXISTS (
+    SELECT 1 FROM objects o WHERE o.bucket_id = b.bucket_id AND o.blob_key = b.storage_key
+);
+
+-- One blob per content hash of a bucket
+DROP INDEX IF EXISTS idx_blobs_bucket_hash;
+CREATE UNIQUE INDEX IF NOT EXISTS uq_blobs_bucket_hash ON blobs(bucket_id, file_hash) WHERE file_hash <> '';
@@ -0,0 +1,57 @@
+// Package quotawarn emails account owners when their storage quotas cross a warning threshold.
+// Uploads from the HTTP API and from the consumer workers report through the same function, so
+// every path records a threshold once and words the warning the same way.
+package quotawarn
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tnqbao/gau-cloud-orchestrator/entity"
+	"github.com/tnqbao/gau-cloud-orchestrator/infra"
+	"github.com/tnqbao/gau-cloud-orchestrator/repository"
+)
+
+// Notify emails the account owner once per threshold (80%, 100%) reached by the account or
+// bucket quota. The address comes from the account quota among quotas. Failures are logged
+// and never affect the upload that reached the threshold.
+func Notify(ctx context.Context, inf *infra.Infra, repo *repository.Repository, quotas []entity.StorageQuota) {
+	var email string
+	for i := range quotas {
+		if quotas[i].Scope == entity.QuotaScopeAccount {
+			email = quotas[i].NotifyEmail
+		}
+	}
+
+	for i := range quotas {
+		quota := &quotas[i]
+		percent := quota.WarningPercent()
+		if percent == 0 || percent <= quota.WarnedPercent {
+			continue
+		}
+		if email == "" {
+			inf.Logger.WarningWithContextf(ctx, "[Quota] %s quota of owner %s reached %d%% but no notify_email is set", quota.Scope, quota.OwnerID, percent)
+			continue
+		}
+
+		marked, err := repo.StorageQuotaRepo.MarkWarned(quota.ID, percent)
+		if err != nil {
+			inf.Logger.ErrorWithContextf(ctx, err, "[Quota] Failed to record quota warning: %v", err)
+			continue
+		}
+		if !marked {
+			continue
+		}
+
+		target := "Your account"
+		if quota.Scope == entity.QuotaScopeBucket && quota.BucketID != nil {
+			target = "Your bucket"
+			if bucket, err := repo.BucketRepo.FindByID(*quota.BucketID); err == nil {
+				target = fmt.Sprintf("Bucket '%s'", bucket.Name)
+			}
+		}
+		if err := inf.Produce.EmailService.SendQuotaWarning(ctx, email, target, quota, percent); err != nil {
+			inf.Logger.ErrorWithContextf(ctx, err, "[Quota] Failed to send %d%% quota warning: %v", percent, err)
+		}
+	}
+}
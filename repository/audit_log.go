@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tnqbao/gau-cloud-orchestrator/entity"
+	"gorm.io/gorm"
+)
+
+// AuditLogFilter narrows audit log queries; zero values are ignored
+type AuditLogFilter struct {
+	OwnerID    *uuid.UUID
+	ActorID    *uuid.UUID
+	IAMUserID  *uuid.UUID
+	Action     string
+	TargetType string
+	TargetID   string
+	Result     string
+	From       *time.Time
+	To         *time.Time
+}
+
+type AuditLogRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditLogRepository(db *gorm.DB) *AuditLogRepository {
+	return &AuditLogRepository{db: db}
+}
+
+// Create appends an audit log entry. Entries are never updated or deleted.
+func (r *AuditLogRepository) Create(log *entity.AuditLog) error {
+	if log.ID == uuid.Nil {
+		log.ID = uuid.New()
+	}
+	return r.db.Create(log).Error
+}
+
+// List returns one page of entries matching the filter, newest first, with the total count
+func (r *AuditLogRepository) List(filter AuditLogFilter, page, limit int) ([]entity.AuditLog, int64, error) {
+	var total int64
+	if err := r.applyFilter(r.db.Model(&entity.AuditLog{}), filter).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var logs []entity.AuditLog
+	err := r.applyFilter(r.db, filter).
+		Order("created_at DESC, id DESC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&logs).Error
+	return logs, total, err
+}
+
+// ForEachBatch walks every entry matching the filter in chronological order,
+// loading batchSize rows at a time, and stops at the first error from fn.
+// Batches are paged by the (created_at, id) of the last entry, the order they are read in.
+func (r *AuditLogRepository) ForEachBatch(filter AuditLogFilter, batchSize int, fn func(logs []entity.AuditLog) error) error {
+	var last *entity.AuditLog
+	for {
+		q := r.applyFilter(r.db, filter)
+		if last != nil {
+			q = q.Where("(created_at, id) > (?, ?)", last.CreatedAt, last.ID)
+		}
+
+		var batch []entity.AuditLog
+		if err := q.Order("created_at ASC, id ASC").Limit(batchSize).Find(&batch).Error; err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := fn(batch); err != nil {
+			return err
+		}
+		if len(batch) < batchSize {
+			return nil
+		}
+		last = &batch[len(batch)-1]
+	}
+}
+
+func (r *AuditLogRepository) applyFilter(q *gorm.DB, filter AuditLogFilter) *gorm.DB {
+	if filter.OwnerID != nil {
+		q = q.Where("owner_id = ?", *filter.OwnerID)
+	}
+	if filter.ActorID != nil {
+		q = q.Where("actor_id = ?", *filter.ActorID)
+	}
+	if filter.IAMUserID != nil {
+		q = q.Where("iam_user_id = ?", *filter.IAMUserID)
+	}
+	if filter.Action != "" {
+		q = q.Where("action = ?", filter.Action)
+	}
+	if filter.TargetType != "" {
+		q = q.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		q = q.Where("target_id = ?", filter.TargetID)
+	}
+	if filter.Result != "" {
+		q = q.Where("result = ?", filter.Result)
+	}
+	if filter.From != nil {
+		q = q.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		q = q.Where("created_at < ?", *filter.To)
+	}
+	return q
+}
@@ -0,0 +1,218 @@
+package repository
+
+import (
+	"github.com/google/uuid"
+	"github.com/tnqbao/gau-cloud-orchestrator/entity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type BlobRepository struct {
+	db *gorm.DB
+}
+
+func NewBlobRepository(db *gorm.DB) *BlobRepository {
+	return &BlobRepository{db: db}
+}
+
+// Acquire adds a reference from object to the blob holding its content and points object at the
+// blob's key. Content with a hash has one blob per bucket: when the bucket already stores it, the
+// reference goes to that blob, wherever it lies, and the key object was written to is left unused.
+// Content without a hash is a blob of its own key.
+func (r *BlobRepository) Acquire(object *entity.Object) error {
+	blob := &entity.Blob{
+		ID:         uuid.New(),
+		BucketID:   object.BucketID,
+		StorageKey: object.StorageKey(),
+		FileHash:   object.FileHash,
+		Size:       object.Size,
+		RefCount:   1,
+	}
+	reference := clause.Assignments(map[string]interface{}{
+		"ref_count":  gorm.Expr("blobs.ref_count + 1"),
+		"updated_at": gorm.Expr("NOW()"),
+	})
+
+	if object.FileHash == "" {
+		err := r.db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "bucket_id"}, {Name: "storage_key"}},
+			DoUpdates: reference,
+		}).Create(blob).Error
+		if err != nil {
+			return err
+		}
+		object.BlobKey = blob.StorageKey
+		return nil
+	}
+
+	err := r.db.Clauses(clause.OnConflict{
+		Columns:     []clause.Column{{Name: "bucket_id"}, {Name: "file_hash"}},
+		TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "file_hash <> ''"}}},
+		DoUpdates:   reference,
+	}).Create(blob).Error
+	if err != nil {
+		return err
+	}
+	shared, err := r.FindByHash(object.BucketID, object.FileHash)
+	if err != nil {
+		return err
+	}
+	object.BlobKey = shared.StorageKey
+	return nil
+}
+
+// Release drops count references from the blob under key. It returns true when no reference is
+// left, the blob row is then removed and the caller deletes the file from storage.
+// A key without a blob row is unreferenced.
+func (r *BlobRepository) Release(bucketID uuid.UUID, key string, count int64) (bool, error) {
+	var blob entity.Blob
+	result := r.db.Model(&blob).Clauses(clause.Returning{}).
+		Where("bucket_id = ? AND storage_key = ?", bucketID, key).
+		Updates(map[string]interface{}{
+			"ref_count":  gorm.Expr("ref_count - ?", count),
+			"updated_at": gorm.Expr("NOW()"),
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return true, nil
+	}
+	if blob.RefCount > 0 {
+		return false, nil
+	}
+	// A reference acquired since the update keeps the row, and the file
+	deleted := r.db.Where("bucket_id = ? AND storage_key = ? AND ref_count <= 0", bucketID, key).Delete(&entity.Blob{})
+	if deleted.Error != nil {
+		return false, deleted.Error
+	}
+	return deleted.RowsAffected > 0, nil
+}
+
+// ReleaseObjects drops the references of deleted objects and returns the keys left unreferenced
+func (r *BlobRepository) ReleaseObjects(objects []entity.Object) ([]string, error) {
+	type ref struct {
+		bucketID uuid.UUID
+		key      string
+	}
+	counts := make(map[ref]int64)
+	var order []ref
+	for i := range objects {
+		k := ref{objects[i].BucketID, objects[i].StorageKey()}
+		if counts[k] == 0 {
+			order = append(order, k)
+		}
+		counts[k]++
+	}
+
+	var unreferenced []string
+	for _, k := range order {
+		released, err := r.Release(k.bucketID, k.key, counts[k])
+		if err != nil {
+			return nil, err
+		}
+		if released {
+			unreferenced = append(unreferenced, k.key)
+		}
+	}
+	return unreferenced, nil
+}
+
+// FindByKey returns the blob stored under key
+func (r *BlobRepository) FindByKey(bucketID uuid.UUID, key string) (*entity.Blob, error) {
+	var blob entity.Blob
+	err := r.db.Where("bucket_id = ? AND storage_key = ?", bucketID, key).First(&blob).Error
+	if err != nil {
+		return nil, err
+	}
+	return &blob, nil
+}
+
+// FindByHash returns the blob of bucketID holding the content with fileHash
+func (r *BlobRepository) FindByHash(bucketID uuid.UUID, fileHash string) (*entity.Blob, error) {
+	var blob entity.Blob
+	err := r.db.Where("bucket_id = ? AND file_hash = ?", bucketID, fileHash).First(&blob).Error
+	if err != nil {
+		return nil, err
+	}
+	return &blob, nil
+}
+
+// ReferencedKeys returns which of keys hold a blob of bucketID
+func (r *BlobRepository) ReferencedKeys(bucketID uuid.UUID, keys []string) (map[string]bool, error) {
+	referenced := make(map[string]bool)
+	if len(keys) == 0 {
+		return referenced, nil
+	}
+	var found []string
+	err := r.db.Model(&entity.Blob{}).
+		Where("bucket_id = ? AND storage_key IN ?", bucketID, keys).
+		Pluck("storage_key", &found).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range found {
+		referenced[key] = true
+	}
+	return referenced, nil
+}
+
+// OrphanedKeys returns up to limit files of folded duplicate blobs still to delete, with their bucket
+func (r *BlobRepository) OrphanedKeys(limit int) ([]entity.OrphanedBlobKey, error) {
+	var orphans []entity.OrphanedBlobKey
+	err := r.db.Preload("Bucket").Order("bucket_id, storage_key").Limit(limit).Find(&orphans).Error
+	return orphans, err
+}
+
+// DeleteOrphanedKeys forgets files of bucketID deleted from storage
+func (r *BlobRepository) DeleteOrphanedKeys(bucketID uuid.UUID, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return r.db.Where("bucket_id = ? AND storage_key IN ?", bucketID, keys).Delete(&entity.OrphanedBlobKey{}).Error
+}
+
+// DedupReport sums the objects and blobs of a bucket, or of every bucket of the owner when bucketID is uuid.Nil
+func (r *BlobRepository) DedupReport(ownerID, bucketID uuid.UUID) (*entity.DedupReport, error) {
+	objects := r.db.Model(&entity.Object{}).Select("COUNT(*) AS objects, COALESCE(SUM(objects.size), 0) AS logical_bytes")
+	blobs := r.db.Model(&entity.Blob{}).Select(`COUNT(*) AS blobs, COALESCE(SUM(blobs.size), 0) AS stored_bytes,
+		COUNT(*) FILTER (WHERE blobs.ref_count > 1) AS shared_blobs`)
+
+	if bucketID != uuid.Nil {
+		objects = objects.Where("objects.bucket_id = ?", bucketID)
+	} else {
+		objects = objects.Joins("JOIN buckets b ON b.id = objects.bucket_id").Where("b.owner_id = ?", ownerID)
+	}
+
+	var objectTotals struct{ Objects, LogicalBytes int64 }
+	if err := objects.Scan(&objectTotals).Error; err != nil {
+		return nil, err
+	}
+	var blobTotals struct{ Blobs, StoredBytes, SharedBlobs int64 }
+	if err := r.scopeBlobs(blobs, ownerID, bucketID).Scan(&blobTotals).Error; err != nil {
+		return nil, err
+	}
+
+	report := &entity.DedupReport{
+		Objects:      objectTotals.Objects,
+		LogicalBytes: objectTotals.LogicalBytes,
+		Blobs:        blobTotals.Blobs,
+		StoredBytes:  blobTotals.StoredBytes,
+		SharedBlobs:  blobTotals.SharedBlobs,
+	}
+	report.SavedBytes = report.LogicalBytes - report.StoredBytes
+	if report.SavedBytes < 0 {
+		report.SavedBytes = 0
+	}
+	if report.LogicalBytes > 0 {
+		report.SavedPercent = float64(report.SavedBytes) / float64(report.LogicalBytes) * 100
+	}
+	return report, nil
+}
+
+func (r *BlobRepository) scopeBlobs(q *gorm.DB, ownerID, bucketID uuid.UUID) *gorm.DB {
+	if bucketID != uuid.Nil {
+		return q.Where("blobs.bucket_id = ?", bucketID)
+	}
+	return q.Joins("JOIN buckets b ON b.id = blobs.bucket_id").Where("b.owner_id = ?", ownerID)
+}
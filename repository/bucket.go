@@ -54,6 +54,11 @@ func (r *BucketRepository) FindByOwnerID(ownerID uuid.UUID) ([]entity.Bucket, er
 	return buckets, nil
 }
 
+// UpdateConflictMode sets the conflict mode applied to uploads into the bucket
+func (r *BucketRepository) UpdateConflictMode(id uuid.UUID, mode string) error {
+	return r.db.Model(&entity.Bucket{}).Where("id = ?", id).Update("conflict_mode", mode).Error
+}
+
 func (r *BucketRepository) Delete(id uuid.UUID) error {
 	return r.db.Delete(&entity.Bucket{}, "id = ?", id).Error
 }
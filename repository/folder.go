@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/tnqbao/gau-cloud-orchestrator/entity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrFolderExists is returned when creating a folder whose path is already taken
+var ErrFolderExists = errors.New("folder already exists")
+
+type FolderRepository struct {
+	db *gorm.DB
+}
+
+func NewFolderRepository(db *gorm.DB) *FolderRepository {
+	return &FolderRepository{db: db}
+}
+
+// folderAncestors returns path and every ancestor of it, outermost first:
+// "a/b/c" -> ["a", "a/b", "a/b/c"]. The root ("") has none.
+func folderAncestors(path string) []string {
+	if path == "" {
+		return nil
+	}
+	parts := strings.Split(path, "/")
+	paths := make([]string, len(parts))
+	for i := range parts {
+		paths[i] = strings.Join(parts[:i+1], "/")
+	}
+	return paths
+}
+
+// splitFolderPath returns the parent path and name of a folder path
+func splitFolderPath(path string) (string, string) {
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		return path[:i], path[i+1:]
+	}
+	return "", path
+}
+
+// EnsurePath creates the folder at path and any missing ancestors
+func (r *FolderRepository) EnsurePath(bucketID uuid.UUID, path string) error {
+	paths := folderAncestors(path)
+	if len(paths) == 0 {
+		return nil
+	}
+
+	folders := make([]entity.Folder, len(paths))
+	for i, p := range paths {
+		parent, name := splitFolderPath(p)
+		folders[i] = entity.Folder{
+			ID:         uuid.New(),
+			BucketID:   bucketID,
+			Path:       p,
+			ParentPath: parent,
+			Name:       name,
+		}
+	}
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&folders).Error
+}
+
+// Create explicitly creates an (empty) folder and its missing ancestors.
+// Returns ErrFolderExists if the folder is already there.
+func (r *FolderRepository) Create(bucketID uuid.UUID, path string) (*entity.Folder, error) {
+	parent, name := splitFolderPath(path)
+	if err := r.EnsurePath(bucketID, parent); err != nil {
+		return nil, err
+	}
+
+	folder := &entity.Folder{
+		ID:         uuid.New(),
+		BucketID:   bucketID,
+		Path:       path,
+		ParentPath: parent,
+		Name:       name,
+	}
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(folder)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrFolderExists
+	}
+	return folder, nil
+}
+
+func (r *FolderRepository) FindByPath(bucketID uuid.UUID, path string) (*entity.Folder, error) {
+	var folder entity.Folder
+	err := r.db.Where("bucket_id = ? AND path = ?", bucketID, path).First(&folder).Error
+	if err != nil {
+		return nil, err
+	}
+	return &folder, nil
+}
+
+// ListChildren returns the folders directly under parentPath ordered by name
+func (r *FolderRepository) ListChildren(bucketID uuid.UUID, parentPath string, desc bool) ([]entity.Folder, error) {
+	order := "name ASC"
+	if desc {
+		order = "name DESC"
+	}
+	var folders []entity.Folder
+	err := r.db.Where("bucket_id = ? AND parent_path = ?", bucketID, parentPath).
+		Order(order).
+		Find(&folders).Error
+	return folders, err
+}
+
+// FindTree returns the folder at path and all of its subfolders, shallowest first;
+// "" returns every folder of the bucket
+func (r *FolderRepository) FindTree(bucketID uuid.UUID, path string) ([]entity.Folder, error) {
+	q := r.db.Where("bucket_id = ?", bucketID)
+	if path != "" {
+		q = q.Where("(path = ? OR path LIKE ?)", path, path+"/%")
+	}
+	var folders []entity.Folder
+	err := q.Order("path ASC").Find(&folders).Error
+	return folders, err
+}
+
+// AddObject counts a new object in its folder and every ancestor, creating missing folders
+func (r *FolderRepository) AddObject(bucketID uuid.UUID, parentPath string, size int64) error {
+	if parentPath == "" {
+		return nil
+	}
+	if err := r.EnsurePath(bucketID, parentPath); err != nil {
+		return err
+	}
+	return r.db.Model(&entity.Folder{}).
+		Where("bucket_id = ? AND path IN ?", bucketID, folderAncestors(parentPath)).
+		Updates(map[string]interface{}{
+			"object_count": gorm.Expr("object_count + 1"),
+			"size":         gorm.Expr("size + ?", size),
+		}).Error
+}
+
+// RemoveUsage subtracts removed objects from a folder and every ancestor
+func (r *FolderRepository) RemoveUsage(bucketID uuid.UUID, folderPath string, objects, bytes int64) error {
+	if folderPath == "" || (objects == 0 && bytes == 0) {
+		return nil
+	}
+	return r.db.Model(&entity.Folder{}).
+		Where("bucket_id = ? AND path IN ?", bucketID, folderAncestors(folderPath)).
+		Updates(map[string]interface{}{
+			"object_count": gorm.Expr("GREATEST(object_count - ?, 0)", objects),
+			"size":         gorm.Expr("GREATEST(size - ?, 0)", bytes),
+		}).Error
+}
+
+// DeleteTree deletes the folder at path and all of its subfolders; "" deletes every folder of the bucket
+func (r *FolderRepository) DeleteTree(bucketID uuid.UUID, path string) error {
+	if path == "" {
+		return r.db.Delete(&entity.Folder{}, "bucket_id = ?", bucketID).Error
+	}
+	return r.db.Delete(&entity.Folder{}, "bucket_id = ? AND (path = ? OR path LIKE ?)", bucketID, path, path+"/%").Error
+}
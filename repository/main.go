@@ -6,22 +6,36 @@ import (
 )
 
 type Repository struct {
-	IAMUserRepo       *IAMUserRepository
-	IAMPolicyRepo     *IAMPolicyRepository
-	BucketRepo        *BucketRepository
-	ObjectRepo        *ObjectRepository
-	UploadSessionRepo *UploadSessionRepository
+	IAMUserRepo        *IAMUserRepository
+	IAMPolicyRepo      *IAMPolicyRepository
+	BucketRepo         *BucketRepository
+	ObjectRepo         *ObjectRepository
+	UploadSessionRepo  *UploadSessionRepository
+	AuditLogRepo       *AuditLogRepository
+	StorageQuotaRepo   *StorageQuotaRepository
+	MetricRepo         *MetricRepository
+	FolderRepo         *FolderRepository
+	ObjectVersionRepo  *ObjectVersionRepository
+	BlobRepo           *BlobRepository
+	UploadCallbackRepo *UploadCallbackRepository
 }
 
 var repository *Repository
 
 func InitRepository(infra *infra.Infra) *Repository {
 	repository = &Repository{
-		IAMUserRepo:       NewIAMUserRepository(infra.Postgres.DB),
-		IAMPolicyRepo:     NewIAMPolicyRepository(infra.Postgres.DB),
-		BucketRepo:        NewBucketRepository(infra.Postgres.DB),
-		ObjectRepo:        NewObjectRepository(infra.Postgres.DB),
-		UploadSessionRepo: NewUploadSessionRepository(infra.Postgres.DB),
+		IAMUserRepo:        NewIAMUserRepository(infra.Postgres.DB),
+		IAMPolicyRepo:      NewIAMPolicyRepository(infra.Postgres.DB),
+		BucketRepo:         NewBucketRepository(infra.Postgres.DB),
+		ObjectRepo:         NewObjectRepository(infra.Postgres.DB),
+		UploadSessionRepo:  NewUploadSessionRepository(infra.Postgres.DB),
+		AuditLogRepo:       NewAuditLogRepository(infra.Postgres.DB),
+		StorageQuotaRepo:   NewStorageQuotaRepository(infra.Postgres.DB),
+		MetricRepo:         NewMetricRepository(infra.Postgres.DB),
+		FolderRepo:         NewFolderRepository(infra.Postgres.DB),
+		ObjectVersionRepo:  NewObjectVersionRepository(infra.Postgres.DB),
+		BlobRepo:           NewBlobRepository(infra.Postgres.DB),
+		UploadCallbackRepo: NewUploadCallbackRepository(infra.Postgres.DB),
 	}
 	return repository
 }
@@ -39,10 +53,17 @@ func (r *Repository) BeginTransaction(db *gorm.DB) *gorm.DB {
 
 func (r *Repository) WithTransaction(tx *gorm.DB) *Repository {
 	return &Repository{
-		IAMUserRepo:       NewIAMUserRepository(tx),
-		IAMPolicyRepo:     NewIAMPolicyRepository(tx),
-		BucketRepo:        NewBucketRepository(tx),
-		ObjectRepo:        NewObjectRepository(tx),
-		UploadSessionRepo: NewUploadSessionRepository(tx),
+		IAMUserRepo:        NewIAMUserRepository(tx),
+		IAMPolicyRepo:      NewIAMPolicyRepository(tx),
+		BucketRepo:         NewBucketRepository(tx),
+		ObjectRepo:         NewObjectRepository(tx),
+		UploadSessionRepo:  NewUploadSessionRepository(tx),
+		AuditLogRepo:       NewAuditLogRepository(tx),
+		StorageQuotaRepo:   NewStorageQuotaRepository(tx),
+		MetricRepo:         NewMetricRepository(tx),
+		FolderRepo:         NewFolderRepository(tx),
+		ObjectVersionRepo:  NewObjectVersionRepository(tx),
+		BlobRepo:           NewBlobRepository(tx),
+		UploadCallbackRepo: NewUploadCallbackRepository(tx),
 	}
 }
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tnqbao/gau-cloud-orchestrator/entity"
+	"gorm.io/gorm"
+)
+
+// Usage report granularities
+const (
+	UsageGranularityDay   = "day"
+	UsageGranularityMonth = "month"
+)
+
+// UsageFilter narrows usage reports; zero values are ignored
+type UsageFilter struct {
+	OwnerID     *uuid.UUID
+	BucketID    *uuid.UUID
+	Granularity string // UsageGranularityDay or UsageGranularityMonth
+	From        *time.Time
+	To          *time.Time
+}
+
+// BucketStoredBytes is the current size of a bucket, used for stored-bytes samples
+type BucketStoredBytes struct {
+	OwnerID  uuid.UUID
+	BucketID uuid.UUID
+	Bytes    int64
+}
+
+type MetricRepository struct {
+	db *gorm.DB
+}
+
+func NewMetricRepository(db *gorm.DB) *MetricRepository {
+	return &MetricRepository{db: db}
+}
+
+// CreateBatch inserts usage samples in one statement
+func (r *MetricRepository) CreateBatch(metrics []entity.Metric) error {
+	if len(metrics) == 0 {
+		return nil
+	}
+	return r.db.CreateInBatches(metrics, 500).Error
+}
+
+// StoredBytesByBucket returns the stored bytes of every bucket, including empty ones
+func (r *MetricRepository) StoredBytesByBucket() ([]BucketStoredBytes, error) {
+	var rows []BucketStoredBytes
+	err := r.db.Raw(`SELECT b.owner_id, b.id AS bucket_id, COALESCE(SUM(o.size), 0) AS bytes
+		FROM buckets b LEFT JOIN objects o ON o.bucket_id = b.id
+		GROUP BY b.owner_id, b.id`).
+		Scan(&rows).Error
+	return rows, err
+}
+
+// Aggregate groups usage per period, bucket, metric type and operation.
+// Stored bytes are averaged over the samples in the period, every other metric is summed.
+func (r *MetricRepository) Aggregate(filter UsageFilter) ([]entity.UsageRow, error) {
+	granularity := filter.Granularity
+	if granularity != UsageGranularityMonth {
+		granularity = UsageGranularityDay
+	}
+
+	q := r.db.Table("metrics m").
+		Select(`date_trunc(?, m.timestamp) AS period,
+			m.entity_id AS bucket_id,
+			COALESCE(b.name, '') AS bucket_name,
+			m.metric_type,
+			COALESCE(m.operation, '') AS operation,
+			CASE WHEN m.metric_type = ? THEN AVG(m.value) ELSE SUM(m.value) END AS value`,
+			granularity, entity.MetricStoredBytes).
+		Joins("LEFT JOIN buckets b ON b.id = m.entity_id").
+		Where("m.entity_type = ?", entity.MetricEntityBucket)
+
+	if filter.OwnerID != nil {
+		q = q.Where("m.owner_id = ?", *filter.OwnerID)
+	}
+	if filter.BucketID != nil {
+		q = q.Where("m.entity_id = ?", *filter.BucketID)
+	}
+	if filter.From != nil {
+		q = q.Where("m.timestamp >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		q = q.Where("m.timestamp < ?", *filter.To)
+	}
+
+	var rows []entity.UsageRow
+	err := q.Group("period, m.entity_id, b.name, m.metric_type, m.operation").
+		Order("period ASC, bucket_name ASC, m.metric_type ASC, operation ASC").
+		Scan(&rows).Error
+	return rows, err
+}
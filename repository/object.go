@@ -1,13 +1,24 @@
 package repository
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/tnqbao/gau-cloud-orchestrator/entity"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// ErrObjectChanged is returned by Replace when the object was deleted or replaced since it was loaded
+var ErrObjectChanged = errors.New("object was changed concurrently")
+
 type ObjectRepository struct {
 	db *gorm.DB
 }
@@ -16,8 +27,24 @@ func NewObjectRepository(db *gorm.DB) *ObjectRepository {
 	return &ObjectRepository{db: db}
 }
 
+// Create stores the object, references its blob and counts it in the folder hierarchy. The object
+// points at the blob of its content afterwards, which is not the key it was written to when the
+// bucket already stored the content; the caller removes that copy.
 func (r *ObjectRepository) Create(object *entity.Object) error {
-	return r.db.Create(object).Error
+	blobKey := object.BlobKey
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := NewBlobRepository(tx).Acquire(object); err != nil {
+			return err
+		}
+		if err := tx.Create(object).Error; err != nil {
+			return err
+		}
+		return NewFolderRepository(tx).AddObject(object.BucketID, object.ParentPath, object.Size)
+	})
+	if err != nil {
+		object.BlobKey = blobKey
+	}
+	return err
 }
 
 func (r *ObjectRepository) FindByID(id uuid.UUID) (*entity.Object, error) {
@@ -47,6 +74,19 @@ func (r *ObjectRepository) FindByFileHash(fileHash string) (*entity.Object, erro
 	return &object, nil
 }
 
+// FindByBucketIDsAndHash returns the oldest object of the owner with the given content in one of bucketIDs
+func (r *ObjectRepository) FindByBucketIDsAndHash(ownerID uuid.UUID, bucketIDs []uuid.UUID, fileHash string, size int64) (*entity.Object, error) {
+	var object entity.Object
+	err := r.db.Joins("JOIN buckets b ON b.id = objects.bucket_id").
+		Where("b.owner_id = ? AND b.id IN ? AND objects.file_hash = ? AND objects.size = ?", ownerID, bucketIDs, fileHash, size).
+		Order("objects.created_at").
+		First(&object).Error
+	if err != nil {
+		return nil, err
+	}
+	return &object, nil
+}
+
 func (r *ObjectRepository) FindByBucketIDAndPath(bucketID uuid.UUID, parentPath string) ([]entity.Object, error) {
 	var objects []entity.Object
 	err := r.db.Where("bucket_id = ? AND parent_path = ?", bucketID, parentPath).Find(&objects).Error
@@ -56,67 +96,192 @@ func (r *ObjectRepository) FindByBucketIDAndPath(bucketID uuid.UUID, parentPath
 	return objects, nil
 }
 
-// FindFoldersByBucketIDAndPath finds distinct folder names at the given path level
-func (r *ObjectRepository) FindFoldersByBucketIDAndPath(bucketID uuid.UUID, parentPath string) ([]string, error) {
-	var allPaths []string
+// Sort keys for object listings, mapped to the column they order by
+const (
+	ObjectSortName         = "name"
+	ObjectSortSize         = "size"
+	ObjectSortLastModified = "last_modified"
+)
+
+var objectSortColumns = map[string]string{
+	ObjectSortName:         "origin_name",
+	ObjectSortSize:         "size",
+	ObjectSortLastModified: "last_modified",
+}
+
+// ErrInvalidCursor is returned when a cursor is malformed or was issued for a different sort
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ObjectListOptions controls pagination, ordering and filtering of an object listing; zero filters are ignored
+type ObjectListOptions struct {
+	Limit          int
+	Cursor         *ObjectCursor
+	Sort           string // ObjectSortName (default), ObjectSortSize or ObjectSortLastModified
+	Desc           bool
+	ContentType    string // exact match, or prefix match when it ends with "/*"
+	MinSize        *int64
+	MaxSize        *int64
+	ModifiedAfter  *time.Time
+	ModifiedBefore *time.Time
+}
 
-	// Get all distinct parent_paths that are "deeper" than current path
-	if parentPath == "" {
-		// Root level: get all non-empty parent_paths
-		err := r.db.Model(&entity.Object{}).
-			Where("bucket_id = ? AND parent_path != '' AND parent_path IS NOT NULL", bucketID).
-			Distinct("parent_path").
-			Pluck("parent_path", &allPaths).Error
+// ObjectCursor is the position after the last object of a page: its sort value and ID,
+// so ties on the sort column are broken deterministically
+type ObjectCursor struct {
+	Sort  string    `json:"s"`
+	Desc  bool      `json:"d,omitempty"`
+	Value string    `json:"v"`
+	ID    uuid.UUID `json:"i"`
+}
+
+// Encode returns the cursor as an opaque continuation token
+func (c *ObjectCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeObjectCursor parses a continuation token produced by Encode
+func DecodeObjectCursor(token string) (*ObjectCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor ObjectCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == uuid.Nil {
+		return nil, ErrInvalidCursor
+	}
+	if _, ok := objectSortColumns[cursor.Sort]; !ok {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+// cursorFor builds the cursor positioned after object for the given sort
+func cursorFor(object *entity.Object, sort string, desc bool) *ObjectCursor {
+	cursor := &ObjectCursor{Sort: sort, Desc: desc, ID: object.ID}
+	switch sort {
+	case ObjectSortSize:
+		cursor.Value = strconv.FormatInt(object.Size, 10)
+	case ObjectSortLastModified:
+		cursor.Value = object.LastModified.UTC().Format(time.RFC3339Nano)
+	default:
+		cursor.Value = object.OriginName
+	}
+	return cursor
+}
+
+// cursorValue converts the cursor value back to the type of the sort column
+func (c *ObjectCursor) cursorValue() (interface{}, error) {
+	switch c.Sort {
+	case ObjectSortSize:
+		size, err := strconv.ParseInt(c.Value, 10, 64)
 		if err != nil {
-			return nil, err
-		}
-	} else {
-		// Nested level: get parent_paths that start with parentPath/
-		prefix := parentPath + "/"
-		err := r.db.Model(&entity.Object{}).
-			Where("bucket_id = ? AND parent_path LIKE ?", bucketID, prefix+"%").
-			Distinct("parent_path").
-			Pluck("parent_path", &allPaths).Error
+			return nil, ErrInvalidCursor
+		}
+		return size, nil
+	case ObjectSortLastModified:
+		t, err := time.Parse(time.RFC3339Nano, c.Value)
 		if err != nil {
-			return nil, err
+			return nil, ErrInvalidCursor
 		}
+		return t, nil
+	default:
+		return c.Value, nil
 	}
+}
 
-	// Extract first segment after parentPath
-	folderSet := make(map[string]bool)
-	for _, path := range allPaths {
-		var remaining string
-		if parentPath == "" {
-			remaining = path
+// ListByBucketIDAndPath returns one page of the objects directly under parentPath using keyset
+// pagination on (sort column, id). The returned cursor is nil on the last page.
+func (r *ObjectRepository) ListByBucketIDAndPath(bucketID uuid.UUID, parentPath string, opts ObjectListOptions) ([]entity.Object, *ObjectCursor, error) {
+	sort := opts.Sort
+	if sort == "" {
+		sort = ObjectSortName
+	}
+	column, ok := objectSortColumns[sort]
+	if !ok {
+		return nil, nil, fmt.Errorf("unsupported sort %q", sort)
+	}
+	direction, comparison := "ASC", ">"
+	if opts.Desc {
+		direction, comparison = "DESC", "<"
+	}
+
+	q := r.db.Where("bucket_id = ? AND parent_path = ?", bucketID, parentPath)
+
+	if opts.ContentType != "" {
+		if prefix, ok := strings.CutSuffix(opts.ContentType, "/*"); ok {
+			q = q.Where("content_type LIKE ?", prefix+"/%")
 		} else {
-			// Remove "parentPath/" prefix
-			remaining = strings.TrimPrefix(path, parentPath+"/")
+			q = q.Where("content_type = ?", opts.ContentType)
 		}
+	}
+	if opts.MinSize != nil {
+		q = q.Where("size >= ?", *opts.MinSize)
+	}
+	if opts.MaxSize != nil {
+		q = q.Where("size <= ?", *opts.MaxSize)
+	}
+	if opts.ModifiedAfter != nil {
+		q = q.Where("last_modified >= ?", *opts.ModifiedAfter)
+	}
+	if opts.ModifiedBefore != nil {
+		q = q.Where("last_modified < ?", *opts.ModifiedBefore)
+	}
 
-		// Get first segment
-		if remaining != "" {
-			parts := strings.SplitN(remaining, "/", 2)
-			if parts[0] != "" {
-				folderSet[parts[0]] = true
-			}
+	if opts.Cursor != nil {
+		if opts.Cursor.Sort != sort || opts.Cursor.Desc != opts.Desc {
+			return nil, nil, ErrInvalidCursor
 		}
+		value, err := opts.Cursor.cursorValue()
+		if err != nil {
+			return nil, nil, err
+		}
+		q = q.Where(fmt.Sprintf("(%s, id) %s (?, ?)", column, comparison), value, opts.Cursor.ID)
 	}
 
-	// Convert map to slice
-	folders := make([]string, 0, len(folderSet))
-	for folder := range folderSet {
-		folders = append(folders, folder)
+	// Fetch one extra row to know whether another page follows
+	var objects []entity.Object
+	err := q.Order(fmt.Sprintf("%s %s, id %s", column, direction, direction)).
+		Limit(opts.Limit + 1).
+		Find(&objects).Error
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return folders, nil
+	if len(objects) <= opts.Limit {
+		return objects, nil, nil
+	}
+	objects = objects[:opts.Limit]
+	return objects, cursorFor(&objects[len(objects)-1], sort, opts.Desc), nil
 }
 
-func (r *ObjectRepository) Delete(id uuid.UUID) error {
-	return r.db.Delete(&entity.Object{}, "id = ?", id).Error
+// Delete removes the object and subtracts it from the folder hierarchy. It returns true when
+// the object held the last reference to its blob, which must then be deleted from storage.
+func (r *ObjectRepository) Delete(id uuid.UUID) (bool, error) {
+	unreferenced := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var object entity.Object
+		result := tx.Clauses(clause.Returning{}).Where("id = ?", id).Delete(&object)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		released, err := NewBlobRepository(tx).Release(object.BucketID, object.StorageKey(), 1)
+		if err != nil {
+			return err
+		}
+		unreferenced = released
+		return NewFolderRepository(tx).RemoveUsage(object.BucketID, object.ParentPath, 1, object.Size)
+	})
+	return unreferenced, err
 }
 
 func (r *ObjectRepository) DeleteByBucketID(bucketID uuid.UUID) error {
-	return r.db.Delete(&entity.Object{}, "bucket_id = ?", bucketID).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&entity.Object{}, "bucket_id = ?", bucketID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&entity.Blob{}, "bucket_id = ?", bucketID).Error
+	})
 }
 
 func (r *ObjectRepository) FindByBucketIDAndHash(bucketID uuid.UUID, fileHash string) ([]entity.Object, error) {
@@ -128,52 +293,272 @@ func (r *ObjectRepository) FindByBucketIDAndHash(bucketID uuid.UUID, fileHash st
 	return objects, nil
 }
 
-// DeleteByBucketIDAndPath deletes all objects with the exact parent_path
-// Returns the deleted objects for tracking what needs to be cleaned up in storage
-func (r *ObjectRepository) DeleteByBucketIDAndPath(bucketID uuid.UUID, path string) ([]entity.Object, error) {
-	var objects []entity.Object
-	// First, find all objects to return them
-	err := r.db.Where("bucket_id = ? AND parent_path = ?", bucketID, path).Find(&objects).Error
+// FindByBucketPathAndName finds the object named name directly under parentPath
+func (r *ObjectRepository) FindByBucketPathAndName(bucketID uuid.UUID, parentPath, name string) (*entity.Object, error) {
+	var object entity.Object
+	err := r.db.Where("bucket_id = ? AND parent_path = ? AND origin_name = ?", bucketID, parentPath, name).First(&object).Error
 	if err != nil {
 		return nil, err
 	}
+	return &object, nil
+}
+
+// NextFreeName returns name, or the first "name (n).ext" with n >= 2, that is not taken directly under parentPath
+func (r *ObjectRepository) NextFreeName(bucketID uuid.UUID, parentPath, name string) (string, error) {
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+
+	var taken []string
+	err := r.db.Model(&entity.Object{}).
+		Where("bucket_id = ? AND parent_path = ? AND (origin_name = ? OR origin_name LIKE ?)", bucketID, parentPath, name, base+" (%)"+ext).
+		Pluck("origin_name", &taken).Error
+	if err != nil {
+		return "", err
+	}
+
+	names := make(map[string]bool, len(taken))
+	for _, n := range taken {
+		names[n] = true
+	}
+	if !names[name] {
+		return name, nil
+	}
+	for n := 2; ; n++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, n, ext)
+		if !names[candidate] {
+			return candidate, nil
+		}
+	}
+}
 
-	// Then delete them
-	err = r.db.Delete(&entity.Object{}, "bucket_id = ? AND parent_path = ?", bucketID, path).Error
+// FindByIDsInBucket returns the objects of bucketID among ids ordered by path and name;
+// IDs of other buckets or of deleted objects are left out
+func (r *ObjectRepository) FindByIDsInBucket(bucketID uuid.UUID, ids []uuid.UUID) ([]entity.Object, error) {
+	var objects []entity.Object
+	err := r.db.Where("bucket_id = ? AND id IN ?", bucketID, ids).
+		Order("parent_path ASC, origin_name ASC").
+		Find(&objects).Error
 	if err != nil {
 		return nil, err
 	}
+	return objects, nil
+}
 
+// FindByStorageKey returns the objects stored under the same MinIO key (deduplicated uploads share one)
+func (r *ObjectRepository) FindByStorageKey(bucketID uuid.UUID, key string) ([]entity.Object, error) {
+	var objects []entity.Object
+	err := r.db.Where("bucket_id = ? AND blob_key = ?", bucketID, key).Find(&objects).Error
+	if err != nil {
+		return nil, err
+	}
 	return objects, nil
 }
 
-// DeleteByBucketIDAndPathPrefix deletes all objects where parent_path starts with the given prefix
-// This is used for deleting entire folder hierarchies
-// Returns the deleted objects for tracking what needs to be cleaned up in storage
-func (r *ObjectRepository) DeleteByBucketIDAndPathPrefix(bucketID uuid.UUID, pathPrefix string) ([]entity.Object, error) {
+// FindUnderPath returns up to limit objects at path or below it with an ID greater than afterID,
+// ordered by ID. "" matches the whole bucket.
+func (r *ObjectRepository) FindUnderPath(bucketID uuid.UUID, path string, afterID uuid.UUID, limit int) ([]entity.Object, error) {
+	q := r.db.Where("bucket_id = ? AND id > ?", bucketID, afterID)
+	if path != "" {
+		q = q.Where("(parent_path = ? OR parent_path LIKE ?)", path, path+"/%")
+	}
 	var objects []entity.Object
+	err := q.Order("id ASC").Limit(limit).Find(&objects).Error
+	return objects, err
+}
 
-	if pathPrefix == "" {
-		// Empty prefix means delete all objects at root level and in subfolders
-		err := r.db.Where("bucket_id = ?", bucketID).Find(&objects).Error
-		if err != nil {
-			return nil, err
+// CountUnderPath counts the objects at path or below it
+func (r *ObjectRepository) CountUnderPath(bucketID uuid.UUID, path string) (int64, error) {
+	q := r.db.Model(&entity.Object{}).Where("bucket_id = ?", bucketID)
+	if path != "" {
+		q = q.Where("(parent_path = ? OR parent_path LIKE ?)", path, path+"/%")
+	}
+	var count int64
+	err := q.Count(&count).Error
+	return count, err
+}
+
+// Relocate moves or renames an object and updates the folder totals on both sides in one transaction.
+// Within a bucket the object keeps its blob; a move into another bucket references the blob of the
+// content in that bucket, which the caller has stored under <parentPath>/<url> unless it already was.
+func (r *ObjectRepository) Relocate(object *entity.Object, bucketID uuid.UUID, parentPath, name string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		folders := NewFolderRepository(tx)
+		if err := folders.RemoveUsage(object.BucketID, object.ParentPath, 1, object.Size); err != nil {
+			return err
 		}
-		err = r.db.Delete(&entity.Object{}, "bucket_id = ?", bucketID).Error
+
+		moved := *object
+		moved.BucketID = bucketID
+		moved.ParentPath = parentPath
+		moved.OriginName = name
+		moved.LastModified = time.Now()
+
+		blobs := NewBlobRepository(tx)
+		if bucketID != object.BucketID {
+			moved.BlobKey = ""
+			if err := blobs.Acquire(&moved); err != nil {
+				return err
+			}
+			if _, err := blobs.Release(object.BucketID, object.StorageKey(), 1); err != nil {
+				return err
+			}
+		}
+
+		err := tx.Model(&entity.Object{}).Where("id = ?", object.ID).Updates(map[string]interface{}{
+			"bucket_id":     moved.BucketID,
+			"parent_path":   moved.ParentPath,
+			"origin_name":   moved.OriginName,
+			"blob_key":      moved.BlobKey,
+			"last_modified": moved.LastModified,
+		}).Error
 		if err != nil {
-			return nil, err
+			return err
 		}
-	} else {
-		// Find all objects where parent_path equals prefix OR starts with prefix/
-		err := r.db.Where("bucket_id = ? AND (parent_path = ? OR parent_path LIKE ?)", bucketID, pathPrefix, pathPrefix+"/%").Find(&objects).Error
+
+		if err := folders.AddObject(bucketID, parentPath, object.Size); err != nil {
+			return err
+		}
+
+		*object = moved
+		return nil
+	})
+}
+
+// Replace gives existing the content of replacement, keeping its ID, name and path, and bumps its version.
+// A non-nil version records the previous content in the same transaction. Folder totals follow the size
+// change. It returns true when the replaced blob lost its last reference and must be deleted from storage.
+func (r *ObjectRepository) Replace(existing, replacement *entity.Object, version *entity.ObjectVersion) (bool, error) {
+	unreferenced := false
+	blobKey := replacement.BlobKey
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if version != nil {
+			if err := tx.Create(version).Error; err != nil {
+				return err
+			}
+		}
+
+		blobs := NewBlobRepository(tx)
+		if err := blobs.Acquire(replacement); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		result := tx.Model(&entity.Object{}).Where("id = ? AND version = ?", existing.ID, existing.Version).Updates(map[string]interface{}{
+			"content_type":  replacement.ContentType,
+			"size":          replacement.Size,
+			"url":           replacement.URL,
+			"file_hash":     replacement.FileHash,
+			"blob_key":      replacement.BlobKey,
+			"version":       existing.Version + 1,
+			"last_modified": now,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrObjectChanged
+		}
+
+		folders := NewFolderRepository(tx)
+		if err := folders.RemoveUsage(existing.BucketID, existing.ParentPath, 1, existing.Size); err != nil {
+			return err
+		}
+		if err := folders.AddObject(existing.BucketID, existing.ParentPath, replacement.Size); err != nil {
+			return err
+		}
+
+		oldKey := existing.StorageKey()
+		existing.ContentType = replacement.ContentType
+		existing.Size = replacement.Size
+		existing.URL = replacement.URL
+		existing.FileHash = replacement.FileHash
+		existing.BlobKey = replacement.BlobKey
+		existing.Version++
+		existing.LastModified = now
+
+		released, err := blobs.Release(existing.BucketID, oldKey, 1)
+		unreferenced = released
+		return err
+	})
+	if err != nil {
+		replacement.BlobKey = blobKey
+	}
+	return unreferenced, err
+}
+
+// DeleteByBucketIDAndPath deletes all objects with the exact parent_path
+// Returns the deleted objects for tracking what needs to be cleaned up in storage
+func (r *ObjectRepository) DeleteByBucketIDAndPath(bucketID uuid.UUID, path string) ([]entity.Object, error) {
+	var objects []entity.Object
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// Delete and return the deleted rows in one statement
+		err := tx.Clauses(clause.Returning{}).
+			Where("bucket_id = ? AND parent_path = ?", bucketID, path).
+			Delete(&objects).Error
 		if err != nil {
-			return nil, err
+			return err
+		}
+
+		if _, err := NewBlobRepository(tx).ReleaseObjects(objects); err != nil {
+			return err
+		}
+
+		// The folder itself stays, only its totals (and its ancestors') shrink
+		count, size := objectTotals(objects)
+		return NewFolderRepository(tx).RemoveUsage(bucketID, path, count, size)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// DeleteByBucketIDAndPathPrefix deletes all objects where parent_path starts with the given prefix
+// This is used for deleting entire folder hierarchies, including empty subfolders
+// Returns the deleted objects and the blob keys they left unreferenced, which may lie outside the prefix
+func (r *ObjectRepository) DeleteByBucketIDAndPathPrefix(bucketID uuid.UUID, pathPrefix string) ([]entity.Object, []string, error) {
+	var objects []entity.Object
+	var unreferenced []string
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		q := tx.Clauses(clause.Returning{})
+		if pathPrefix == "" {
+			// Empty prefix means delete all objects at root level and in subfolders
+			q = q.Where("bucket_id = ?", bucketID)
+		} else {
+			// Delete all objects where parent_path equals prefix OR starts with prefix/
+			q = q.Where("bucket_id = ? AND (parent_path = ? OR parent_path LIKE ?)", bucketID, pathPrefix, pathPrefix+"/%")
 		}
-		err = r.db.Delete(&entity.Object{}, "bucket_id = ? AND (parent_path = ? OR parent_path LIKE ?)", bucketID, pathPrefix, pathPrefix+"/%").Error
+		if err := q.Delete(&objects).Error; err != nil {
+			return err
+		}
+		released, err := NewBlobRepository(tx).ReleaseObjects(objects)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		unreferenced = released
+
+		folders := NewFolderRepository(tx)
+		if err := folders.DeleteTree(bucketID, pathPrefix); err != nil {
+			return err
 		}
+		// The deleted subtree no longer counts towards the folders above it
+		parent, _ := splitFolderPath(pathPrefix)
+		count, size := objectTotals(objects)
+		return folders.RemoveUsage(bucketID, parent, count, size)
+	})
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return objects, nil
+	return objects, unreferenced, nil
+}
+
+// objectTotals returns the number and total size of objects
+func objectTotals(objects []entity.Object) (int64, int64) {
+	var size int64
+	for i := range objects {
+		size += objects[i].Size
+	}
+	return int64(len(objects)), size
 }
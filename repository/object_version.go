@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"github.com/google/uuid"
+	"github.com/tnqbao/gau-cloud-orchestrator/entity"
+	"gorm.io/gorm"
+)
+
+type ObjectVersionRepository struct {
+	db *gorm.DB
+}
+
+func NewObjectVersionRepository(db *gorm.DB) *ObjectVersionRepository {
+	return &ObjectVersionRepository{db: db}
+}
+
+// FindByObjectID returns the previous contents of an object, newest first
+func (r *ObjectVersionRepository) FindByObjectID(objectID uuid.UUID) ([]entity.ObjectVersion, error) {
+	var versions []entity.ObjectVersion
+	err := r.db.Where("object_id = ?", objectID).Order("version DESC").Find(&versions).Error
+	if err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// FindUnderPath returns the versions of every object at path or below it; "" matches the whole bucket
+func (r *ObjectVersionRepository) FindUnderPath(bucketID uuid.UUID, path string) ([]entity.ObjectVersion, error) {
+	q := r.db.Model(&entity.ObjectVersion{}).
+		Joins("JOIN objects o ON o.id = object_versions.object_id").
+		Where("object_versions.bucket_id = ?", bucketID)
+	if path != "" {
+		q = q.Where("(o.parent_path = ? OR o.parent_path LIKE ?)", path, path+"/%")
+	}
+	var versions []entity.ObjectVersion
+	err := q.Find(&versions).Error
+	if err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
@@ -0,0 +1,303 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tnqbao/gau-cloud-orchestrator/entity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrQuotaExceeded is matched by every QuotaExceededError
+var ErrQuotaExceeded = errors.New("storage quota exceeded")
+
+// versionBytesByOwner sums the kept versions of an owner's objects, which count towards used bytes;
+// it takes the owner ID as its only argument
+const versionBytesByOwner = `(SELECT COALESCE(SUM(v.size), 0) FROM object_versions v JOIN buckets vb ON vb.id = v.bucket_id WHERE vb.owner_id = ?)`
+
+// Limits a reservation can exceed
+const (
+	QuotaLimitBytes      = "max_bytes"
+	QuotaLimitObjects    = "max_objects"
+	QuotaLimitObjectSize = "max_object_size"
+)
+
+// QuotaExceededError reports which quota and limit a reservation would exceed
+type QuotaExceededError struct {
+	Scope entity.QuotaScope
+	Limit string
+	Max   int64
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("%s quota exceeded: %s is %d", e.Scope, e.Limit, e.Max)
+}
+
+func (e *QuotaExceededError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+type StorageQuotaRepository struct {
+	db *gorm.DB
+}
+
+func NewStorageQuotaRepository(db *gorm.DB) *StorageQuotaRepository {
+	return &StorageQuotaRepository{db: db}
+}
+
+// EnsureAccount returns the owner's account quota. A missing quota is created with
+// the given limits and the usage of the objects the owner already stores.
+func (r *StorageQuotaRepository) EnsureAccount(ownerID uuid.UUID, limits entity.QuotaLimits, notifyEmail string) (*entity.StorageQuota, error) {
+	err := r.db.Exec(`INSERT INTO storage_quotas
+		(id, owner_id, scope, max_bytes, max_objects, max_object_size, used_bytes, object_count, notify_email, warned_percent, created_at, updated_at)
+		SELECT ?, ?, ?, ?, ?, ?, COALESCE(SUM(o.size), 0) + `+versionBytesByOwner+`, COUNT(o.id), ?, 0, NOW(), NOW()
+		FROM objects o JOIN buckets b ON b.id = o.bucket_id
+		WHERE b.owner_id = ?
+		ON CONFLICT DO NOTHING`,
+		uuid.New(), ownerID, entity.QuotaScopeAccount, limits.MaxBytes, limits.MaxObjects, limits.MaxObjectSize, ownerID, notifyEmail, ownerID,
+	).Error
+	if err != nil {
+		return nil, err
+	}
+	return r.FindAccount(ownerID)
+}
+
+// EnsureBucket returns the bucket quota, creating it like EnsureAccount if missing
+func (r *StorageQuotaRepository) EnsureBucket(ownerID, bucketID uuid.UUID, limits entity.QuotaLimits) (*entity.StorageQuota, error) {
+	err := r.db.Exec(`INSERT INTO storage_quotas
+		(id, owner_id, bucket_id, scope, max_bytes, max_objects, max_object_size, used_bytes, object_count, notify_email, warned_percent, created_at, updated_at)
+		SELECT ?, ?, ?, ?, ?, ?, ?, COALESCE(SUM(o.size), 0) + (SELECT COALESCE(SUM(v.size), 0) FROM object_versions v WHERE v.bucket_id = ?), COUNT(o.id), '', 0, NOW(), NOW()
+		FROM objects o
+		WHERE o.bucket_id = ?
+		ON CONFLICT DO NOTHING`,
+		uuid.New(), ownerID, bucketID, entity.QuotaScopeBucket, limits.MaxBytes, limits.MaxObjects, limits.MaxObjectSize, bucketID, bucketID,
+	).Error
+	if err != nil {
+		return nil, err
+	}
+	return r.FindBucket(bucketID)
+}
+
+func (r *StorageQuotaRepository) FindAccount(ownerID uuid.UUID) (*entity.StorageQuota, error) {
+	var quota entity.StorageQuota
+	err := r.db.Where("scope = ? AND owner_id = ?", entity.QuotaScopeAccount, ownerID).First(&quota).Error
+	if err != nil {
+		return nil, err
+	}
+	return &quota, nil
+}
+
+func (r *StorageQuotaRepository) FindBucket(bucketID uuid.UUID) (*entity.StorageQuota, error) {
+	var quota entity.StorageQuota
+	err := r.db.Where("scope = ? AND bucket_id = ?", entity.QuotaScopeBucket, bucketID).First(&quota).Error
+	if err != nil {
+		return nil, err
+	}
+	return &quota, nil
+}
+
+// FindByOwnerID returns the account quota followed by the owner's bucket quotas
+func (r *StorageQuotaRepository) FindByOwnerID(ownerID uuid.UUID) ([]entity.StorageQuota, error) {
+	var quotas []entity.StorageQuota
+	err := r.db.Where("owner_id = ?", ownerID).Order("scope ASC, created_at ASC").Find(&quotas).Error
+	return quotas, err
+}
+
+// FillReserved sets the bytes and objects held by the quota's active chunked upload sessions
+func (r *StorageQuotaRepository) FillReserved(quota *entity.StorageQuota) error {
+	return fillReserved(r.db, quota)
+}
+
+// Update saves the limits and notification address. A warning already sent is
+// lowered when the new limits put usage back under its threshold.
+func (r *StorageQuotaRepository) Update(quota *entity.StorageQuota) error {
+	if level := quota.WarningPercent(); quota.WarnedPercent > level {
+		quota.WarnedPercent = level
+	}
+	return r.db.Model(quota).
+		Select("max_bytes", "max_objects", "max_object_size", "notify_email", "warned_percent", "updated_at").
+		Updates(quota).Error
+}
+
+// ReserveUsage atomically checks that one object of size bytes fits within the account
+// and bucket quotas and commits it to their usage. Call ReleaseUsage if the upload fails.
+func (r *StorageQuotaRepository) ReserveUsage(ownerID, bucketID uuid.UUID, size int64) ([]entity.StorageQuota, error) {
+	var quotas []entity.StorageQuota
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		quotas, err = lockForReservation(tx, ownerID, bucketID, size)
+		if err != nil {
+			return err
+		}
+		for i := range quotas {
+			quotas[i].UsedBytes += size
+			quotas[i].ObjectCount++
+		}
+		return addUsage(tx, ownerID, bucketID, size, 1)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return quotas, nil
+}
+
+// ReserveSession atomically checks that the session's file fits within the account and
+// bucket quotas and creates the session. Its bytes stay reserved while it is active.
+func (r *StorageQuotaRepository) ReserveSession(session *entity.UploadSession) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if _, err := lockForReservation(tx, session.UserID, session.BucketID, session.FileSize); err != nil {
+			return err
+		}
+		return tx.Create(session).Error
+	})
+}
+
+// AddUsage commits bytes and objects to the account and bucket quotas without checking
+// the limits, for uploads whose bytes were already reserved by a session
+func (r *StorageQuotaRepository) AddUsage(ownerID, bucketID uuid.UUID, bytes, objects int64) ([]entity.StorageQuota, error) {
+	if err := addUsage(r.db, ownerID, bucketID, bytes, objects); err != nil {
+		return nil, err
+	}
+	return r.findScopes(ownerID, bucketID)
+}
+
+// ReleaseUsage removes deleted bytes and objects from the account and bucket quotas.
+// bucketID may be uuid.Nil when only the account usage is known.
+func (r *StorageQuotaRepository) ReleaseUsage(ownerID, bucketID uuid.UUID, bytes, objects int64) error {
+	if err := addUsage(r.db, ownerID, bucketID, -bytes, -objects); err != nil {
+		return err
+	}
+	quotas, err := r.findScopes(ownerID, bucketID)
+	if err != nil {
+		return err
+	}
+	return r.lowerWarnings(quotas)
+}
+
+// RecalculateAccount resets the account usage from the objects the owner still stores
+func (r *StorageQuotaRepository) RecalculateAccount(ownerID uuid.UUID) error {
+	err := r.db.Exec(`UPDATE storage_quotas q
+		SET used_bytes = u.bytes, object_count = u.objects, updated_at = NOW()
+		FROM (
+			SELECT COALESCE(SUM(o.size), 0) + `+versionBytesByOwner+` AS bytes, COUNT(o.id) AS objects
+			FROM objects o JOIN buckets b ON b.id = o.bucket_id
+			WHERE b.owner_id = ?
+		) u
+		WHERE q.scope = ? AND q.owner_id = ?`,
+		ownerID, ownerID, entity.QuotaScopeAccount, ownerID,
+	).Error
+	if err != nil {
+		return err
+	}
+	quotas, err := r.findScopes(ownerID, uuid.Nil)
+	if err != nil {
+		return err
+	}
+	return r.lowerWarnings(quotas)
+}
+
+// MarkWarned records that the owner was warned at percent. It returns false when that
+// warning was already sent, so concurrent writers send it only once.
+func (r *StorageQuotaRepository) MarkWarned(id uuid.UUID, percent int) (bool, error) {
+	result := r.db.Model(&entity.StorageQuota{}).
+		Where("id = ? AND warned_percent < ?", id, percent).
+		Update("warned_percent", percent)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *StorageQuotaRepository) findScopes(ownerID, bucketID uuid.UUID) ([]entity.StorageQuota, error) {
+	var quotas []entity.StorageQuota
+	err := scopeQuery(r.db, ownerID, bucketID).Order("scope ASC").Find(&quotas).Error
+	return quotas, err
+}
+
+// lowerWarnings re-arms warnings for quotas whose usage dropped below a threshold
+func (r *StorageQuotaRepository) lowerWarnings(quotas []entity.StorageQuota) error {
+	for i := range quotas {
+		level := quotas[i].WarningPercent()
+		if quotas[i].WarnedPercent <= level {
+			continue
+		}
+		err := r.db.Model(&entity.StorageQuota{}).
+			Where("id = ?", quotas[i].ID).
+			Update("warned_percent", level).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scopeQuery selects the account quota of ownerID and the quota of bucketID
+func scopeQuery(db *gorm.DB, ownerID, bucketID uuid.UUID) *gorm.DB {
+	return db.Model(&entity.StorageQuota{}).
+		Where("(scope = ? AND owner_id = ?) OR (scope = ? AND bucket_id = ?)",
+			entity.QuotaScopeAccount, ownerID, entity.QuotaScopeBucket, bucketID)
+}
+
+func addUsage(db *gorm.DB, ownerID, bucketID uuid.UUID, bytes, objects int64) error {
+	return scopeQuery(db, ownerID, bucketID).
+		Updates(map[string]interface{}{
+			"used_bytes":   gorm.Expr("GREATEST(used_bytes + ?, 0)", bytes),
+			"object_count": gorm.Expr("GREATEST(object_count + ?, 0)", objects),
+			"updated_at":   time.Now(),
+		}).Error
+}
+
+// lockForReservation locks the account and bucket quotas, in a fixed order, and verifies
+// that one more object of size bytes fits within both, counting active sessions as reserved
+func lockForReservation(tx *gorm.DB, ownerID, bucketID uuid.UUID, size int64) ([]entity.StorageQuota, error) {
+	var quotas []entity.StorageQuota
+	err := scopeQuery(tx, ownerID, bucketID).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Order("scope ASC").
+		Find(&quotas).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range quotas {
+		quota := &quotas[i]
+		if err := fillReserved(tx, quota); err != nil {
+			return nil, err
+		}
+		if quota.MaxObjectSize > 0 && size > quota.MaxObjectSize {
+			return nil, &QuotaExceededError{Scope: quota.Scope, Limit: QuotaLimitObjectSize, Max: quota.MaxObjectSize}
+		}
+		if quota.MaxBytes > 0 && quota.UsedBytes+quota.ReservedBytes+size > quota.MaxBytes {
+			return nil, &QuotaExceededError{Scope: quota.Scope, Limit: QuotaLimitBytes, Max: quota.MaxBytes}
+		}
+		if quota.MaxObjects > 0 && quota.ObjectCount+quota.ReservedObjects+1 > quota.MaxObjects {
+			return nil, &QuotaExceededError{Scope: quota.Scope, Limit: QuotaLimitObjects, Max: quota.MaxObjects}
+		}
+	}
+	return quotas, nil
+}
+
+// fillReserved sums the chunked upload sessions that still hold space: unexpired
+// sessions receiving chunks, and sessions whose chunks are being composed
+func fillReserved(db *gorm.DB, quota *entity.StorageQuota) error {
+	query := db.Model(&entity.UploadSession{}).
+		Select("COALESCE(SUM(file_size), 0) AS bytes, COUNT(*) AS objects").
+		Where("((status IN ? AND expires_at > ?) OR status = ?)",
+			[]entity.UploadStatus{entity.UploadStatusInit, entity.UploadStatusUploading}, time.Now(), entity.UploadStatusProcessing)
+	if quota.Scope == entity.QuotaScopeAccount {
+		query = query.Where("user_id = ?", quota.OwnerID)
+	} else if quota.BucketID != nil {
+		query = query.Where("bucket_id = ?", *quota.BucketID)
+	}
+
+	var reserved struct {
+		Bytes   int64
+		Objects int64
+	}
+	if err := query.Scan(&reserved).Error; err != nil {
+		return err
+	}
+	quota.ReservedBytes = reserved.Bytes
+	quota.ReservedObjects = reserved.Objects
+	return nil
+}
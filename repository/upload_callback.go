@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"github.com/google/uuid"
+	"github.com/tnqbao/gau-cloud-orchestrator/entity"
+	"gorm.io/gorm"
+)
+
+type UploadCallbackRepository struct {
+	db *gorm.DB
+}
+
+func NewUploadCallbackRepository(db *gorm.DB) *UploadCallbackRepository {
+	return &UploadCallbackRepository{db: db}
+}
+
+// Create records a delivery attempt
+func (r *UploadCallbackRepository) Create(delivery *entity.UploadCallbackDelivery) error {
+	if delivery.ID == uuid.Nil {
+		delivery.ID = uuid.New()
+	}
+	return r.db.Create(delivery).Error
+}
+
+// ListByUploadID returns the delivery attempts of an upload, oldest first
+func (r *UploadCallbackRepository) ListByUploadID(uploadID uuid.UUID) ([]entity.UploadCallbackDelivery, error) {
+	var deliveries []entity.UploadCallbackDelivery
+	err := r.db.Where("upload_id = ?", uploadID).
+		Order("created_at ASC, attempt ASC").
+		Find(&deliveries).Error
+	return deliveries, err
+}
+
+// Progress returns how many attempts were made to deliver event for an upload, and whether one succeeded
+func (r *UploadCallbackRepository) Progress(uploadID uuid.UUID, event string) (int, bool, error) {
+	var result struct {
+		Attempts  int
+		Delivered bool
+	}
+	err := r.db.Model(&entity.UploadCallbackDelivery{}).
+		Select("COUNT(*) AS attempts, COALESCE(BOOL_OR(success), FALSE) AS delivered").
+		Where("upload_id = ? AND event = ?", uploadID, event).
+		Scan(&result).Error
+	return result.Attempts, result.Delivered, err
+}
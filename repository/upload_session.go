@@ -7,6 +7,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/tnqbao/gau-cloud-orchestrator/entity"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type UploadSessionRepository struct {
@@ -58,6 +59,44 @@ func (r *UploadSessionRepository) FindActiveByBucketID(bucketID uuid.UUID) ([]en
 	return sessions, err
 }
 
+// FindActiveByUserID finds the sessions of a user that still accept chunks or have expired without being cleaned up
+func (r *UploadSessionRepository) FindActiveByUserID(userID uuid.UUID) ([]entity.UploadSession, error) {
+	var sessions []entity.UploadSession
+	err := r.db.Where("user_id = ? AND status IN ?", userID,
+		[]entity.UploadStatus{entity.UploadStatusInit, entity.UploadStatusUploading}).
+		Order("created_at DESC").Find(&sessions).Error
+	return sessions, err
+}
+
+// UpdateExpiry moves the deadline of a session that is still active; it returns false when the
+// session is no longer active
+func (r *UploadSessionRepository) UpdateExpiry(id uuid.UUID, expiresAt time.Time) (bool, error) {
+	result := r.db.Model(&entity.UploadSession{}).
+		Where("id = ? AND status IN ? AND expires_at > ?", id,
+			[]entity.UploadStatus{entity.UploadStatusInit, entity.UploadStatusUploading}, time.Now()).
+		Updates(map[string]interface{}{
+			"expires_at": expiresAt,
+			"updated_at": time.Now(),
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// DeleteAbortable deletes the sessions of a user that are not being processed and returns them.
+// bucketID uuid.Nil matches every bucket and nil ids every session.
+func (r *UploadSessionRepository) DeleteAbortable(userID, bucketID uuid.UUID, ids []uuid.UUID) ([]entity.UploadSession, error) {
+	q := r.db.Clauses(clause.Returning{}).Where("user_id = ? AND status IN ?", userID,
+		[]entity.UploadStatus{entity.UploadStatusInit, entity.UploadStatusUploading, entity.UploadStatusFailed, entity.UploadStatusExpired})
+	if bucketID != uuid.Nil {
+		q = q.Where("bucket_id = ?", bucketID)
+	}
+	if ids != nil {
+		q = q.Where("id IN ?", ids)
+	}
+	var sessions []entity.UploadSession
+	err := q.Delete(&sessions).Error
+	return sessions, err
+}
+
 // UpdateStatus updates the status of an upload session
 func (r *UploadSessionRepository) UpdateStatus(id uuid.UUID, status entity.UploadStatus) error {
 	return r.db.Model(&entity.UploadSession{}).Where("id = ?", id).
@@ -67,6 +106,16 @@ func (r *UploadSessionRepository) UpdateStatus(id uuid.UUID, status entity.Uploa
 		}).Error
 }
 
+// MarkFailed fails the session with a message shown to the client
+func (r *UploadSessionRepository) MarkFailed(id uuid.UUID, message string) error {
+	return r.db.Model(&entity.UploadSession{}).Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":        entity.UploadStatusFailed,
+			"error_message": message,
+			"updated_at":    time.Now(),
+		}).Error
+}
+
 // IncrementUploadedChunks increments the uploaded chunks count
 func (r *UploadSessionRepository) IncrementUploadedChunks(id uuid.UUID) error {
 	return r.db.Model(&entity.UploadSession{}).Where("id = ?", id).
@@ -77,6 +126,21 @@ func (r *UploadSessionRepository) IncrementUploadedChunks(id uuid.UUID) error {
 		}).Error
 }
 
+// AdvanceOffset moves a tus session from offset from to offset to, counting the chunks now complete.
+// It returns false when the session is no longer active or another request moved the offset first.
+func (r *UploadSessionRepository) AdvanceOffset(id uuid.UUID, from, to int64, uploadedChunks int) (bool, error) {
+	result := r.db.Model(&entity.UploadSession{}).
+		Where("id = ? AND upload_offset = ? AND status IN ? AND expires_at > ?",
+			id, from, []entity.UploadStatus{entity.UploadStatusInit, entity.UploadStatusUploading}, time.Now()).
+		Updates(map[string]interface{}{
+			"upload_offset":   to,
+			"uploaded_chunks": uploadedChunks,
+			"status":          entity.UploadStatusUploading,
+			"updated_at":      time.Now(),
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
 // UpdateFileHash updates the file hash after composition
 func (r *UploadSessionRepository) UpdateFileHash(id uuid.UUID, fileHash string) error {
 	return r.db.Model(&entity.UploadSession{}).Where("id = ?", id).
@@ -86,6 +150,32 @@ func (r *UploadSessionRepository) UpdateFileHash(id uuid.UUID, fileHash string)
 		}).Error
 }
 
+// UpdateUploadType sets whether the session is stored as a file or extracted into extractPath once composed
+func (r *UploadSessionRepository) UpdateUploadType(id uuid.UUID, uploadType, extractPath string) error {
+	return r.db.Model(&entity.UploadSession{}).Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"upload_type":  uploadType,
+			"extract_path": extractPath,
+			"updated_at":   time.Now(),
+		}).Error
+}
+
+// UpdateExtraction saves the extraction progress; a non-empty status also changes the session status
+func (r *UploadSessionRepository) UpdateExtraction(id uuid.UUID, extraction entity.Extraction, status entity.UploadStatus) error {
+	updates := map[string]interface{}{
+		"extract_total_entries": extraction.TotalEntries,
+		"extract_entries":       extraction.Entries,
+		"extract_bytes":         extraction.Bytes,
+		"extract_skipped":       extraction.Skipped,
+		"extract_error":         extraction.Error,
+		"updated_at":            time.Now(),
+	}
+	if status != "" {
+		updates["status"] = status
+	}
+	return r.db.Model(&entity.UploadSession{}).Where("id = ?", id).Updates(updates).Error
+}
+
 // Delete deletes an upload session
 func (r *UploadSessionRepository) Delete(id uuid.UUID) error {
 	return r.db.Delete(&entity.UploadSession{}, "id = ?", id).Error
@@ -94,7 +184,7 @@ func (r *UploadSessionRepository) Delete(id uuid.UUID) error {
 // DeleteExpired deletes all expired upload sessions
 func (r *UploadSessionRepository) DeleteExpired() (int64, error) {
 	result := r.db.Where("expires_at < ? AND status NOT IN ?", time.Now(),
-		[]entity.UploadStatus{entity.UploadStatusCompleted, entity.UploadStatusProcessing}).
+		[]entity.UploadStatus{entity.UploadStatusCompleted, entity.UploadStatusProcessing, entity.UploadStatusExtracting}).
 		Delete(&entity.UploadSession{})
 	return result.RowsAffected, result.Error
 }
@@ -103,7 +193,7 @@ func (r *UploadSessionRepository) DeleteExpired() (int64, error) {
 func (r *UploadSessionRepository) FindExpired() ([]entity.UploadSession, error) {
 	var sessions []entity.UploadSession
 	err := r.db.Where("expires_at < ? AND status NOT IN ?", time.Now(),
-		[]entity.UploadStatus{entity.UploadStatusCompleted, entity.UploadStatusProcessing}).
+		[]entity.UploadStatus{entity.UploadStatusCompleted, entity.UploadStatusProcessing, entity.UploadStatusExtracting}).
 		Find(&sessions).Error
 	return sessions, err
 }
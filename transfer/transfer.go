@@ -0,0 +1,252 @@
+// Package transfer copies and moves objects between folders and buckets of the same owner.
+// Within a bucket objects keep sharing their blob and no bytes move. Into another bucket the
+// bytes are copied server-side by MinIO, unless that bucket already stores the content; the
+// object rows, folder totals and quotas are updated afterwards, and the copy is rolled back
+// if the database update fails.
+package transfer
+
+import (
+	"context"
+	"errors"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tnqbao/gau-cloud-orchestrator/conflict"
+	"github.com/tnqbao/gau-cloud-orchestrator/entity"
+	"github.com/tnqbao/gau-cloud-orchestrator/infra"
+	"github.com/tnqbao/gau-cloud-orchestrator/repository"
+	"gorm.io/gorm"
+)
+
+type Mode string
+
+const (
+	ModeCopy Mode = "copy"
+	ModeMove Mode = "move"
+)
+
+// folderBatchSize is the number of objects loaded at a time while transferring a folder
+const folderBatchSize = 200
+
+var (
+	// ErrConflict is returned when the destination already holds a different object under the same name or storage key
+	ErrConflict = errors.New("destination already has an object with this name")
+	// ErrInvalidDestination is returned when a folder would be copied or moved into itself
+	ErrInvalidDestination = errors.New("destination is inside the source folder")
+)
+
+// FolderResult summarizes a folder transfer
+type FolderResult struct {
+	Transferred int64 `json:"transferred"`
+	Skipped     int64 `json:"skipped"` // objects left in place because the destination name is taken
+	Bytes       int64 `json:"bytes"`
+}
+
+type Transfer struct {
+	infra      *infra.Infra
+	repository *repository.Repository
+}
+
+func New(inf *infra.Infra, repo *repository.Repository) *Transfer {
+	return &Transfer{infra: inf, repository: repo}
+}
+
+// Object copies or moves one object to dstPath in dst under name. Nothing is written to storage
+// within a bucket. On ErrConflict the object occupying the destination is returned.
+func (t *Transfer) Object(ctx context.Context, mode Mode, src *entity.Bucket, object *entity.Object, dst *entity.Bucket, dstPath, name string) (*entity.Object, error) {
+	if name == "" {
+		name = object.OriginName
+	}
+	if mode == ModeMove && dst.ID == src.ID && dstPath == object.ParentPath && name == object.OriginName {
+		return object, nil
+	}
+
+	existing, err := t.repository.ObjectRepo.FindByBucketPathAndName(dst.ID, dstPath, name)
+	if err == nil && existing.ID != object.ID {
+		return existing, ErrConflict
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// Within a bucket the object keeps referencing its blob. Another bucket references its own blob
+	// of the content when it has one, otherwise the bytes are copied there under <dstPath>/<url>.
+	srcKey := object.StorageKey()
+	dstKey := path.Join(dstPath, object.URL)
+	copyBytes := false
+	if dst.ID != src.ID {
+		shared := false
+		if object.FileHash != "" {
+			_, err := t.repository.BlobRepo.FindByHash(dst.ID, object.FileHash)
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, err
+			}
+			shared = err == nil
+		}
+		if !shared {
+			// Anything else stored under the destination key would be overwritten
+			holders, err := t.repository.ObjectRepo.FindByStorageKey(dst.ID, dstKey)
+			if err != nil {
+				return nil, err
+			}
+			if len(holders) > 0 {
+				return &holders[0], ErrConflict
+			}
+			copyBytes = true
+		}
+	}
+
+	// Copies and moves into another bucket count against the destination quotas first
+	reserved := mode == ModeCopy || dst.ID != src.ID
+	if reserved {
+		if _, err := t.repository.StorageQuotaRepo.ReserveUsage(dst.OwnerID, dst.ID, object.Size); err != nil {
+			return nil, err
+		}
+	}
+
+	if copyBytes {
+		if err := t.infra.Minio.CopyObject(ctx, src.Name, srcKey, dst.Name, dstKey, object.Size); err != nil {
+			t.release(ctx, reserved, dst, object.Size)
+			return nil, err
+		}
+	}
+
+	result := object
+	if mode == ModeCopy {
+		now := time.Now()
+		result = &entity.Object{
+			ID:           uuid.New(),
+			BucketID:     dst.ID,
+			ContentType:  object.ContentType,
+			OriginName:   name,
+			ParentPath:   dstPath,
+			CreatedAt:    now,
+			LastModified: now,
+			Size:         object.Size,
+			URL:          object.URL,
+			FileHash:     object.FileHash,
+		}
+		if dst.ID == src.ID {
+			result.BlobKey = srcKey
+		}
+		err = t.repository.ObjectRepo.Create(result)
+	} else {
+		err = t.repository.ObjectRepo.Relocate(object, dst.ID, dstPath, name)
+	}
+	if err != nil {
+		if copyBytes {
+			if rmErr := t.infra.Minio.DeleteObject(ctx, dst.Name, dstKey); rmErr != nil {
+				t.infra.Logger.ErrorWithContextf(ctx, rmErr, "[Transfer] Failed to roll back copy '%s/%s': %v", dst.Name, dstKey, rmErr)
+			}
+		}
+		t.release(ctx, reserved, dst, object.Size)
+		return nil, err
+	}
+
+	// The destination may have stored the content meanwhile, the copy then goes unused
+	if copyBytes {
+		conflict.RemoveUnusedCopy(ctx, t.infra, t.repository, dst, result)
+	}
+	if mode == ModeMove && dst.ID != src.ID {
+		t.release(ctx, true, src, object.Size)
+		t.removeUnreferenced(ctx, src, srcKey)
+	}
+
+	return result, nil
+}
+
+// Folder copies or moves every object at srcPath or below it so that srcPath becomes dstPath,
+// recreating empty subfolders as well. It is safe to run again after a failure: moved objects
+// have left the source, and copies already made are skipped as identical.
+func (t *Transfer) Folder(ctx context.Context, mode Mode, src *entity.Bucket, srcPath string, dst *entity.Bucket, dstPath string) (FolderResult, error) {
+	var result FolderResult
+	if src.ID == dst.ID && (dstPath == srcPath || strings.HasPrefix(dstPath, srcPath+"/")) {
+		return result, ErrInvalidDestination
+	}
+
+	folders, err := t.repository.FolderRepo.FindTree(src.ID, srcPath)
+	if err != nil {
+		return result, err
+	}
+	if err := t.repository.FolderRepo.EnsurePath(dst.ID, dstPath); err != nil {
+		return result, err
+	}
+	for i := range folders {
+		if err := t.repository.FolderRepo.EnsurePath(dst.ID, RebasePath(folders[i].Path, srcPath, dstPath)); err != nil {
+			return result, err
+		}
+	}
+
+	afterID := uuid.Nil
+	for {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		objects, err := t.repository.ObjectRepo.FindUnderPath(src.ID, srcPath, afterID, folderBatchSize)
+		if err != nil {
+			return result, err
+		}
+		if len(objects) == 0 {
+			break
+		}
+
+		for i := range objects {
+			object := &objects[i]
+			afterID = object.ID
+
+			_, err := t.Object(ctx, mode, src, object, dst, RebasePath(object.ParentPath, srcPath, dstPath), object.OriginName)
+			if errors.Is(err, ErrConflict) {
+				result.Skipped++
+				continue
+			}
+			if err != nil {
+				return result, err
+			}
+			result.Transferred++
+			result.Bytes += object.Size
+		}
+	}
+
+	// Empty source folders go once everything has moved out
+	if mode == ModeMove && result.Skipped == 0 {
+		if err := t.repository.FolderRepo.DeleteTree(src.ID, srcPath); err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// RebasePath maps p, which is srcPath or below it, to the same position below dstPath
+func RebasePath(p, srcPath, dstPath string) string {
+	return strings.Trim(dstPath+strings.TrimPrefix(p, srcPath), "/")
+}
+
+// release gives back a destination reservation, or the source usage after a cross-bucket move
+func (t *Transfer) release(ctx context.Context, reserved bool, bucket *entity.Bucket, size int64) {
+	if !reserved {
+		return
+	}
+	if err := t.repository.StorageQuotaRepo.ReleaseUsage(bucket.OwnerID, bucket.ID, size, 1); err != nil {
+		t.infra.Logger.ErrorWithContextf(ctx, err, "[Transfer] Failed to release %d bytes of bucket %s: %v", size, bucket.ID, err)
+	}
+}
+
+// removeUnreferenced deletes the old storage key after a move once its blob has no reference left
+func (t *Transfer) removeUnreferenced(ctx context.Context, bucket *entity.Bucket, key string) {
+	_, err := t.repository.BlobRepo.FindByKey(bucket.ID, key)
+	if err == nil {
+		return
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.infra.Logger.ErrorWithContextf(ctx, err, "[Transfer] Failed to check references of '%s/%s': %v", bucket.Name, key, err)
+		return
+	}
+	if err := t.infra.Minio.DeleteObject(ctx, bucket.Name, key); err != nil {
+		// The moved object is already served from its new key, the old one is only an orphan
+		t.infra.Logger.ErrorWithContextf(ctx, err, "[Transfer] Failed to remove moved object '%s/%s': %v", bucket.Name, key, err)
+	}
+}
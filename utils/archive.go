@@ -0,0 +1,151 @@
+package utils
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	ArchiveFormatZip   = "zip"
+	ArchiveFormatTar   = "tar" // extraction only
+	ArchiveFormatTarGz = "tar.gz"
+)
+
+// ArchiveWriter streams entries into a ZIP or gzip-compressed tar archive.
+// Nothing is buffered beyond the compressor window, so entries can be copied
+// straight from object storage to the response.
+type ArchiveWriter struct {
+	zip  *zip.Writer
+	gzip *gzip.Writer
+	tar  *tar.Writer
+}
+
+func NewArchiveWriter(w io.Writer, format string) (*ArchiveWriter, error) {
+	switch format {
+	case ArchiveFormatZip:
+		return &ArchiveWriter{zip: zip.NewWriter(w)}, nil
+	case ArchiveFormatTarGz:
+		gz := gzip.NewWriter(w)
+		return &ArchiveWriter{gzip: gz, tar: tar.NewWriter(gz)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported archive format %q", format)
+	}
+}
+
+// ArchiveContentType returns the MIME type served for an archive format
+func ArchiveContentType(format string) string {
+	if format == ArchiveFormatTarGz {
+		return "application/gzip"
+	}
+	return "application/zip"
+}
+
+// ArchiveFormatFromName detects the archive format from a file name, "" if it is not an archive
+func ArchiveFormatFromName(name string) string {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return ArchiveFormatZip
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return ArchiveFormatTarGz
+	case strings.HasSuffix(name, ".tar"):
+		return ArchiveFormatTar
+	}
+	return ""
+}
+
+// CleanArchiveEntryPath turns an entry name read from an archive into a relative
+// slash-separated path. Absolute names, drive letters and any ".." segment are
+// rejected so that entries cannot escape the folder they are extracted into.
+func CleanArchiveEntryPath(name string) (string, error) {
+	p := strings.ReplaceAll(name, "\\", "/")
+	if strings.HasPrefix(p, "/") || (len(p) >= 2 && p[1] == ':') {
+		return "", fmt.Errorf("absolute entry path %q", name)
+	}
+	for _, segment := range strings.Split(p, "/") {
+		if segment == ".." {
+			return "", fmt.Errorf("entry path %q escapes the archive", name)
+		}
+	}
+	if strings.ContainsFunc(p, func(r rune) bool { return r < 0x20 || r == 0x7f }) {
+		return "", fmt.Errorf("entry path %q contains control characters", name)
+	}
+
+	p = strings.Trim(path.Clean("/"+p), "/")
+	if p == "" {
+		return "", fmt.Errorf("empty entry path %q", name)
+	}
+	return p, nil
+}
+
+// AddDir adds an (empty) directory entry
+func (a *ArchiveWriter) AddDir(name string, modified time.Time) error {
+	name = strings.TrimSuffix(name, "/") + "/"
+	if a.zip != nil {
+		_, err := a.zip.CreateHeader(&zip.FileHeader{Name: name, Modified: modified})
+		return err
+	}
+	return a.tar.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     name,
+		Mode:     0o755,
+		ModTime:  modified,
+	})
+}
+
+// AddFile copies size bytes from r into a new file entry. tar needs the size up front,
+// so a reader yielding a different length fails the entry.
+func (a *ArchiveWriter) AddFile(name string, size int64, modified time.Time, r io.Reader) (int64, error) {
+	var dst io.Writer
+	if a.zip != nil {
+		header := &zip.FileHeader{
+			Name:               name,
+			Method:             zip.Deflate,
+			Modified:           modified,
+			UncompressedSize64: uint64(size),
+		}
+		w, err := a.zip.CreateHeader(header)
+		if err != nil {
+			return 0, err
+		}
+		dst = w
+	} else {
+		err := a.tar.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Size:     size,
+			Mode:     0o644,
+			ModTime:  modified,
+		})
+		if err != nil {
+			return 0, err
+		}
+		dst = a.tar
+	}
+
+	n, err := io.Copy(dst, r)
+	if err != nil {
+		return n, err
+	}
+	if n != size {
+		return n, fmt.Errorf("entry %s: read %d bytes, expected %d", name, n, size)
+	}
+	return n, nil
+}
+
+// Close writes the archive trailer. It does not close the underlying writer.
+func (a *ArchiveWriter) Close() error {
+	if a.zip != nil {
+		return a.zip.Close()
+	}
+	if err := a.tar.Close(); err != nil {
+		return err
+	}
+	return a.gzip.Close()
+}
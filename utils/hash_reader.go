@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UnsignedPayload can be sent in X-Content-SHA256 to skip body hashing
+const UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+// maxVerifyDrain bounds how much unread body Verify will consume to reach EOF
+const maxVerifyDrain = 64 * 1024
+
+// BodyHashVerifierKey is the gin context key holding the *HashVerifyingReader of the request body
+const BodyHashVerifierKey = "body_hash_verifier"
+
+var (
+	// ErrBodyHashMismatch is returned when the streamed body doesn't match the declared hash
+	ErrBodyHashMismatch = errors.New("request body does not match X-Content-SHA256")
+	// ErrBodyNotFullyRead is returned when the body hash is checked before the body was consumed
+	ErrBodyNotFullyRead = errors.New("request body was not fully read")
+)
+
+// HashVerifyingReader hashes a body while it streams and compares the result
+// with a declared SHA-256 once EOF is reached. On mismatch the final Read
+// returns ErrBodyHashMismatch instead of io.EOF, so whoever is consuming the
+// stream (multipart parser, upload proxy) fails before it sees a complete body.
+type HashVerifyingReader struct {
+	body     io.ReadCloser
+	hasher   hash.Hash
+	declared string
+	done     bool
+	err      error
+}
+
+func NewHashVerifyingReader(body io.ReadCloser, declaredHash string) *HashVerifyingReader {
+	return &HashVerifyingReader{
+		body:     body,
+		hasher:   sha256.New(),
+		declared: declaredHash,
+	}
+}
+
+func (r *HashVerifyingReader) Read(p []byte) (int, error) {
+	if r.done {
+		if r.err != nil {
+			return 0, r.err
+		}
+		return 0, io.EOF
+	}
+
+	n, err := r.body.Read(p)
+	if n > 0 {
+		r.hasher.Write(p[:n])
+	}
+	if errors.Is(err, io.EOF) {
+		r.done = true
+		if !SecureCompare(hex.EncodeToString(r.hasher.Sum(nil)), r.declared) {
+			r.err = ErrBodyHashMismatch
+			return n, r.err
+		}
+	}
+	return n, err
+}
+
+func (r *HashVerifyingReader) Close() error {
+	return r.body.Close()
+}
+
+// Verify reports whether the whole body was read and matched the declared hash.
+// A small unread tail (e.g. the multipart epilogue left behind by the form
+// parser) is drained first; anything larger means the handler stopped early.
+func (r *HashVerifyingReader) Verify() error {
+	if !r.done {
+		_, _ = io.Copy(io.Discard, io.LimitReader(r, maxVerifyDrain))
+	}
+	if !r.done {
+		return ErrBodyNotFullyRead
+	}
+	return r.err
+}
+
+// VerifyBodyHash checks the streamed body hash when the request declared one.
+// Handlers call it after consuming the body and before committing any state.
+// Returns nil when no declared hash is in play (JWT, UNSIGNED-PAYLOAD or buffered HMAC).
+func VerifyBodyHash(c *gin.Context) error {
+	value, ok := c.Get(BodyHashVerifierKey)
+	if !ok {
+		return nil
+	}
+	verifier, ok := value.(*HashVerifyingReader)
+	if !ok {
+		return nil
+	}
+	return verifier.Verify()
+}
+
+// IsSHA256Hex reports whether s is a 64 character hex-encoded SHA-256
+func IsSHA256Hex(s string) bool {
+	if len(s) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
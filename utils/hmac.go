@@ -6,11 +6,17 @@ import (
 	"crypto/subtle"
 	"encoding/hex"
 	"fmt"
+	"net/url"
+	"sort"
+	"strings"
 )
 
 // EmptyBodyHash is the SHA256 hash of an empty body
 const EmptyBodyHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
 
+// SignatureAlgorithmV2 is the first line of a v2 string to sign
+const SignatureAlgorithmV2 = "HMAC-SHA256-V2"
+
 // BuildStringToSign constructs the canonical string to sign for HMAC authentication.
 // Format: METHOD\nPATH\nTIMESTAMP\nSHA256(body)
 //
@@ -25,6 +31,85 @@ func BuildStringToSign(method, path string, timestamp int64, bodyHash string) st
 	return fmt.Sprintf("%s\n%s\n%d\n%s", method, path, timestamp, bodyHash)
 }
 
+// BuildStringToSignV2 constructs the v2 canonical string to sign for HMAC authentication.
+// Format:
+//
+//	HMAC-SHA256-V2
+//	METHOD
+//	PATH
+//	CANONICAL_QUERY
+//	CANONICAL_HEADERS
+//	SIGNED_HEADERS
+//	TIMESTAMP
+//	NONCE
+//	SHA256(body)
+//
+// Parameters:
+//   - canonicalQuery: output of BuildCanonicalQuery
+//   - canonicalHeaders: output of BuildCanonicalHeaders
+//   - signedHeaders: lowercase header names sorted and joined with ';'
+func BuildStringToSignV2(method, path, canonicalQuery, canonicalHeaders, signedHeaders string, timestamp int64, nonce, bodyHash string) string {
+	return strings.Join([]string{
+		SignatureAlgorithmV2,
+		method,
+		path,
+		canonicalQuery,
+		canonicalHeaders,
+		signedHeaders,
+		fmt.Sprintf("%d", timestamp),
+		nonce,
+		bodyHash,
+	}, "\n")
+}
+
+// BuildCanonicalQuery sorts query parameters by key, then by value, and
+// joins them as URL-encoded key=value pairs separated by '&'.
+// Returns an empty string when there are no parameters.
+func BuildCanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(query))
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, url.QueryEscape(key)+"="+url.QueryEscape(value))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// ParseSignedHeaders normalises a ';' separated list of header names
+// to lowercase, sorted and de-duplicated
+func ParseSignedHeaders(raw string) []string {
+	seen := make(map[string]bool)
+	var headers []string
+	for _, name := range strings.Split(raw, ";") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		headers = append(headers, name)
+	}
+	sort.Strings(headers)
+	return headers
+}
+
+// BuildCanonicalHeaders renders the signed headers as "name:value" lines in
+// the order given. Values are trimmed; lookup returns the value of a header.
+func BuildCanonicalHeaders(signedHeaders []string, lookup func(name string) string) string {
+	lines := make([]string, 0, len(signedHeaders))
+	for _, name := range signedHeaders {
+		lines = append(lines, name+":"+strings.TrimSpace(lookup(name)))
+	}
+	return strings.Join(lines, "\n")
+}
+
 // ComputeHMACSHA256 computes HMAC-SHA256 signature and returns hex-encoded string.
 //
 // Parameters:
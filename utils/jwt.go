@@ -51,6 +51,11 @@ func InjectClaimsToContext(c *gin.Context, claims jwt.MapClaims) error {
 	} else {
 		c.Set("permission", "")
 	}
+
+	// Optional, used as the default recipient of quota warnings
+	if email, ok := claims["email"].(string); ok {
+		c.Set("email", email)
+	}
 	return nil
 }
 
@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"strings"
+)
+
+// Permissions required by routes, in "<resource>:<action>" form
+const (
+	PermBucketRead  = "bucket:read"
+	PermBucketWrite = "bucket:write"
+	PermObjectRead  = "object:read"
+	PermObjectWrite = "object:write"
+	PermIAMRead     = "iam:read"
+	PermIAMAdmin    = "iam:admin"
+	PermAuditRead   = "audit:read"
+	PermUsageRead   = "usage:read"
+	PermAdmin       = "admin"
+)
+
+// Roles that may appear in the JWT permission claim
+const (
+	RoleAdmin    = "admin"
+	RoleMember   = "member"
+	RoleReadOnly = "readonly"
+)
+
+var rolePermissions = map[string][]string{
+	RoleAdmin:    {"*"},
+	RoleMember:   {"bucket:*", "object:*", "iam:*", PermAuditRead, PermUsageRead},
+	RoleReadOnly: {PermBucketRead, PermObjectRead, PermIAMRead, PermAuditRead, PermUsageRead},
+}
+
+// accessKeyPermissions maps the role of an IAM access key to what requests signed with it may do.
+// Which buckets they may do it on is left to the key's S3 policy.
+var accessKeyPermissions = map[string][]string{
+	"admin":  {"bucket:*", "object:*"},
+	"user":   {PermBucketRead, PermBucketWrite, PermObjectRead, PermObjectWrite},
+	"viewer": {PermBucketRead, PermObjectRead},
+}
+
+// AccessKeyPermissions returns the permission claim for requests authenticated with an IAM access
+// key of the given role. Unknown roles get the viewer permissions.
+func AccessKeyPermissions(role string) string {
+	perms, ok := accessKeyPermissions[strings.ToLower(role)]
+	if !ok {
+		perms = accessKeyPermissions["viewer"]
+	}
+	return strings.Join(perms, ",")
+}
+
+// ResolvePermissions expands a permission claim into the list of granted permissions.
+// The claim is either a role name or a comma/space separated list of roles and
+// permissions, e.g. "member" or "bucket:read,object:read". An empty claim
+// resolves to defaultRole.
+func ResolvePermissions(claim, defaultRole string) []string {
+	claim = strings.TrimSpace(claim)
+	if claim == "" {
+		claim = defaultRole
+	}
+
+	var granted []string
+	for _, item := range strings.FieldsFunc(claim, func(r rune) bool {
+		return r == ',' || r == ' '
+	}) {
+		item = strings.ToLower(item)
+		if perms, ok := rolePermissions[item]; ok {
+			granted = append(granted, perms...)
+			continue
+		}
+		granted = append(granted, item)
+	}
+
+	return granted
+}
+
+// HasPermission reports whether the granted permissions cover the required one.
+// Supports the "*" wildcard and resource wildcards such as "bucket:*".
+func HasPermission(granted []string, required string) bool {
+	resource, _, _ := strings.Cut(required, ":")
+	for _, perm := range granted {
+		if perm == "*" || perm == required || perm == resource+":*" {
+			return true
+		}
+	}
+	return false
+}
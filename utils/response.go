@@ -63,3 +63,10 @@ func JSON413(c *gin.Context, data gin.H) {
 	data["status"] = 413
 	c.JSON(413, data)
 }
+
+func JSON429(c *gin.Context, err string) {
+	c.JSON(429, gin.H{
+		"error":  err,
+		"status": 429,
+	})
+}
@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+const s3ARNPrefix = "arn:aws:s3:::"
+
+type policyDocument struct {
+	Statement []policyStatement `json:"Statement"`
+}
+
+type policyStatement struct {
+	Effect   string          `json:"Effect"`
+	Action   json.RawMessage `json:"Action"`
+	Resource json.RawMessage `json:"Resource"`
+}
+
+// S3 actions checked against the policy of an IAM access key
+const (
+	S3GetObject    = "s3:GetObject"
+	S3PutObject    = "s3:PutObject"
+	S3DeleteObject = "s3:DeleteObject"
+	S3ListBucket   = "s3:ListBucket"
+	S3DeleteBucket = "s3:DeleteBucket"
+	// S3PutBucketPolicy guards changes to bucket settings such as access, quota and conflict mode
+	S3PutBucketPolicy = "s3:PutBucketPolicy"
+)
+
+// PolicyAllows reports whether an S3 policy document lets its holder perform action on bucket.
+// Object actions must be granted on every object of the bucket ("<bucket>/*" or "*/*") and bucket
+// actions on the bucket itself ("<bucket>" or "*"). A Deny statement on any part of the bucket the
+// action applies to withdraws it.
+func PolicyAllows(policy []byte, action, bucket string) (bool, error) {
+	var doc policyDocument
+	if err := json.Unmarshal(policy, &doc); err != nil {
+		return false, err
+	}
+
+	action = strings.ToLower(action)
+	objectLevel := strings.Contains(action, "object")
+
+	allowed := false
+	for _, stmt := range doc.Statement {
+		if !policyMatchesAny(stmt.Action, func(a string) bool { return actionCovers(a, action) }) {
+			continue
+		}
+		switch {
+		case strings.EqualFold(stmt.Effect, "Deny"):
+			if policyMatchesAny(stmt.Resource, func(resource string) bool { return resourceTouches(resource, bucket, objectLevel) }) {
+				return false, nil
+			}
+		case strings.EqualFold(stmt.Effect, "Allow"):
+			if policyMatchesAny(stmt.Resource, func(resource string) bool { return resourceCovers(resource, bucket, objectLevel) }) {
+				allowed = true
+			}
+		}
+	}
+	return allowed, nil
+}
+
+// policyMatchesAny reports whether match holds for the value, or any value of the list, in raw
+func policyMatchesAny(raw json.RawMessage, match func(string) bool) bool {
+	var values []string
+	if err := json.Unmarshal(raw, &values); err != nil {
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return false
+		}
+		values = []string{value}
+	}
+	for _, v := range values {
+		if match(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// actionCovers reports whether the policy action, possibly ending in a wildcard, covers action
+func actionCovers(pattern, action string) bool {
+	pattern = strings.ToLower(pattern)
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(action, prefix)
+	}
+	return pattern == action
+}
+
+// resourceCovers reports whether the resource grants every object of bucket, or the bucket itself
+// when objectLevel is false
+func resourceCovers(resource, bucket string, objectLevel bool) bool {
+	if resource == "*" {
+		return true
+	}
+	rest, ok := strings.CutPrefix(resource, s3ARNPrefix)
+	if !ok {
+		return false
+	}
+	if objectLevel {
+		return rest == "*/*" || rest == bucket+"/*"
+	}
+	return rest == "*" || rest == bucket
+}
+
+// resourceTouches reports whether the resource names any object of bucket, or the bucket itself
+// when objectLevel is false
+func resourceTouches(resource, bucket string, objectLevel bool) bool {
+	if resource == "*" {
+		return true
+	}
+	rest, ok := strings.CutPrefix(resource, s3ARNPrefix)
+	if !ok {
+		return false
+	}
+	name, _, hasObject := strings.Cut(rest, "/")
+	return hasObject == objectLevel && (name == "*" || name == bucket)
+}